@@ -523,10 +523,22 @@ func (h *APIKeyHandler) GetStats(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"overall":     stats,
 		"by_endpoint": byEndpoint,
-	})
+	}
+
+	if experiment := c.Query("experiment"); experiment != "" {
+		byArm, err := h.budgetService.GetUsageByExperimentArm(c.Context(), uint(id), experiment, startTime, endTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		response["by_experiment_arm"] = byArm
+	}
+
+	return c.JSON(response)
 }
 
 func (h *APIKeyHandler) ResetBudget(c *fiber.Ctx) error {
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response_cache"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// CacheAdminHandler inspects and manages the router and response caches
+type CacheAdminHandler struct {
+	routerCache   *model_router.ModelRouterCache
+	responseCache *response_cache.Service
+}
+
+// NewCacheAdminHandler initializes the cache admin handler. Either cache may be nil when disabled.
+func NewCacheAdminHandler(routerCache *model_router.ModelRouterCache, responseCache *response_cache.Service) *CacheAdminHandler {
+	return &CacheAdminHandler{
+		routerCache:   routerCache,
+		responseCache: responseCache,
+	}
+}
+
+// Stats returns entry counts, hit rates, similarity and lookup latency of every enabled cache
+func (h *CacheAdminHandler) Stats(c *fiber.Ctx) error {
+	resp := models.CacheStatsResponse{Caches: []models.CacheStats{}}
+
+	if h.routerCache != nil {
+		stats, err := h.routerCache.Stats(c.UserContext())
+		if err != nil {
+			return h.internalError(c, "Failed to read router cache statistics", err)
+		}
+		resp.Caches = append(resp.Caches, stats)
+	}
+	if h.responseCache != nil {
+		stats, err := h.responseCache.Stats(c.UserContext())
+		if err != nil {
+			return h.internalError(c, "Failed to read response cache statistics", err)
+		}
+		resp.Caches = append(resp.Caches, stats)
+	}
+
+	return c.JSON(resp)
+}
+
+// Search returns the router cache entries most similar to a prompt
+func (h *CacheAdminHandler) Search(c *fiber.Ctx) error {
+	var req models.CacheSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	resp, err := h.routerCache.Search(c.UserContext(), req)
+	if err != nil {
+		return h.cacheError(c, "Failed to search router cache", err)
+	}
+	return c.JSON(resp)
+}
+
+// Lookup reports what the router cache would return for a prompt, without serving it
+func (h *CacheAdminHandler) Lookup(c *fiber.Ctx) error {
+	var req models.CacheLookupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	resp, err := h.routerCache.DryRun(c.UserContext(), req)
+	if err != nil {
+		return h.cacheError(c, "Failed to look up router cache", err)
+	}
+	return c.JSON(resp)
+}
+
+// DeleteEntry removes the router cache entry named by the key query parameter
+func (h *CacheAdminHandler) DeleteEntry(c *fiber.Ctx) error {
+	deleted, err := h.routerCache.Delete(c.UserContext(), c.Query("key"))
+	if err != nil {
+		return h.cacheError(c, "Failed to delete router cache entry", err)
+	}
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Cache entry not found",
+		})
+	}
+	return c.JSON(models.CacheFlushResponse{Deleted: 1})
+}
+
+// Flush deletes router cache entries by namespace, provider and/or model, or all of them
+func (h *CacheAdminHandler) Flush(c *fiber.Ctx) error {
+	var req models.CacheFlushRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	deleted, err := h.routerCache.Flush(c.UserContext(), req)
+	if err != nil {
+		return h.cacheError(c, "Failed to flush router cache", err)
+	}
+	fiberlog.Infof("🧹 Flushed %d router cache entries (namespace=%q provider=%q model=%q all=%t)",
+		deleted, req.Namespace, req.Provider, req.Model, req.All)
+	return c.JSON(models.CacheFlushResponse{Deleted: deleted})
+}
+
+func (h *CacheAdminHandler) cacheError(c *fiber.Ctx, message string, err error) error {
+	switch {
+	case errors.Is(err, model_router.ErrInvalidCacheRequest):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, model_router.ErrCacheDisabled):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return h.internalError(c, message, err)
+}
+
+func (h *CacheAdminHandler) internalError(c *fiber.Ctx, message string, err error) error {
+	fiberlog.Errorf("%s: %v", message, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/Egham-7/adaptive-proxy/internal/services/format_adapter"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
 	"github.com/Egham-7/adaptive-proxy/internal/services/openai/chat/completions"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response_cache"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -33,6 +35,7 @@ type CompletionHandler struct {
 	completionSvc   *completions.CompletionService
 	modelRouter     *model_router.ModelRouter
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker
+	responseCache   *response_cache.Service
 }
 
 // NewCompletionHandler wires up dependencies and initializes the completion handler.
@@ -43,6 +46,7 @@ func NewCompletionHandler(
 	completionSvc *completions.CompletionService,
 	modelRouter *model_router.ModelRouter,
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker,
+	responseCache *response_cache.Service,
 ) *CompletionHandler {
 	return &CompletionHandler{
 		cfg:             cfg,
@@ -51,6 +55,7 @@ func NewCompletionHandler(
 		completionSvc:   completionSvc,
 		modelRouter:     modelRouter,
 		circuitBreakers: circuitBreakers,
+		responseCache:   responseCache,
 	}
 }
 
@@ -67,6 +72,10 @@ func (h *CompletionHandler) ChatCompletion(c *fiber.Ctx) error {
 		return h.respSvc.HandleBadRequest(c, err.Error(), reqID)
 	}
 
+	// Record routing decisions; they are returned as X-Adaptive-* headers and, on request, in the body
+	routing_trace.Start(c, req.IncludeRouting)
+	defer routing_trace.WriteHeaders(c)
+
 	// Get userID from request
 	userID := "anonymous"
 	if req.User.Value != "" {
@@ -80,18 +89,79 @@ func (h *CompletionHandler) ChatCompletion(c *fiber.Ctx) error {
 		return h.respSvc.HandleInternalError(c, fmt.Sprintf("failed to resolve config: %v", err), reqID)
 	}
 
+	// Apply routing_rules before model selection
+	if err := h.applyRoutingRules(c, req, resolvedConfig, reqID); err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.respSvc.HandleForbidden(c, err.Error(), reqID)
+		}
+		return h.respSvc.HandleInternalError(c, err.Error(), reqID)
+	}
+
+	// Serve deterministic requests from the response cache before any provider is called
+	cacheKey, cacheable := h.responseCache.OpenAIKey(c, req)
+	if cacheable {
+		if hit, found := h.responseCache.Lookup(c.UserContext(), cacheKey, reqID); found {
+			return h.responseCache.ServeOpenAI(c, hit, isStream, reqID)
+		}
+	}
+
 	resp, cacheSource, err := h.selectModel(
 		c.UserContext(), req, userID, reqID, h.circuitBreakers, resolvedConfig,
 	)
 	if err != nil {
 		// Check for invalid model specification error to return 400 instead of 500
-		if errors.Is(err, ErrInvalidModelSpec) {
+		if errors.Is(err, ErrInvalidModelSpec) || errors.Is(err, model_router.ErrContextTooLong) ||
+			errors.Is(err, model_router.ErrUnsupportedFeature) || errors.Is(err, model_router.ErrInvalidModelList) {
 			return h.respSvc.HandleBadRequest(c, err.Error(), reqID)
 		}
-		return h.respSvc.HandleInternalError(c, err.Error(), reqID)
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.respSvc.HandleForbidden(c, err.Error(), reqID)
+		}
+		return h.respSvc.HandleProviderError(c, err, reqID)
+	}
+
+	if err := h.completionSvc.HandleModel(c, req, resp, reqID, isStream, cacheSource, resolvedConfig); err != nil {
+		return err
+	}
+	if cacheable && !isStream {
+		h.responseCache.Store(c, cacheKey, reqID)
+	}
+	return nil
+}
+
+// applyRoutingRules matches routing_rules against the request and applies the matched rule:
+// pinning the model, restricting router candidates, or overriding cost bias and fallback mode.
+// The request is then assigned to an experiment arm when an experiment applies.
+func (h *CompletionHandler) applyRoutingRules(
+	c *fiber.Ctx,
+	req *models.ChatCompletionRequest,
+	resolvedConfig *config.Config,
+	requestID string,
+) error {
+	if format_adapter.AdaptiveToOpenAI == nil {
+		return fmt.Errorf("format_adapter.AdaptiveToOpenAI is not initialized")
+	}
+	openAIParams, err := format_adapter.AdaptiveToOpenAI.ConvertRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to convert request to OpenAI parameters: %w", err)
 	}
 
-	return h.completionSvc.HandleModel(c, req, resp, reqID, isStream, cacheSource, resolvedConfig)
+	rc := model_router.NewRoutingContext(c, "chat_completions")
+	rc.User = req.User.Value
+	rc.Metadata = req.Metadata
+	rc.HasTools = len(req.Tools) > 0
+	rc.Prompt, _ = utils.ExtractOpenAIPrompt(openAIParams.Messages, h.modelRouter.PromptExtraction())
+	rc.EstimatedTokens = utils.EstimateOpenAIRequestTokens(openAIParams.Messages, openAIParams.Tools)
+
+	rule, model, err := h.modelRouter.ApplyRoutingRules(c.UserContext(), rc, string(req.Model), resolvedConfig.ModelRouter, requestID)
+	if err != nil {
+		return err
+	}
+	c.SetUserContext(model_router.WithRule(c.UserContext(), rule))
+	c.SetUserContext(h.modelRouter.ScopeCache(c.UserContext(), rc, resolvedConfig.ModelRouter))
+	req.Model = shared.ChatModel(h.modelRouter.ApplyExperiment(c, rc, model, rule, requestID))
+	req.Fallback = rule.OverrideFallback(req.Fallback)
+	return nil
 }
 
 // selectModel runs model selection and returns the chosen model response and cache source.
@@ -108,6 +178,29 @@ func (h *CompletionHandler) selectModel(
 ) {
 	fiberlog.Infof("[%s] Starting model selection for user: %s", requestID, userID)
 
+	// Explicit fallback lists run across exactly the listed models
+	if entries, ok := utils.SplitModelList(string(req.Model)); ok {
+		listResp, err := h.modelRouter.ResolveModelList(ctx, entries, resolvedConfig.ModelRouter, circuitBreakers, requestID)
+		if err != nil {
+			return nil, "", err
+		}
+		req.Fallback = resolvedConfig.ModelListFallback(req.Fallback)
+		return listResp, "", nil
+	}
+
+	// Resolve virtual model aliases into their ordered provider chain
+	if req.Model != "" {
+		aliasResp, alias, err := h.modelRouter.ResolveAlias(string(req.Model), resolvedConfig.ModelRouter, requestID)
+		if err != nil {
+			return nil, "", err
+		}
+		if aliasResp != nil {
+			routing_trace.FromContext(ctx).SetAlias(string(req.Model))
+			req.Fallback = alias.ApplyFallback(req.Fallback)
+			return aliasResp, "", nil
+		}
+	}
+
 	// Check if model is explicitly provided (non-empty) - if so, try manual override
 	if req.Model != "" {
 		fiberlog.Infof("[%s] Model explicitly provided (%s), attempting manual override", requestID, req.Model)
@@ -137,7 +230,7 @@ func (h *CompletionHandler) selectModel(
 	}
 
 	// Extract prompt from messages
-	prompt, err := utils.ExtractLastMessage(openAIParams.Messages)
+	prompt, err := utils.ExtractOpenAIPrompt(openAIParams.Messages, h.modelRouter.PromptExtraction())
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to extract prompt: %w", err)
 	}
@@ -145,10 +238,18 @@ func (h *CompletionHandler) selectModel(
 	// Extract tool calls from the last message
 	toolCall := utils.ExtractToolCallsFromLastMessage(openAIParams.Messages)
 
+	// Estimate the request size so models with too small a context window are skipped
+	maxOutputTokens := req.MaxCompletionTokens.Value
+	if maxOutputTokens == 0 {
+		maxOutputTokens = req.MaxTokens.Value
+	}
+	profile := utils.OpenAIRequestProfile(openAIParams.Messages, openAIParams.Tools, int(maxOutputTokens))
+	profile.Features = utils.OpenAIRequestFeatures(req)
+
 	resp, cacheSource, err = h.modelRouter.SelectModelWithCache(
 		ctx,
 		prompt, userID, requestID, resolvedConfig.ModelRouter, circuitBreakers,
-		req.Tools, toolCall,
+		req.Tools, toolCall, profile,
 	)
 	if err != nil {
 		fiberlog.Errorf("[%s] Model selection error: %v", requestID, err)
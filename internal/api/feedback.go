@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/feedback"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// FeedbackHandler accepts ratings of previously served requests
+type FeedbackHandler struct {
+	feedbackSvc *feedback.Service
+}
+
+// NewFeedbackHandler initializes the feedback handler
+func NewFeedbackHandler(feedbackSvc *feedback.Service) *FeedbackHandler {
+	return &FeedbackHandler{
+		feedbackSvc: feedbackSvc,
+	}
+}
+
+// Feedback records a rating, thumbs up/down or task outcome for a request ID.
+// Callers authenticated with an API key can only rate the requests made with that key, users
+// those made with API keys of their own or of their organizations.
+func (h *FeedbackHandler) Feedback(c *fiber.Ctx) error {
+	var req models.FeedbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	var caller feedback.Caller
+	if apiKey, ok := auth.GetAPIKey(c); ok {
+		caller.APIKeyID = &apiKey.ID
+	} else if userID, ok := auth.GetUserID(c); ok {
+		caller.UserID = userID
+	}
+
+	result, err := h.feedbackSvc.Submit(c.UserContext(), req, caller)
+	if err != nil {
+		switch {
+		case errors.Is(err, feedback.ErrInvalidFeedback):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, feedback.ErrRequestNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No usage recorded for this request_id",
+			})
+		}
+		fiberlog.Errorf("[%s] Failed to record feedback: %v", req.RequestID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record feedback",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
 	"github.com/Egham-7/adaptive-proxy/internal/services/gemini/count_tokens"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -72,32 +73,22 @@ func (h *CountTokensHandler) CountTokens(c *fiber.Ctx) error {
 
 	// Validate circuit breaker
 	if err := h.checkCircuitBreaker(provider, requestID); err != nil {
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-			"error": fiber.Map{
-				"message": "Service temporarily unavailable",
-				"type":    "service_unavailable",
-			},
-		})
+		return response.SendError(c, response.FormatGemini, response.ClassifyError(err), requestID)
 	}
 
 	// Execute count tokens request
-	response, err := h.countTokensSvc.HandleGeminiCountTokensProvider(c, req.Contents, model, providerConfig, requestID)
+	countResp, err := h.countTokensSvc.HandleGeminiCountTokensProvider(c, req.Contents, model, providerConfig, requestID)
 	if err != nil {
 		fiberlog.Errorf("[%s] Count tokens request failed: %v", requestID, err)
 		h.recordCircuitBreakerFailure(provider)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fiber.Map{
-				"message": "Count tokens failed",
-				"type":    "server_error",
-			},
-		})
+		return response.SendError(c, response.FormatGemini, response.ClassifyError(err), requestID)
 	}
 
 	// Record success
 	h.recordCircuitBreakerSuccess(provider)
 
 	// Send response
-	if err := h.responseSvc.SendNonStreamingResponse(c, response, requestID); err != nil {
+	if err := h.responseSvc.SendNonStreamingResponse(c, countResp, requestID); err != nil {
 		fiberlog.Errorf("[%s] Failed to send response: %v", requestID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": fiber.Map{
@@ -144,7 +135,7 @@ func (h *CountTokensHandler) selectModelViaRouter(
 	fiberlog.Infof("[%s] Using model router for intelligent selection", requestID)
 
 	// Extract prompt from contents
-	prompt, err := utils.ExtractPromptFromGeminiContents(req.Contents)
+	prompt, err := utils.ExtractGeminiPrompt(nil, req.Contents, h.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Warnf("[%s] Failed to extract prompt: %v", requestID, err)
 		return "", "", c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -168,6 +159,10 @@ func (h *CountTokensHandler) selectModelViaRouter(
 		})
 	}
 
+	// Count tokens has no routing rules, but cache entries stay scoped to the caller
+	rc := model_router.NewRoutingContext(c, "count_tokens")
+	c.SetUserContext(h.modelRouter.ScopeCache(c.UserContext(), rc, resolvedConfig.ModelRouter))
+
 	// Call model router
 	toolCall := utils.ExtractToolCallsFromGeminiContents(req.Contents)
 	routingDecision, _, err := h.modelRouter.SelectModelWithCache(
@@ -179,6 +174,7 @@ func (h *CountTokensHandler) selectModelViaRouter(
 		h.circuitBreakers,
 		nil, // tools
 		toolCall,
+		nil, // token counting has no output, so context filtering does not apply
 	)
 	if err != nil {
 		fiberlog.Errorf("[%s] Model router failed: %v", requestID, err)
@@ -229,7 +225,7 @@ func (h *CountTokensHandler) checkCircuitBreaker(provider, requestID string) err
 	cb := h.circuitBreakers[provider]
 	if cb != nil && !cb.CanExecute() {
 		fiberlog.Warnf("[%s] Circuit breaker open for %s", requestID, provider)
-		return fmt.Errorf("circuit breaker open")
+		return fmt.Errorf("%w for provider %s", circuitbreaker.ErrOpen, provider)
 	}
 	return nil
 }
@@ -2,19 +2,32 @@ package gemini
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"slices"
+	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/anthropic/messages"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
 	"github.com/Egham-7/adaptive-proxy/internal/services/fallback"
 	"github.com/Egham-7/adaptive-proxy/internal/services/gemini/generate"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/protocols"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response_cache"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
+	"google.golang.org/genai"
 )
 
 // GenerateHandler handles Gemini GenerateContent API requests using dedicated Gemini services
@@ -22,12 +35,16 @@ type GenerateHandler struct {
 	cfg             *config.Config
 	requestSvc      *generate.RequestService
 	generateSvc     *generate.GenerateService
+	gateway         *protocols.Gateway
 	responseSvc     *generate.ResponseService
 	modelRouter     *model_router.ModelRouter
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker
 	fallbackService *fallback.FallbackService
 	usageService    *usage.Service
 	usageWorker     *usage.Worker
+	statsTracker    *provider_stats.Tracker
+	shadowSvc       *shadow.Service
+	responseCache   *response_cache.Service
 }
 
 // NewGenerateHandler creates a new GenerateHandler with Gemini-specific services
@@ -37,17 +54,25 @@ func NewGenerateHandler(
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker,
 	usageService *usage.Service,
 	usageWorker *usage.Worker,
+	statsTracker *provider_stats.Tracker,
+	shadowSvc *shadow.Service,
+	responseCache *response_cache.Service,
 ) *GenerateHandler {
+	generateSvc := generate.NewGenerateService()
 	return &GenerateHandler{
 		cfg:             cfg,
 		requestSvc:      generate.NewRequestService(),
-		generateSvc:     generate.NewGenerateService(),
+		generateSvc:     generateSvc,
+		gateway:         protocols.NewGateway(messages.NewMessagesService(), generateSvc),
 		responseSvc:     generate.NewResponseService(modelRouter, usageService, usageWorker),
 		modelRouter:     modelRouter,
 		circuitBreakers: circuitBreakers,
-		fallbackService: fallback.NewFallbackService(cfg),
+		fallbackService: fallback.NewFallbackService(cfg, "generate", statsTracker),
 		usageService:    usageService,
 		usageWorker:     usageWorker,
+		statsTracker:    statsTracker,
+		shadowSvc:       shadowSvc,
+		responseCache:   responseCache,
 	}
 }
 
@@ -61,7 +86,7 @@ func (h *GenerateHandler) Generate(c *fiber.Ctx) error {
 	req, err := h.requestSvc.ParseRequest(c)
 	if err != nil {
 		fiberlog.Warnf("[%s] Request parsing failed: %v", requestID, err)
-		return h.responseSvc.HandleError(c, fmt.Errorf("invalid request: %w", err), requestID)
+		return h.responseSvc.HandleBadRequest(c, "invalid request: "+err.Error(), requestID)
 	}
 
 	// Extract model from route parameter if present (for Gemini SDK compatibility)
@@ -70,8 +95,16 @@ func (h *GenerateHandler) Generate(c *fiber.Ctx) error {
 		req.Model = routeModel
 	}
 
+	// An OpenRouter-style models array is handled as a comma-separated model list
+	req.Model = utils.JoinModelList(req.Model, req.Models)
+	req.Models = nil
+
 	fiberlog.Debugf("[%s] Request parsed successfully - model: %s", requestID, req.Model)
 
+	// Record routing decisions; they are returned as X-Adaptive-* headers
+	routing_trace.Start(c, false)
+	defer routing_trace.WriteHeaders(c)
+
 	// Resolve configuration
 	resolvedConfig, err := h.cfg.ResolveConfigFromGeminiRequest(req)
 	if err != nil {
@@ -79,6 +112,55 @@ func (h *GenerateHandler) Generate(c *fiber.Ctx) error {
 		return h.responseSvc.HandleError(c, fmt.Errorf("failed to resolve config: %w", err), requestID)
 	}
 
+	// Mirror the request to the endpoint's shadow model once the response has been served
+	defer h.mirrorShadow(c, req, resolvedConfig, false, time.Now(), requestID)
+
+	// Apply routing_rules before direct routing or model selection
+	if err := h.applyRoutingRules(c, req, resolvedConfig, requestID); err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+		}
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+
+	// Serve deterministic requests from the response cache before any provider is called
+	if cacheKey, ok := h.responseCache.GeminiKey(c, req); ok {
+		if hit, found := h.responseCache.Lookup(c.UserContext(), cacheKey, requestID); found {
+			return h.responseCache.ServeGemini(c, hit, false, requestID)
+		}
+		defer h.responseCache.Store(c, cacheKey, requestID)
+	}
+
+	// Explicit fallback lists run across exactly the listed models
+	if entries, ok := utils.SplitModelList(req.Model); ok {
+		listResp, err := h.modelRouter.ResolveModelList(c.UserContext(), entries, resolvedConfig.ModelRouter, h.circuitBreakers, requestID)
+		if err != nil {
+			switch {
+			case errors.Is(err, model_router.ErrModelNotAllowed):
+				return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+			case errors.Is(err, model_router.ErrInvalidModelList):
+				return h.responseSvc.HandleBadRequest(c, err.Error(), requestID)
+			}
+			return h.responseSvc.HandleError(c, err, requestID)
+		}
+		req.Fallback = resolvedConfig.ModelListFallback(req.Fallback)
+		return h.executeWithFallback(c, req, listResp, false, "", requestID)
+	}
+
+	// Resolve virtual model aliases into their ordered provider chain
+	aliasResp, alias, err := h.modelRouter.ResolveAlias(req.Model, resolvedConfig.ModelRouter, requestID)
+	if err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+		}
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+	if aliasResp != nil {
+		routing_trace.From(c).SetAlias(req.Model)
+		req.Fallback = alias.ApplyFallback(req.Fallback)
+		return h.executeWithFallback(c, req, aliasResp, false, "", requestID)
+	}
+
 	// If a model is specified, try to directly route to the appropriate provider
 	if req.Model != "" {
 		fiberlog.Debugf("[%s] Model specified: %s, attempting direct routing", requestID, req.Model)
@@ -98,7 +180,7 @@ func (h *GenerateHandler) Generate(c *fiber.Ctx) error {
 			providers := resolvedConfig.GetProviders("generate")
 			providerConfig, exists := providers[provider]
 			if !exists {
-				return h.responseSvc.HandleError(c, fmt.Errorf(fmt.Sprintf("provider %s not configured", provider), nil), requestID)
+				return h.responseSvc.HandleBadRequest(c, fmt.Sprintf("provider %s not configured", provider), requestID)
 			}
 
 			// Direct execution - no fallback for user-specified models
@@ -122,10 +204,10 @@ func (h *GenerateHandler) Generate(c *fiber.Ctx) error {
 	fiberlog.Debugf("[%s] No model specified, using model router for selection with fallback", requestID)
 
 	// Extract prompt for routing
-	prompt, err := utils.ExtractPromptFromGeminiContents(req.Contents)
+	prompt, err := utils.ExtractGeminiPrompt(req.SystemInstruction, req.Contents, h.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Warnf("[%s] Failed to extract prompt for routing: %v", requestID, err)
-		return h.responseSvc.HandleError(c, fmt.Errorf("failed to extract prompt for routing: "+err.Error(), err), requestID)
+		return h.responseSvc.HandleBadRequest(c, "failed to extract prompt for routing: "+err.Error(), requestID)
 	}
 
 	// Use model router to select best model WITH CIRCUIT BREAKERS
@@ -135,46 +217,20 @@ func (h *GenerateHandler) Generate(c *fiber.Ctx) error {
 	modelResp, cacheSource, err := h.modelRouter.SelectModelWithCache(
 		c.UserContext(),
 		prompt, userID, requestID, resolvedConfig.ModelRouter, h.circuitBreakers,
-		req.Tools, toolCall,
+		req.Tools, toolCall, requestProfile(req),
 	)
 	if err != nil {
 		fiberlog.Errorf("[%s] Model router selection failed: %v", requestID, err)
+		if errors.Is(err, model_router.ErrContextTooLong) || errors.Is(err, model_router.ErrUnsupportedFeature) {
+			return h.responseSvc.HandleBadRequest(c, err.Error(), requestID)
+		}
 		return h.responseSvc.HandleError(c, err, requestID)
 	}
 
-	// Update request with selected model
-	req.Model = modelResp.Model
 	fiberlog.Infof("[%s] Model router selected - provider: %s, model: %s (with %d alternatives)",
 		requestID, modelResp.Provider, modelResp.Model, len(modelResp.Alternatives))
 
-	// Try primary provider first
-	primary := models.Alternative{
-		Provider: modelResp.Provider,
-		Model:    modelResp.Model,
-	}
-	executeFunc := h.createExecuteFunc(req, false, cacheSource)
-
-	fiberlog.Infof("[%s] Trying primary provider: %s/%s", requestID, primary.Provider, primary.Model)
-	err = executeFunc(c, primary, requestID)
-
-	if err == nil {
-		// Primary succeeded
-		fiberlog.Infof("[%s] ✅ Primary provider succeeded: %s/%s", requestID, primary.Provider, primary.Model)
-		return nil
-	}
-
-	// Primary failed - check if we have alternatives
-	if len(modelResp.Alternatives) == 0 {
-		fiberlog.Errorf("[%s] ❌ Primary provider failed and no alternatives available: %v", requestID, err)
-		return err
-	}
-
-	// Use fallback service with alternatives only
-	fiberlog.Warnf("[%s] ⚠️  Primary provider failed: %v", requestID, err)
-	fiberlog.Infof("[%s] Using fallback with %d alternatives", requestID, len(modelResp.Alternatives))
-
-	fallbackConfig := h.fallbackService.GetFallbackConfig(req.Fallback)
-	return h.fallbackService.Execute(c, modelResp.Alternatives, fallbackConfig, executeFunc, requestID, false)
+	return h.executeWithFallback(c, req, modelResp, false, cacheSource, requestID)
 }
 
 // StreamGenerate handles the Gemini GenerateContent API HTTP request (streaming)
@@ -187,7 +243,7 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 	req, err := h.requestSvc.ParseRequest(c)
 	if err != nil {
 		fiberlog.Warnf("[%s] Request parsing failed: %v", requestID, err)
-		return h.responseSvc.HandleError(c, fmt.Errorf("invalid request: %w", err), requestID)
+		return h.responseSvc.HandleBadRequest(c, "invalid request: "+err.Error(), requestID)
 	}
 
 	// Extract model from route parameter if present (for Gemini SDK compatibility)
@@ -196,8 +252,16 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 		req.Model = routeModel
 	}
 
+	// An OpenRouter-style models array is handled as a comma-separated model list
+	req.Model = utils.JoinModelList(req.Model, req.Models)
+	req.Models = nil
+
 	fiberlog.Debugf("[%s] Request parsed successfully - model: %s", requestID, req.Model)
 
+	// Record routing decisions; they are returned as X-Adaptive-* headers
+	routing_trace.Start(c, false)
+	defer routing_trace.WriteHeaders(c)
+
 	// Resolve configuration
 	resolvedConfig, err := h.cfg.ResolveConfigFromGeminiRequest(req)
 	if err != nil {
@@ -205,6 +269,54 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 		return h.responseSvc.HandleError(c, fmt.Errorf("failed to resolve config: %w", err), requestID)
 	}
 
+	// Mirror the request to the endpoint's shadow model once the response has been served
+	defer h.mirrorShadow(c, req, resolvedConfig, true, time.Now(), requestID)
+
+	// Apply routing_rules before direct routing or model selection
+	if err := h.applyRoutingRules(c, req, resolvedConfig, requestID); err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+		}
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+
+	// Serve deterministic requests from the response cache; streamed responses are not stored
+	if cacheKey, ok := h.responseCache.GeminiKey(c, req); ok {
+		if hit, found := h.responseCache.Lookup(c.UserContext(), cacheKey, requestID); found {
+			return h.responseCache.ServeGemini(c, hit, true, requestID)
+		}
+	}
+
+	// Explicit fallback lists run across exactly the listed models
+	if entries, ok := utils.SplitModelList(req.Model); ok {
+		listResp, err := h.modelRouter.ResolveModelList(c.UserContext(), entries, resolvedConfig.ModelRouter, h.circuitBreakers, requestID)
+		if err != nil {
+			switch {
+			case errors.Is(err, model_router.ErrModelNotAllowed):
+				return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+			case errors.Is(err, model_router.ErrInvalidModelList):
+				return h.responseSvc.HandleBadRequest(c, err.Error(), requestID)
+			}
+			return h.responseSvc.HandleError(c, err, requestID)
+		}
+		req.Fallback = resolvedConfig.ModelListFallback(req.Fallback)
+		return h.executeWithFallback(c, req, listResp, true, "", requestID)
+	}
+
+	// Resolve virtual model aliases into their ordered provider chain
+	aliasResp, alias, err := h.modelRouter.ResolveAlias(req.Model, resolvedConfig.ModelRouter, requestID)
+	if err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+		}
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+	if aliasResp != nil {
+		routing_trace.From(c).SetAlias(req.Model)
+		req.Fallback = alias.ApplyFallback(req.Fallback)
+		return h.executeWithFallback(c, req, aliasResp, true, "", requestID)
+	}
+
 	// If a model is specified, try to directly route to the appropriate provider
 	if req.Model != "" {
 		fiberlog.Debugf("[%s] Model specified: %s, attempting direct routing", requestID, req.Model)
@@ -224,7 +336,7 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 			providers := resolvedConfig.GetProviders("generate")
 			providerConfig, exists := providers[provider]
 			if !exists {
-				return h.responseSvc.HandleError(c, fmt.Errorf(fmt.Sprintf("provider %s not configured", provider), nil), requestID)
+				return h.responseSvc.HandleBadRequest(c, fmt.Sprintf("provider %s not configured", provider), requestID)
 			}
 
 			// Direct execution - no fallback for user-specified models
@@ -248,10 +360,10 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 	fiberlog.Debugf("[%s] No model specified, using model router for selection with fallback", requestID)
 
 	// Extract prompt for routing
-	prompt, err := utils.ExtractPromptFromGeminiContents(req.Contents)
+	prompt, err := utils.ExtractGeminiPrompt(req.SystemInstruction, req.Contents, h.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Warnf("[%s] Failed to extract prompt for routing: %v", requestID, err)
-		return h.responseSvc.HandleError(c, fmt.Errorf("failed to extract prompt for routing: "+err.Error(), err), requestID)
+		return h.responseSvc.HandleBadRequest(c, "failed to extract prompt for routing: "+err.Error(), requestID)
 	}
 
 	// Use model router to select best model WITH CIRCUIT BREAKERS
@@ -261,27 +373,82 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 	modelResp, cacheSource, err := h.modelRouter.SelectModelWithCache(
 		c.UserContext(),
 		prompt, userID, requestID, resolvedConfig.ModelRouter, h.circuitBreakers,
-		req.Tools, toolCall,
+		req.Tools, toolCall, requestProfile(req),
 	)
 	if err != nil {
 		fiberlog.Errorf("[%s] Model router selection failed: %v", requestID, err)
+		if errors.Is(err, model_router.ErrContextTooLong) || errors.Is(err, model_router.ErrUnsupportedFeature) {
+			return h.responseSvc.HandleBadRequest(c, err.Error(), requestID)
+		}
 		return h.responseSvc.HandleError(c, err, requestID)
 	}
 
-	// Update request with selected model
-	req.Model = modelResp.Model
 	fiberlog.Infof("[%s] Model router selected - provider: %s, model: %s (with %d alternatives)",
 		requestID, modelResp.Provider, modelResp.Model, len(modelResp.Alternatives))
 
+	return h.executeWithFallback(c, req, modelResp, true, cacheSource, requestID)
+}
+
+// executeWithFallback runs the request through the fallback service and sends the final
+// failure to the client
+func (h *GenerateHandler) executeWithFallback(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	modelResp *models.ModelSelectionResponse,
+	isStreaming bool,
+	cacheSource string,
+	requestID string,
+) error {
+	if err := h.runWithFallback(c, req, modelResp, isStreaming, cacheSource, requestID); err != nil {
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+	return nil
+}
+
+// runWithFallback tries the selected primary model, then its alternatives through the fallback service
+func (h *GenerateHandler) runWithFallback(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	modelResp *models.ModelSelectionResponse,
+	isStreaming bool,
+	cacheSource string,
+	requestID string,
+) error {
+	// Update request with selected model
+	req.Model = modelResp.Model
+
 	// Try primary provider first
 	primary := models.Alternative{
 		Provider: modelResp.Provider,
 		Model:    modelResp.Model,
 	}
-	executeFunc := h.createExecuteFunc(req, true, cacheSource)
+	fallbackConfig := h.fallbackService.GetFallbackConfig(req.Fallback)
+	executeFunc := h.fallbackService.WithRetries(h.createExecuteFunc(req, isStreaming, cacheSource), fallbackConfig)
+
+	// Streaming races and hedges, and hedged requests, run the primary alongside its alternatives
+	if len(modelResp.Alternatives) > 0 {
+		candidates := append([]models.Alternative{primary}, modelResp.Alternatives...)
+		if isStreaming && fallbackConfig.MidStreamRecovery {
+			continueFunc, err := h.createStreamContinueFunc(c, req, cacheSource)
+			if err != nil {
+				return err
+			}
+			h.fallbackService.EnableStreamResume(c, candidates, fallbackConfig, continueFunc, requestID)
+		}
+		switch {
+		case isStreaming && (fallbackConfig.Mode == models.FallbackModeRace || fallbackConfig.Mode == models.FallbackModeHedge):
+			openFunc, err := h.createStreamOpenFunc(c, req, cacheSource)
+			if err != nil {
+				return err
+			}
+			return h.fallbackService.RaceStreams(c, candidates, fallbackConfig, openFunc, requestID)
+		case fallbackConfig.Mode == models.FallbackModeHedge:
+			return h.fallbackService.Execute(c, candidates, fallbackConfig, executeFunc, requestID, isStreaming)
+		}
+	}
 
 	fiberlog.Infof("[%s] Trying primary provider: %s/%s", requestID, primary.Provider, primary.Model)
-	err = executeFunc(c, primary, requestID)
+	err := executeFunc(c, primary, requestID)
 
 	if err == nil {
 		// Primary succeeded
@@ -294,13 +461,17 @@ func (h *GenerateHandler) StreamGenerate(c *fiber.Ctx) error {
 		fiberlog.Errorf("[%s] ❌ Primary provider failed and no alternatives available: %v", requestID, err)
 		return err
 	}
+	if !fallback.ShouldFallback(c.UserContext(), err) {
+		fiberlog.Errorf("[%s] ❌ Primary provider failed with a %s error, not trying alternatives: %v",
+			requestID, fallback.ClassifyAttempt(c.UserContext(), err), err)
+		return err
+	}
 
 	// Use fallback service with alternatives only
 	fiberlog.Warnf("[%s] ⚠️  Primary provider failed: %v", requestID, err)
 	fiberlog.Infof("[%s] Using fallback with %d alternatives", requestID, len(modelResp.Alternatives))
 
-	fallbackConfig := h.fallbackService.GetFallbackConfig(req.Fallback)
-	return h.fallbackService.Execute(c, modelResp.Alternatives, fallbackConfig, executeFunc, requestID, true)
+	return h.fallbackService.Execute(c, modelResp.Alternatives, fallbackConfig, executeFunc, requestID, isStreaming)
 }
 
 // checkCircuitBreaker validates circuit breaker state for the provider
@@ -308,7 +479,7 @@ func (h *GenerateHandler) checkCircuitBreaker(provider, requestID string) error
 	cb := h.circuitBreakers[provider]
 	if cb != nil && !cb.CanExecute() {
 		fiberlog.Warnf("[%s] Circuit breaker is open for provider %s", requestID, provider)
-		return fmt.Errorf("circuit breaker open")
+		return fmt.Errorf("%w for provider %s", circuitbreaker.ErrOpen, provider)
 	}
 	return nil
 }
@@ -322,12 +493,13 @@ func (h *GenerateHandler) executeWithCircuitBreaker(
 	isStreaming bool,
 	requestID string,
 	cacheSource string,
+	observation *provider_stats.Observation,
 ) error {
 	cb := h.circuitBreakers[provider]
 
 	// Execute the request with concrete types
 	if isStreaming {
-		return h.executeStreamingWithCircuitBreaker(c, req, provider, providerConfig, requestID, cb, cacheSource)
+		return h.executeStreamingWithCircuitBreaker(c, req, provider, providerConfig, requestID, cb, cacheSource, observation)
 	}
 	return h.executeNonStreamingWithCircuitBreaker(c, req, provider, providerConfig, requestID, cb, cacheSource)
 }
@@ -343,10 +515,14 @@ func (h *GenerateHandler) executeNonStreamingWithCircuitBreaker(
 	cacheSource string,
 ) error {
 	// Execute the non-streaming request
-	response, err := h.generateSvc.HandleGeminiNonStreamingProvider(c, req, providerConfig, requestID)
+	client, err := h.gateway.Generate(c.Context(), providerConfig)
+	var response *genai.GenerateContentResponse
+	if err == nil {
+		response, err = h.generateSvc.HandleNonStreamingProvider(c, req, client, requestID)
+	}
 	if err != nil {
 		// Record failure in circuit breaker
-		if cb != nil {
+		if cb != nil && !errors.Is(err, context.Canceled) {
 			cb.RecordFailure()
 			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (non-streaming)", requestID, provider)
 		}
@@ -358,7 +534,7 @@ func (h *GenerateHandler) executeNonStreamingWithCircuitBreaker(
 	err = h.responseSvc.HandleNonStreamingResponse(c, response, requestID, provider, req.Model, cacheSource)
 	if err != nil {
 		// Record failure in circuit breaker
-		if cb != nil {
+		if cb != nil && !errors.Is(err, context.Canceled) {
 			cb.RecordFailure()
 			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (response handling)", requestID, provider)
 		}
@@ -385,29 +561,35 @@ func (h *GenerateHandler) executeStreamingWithCircuitBreaker(
 	requestID string,
 	cb *circuitbreaker.CircuitBreaker,
 	cacheSource string,
+	observation *provider_stats.Observation,
 ) error {
-	// Execute the streaming request
-	streamIter, err := h.generateSvc.HandleGeminiStreamingProvider(c, req, providerConfig, requestID)
+	// Execute the streaming request; the client outlives c.Context(), which is cancelled once
+	// headers are sent
+	client, err := h.gateway.Generate(context.Background(), providerConfig)
+	var streamIter iter.Seq2[*genai.GenerateContentResponse, error]
+	if err == nil {
+		streamIter, err = h.generateSvc.HandleStreamingProvider(c, req, client, requestID)
+	}
 	if err != nil {
 		// Record failure in circuit breaker
-		if cb != nil {
+		if cb != nil && !errors.Is(err, context.Canceled) {
 			cb.RecordFailure()
 			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (streaming)", requestID, provider)
 		}
 		fiberlog.Errorf("[%s] Streaming provider request failed: %v", requestID, err)
-		return h.responseSvc.HandleError(c, err, requestID)
+		return err
 	}
 
 	// Handle the streaming response with proper cache source
-	err = h.responseSvc.HandleStreamingResponse(c, streamIter, requestID, provider, cacheSource, req.Model, "/v1/models/"+req.Model+":streamGenerateContent")
+	err = h.responseSvc.HandleStreamingResponse(c, streamIter, requestID, provider, cacheSource, req.Model, "/v1/models/"+req.Model+":streamGenerateContent", observation)
 	if err != nil {
 		// Record failure in circuit breaker
-		if cb != nil {
+		if cb != nil && !errors.Is(err, context.Canceled) {
 			cb.RecordFailure()
 			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (streaming)", requestID, provider)
 		}
 		fiberlog.Errorf("[%s] Streaming response handling failed: %v", requestID, err)
-		return h.responseSvc.HandleError(c, err, requestID)
+		return err
 	}
 
 	// Record success in circuit breaker
@@ -420,6 +602,116 @@ func (h *GenerateHandler) executeStreamingWithCircuitBreaker(
 	return nil
 }
 
+// createStreamOpenFunc creates the stream opener for streaming races
+func (h *GenerateHandler) createStreamOpenFunc(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	cacheSource string,
+) (models.StreamOpenFunc, error) {
+	open, err := h.streamOpener(c, req, cacheSource)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, provider models.Alternative, reqID string) (models.PendingStream, error) {
+		return open(ctx, req, provider, reqID)
+	}, nil
+}
+
+// createStreamContinueFunc creates the opener of continuations for mid-stream recovery. The
+// partial reply is followed by a request to continue it.
+func (h *GenerateHandler) createStreamContinueFunc(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	cacheSource string,
+) (models.StreamContinueFunc, error) {
+	open, err := h.streamOpener(c, req, cacheSource)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, provider models.Alternative, partial, reqID string) (models.PendingStream, error) {
+		continuation := *req
+		continuation.Contents = append(slices.Clip(req.Contents),
+			genai.NewContentFromText(partial, genai.RoleModel),
+			genai.NewContentFromText(models.MidStreamContinuePrompt, genai.RoleUser),
+		)
+		return open(ctx, &continuation, provider, reqID)
+	}, nil
+}
+
+// streamOpener returns a function opening a provider's stream without touching the response,
+// using the providers configured for req. Everything it needs from c is read here, since
+// streams are opened concurrently, or after the handler returned, and must not touch c.
+func (h *GenerateHandler) streamOpener(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	cacheSource string,
+) (func(ctx context.Context, streamReq *models.GeminiGenerateRequest, provider models.Alternative, reqID string) (models.PendingStream, error), error) {
+	resolvedConfig, err := h.cfg.ResolveConfigFromGeminiRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config: %w", err)
+	}
+	providers := resolvedConfig.GetProviders("generate")
+	apiKey, _ := auth.GetAPIKey(c)
+	usageMetadata := usage.UsageMetadata(c)
+	trace := routing_trace.From(c)
+
+	return func(ctx context.Context, streamReq *models.GeminiGenerateRequest, provider models.Alternative, reqID string) (models.PendingStream, error) {
+		providerConfig, exists := providers[provider.Provider]
+		if !exists {
+			return nil, fmt.Errorf("provider %s not configured", provider.Provider)
+		}
+		if err := h.checkCircuitBreaker(provider.Provider, reqID); err != nil {
+			trace.Filter(provider.Provider, provider.Model, models.FilterReasonCircuitBreaker)
+			return nil, err
+		}
+		cb := h.circuitBreakers[provider.Provider]
+
+		reqCopy := *streamReq
+		reqCopy.Model = provider.Model
+
+		// ctx is cancelled when the contender loses, which aborts the upstream request
+		start := time.Now()
+		attempt := usage.Attempt{Metadata: usageMetadata, Start: start}
+		endpoint := "/v1/models/" + provider.Model + ":streamGenerateContent"
+		observation := h.statsTracker.Start(provider.Provider, provider.Model)
+		client, err := h.gateway.Generate(context.Background(), providerConfig)
+		var pending *handlers.PendingStream
+		if err == nil {
+			var streamIter iter.Seq2[*genai.GenerateContentResponse, error]
+			streamIter, err = client.SendStreamingRequest(ctx, &reqCopy, reqID)
+			if err == nil {
+				pending, err = h.responseSvc.PrepareStreamingResponse(streamIter, reqID, provider.Provider, cacheSource, provider.Model,
+					endpoint, apiKey, attempt, observation)
+			}
+		}
+		if err != nil {
+			observation.Finish(err)
+			if !errors.Is(err, context.Canceled) {
+				trace.Failed(provider.Provider, provider.Model, err, time.Since(start))
+				if h.usageWorker != nil && apiKey != nil {
+					h.usageWorker.Submit(usage.FailedAttempt(apiKey, attempt, endpoint, provider, fallback.StatusCode(err), err, reqID), reqID)
+				}
+				if cb != nil {
+					cb.RecordFailure()
+				}
+			}
+			return nil, err
+		}
+		if cb != nil {
+			cb.RecordSuccess()
+		}
+
+		return fallback.NewPendingStream(pending, observation, func(c *fiber.Ctx) {
+			routing_trace.From(c).Select(provider.Provider, provider.Model)
+			shadow.SetPrimary(c, models.ShadowResult{Provider: provider.Provider, Model: provider.Model})
+			h.storeSuccessfulSemanticCache(c.UserContext(), &reqCopy, &models.ModelSelectionResponse{
+				Provider: provider.Provider,
+				Model:    provider.Model,
+			}, reqID)
+		}), nil
+	}, nil
+}
+
 // createExecuteFunc creates an execution function for the fallback service
 func (h *GenerateHandler) createExecuteFunc(
 	req *models.GeminiGenerateRequest,
@@ -472,14 +764,95 @@ func (h *GenerateHandler) executeProviderRequest(
 ) error {
 	// Check circuit breaker state
 	if err := h.checkCircuitBreaker(provider, requestID); err != nil {
+		routing_trace.From(c).Filter(provider, req.Model, models.FilterReasonCircuitBreaker)
 		return err
 	}
 
-	// Execute request with circuit breaker tracking
-	return h.executeWithCircuitBreaker(c, req, provider, providerConfig, isStreaming, requestID, cacheSource)
+	// Execute request with circuit breaker tracking; streams are finished by the stream orchestrator once they end
+	start := time.Now()
+	observation := h.statsTracker.Start(provider, req.Model)
+	err := h.executeWithCircuitBreaker(c, req, provider, providerConfig, isStreaming, requestID, cacheSource, observation)
+	if err != nil || !isStreaming {
+		observation.Finish(err)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		routing_trace.From(c).Failed(provider, req.Model, err, time.Since(start))
+		if apiKey, ok := auth.GetAPIKey(c); ok && apiKey != nil && h.usageService != nil {
+			endpoint := "/v1beta/models/:model:generateContent"
+			if isStreaming {
+				endpoint = "/v1/models/" + req.Model + ":streamGenerateContent"
+			}
+			attempted := models.Alternative{Provider: provider, Model: req.Model}
+			h.usageService.Record(c, usage.FailedAttempt(apiKey, usage.AttemptOf(c), endpoint, attempted, fallback.StatusCode(err), err, requestID))
+		}
+	}
+	return err
 }
 
 // storeSuccessfulSemanticCache stores successful responses in semantic cache
 func (h *GenerateHandler) storeSuccessfulSemanticCache(ctx context.Context, req *models.GeminiGenerateRequest, modelResp *models.ModelSelectionResponse, requestID string) {
 	h.responseSvc.StoreSuccessfulSemanticCache(ctx, req, modelResp, requestID)
 }
+
+// applyRoutingRules matches routing_rules against the request and applies the matched rule:
+// pinning the model, restricting router candidates, or overriding cost bias and fallback mode.
+// The request is then assigned to an experiment arm when an experiment applies.
+func (h *GenerateHandler) applyRoutingRules(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	resolvedConfig *config.Config,
+	requestID string,
+) error {
+	rc := model_router.NewRoutingContext(c, "generate")
+	if req.GenerationConfig != nil {
+		rc.Metadata = req.GenerationConfig.Labels
+	}
+	rc.HasTools = len(req.Tools) > 0
+	rc.Prompt, _ = utils.ExtractGeminiPrompt(req.SystemInstruction, req.Contents, h.modelRouter.PromptExtraction())
+	rc.EstimatedTokens = requestProfile(req).EstimatedInputTokens
+
+	rule, model, err := h.modelRouter.ApplyRoutingRules(c.UserContext(), rc, req.Model, resolvedConfig.ModelRouter, requestID)
+	if err != nil {
+		return err
+	}
+	c.SetUserContext(model_router.WithRule(c.UserContext(), rule))
+	c.SetUserContext(h.modelRouter.ScopeCache(c.UserContext(), rc, resolvedConfig.ModelRouter))
+	req.Model = h.modelRouter.ApplyExperiment(c, rc, model, rule, requestID)
+	req.Fallback = rule.OverrideFallback(req.Fallback)
+	return nil
+}
+
+// mirrorShadow replays a successfully served request against the generate endpoint's shadow model
+func (h *GenerateHandler) mirrorShadow(
+	c *fiber.Ctx,
+	req *models.GeminiGenerateRequest,
+	resolvedConfig *config.Config,
+	isStreaming bool,
+	start time.Time,
+	requestID string,
+) {
+	reqCopy := *req
+	h.shadowSvc.Mirror(c, h.cfg.GetShadowConfig("generate"), "generate", requestID, start, isStreaming,
+		func(ctx context.Context, provider, model string) models.ShadowResult {
+			providerConfig, exists := resolvedConfig.GetProviderConfig(provider, "generate")
+			if !exists {
+				return models.ShadowResult{Provider: provider, Model: model, Error: "provider not configured"}
+			}
+			client, err := h.gateway.Generate(ctx, providerConfig)
+			if err != nil {
+				return models.ShadowResult{Provider: provider, Model: model, Error: err.Error()}
+			}
+			return h.generateSvc.SendShadowRequest(ctx, reqCopy, client, provider, model, requestID)
+		})
+}
+
+// requestProfile describes a Gemini request for context-window filtering and routing
+func requestProfile(req *models.GeminiGenerateRequest) *models.RequestProfile {
+	maxOutputTokens := 0
+	if req.GenerationConfig != nil {
+		maxOutputTokens = int(req.GenerationConfig.MaxOutputTokens)
+	}
+	profile := utils.GeminiRequestProfile(req.SystemInstruction, req.Contents, req.Tools, maxOutputTokens)
+	profile.Features = utils.GeminiRequestFeatures(req)
+	return profile
+}
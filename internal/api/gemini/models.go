@@ -0,0 +1,45 @@
+package gemini
+
+import (
+	"github.com/Egham-7/adaptive-proxy/internal/config"
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// generationMethods are the Gemini methods the proxy serves for every catalog model
+var generationMethods = []string{"generateContent", "streamGenerateContent", "countTokens"}
+
+// ModelsHandler lists the generate endpoint's model catalog in the Gemini models API format
+type ModelsHandler struct {
+	cfg *config.Config
+}
+
+// NewModelsHandler initializes the Gemini models handler
+func NewModelsHandler(cfg *config.Config) *ModelsHandler {
+	return &ModelsHandler{
+		cfg: cfg,
+	}
+}
+
+// List returns the generate endpoint's catalog models. Model names use the "provider:model"
+// form so they can be passed straight back to generateContent.
+func (h *ModelsHandler) List(c *fiber.Ctx) error {
+	catalog := h.cfg.GetModelCatalog("generate")
+	list := models.GeminiCatalogModelList{
+		Models: make([]models.GeminiCatalogModel, 0, len(catalog)),
+	}
+	for _, model := range catalog {
+		id := model.Provider + ":" + model.ModelName
+		list.Models = append(list.Models, models.GeminiCatalogModel{
+			Name:                       "models/" + id,
+			BaseModelID:                id,
+			DisplayName:                model.ModelName,
+			Description:                model.Description,
+			InputTokenLimit:            model.MaxContextTokens,
+			OutputTokenLimit:           model.MaxOutputTokens,
+			SupportedGenerationMethods: generationMethods,
+		})
+	}
+	return c.JSON(list)
+}
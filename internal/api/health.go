@@ -110,7 +110,10 @@ func (h *HealthHandler) checkAIService() string {
 		Models: configuredModels,
 	}
 
-	response := h.modelRouterClient.SelectModel(ctx, dummyRequest)
+	response, err := h.modelRouterClient.SelectModel(ctx, dummyRequest)
+	if err != nil {
+		return "unhealthy"
+	}
 
 	if response.IsValid() && response.Provider != "" && response.Model != "" {
 		return "healthy"
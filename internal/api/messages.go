@@ -1,14 +1,28 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
 	"github.com/Egham-7/adaptive-proxy/internal/services/anthropic/messages"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
 	"github.com/Egham-7/adaptive-proxy/internal/services/fallback"
+	"github.com/Egham-7/adaptive-proxy/internal/services/gemini/generate"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/protocols"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response_cache"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
@@ -22,12 +36,16 @@ type MessagesHandler struct {
 	cfg             *config.Config
 	requestSvc      *messages.RequestService
 	messagesSvc     *messages.MessagesService
+	gateway         *protocols.Gateway
 	responseSvc     *messages.ResponseService
 	modelRouter     *model_router.ModelRouter
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker
 	fallbackService *fallback.FallbackService
 	usageService    *usage.Service
 	usageWorker     *usage.Worker
+	statsTracker    *provider_stats.Tracker
+	shadowSvc       *shadow.Service
+	responseCache   *response_cache.Service
 }
 
 // NewMessagesHandler creates a new MessagesHandler with Anthropic-specific services
@@ -37,17 +55,25 @@ func NewMessagesHandler(
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker,
 	usageService *usage.Service,
 	usageWorker *usage.Worker,
+	statsTracker *provider_stats.Tracker,
+	shadowSvc *shadow.Service,
+	responseCache *response_cache.Service,
 ) *MessagesHandler {
+	messagesSvc := messages.NewMessagesService()
 	return &MessagesHandler{
 		cfg:             cfg,
 		requestSvc:      messages.NewRequestService(),
-		messagesSvc:     messages.NewMessagesService(),
+		messagesSvc:     messagesSvc,
+		gateway:         protocols.NewGateway(messagesSvc, generate.NewGenerateService()),
 		responseSvc:     messages.NewResponseService(modelRouter, usageService, usageWorker),
 		modelRouter:     modelRouter,
 		circuitBreakers: circuitBreakers,
-		fallbackService: fallback.NewFallbackService(cfg),
+		fallbackService: fallback.NewFallbackService(cfg, "messages", statsTracker),
 		usageService:    usageService,
 		usageWorker:     usageWorker,
+		statsTracker:    statsTracker,
+		shadowSvc:       shadowSvc,
+		responseCache:   responseCache,
 	}
 }
 
@@ -64,6 +90,10 @@ func (h *MessagesHandler) Messages(c *fiber.Ctx) error {
 	}
 	fiberlog.Debugf("[%s] Request parsed successfully - model: %s, messages: %d", requestID, req.Model, len(req.Messages))
 
+	// Record routing decisions; they are returned as X-Adaptive-* headers
+	routing_trace.Start(c, false)
+	defer routing_trace.WriteHeaders(c)
+
 	// Resolve config by merging YAML config with request overrides (single source of truth)
 	resolvedConfig, err := h.cfg.ResolveConfigFromAnthropicRequest(req)
 	if err != nil {
@@ -76,6 +106,57 @@ func (h *MessagesHandler) Messages(c *fiber.Ctx) error {
 	isStreaming := req.Stream != nil && *req.Stream
 	fiberlog.Debugf("[%s] Request type: streaming=%t", requestID, isStreaming)
 
+	// Mirror the request to the endpoint's shadow model once the response has been served
+	defer h.mirrorShadow(c, req, resolvedConfig, isStreaming, time.Now(), requestID)
+
+	// Apply routing_rules before direct routing or model selection
+	if err := h.applyRoutingRules(c, req, resolvedConfig, requestID); err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+		}
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+
+	// Serve deterministic requests from the response cache before any provider is called
+	if cacheKey, ok := h.responseCache.AnthropicKey(c, req); ok {
+		if hit, found := h.responseCache.Lookup(c.UserContext(), cacheKey, requestID); found {
+			return h.responseCache.ServeAnthropic(c, hit, isStreaming, requestID)
+		}
+		if !isStreaming {
+			defer h.responseCache.Store(c, cacheKey, requestID)
+		}
+	}
+
+	// Explicit fallback lists run across exactly the listed models
+	if entries, ok := utils.SplitModelList(string(req.Model)); ok {
+		listResp, err := h.modelRouter.ResolveModelList(c.UserContext(), entries, resolvedConfig.ModelRouter, h.circuitBreakers, requestID)
+		if err != nil {
+			switch {
+			case errors.Is(err, model_router.ErrModelNotAllowed):
+				return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+			case errors.Is(err, model_router.ErrInvalidModelList):
+				return h.responseSvc.HandleBadRequest(c, err.Error(), requestID)
+			}
+			return h.responseSvc.HandleError(c, err, requestID)
+		}
+		req.Fallback = resolvedConfig.ModelListFallback(req.Fallback)
+		return h.executeWithFallback(c, req, listResp, isStreaming, "", requestID)
+	}
+
+	// Resolve virtual model aliases into their ordered provider chain
+	modelResp, alias, err := h.modelRouter.ResolveAlias(string(req.Model), resolvedConfig.ModelRouter, requestID)
+	if err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.HandleForbidden(c, err.Error(), requestID)
+		}
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+	if modelResp != nil {
+		routing_trace.From(c).SetAlias(string(req.Model))
+		req.Fallback = alias.ApplyFallback(req.Fallback)
+		return h.executeWithFallback(c, req, modelResp, isStreaming, "", requestID)
+	}
+
 	// If a model is specified, try to directly route to the appropriate provider
 	if req.Model != "" {
 		modelStr := string(req.Model)
@@ -99,10 +180,19 @@ func (h *MessagesHandler) Messages(c *fiber.Ctx) error {
 				return h.responseSvc.HandleProviderNotConfigured(c, provider, requestID)
 			}
 
+			client, err := h.gateway.Messages(providerConfig)
+			if err != nil {
+				return h.responseSvc.HandleError(c, err, requestID)
+			}
+
 			// Direct execution - no fallback for user-specified models
-			err = h.messagesSvc.HandleAnthropicProvider(c, req, providerConfig, isStreaming, requestID, h.responseSvc, provider, "")
+			observation := h.statsTracker.Start(provider, parsedModel)
+			err = h.messagesSvc.HandleProvider(c, req, client, isStreaming, requestID, h.responseSvc, provider, "", observation)
+			if err != nil || !isStreaming {
+				observation.Finish(err)
+			}
 			if err != nil {
-				return err
+				return h.responseSvc.HandleError(c, err, requestID)
 			}
 
 			// Store successful response in semantic cache for user-specified models
@@ -120,7 +210,7 @@ func (h *MessagesHandler) Messages(c *fiber.Ctx) error {
 	fiberlog.Debugf("[%s] No model specified, using model router for selection with fallback", requestID)
 
 	// Extract prompt for routing
-	prompt, err := utils.ExtractPromptFromAnthropicMessages(req.Messages)
+	prompt, err := utils.ExtractAnthropicPrompt(req.System, req.Messages, h.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Warnf("[%s] Failed to extract prompt for routing: %v", requestID, err)
 		return h.responseSvc.HandleBadRequest(c, "failed to extract prompt for routing: "+err.Error(), requestID)
@@ -129,31 +219,88 @@ func (h *MessagesHandler) Messages(c *fiber.Ctx) error {
 	// Use model router to select best model WITH CIRCUIT BREAKERS
 	userID := "anonymous"
 	toolCall := utils.ExtractToolCallsFromAnthropicMessages(req.Messages)
+	profile := utils.AnthropicRequestProfile(req.System, req.Messages, req.Tools, int(req.MaxTokens))
+	profile.Features = utils.AnthropicRequestFeatures(req)
 
 	modelResp, cacheSource, err := h.modelRouter.SelectModelWithCache(
 		c.UserContext(),
 		prompt, userID, requestID, resolvedConfig.ModelRouter, h.circuitBreakers,
-		req.Tools, toolCall,
+		req.Tools, toolCall, profile,
 	)
 	if err != nil {
 		fiberlog.Errorf("[%s] Model router selection failed: %v", requestID, err)
+		if errors.Is(err, model_router.ErrContextTooLong) || errors.Is(err, model_router.ErrUnsupportedFeature) {
+			return h.responseSvc.HandleBadRequest(c, err.Error(), requestID)
+		}
 		return h.responseSvc.HandleError(c, err, requestID)
 	}
 
-	// Update request with selected model
-	req.Model = anthropic.Model(modelResp.Model)
 	fiberlog.Infof("[%s] Model router selected - provider: %s, model: %s (with %d alternatives)",
 		requestID, modelResp.Provider, modelResp.Model, len(modelResp.Alternatives))
 
+	return h.executeWithFallback(c, req, modelResp, isStreaming, cacheSource, requestID)
+}
+
+// executeWithFallback runs the request through the fallback service and sends the final
+// failure to the client
+func (h *MessagesHandler) executeWithFallback(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	modelResp *models.ModelSelectionResponse,
+	isStreaming bool,
+	cacheSource string,
+	requestID string,
+) error {
+	if err := h.runWithFallback(c, req, modelResp, isStreaming, cacheSource, requestID); err != nil {
+		return h.responseSvc.HandleError(c, err, requestID)
+	}
+	return nil
+}
+
+// runWithFallback tries the selected primary model, then its alternatives through the fallback service
+func (h *MessagesHandler) runWithFallback(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	modelResp *models.ModelSelectionResponse,
+	isStreaming bool,
+	cacheSource string,
+	requestID string,
+) error {
+	// Update request with selected model
+	req.Model = anthropic.Model(modelResp.Model)
+
 	// Try primary provider first
 	primary := models.Alternative{
 		Provider: modelResp.Provider,
 		Model:    modelResp.Model,
 	}
-	executeFunc := h.createExecuteFunc(req, isStreaming, cacheSource)
+	fallbackConfig := h.fallbackService.GetFallbackConfig(req.Fallback)
+	executeFunc := h.fallbackService.WithRetries(h.createExecuteFunc(req, isStreaming, cacheSource), fallbackConfig)
+
+	// Streaming races and hedges, and hedged requests, run the primary alongside its alternatives
+	if len(modelResp.Alternatives) > 0 {
+		candidates := append([]models.Alternative{primary}, modelResp.Alternatives...)
+		if isStreaming && fallbackConfig.MidStreamRecovery {
+			continueFunc, err := h.createStreamContinueFunc(c, req, cacheSource)
+			if err != nil {
+				return err
+			}
+			h.fallbackService.EnableStreamResume(c, candidates, fallbackConfig, continueFunc, requestID)
+		}
+		switch {
+		case isStreaming && (fallbackConfig.Mode == models.FallbackModeRace || fallbackConfig.Mode == models.FallbackModeHedge):
+			openFunc, err := h.createStreamOpenFunc(c, req, cacheSource)
+			if err != nil {
+				return err
+			}
+			return h.fallbackService.RaceStreams(c, candidates, fallbackConfig, openFunc, requestID)
+		case fallbackConfig.Mode == models.FallbackModeHedge:
+			return h.fallbackService.Execute(c, candidates, fallbackConfig, executeFunc, requestID, isStreaming)
+		}
+	}
 
 	fiberlog.Infof("[%s] Trying primary provider: %s/%s", requestID, primary.Provider, primary.Model)
-	err = executeFunc(c, primary, requestID)
+	err := executeFunc(c, primary, requestID)
 
 	if err == nil {
 		// Primary succeeded
@@ -166,15 +313,182 @@ func (h *MessagesHandler) Messages(c *fiber.Ctx) error {
 		fiberlog.Errorf("[%s] ❌ Primary provider failed and no alternatives available: %v", requestID, err)
 		return err
 	}
+	if !fallback.ShouldFallback(c.UserContext(), err) {
+		fiberlog.Errorf("[%s] ❌ Primary provider failed with a %s error, not trying alternatives: %v",
+			requestID, fallback.ClassifyAttempt(c.UserContext(), err), err)
+		return err
+	}
 
 	// Use fallback service with alternatives only
 	fiberlog.Warnf("[%s] ⚠️  Primary provider failed: %v", requestID, err)
 	fiberlog.Infof("[%s] Using fallback with %d alternatives", requestID, len(modelResp.Alternatives))
 
-	fallbackConfig := h.fallbackService.GetFallbackConfig(req.Fallback)
 	return h.fallbackService.Execute(c, modelResp.Alternatives, fallbackConfig, executeFunc, requestID, isStreaming)
 }
 
+// applyRoutingRules matches routing_rules against the request and applies the matched rule:
+// pinning the model, restricting router candidates, or overriding cost bias and fallback mode.
+// The request is then assigned to an experiment arm when an experiment applies.
+func (h *MessagesHandler) applyRoutingRules(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	resolvedConfig *config.Config,
+	requestID string,
+) error {
+	rc := model_router.NewRoutingContext(c, "messages")
+	if req.Metadata.UserID.Valid() {
+		rc.User = req.Metadata.UserID.Value
+		rc.Metadata = map[string]string{"user_id": req.Metadata.UserID.Value}
+	}
+	rc.HasTools = len(req.Tools) > 0
+	rc.Prompt, _ = utils.ExtractAnthropicPrompt(req.System, req.Messages, h.modelRouter.PromptExtraction())
+	rc.EstimatedTokens = utils.EstimateAnthropicRequestTokens(req.System, req.Messages, req.Tools)
+
+	rule, model, err := h.modelRouter.ApplyRoutingRules(c.UserContext(), rc, string(req.Model), resolvedConfig.ModelRouter, requestID)
+	if err != nil {
+		return err
+	}
+	c.SetUserContext(model_router.WithRule(c.UserContext(), rule))
+	c.SetUserContext(h.modelRouter.ScopeCache(c.UserContext(), rc, resolvedConfig.ModelRouter))
+	req.Model = anthropic.Model(h.modelRouter.ApplyExperiment(c, rc, model, rule, requestID))
+	req.Fallback = rule.OverrideFallback(req.Fallback)
+	return nil
+}
+
+// mirrorShadow replays a successfully served request against the messages endpoint's shadow model
+func (h *MessagesHandler) mirrorShadow(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	resolvedConfig *config.Config,
+	isStreaming bool,
+	start time.Time,
+	requestID string,
+) {
+	reqCopy := *req
+	h.shadowSvc.Mirror(c, h.cfg.GetShadowConfig("messages"), "messages", requestID, start, isStreaming,
+		func(ctx context.Context, provider, model string) models.ShadowResult {
+			providerConfig, exists := resolvedConfig.GetProviderConfig(provider, "messages")
+			if !exists {
+				return models.ShadowResult{Provider: provider, Model: model, Error: "provider not configured"}
+			}
+			client, err := h.gateway.Messages(providerConfig)
+			if err != nil {
+				return models.ShadowResult{Provider: provider, Model: model, Error: err.Error()}
+			}
+			return h.messagesSvc.SendShadowMessage(ctx, reqCopy, client, provider, model, requestID)
+		})
+}
+
+// createStreamOpenFunc creates the stream opener for streaming races
+func (h *MessagesHandler) createStreamOpenFunc(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	cacheSource string,
+) (models.StreamOpenFunc, error) {
+	open, err := h.streamOpener(c, req, cacheSource)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, provider models.Alternative, reqID string) (models.PendingStream, error) {
+		return open(ctx, req, provider, reqID)
+	}, nil
+}
+
+// createStreamContinueFunc creates the opener of continuations for mid-stream recovery, which
+// prefill the reply with the partial output
+func (h *MessagesHandler) createStreamContinueFunc(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	cacheSource string,
+) (models.StreamContinueFunc, error) {
+	open, err := h.streamOpener(c, req, cacheSource)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, provider models.Alternative, partial, reqID string) (models.PendingStream, error) {
+		continuation := *req
+		// Anthropic continues a trailing assistant message, which must not end in whitespace
+		if prefill := strings.TrimRightFunc(partial, unicode.IsSpace); prefill != "" {
+			continuation.Messages = append(slices.Clip(req.Messages), anthropic.NewAssistantMessage(anthropic.NewTextBlock(prefill)))
+		}
+		return open(ctx, &continuation, provider, reqID)
+	}, nil
+}
+
+// streamOpener returns a function opening a provider's stream without touching the response,
+// using the providers configured for req. Everything it needs from c is read here, since
+// streams are opened concurrently, or after the handler returned, and must not touch c.
+func (h *MessagesHandler) streamOpener(
+	c *fiber.Ctx,
+	req *models.AnthropicMessageRequest,
+	cacheSource string,
+) (func(ctx context.Context, streamReq *models.AnthropicMessageRequest, provider models.Alternative, reqID string) (models.PendingStream, error), error) {
+	resolvedConfig, err := h.cfg.ResolveConfigFromAnthropicRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config: %w", err)
+	}
+	providers := resolvedConfig.GetProviders("messages")
+	apiKey, _ := auth.GetAPIKey(c)
+	usageMetadata := usage.UsageMetadata(c)
+	trace := routing_trace.From(c)
+
+	return func(ctx context.Context, streamReq *models.AnthropicMessageRequest, provider models.Alternative, reqID string) (models.PendingStream, error) {
+		providerConfig, exists := providers[provider.Provider]
+		if !exists {
+			return nil, fmt.Errorf("provider %s not configured", provider.Provider)
+		}
+		cb := h.circuitBreakers[provider.Provider]
+		if cb != nil && !cb.CanExecute() {
+			fiberlog.Warnf("[%s] Circuit breaker is OPEN for provider %s, skipping", reqID, provider.Provider)
+			trace.Filter(provider.Provider, provider.Model, models.FilterReasonCircuitBreaker)
+			return nil, fmt.Errorf("%w for provider %s", circuitbreaker.ErrOpen, provider.Provider)
+		}
+
+		client, err := h.gateway.Messages(providerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("client creation failed for provider %s: %w", provider.Provider, err)
+		}
+
+		reqCopy := *streamReq
+		reqCopy.Model = anthropic.Model(provider.Model)
+
+		// ctx is cancelled when the contender loses, which aborts the upstream request
+		start := time.Now()
+		attempt := usage.Attempt{Metadata: usageMetadata, Start: start}
+		observation := h.statsTracker.Start(provider.Provider, provider.Model)
+		stream, err := client.SendStreamingMessage(ctx, &reqCopy, reqID)
+		var pending *handlers.PendingStream
+		if err == nil {
+			pending, err = h.responseSvc.PrepareStreamingResponse(stream, reqID, provider.Provider, cacheSource, provider.Model, "/v1/messages", apiKey, attempt, observation)
+		}
+		if err != nil {
+			observation.Finish(err)
+			if !errors.Is(err, context.Canceled) {
+				trace.Failed(provider.Provider, provider.Model, err, time.Since(start))
+				if h.usageWorker != nil && apiKey != nil {
+					h.usageWorker.Submit(usage.FailedAttempt(apiKey, attempt, "/v1/messages", provider, fallback.StatusCode(err), err, reqID), reqID)
+				}
+				if cb != nil {
+					cb.RecordFailure()
+				}
+			}
+			return nil, err
+		}
+		if cb != nil {
+			cb.RecordSuccess()
+		}
+
+		return fallback.NewPendingStream(pending, observation, func(c *fiber.Ctx) {
+			routing_trace.From(c).Select(provider.Provider, provider.Model)
+			shadow.SetPrimary(c, models.ShadowResult{Provider: provider.Provider, Model: provider.Model})
+			h.responseSvc.StoreSuccessfulSemanticCache(c.UserContext(), &reqCopy, &models.ModelSelectionResponse{
+				Provider: provider.Provider,
+				Model:    provider.Model,
+			}, reqID)
+		}), nil
+	}, nil
+}
+
 // createExecuteFunc creates an execution function for the fallback service
 func (h *MessagesHandler) createExecuteFunc(
 	req *models.AnthropicMessageRequest,
@@ -194,13 +508,31 @@ func (h *MessagesHandler) createExecuteFunc(
 			return fmt.Errorf("provider %s not configured", provider.Provider)
 		}
 
+		client, err := h.gateway.Messages(providerConfig)
+		if err != nil {
+			return fmt.Errorf("client creation failed for provider %s: %w", provider.Provider, err)
+		}
+
 		// Create a copy to avoid race conditions when mutating req.Model
 		reqCopy := *req
 		reqCopy.Model = anthropic.Model(provider.Model)
 
-		// Call the messages service
-		err = h.messagesSvc.HandleAnthropicProvider(c, &reqCopy, providerConfig, isStreaming, reqID, h.responseSvc, provider.Provider, cacheSource)
+		// Call the messages service; streams are finished by the stream orchestrator once they end
+		start := time.Now()
+		observation := h.statsTracker.Start(provider.Provider, provider.Model)
+		err = h.messagesSvc.HandleProvider(c, &reqCopy, client, isStreaming, reqID, h.responseSvc, provider.Provider, cacheSource, observation)
+		if err != nil || !isStreaming {
+			observation.Finish(err)
+		}
+		if errors.Is(err, context.Canceled) {
+			// A hedge loser or a disconnected client says nothing about the provider's health
+			return err
+		}
 		if err != nil {
+			routing_trace.From(c).Failed(provider.Provider, provider.Model, err, time.Since(start))
+			if apiKey, ok := auth.GetAPIKey(c); ok && apiKey != nil && h.usageService != nil {
+				h.usageService.Record(c, usage.FailedAttempt(apiKey, usage.AttemptOf(c), "/v1/messages", provider, fallback.StatusCode(err), err, reqID))
+			}
 			// Record failure in circuit breaker
 			if cb := h.circuitBreakers[provider.Provider]; cb != nil {
 				cb.RecordFailure()
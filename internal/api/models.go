@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/config"
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ModelsHandler lists the model catalog in the OpenAI models API format
+type ModelsHandler struct {
+	cfg *config.Config
+	// created is reported as the creation time of every model since the catalog has no dates
+	created int64
+}
+
+// NewModelsHandler initializes the models handler
+func NewModelsHandler(cfg *config.Config) *ModelsHandler {
+	return &ModelsHandler{
+		cfg:     cfg,
+		created: time.Now().Unix(),
+	}
+}
+
+// List returns every catalog model. Model IDs use the "provider:model" form accepted by the
+// model field of completion requests.
+func (h *ModelsHandler) List(c *fiber.Ctx) error {
+	catalog := h.cfg.ModelCatalog()
+	data := make([]models.CatalogModel, 0, len(catalog))
+	for _, model := range catalog {
+		data = append(data, h.catalogModel(model))
+	}
+	return c.JSON(models.CatalogModelList{
+		Object: "list",
+		Data:   data,
+	})
+}
+
+// Get returns one catalog model by "provider:model" or by a model name served by a single provider
+func (h *ModelsHandler) Get(c *fiber.Ctx) error {
+	id, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		id = c.Params("*")
+	}
+
+	model, ok := h.cfg.FindCatalogModel(id)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fiber.Map{
+				"message": "The model '" + id + "' does not exist",
+				"type":    "invalid_request_error",
+				"param":   "model",
+				"code":    "model_not_found",
+			},
+		})
+	}
+	return c.JSON(h.catalogModel(model))
+}
+
+func (h *ModelsHandler) catalogModel(model models.ModelCapability) models.CatalogModel {
+	return models.CatalogModel{
+		ID:              model.Provider + ":" + model.ModelName,
+		Object:          "model",
+		Created:         h.created,
+		OwnedBy:         model.Provider,
+		ModelCapability: usage.WithPricing(model),
+	}
+}
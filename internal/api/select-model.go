@@ -1,12 +1,16 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
+	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
 	"github.com/Egham-7/adaptive-proxy/internal/services/select_model"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
@@ -53,6 +57,10 @@ func (h *SelectModelHandler) SelectModel(c *fiber.Ctx) error {
 		return h.responseSvc.BadRequest(c, fmt.Sprintf("Invalid request body: %s", err.Error()))
 	}
 
+	// Record routing decisions; they are returned as X-Adaptive-* headers and, on request, in the body
+	routing_trace.Start(c, selectReq.IncludeRouting)
+	defer routing_trace.WriteHeaders(c)
+
 	// Extract user ID from request body (use "anonymous" if not provided)
 	userID := "anonymous"
 	if selectReq.User != nil && *selectReq.User != "" {
@@ -78,11 +86,50 @@ func (h *SelectModelHandler) SelectModel(c *fiber.Ctx) error {
 	// Use "select_model" endpoint to get the configured providers for model selection
 	mergedConfig := h.cfg.MergeModelRouterConfig(requestConfig, "select_model")
 
+	// Apply routing_rules and experiments; a pinned model or experiment arm short-circuits model selection
+	rc := model_router.NewRoutingContext(c, "select_model")
+	rc.User = userID
+	rc.Metadata = selectReq.Metadata
+	rc.HasTools = selectReq.Tools != nil
+	rc.Prompt = selectReq.Prompt
+	rc.EstimatedTokens = utils.EstimateTokens(selectReq.Prompt) + utils.EstimateJSONTokens(selectReq.Tools)
+	resp, err := h.selectModelSvc.ApplyRoutingRules(c.UserContext(), rc, selectReq, mergedConfig, reqID)
+	if err == nil {
+		c.SetUserContext(h.selectModelSvc.ScopeCache(c.UserContext(), rc, mergedConfig))
+	}
+	if err == nil && resp == nil && selectReq.Model != "" {
+		// An explicit model or alias is resolved instead of running the router
+		resp, err = h.selectModelSvc.ResolveModel(c.UserContext(), selectReq.Model, mergedConfig, reqID)
+	}
+	if err != nil {
+		if errors.Is(err, model_router.ErrModelNotAllowed) {
+			return h.responseSvc.Forbidden(c, err.Error())
+		}
+		if errors.Is(err, select_model.ErrUnknownModel) {
+			return h.responseSvc.BadRequest(c, err.Error())
+		}
+		return h.responseSvc.InternalError(c, fmt.Sprintf("Model selection failed: %s", err.Error()))
+	}
+	if resp != nil {
+		return h.success(c, resp)
+	}
+
 	// Perform model selection using the service
-	resp, err := h.selectModelSvc.SelectModel(c.UserContext(), selectReq, userID, reqID, h.circuitBreakers, mergedConfig)
+	resp, err = h.selectModelSvc.SelectModel(c.UserContext(), selectReq, userID, reqID, h.circuitBreakers, mergedConfig)
 	if err != nil {
+		if errors.Is(err, model_router.ErrContextTooLong) || errors.Is(err, model_router.ErrUnsupportedFeature) {
+			return h.responseSvc.BadRequest(c, err.Error())
+		}
 		return h.responseSvc.InternalError(c, fmt.Sprintf("Model selection failed: %s", err.Error()))
 	}
 
+	return h.success(c, resp)
+}
+
+// success records the selection in the routing trace and returns it, with the trace when requested
+func (h *SelectModelHandler) success(c *fiber.Ctx, resp *models.SelectModelResponse) error {
+	trace := routing_trace.From(c)
+	trace.Select(resp.Provider, resp.Model)
+	resp.Routing = trace.ForResponse()
 	return h.responseSvc.Success(c, resp)
 }
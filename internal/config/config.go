@@ -6,9 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	fiberlog "github.com/gofiber/fiber/v2/log"
 	"github.com/joho/godotenv"
@@ -19,6 +21,21 @@ const (
 	defaultCostBiasFactor = 0.9
 )
 
+// catalogEndpoints are the endpoints whose provider model lists make up the model catalog, in
+// listing order
+var catalogEndpoints = []string{"chat_completions", "messages", "generate", "select_model", "count_tokens"}
+
+// servingEndpoints are the endpoints that serve requests through their providers, in the order
+// other endpoints' providers are looked up in
+var servingEndpoints = []string{"chat_completions", "messages", "generate"}
+
+// endpointProtocols are the native protocols of the serving endpoints
+var endpointProtocols = map[string]models.ProviderProtocol{
+	"chat_completions": models.ProtocolOpenAI,
+	"messages":         models.ProtocolAnthropic,
+	"generate":         models.ProtocolGemini,
+}
+
 // Config represents the complete application configuration
 type Config struct {
 	Server      models.ServerConfig       `yaml:"server"`
@@ -29,6 +46,16 @@ type Config struct {
 	Auth        *models.AuthConfig        `yaml:"auth,omitempty"`
 	Billing     *models.StripeConfig      `yaml:"billing,omitempty"`
 	APIKey      *models.APIKeyConfig      `yaml:"api_key,omitempty"`
+	// RoutingRules are evaluated in order before the model router; the first match applies
+	RoutingRules []models.RoutingRule `yaml:"routing_rules,omitempty"`
+	// ModelAliases map virtual model names to ordered provider:model chains
+	ModelAliases map[string]models.ModelAlias `yaml:"model_aliases,omitempty"`
+	// Experiments split traffic between models by weight with sticky assignment
+	Experiments []models.Experiment `yaml:"experiments,omitempty"`
+	// Feedback controls cache invalidation and forwarding of /v1/feedback submissions
+	Feedback *models.FeedbackConfig `yaml:"feedback,omitempty"`
+	// ResponseCache serves complete responses to repeated deterministic requests
+	ResponseCache *models.CacheConfig `yaml:"response_cache,omitempty"`
 }
 
 // LoadFromFile loads configuration from a YAML file with environment variable substitution
@@ -101,6 +128,47 @@ func LoadFromFile(configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// NormalizeModelCatalog fills in the provider of every catalog model from the provider it is
+// listed under and rejects entries without a model name
+func (c *Config) NormalizeModelCatalog() error {
+	for _, endpoint := range catalogEndpoints {
+		for providerName, providerConfig := range c.GetProviders(endpoint) {
+			for i := range providerConfig.Models {
+				model := &providerConfig.Models[i]
+				if model.ModelName == "" {
+					return fmt.Errorf("endpoints.%s.providers.%s.models[%d]: model_name is required", endpoint, providerName, i)
+				}
+				if model.Provider != "" && !strings.EqualFold(model.Provider, providerName) {
+					return fmt.Errorf("endpoints.%s.providers.%s.models[%d]: provider %q does not match the provider it is listed under",
+						endpoint, providerName, i, model.Provider)
+				}
+				model.Provider = providerName
+			}
+		}
+	}
+	return nil
+}
+
+// NormalizeProviderProtocols defaults the protocol of every provider to the native protocol of
+// the endpoint it is configured under and rejects unknown protocols
+func (c *Config) NormalizeProviderProtocols() error {
+	for endpoint, native := range endpointProtocols {
+		providers := c.GetProviders(endpoint)
+		for providerName, providerConfig := range providers {
+			switch providerConfig.Protocol {
+			case "":
+				providerConfig.Protocol = native
+			case models.ProtocolOpenAI, models.ProtocolAnthropic, models.ProtocolGemini:
+			default:
+				return fmt.Errorf("endpoints.%s.providers.%s: unsupported protocol %q (supported: openai, anthropic, gemini)",
+					endpoint, providerName, providerConfig.Protocol)
+			}
+			providers[providerName] = providerConfig
+		}
+	}
+	return nil
+}
+
 // LoadEnvFiles loads environment variables from .env files in order of precedence
 // Loads files in the order provided (first has highest priority)
 func LoadEnvFiles(envFiles []string) {
@@ -191,6 +259,62 @@ func (c *Config) GetProviders(endpoint string) map[string]models.ProviderConfig
 	}
 }
 
+// ServingProviders returns the providers that can serve requests on endpoint: its own, and for a
+// serving endpoint those of the other serving endpoints, called through the protocol they speak
+func (c *Config) ServingProviders(endpoint string) map[string]models.ProviderConfig {
+	own := c.GetProviders(endpoint)
+	if _, serving := endpointProtocols[endpoint]; !serving {
+		return own
+	}
+	providers := maps.Clone(own)
+	if providers == nil {
+		providers = make(map[string]models.ProviderConfig)
+	}
+	for _, other := range servingEndpoints {
+		for providerName := range c.GetProviders(other) {
+			if _, exists := providers[providerName]; !exists {
+				providers[providerName], _ = c.ServingProviderConfig(providerName, endpoint)
+			}
+		}
+	}
+	return providers
+}
+
+// ServingProviderConfig returns the configuration of a provider that can serve requests on
+// endpoint. A provider configured under several endpoints uses the entry of endpoint itself,
+// otherwise that of the first serving endpoint that has it.
+func (c *Config) ServingProviderConfig(provider, endpoint string) (models.ProviderConfig, bool) {
+	if providerConfig, exists := c.GetProviderConfig(provider, endpoint); exists {
+		return providerConfig, true
+	}
+	if _, serving := endpointProtocols[endpoint]; !serving {
+		return models.ProviderConfig{}, false
+	}
+	for _, other := range servingEndpoints {
+		if providerConfig, exists := c.GetProviderConfig(provider, other); exists {
+			if providerConfig.Protocol == "" {
+				providerConfig.Protocol = endpointProtocols[other]
+			}
+			return providerConfig, true
+		}
+	}
+	return models.ProviderConfig{}, false
+}
+
+// GetShadowConfig returns the shadow traffic configuration for the specified endpoint, or nil
+func (c *Config) GetShadowConfig(endpoint string) *models.ShadowConfig {
+	switch endpoint {
+	case "chat_completions":
+		return c.Endpoints.ChatCompletions.Shadow
+	case "messages":
+		return c.Endpoints.Messages.Shadow
+	case "generate":
+		return c.Endpoints.Generate.Shadow
+	default:
+		return nil
+	}
+}
+
 // GetProviderConfig returns the configuration for a specific provider from the specified endpoint
 func (c *Config) GetProviderConfig(provider, endpoint string) (models.ProviderConfig, bool) {
 	var providers map[string]models.ProviderConfig
@@ -213,6 +337,76 @@ func (c *Config) GetProviderConfig(provider, endpoint string) (models.ProviderCo
 	return config, exists
 }
 
+// embeddingEndpoints is the order in which endpoints are searched for an embedding provider;
+// chat_completions comes first since its providers are OpenAI-compatible
+var embeddingEndpoints = []string{"chat_completions", "select_model", "messages", "generate", "count_tokens"}
+
+// ResolveEmbedding returns the embedding source of a semantic cache with provider references
+// and the legacy openai_api_key/embedding_model fields resolved
+func (c *Config) ResolveEmbedding(cacheConfig models.CacheConfig) (models.EmbeddingConfig, error) {
+	var embedding models.EmbeddingConfig
+	if cacheConfig.Embedding != nil {
+		embedding = *cacheConfig.Embedding
+	}
+	if embedding.Type == "" {
+		embedding.Type = models.EmbeddingProviderOpenAI
+	}
+	if embedding.Model == "" {
+		embedding.Model = cacheConfig.EmbeddingModel
+	}
+	if embedding.Dimensions < 0 {
+		return embedding, fmt.Errorf("embedding dimensions must not be negative")
+	}
+
+	switch embedding.Type {
+	case models.EmbeddingProviderOpenAI:
+		if embedding.APIKey == "" {
+			embedding.APIKey = cacheConfig.OpenAIAPIKey
+		}
+		if embedding.APIKey == "" {
+			return embedding, fmt.Errorf("OpenAI API key not set in cache configuration")
+		}
+	case models.EmbeddingProviderOpenAICompatible:
+		if embedding.BaseURL == "" {
+			return embedding, fmt.Errorf("base_url is required for openai_compatible embeddings")
+		}
+		if embedding.Model == "" {
+			return embedding, fmt.Errorf("model is required for openai_compatible embeddings")
+		}
+	case models.EmbeddingProviderConfigured:
+		if embedding.Provider == "" {
+			return embedding, fmt.Errorf("provider is required for provider embeddings")
+		}
+		if embedding.Model == "" {
+			return embedding, fmt.Errorf("model is required for provider embeddings")
+		}
+		providerConfig, found := c.findProviderConfig(embedding.Provider)
+		if !found {
+			return embedding, fmt.Errorf("embedding provider %q is not configured on any endpoint", embedding.Provider)
+		}
+		if embedding.APIKey == "" {
+			embedding.APIKey = providerConfig.APIKey
+		}
+		if embedding.BaseURL == "" {
+			embedding.BaseURL = providerConfig.BaseURL
+		}
+	case models.EmbeddingProviderLocal:
+	default:
+		return embedding, fmt.Errorf("unsupported embedding type: %s (supported: openai, openai_compatible, provider, local)", embedding.Type)
+	}
+	return embedding, nil
+}
+
+// findProviderConfig returns the configuration of a provider from the first endpoint that has it
+func (c *Config) findProviderConfig(provider string) (models.ProviderConfig, bool) {
+	for _, endpoint := range embeddingEndpoints {
+		if providerConfig, exists := c.GetProviderConfig(provider, endpoint); exists {
+			return providerConfig, true
+		}
+	}
+	return models.ProviderConfig{}, false
+}
+
 // GetNormalizedLogLevel returns the log level in lowercase for consistent comparison
 func (c *Config) GetNormalizedLogLevel() string {
 	return strings.ToLower(c.Server.LogLevel)
@@ -265,7 +459,7 @@ func cloneStringStringMap(src map[string]string) map[string]string {
 // The request override takes precedence over YAML config for non-empty values.
 func (c *Config) MergeProviderConfig(providerName string, override *models.ProviderConfig, endpoint string) (models.ProviderConfig, error) {
 	// Get base config from YAML
-	baseConfig, exists := c.GetProviderConfig(providerName, endpoint)
+	baseConfig, exists := c.ServingProviderConfig(providerName, endpoint)
 	if !exists {
 		return models.ProviderConfig{}, fmt.Errorf("provider '%s' not found in YAML configuration for endpoint '%s'", providerName, endpoint)
 	}
@@ -286,6 +480,8 @@ func (c *Config) MergeProviderConfig(providerName string, override *models.Provi
 		TimeoutMs:      baseConfig.TimeoutMs,
 		RetryConfig:    cloneStringAnyMap(baseConfig.RetryConfig),
 		Headers:        cloneStringStringMap(baseConfig.Headers),
+		Protocol:       baseConfig.Protocol,
+		Models:         baseConfig.Models,
 	}
 
 	// Override non-empty values from request
@@ -324,17 +520,23 @@ func (c *Config) MergeProviderConfig(providerName string, override *models.Provi
 		}
 		maps.Copy(merged.Headers, override.Headers)
 	}
+	if override.Protocol != "" {
+		merged.Protocol = override.Protocol
+	}
+	if len(override.Models) > 0 {
+		merged.Models = override.Models
+	}
 
 	return merged, nil
 }
 
 // MergeProviderConfigs merges YAML provider configs with a map of request override configs.
-// Returns a map with all providers from YAML, with overrides applied where provided.
+// Returns a map with all providers serving the endpoint, with overrides applied where provided.
 func (c *Config) MergeProviderConfigs(overrides map[string]*models.ProviderConfig, endpoint string) (map[string]models.ProviderConfig, error) {
 	merged := make(map[string]models.ProviderConfig)
 
-	// Get the base providers for the specified endpoint
-	baseProviders := c.GetProviders(endpoint)
+	// Get the base providers for the specified endpoint, including other endpoints' providers
+	baseProviders := c.ServingProviders(endpoint)
 	if baseProviders == nil {
 		return nil, fmt.Errorf("unsupported endpoint: %s", endpoint)
 	}
@@ -427,6 +629,11 @@ func (c *Config) MergeFallbackConfig(override *models.FallbackConfig) *models.Fa
 		TimeoutMs:      c.Fallback.TimeoutMs,
 		MaxRetries:     c.Fallback.MaxRetries,
 		CircuitBreaker: c.Fallback.CircuitBreaker,
+		HedgeDelayMs:   c.Fallback.HedgeDelayMs,
+
+		MidStreamRecovery: c.Fallback.MidStreamRecovery,
+		// Not overridable: it bounds how long a request can hold its handler
+		MaxRetryDelayMs: c.Fallback.MaxRetryDelayMs,
 	}
 
 	// If no override provided, return YAML config
@@ -447,10 +654,31 @@ func (c *Config) MergeFallbackConfig(override *models.FallbackConfig) *models.Fa
 	if override.CircuitBreaker != nil {
 		merged.CircuitBreaker = override.CircuitBreaker
 	}
+	if override.HedgeDelayMs > 0 {
+		merged.HedgeDelayMs = override.HedgeDelayMs
+	}
+	if override.MidStreamRecovery {
+		merged.MidStreamRecovery = true
+	}
 
 	return merged
 }
 
+// ModelListFallback returns the request fallback override for an explicit model list. A client
+// listing models asked for fallback across them, so the list falls back sequentially when
+// neither the request nor YAML config sets a mode.
+func (c *Config) ModelListFallback(override *models.FallbackConfig) *models.FallbackConfig {
+	if c.MergeFallbackConfig(override).Mode != "" {
+		return override
+	}
+	applied := models.FallbackConfig{}
+	if override != nil {
+		applied = *override
+	}
+	applied.Mode = models.FallbackModeSequential
+	return &applied
+}
+
 // ResolveConfig creates a resolved config by merging YAML config with all request overrides.
 // Returns a new Config struct with all merged values as single source of truth.
 func (c *Config) ResolveConfig(req *models.ChatCompletionRequest) (*Config, error) {
@@ -546,17 +774,23 @@ func (c *Config) ResolveConfigFromGeminiCountTokensRequest(req *models.GeminiGen
 	return resolved, nil
 }
 
-// GetModelCapabilitiesFromEndpoint converts endpoint providers to ModelCapability list
-// This allows constraining model router to only available providers for the endpoint
+// GetModelCapabilitiesFromEndpoint converts the providers serving an endpoint to ModelCapability list
+// This allows constraining model router to only available providers for the endpoint.
+// Providers with a model catalog contribute their catalog models; providers without one
+// contribute a provider-only entry.
 func (c *Config) GetModelCapabilitiesFromEndpoint(endpoint string) []models.ModelCapability {
 	var capabilities []models.ModelCapability
 
-	providers := c.GetProviders(endpoint)
+	providers := c.ServingProviders(endpoint)
 	if providers == nil {
 		return capabilities
 	}
 
-	for providerName := range providers {
+	for _, providerName := range slices.Sorted(maps.Keys(providers)) {
+		if catalog := providers[providerName].Models; len(catalog) > 0 {
+			capabilities = append(capabilities, catalog...)
+			continue
+		}
 		// Create a basic ModelCapability with only the provider field set
 		// The AI service will use the provider field to constrain routing
 		capability := models.ModelCapability{
@@ -568,6 +802,55 @@ func (c *Config) GetModelCapabilitiesFromEndpoint(endpoint string) []models.Mode
 	return capabilities
 }
 
+// GetModelCatalog returns the catalog models of an endpoint, ordered by provider
+func (c *Config) GetModelCatalog(endpoint string) []models.ModelCapability {
+	var catalog []models.ModelCapability
+	providers := c.GetProviders(endpoint)
+	for _, providerName := range slices.Sorted(maps.Keys(providers)) {
+		catalog = append(catalog, providers[providerName].Models...)
+	}
+	return catalog
+}
+
+// ModelCatalog returns every catalog model across all endpoints. A model listed under several
+// endpoints is returned once, with the entry of the first endpoint that lists it.
+func (c *Config) ModelCatalog() []models.ModelCapability {
+	var catalog []models.ModelCapability
+	seen := make(map[string]bool)
+	for _, endpoint := range catalogEndpoints {
+		for _, model := range c.GetModelCatalog(endpoint) {
+			id := model.Provider + ":" + model.ModelName
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			catalog = append(catalog, model)
+		}
+	}
+	return catalog
+}
+
+// FindCatalogModel looks up a catalog model by "provider:model" or by model name alone.
+// A bare model name only matches when exactly one provider serves it.
+func (c *Config) FindCatalogModel(id string) (models.ModelCapability, bool) {
+	provider, modelName, err := utils.ParseProviderModel(id)
+	var match models.ModelCapability
+	matches := 0
+	for _, model := range c.ModelCatalog() {
+		if err == nil {
+			if model.Provider == strings.ToLower(provider) && model.ModelName == modelName {
+				return model, true
+			}
+			continue
+		}
+		if model.ModelName == id {
+			match = model
+			matches++
+		}
+	}
+	return match, matches == 1
+}
+
 // ValidationError represents configuration validation errors
 type ValidationError struct {
 	MissingFields []string
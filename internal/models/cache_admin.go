@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// CacheStats reports the contents of a semantic cache and its hit rates since startup.
+// Lookup counts are per proxy instance; entries are counted in the backend.
+type CacheStats struct {
+	Name         string `json:"name"`
+	Backend      string `json:"backend"`
+	Entries      int    `json:"entries"`
+	Lookups      int64  `json:"lookups"`
+	ExactHits    int64  `json:"exact_hits"`
+	SemanticHits int64  `json:"semantic_hits"`
+	Misses       int64  `json:"misses"`
+	// Rates are fractions of all lookups
+	ExactHitRate    float64 `json:"exact_hit_rate"`
+	SemanticHitRate float64 `json:"semantic_hit_rate"`
+	// AverageSimilarity is the mean score of semantic hits
+	AverageSimilarity      float64 `json:"average_similarity"`
+	AverageLookupLatencyMs float64 `json:"average_lookup_latency_ms"`
+}
+
+// CacheStatsResponse lists the statistics of every enabled cache
+type CacheStatsResponse struct {
+	Caches []CacheStats `json:"caches"`
+}
+
+// CacheEntry is a router cache entry as shown by the admin API
+type CacheEntry struct {
+	Key          string        `json:"key"`
+	Namespace    string        `json:"namespace,omitzero"`
+	Provider     string        `json:"provider"`
+	Model        string        `json:"model"`
+	Alternatives []Alternative `json:"alternatives,omitzero"`
+	CachedAt     time.Time     `json:"cached_at,omitzero"`
+	// Score is the similarity to the searched prompt
+	Score float32 `json:"score,omitzero"`
+	// Stale explains why a dry-run lookup would not serve the entry: "generation", "expired" or "unconfigured"
+	Stale string `json:"stale,omitzero"`
+}
+
+// CacheSearchRequest finds the router cache entries most similar to a prompt
+type CacheSearchRequest struct {
+	Prompt string `json:"prompt"`
+	// Namespace limits results to one namespace; empty searches all namespaces
+	Namespace string `json:"namespace,omitzero"`
+	Limit     int    `json:"limit,omitzero"`
+}
+
+// CacheSearchResponse lists router cache entries by descending similarity
+type CacheSearchResponse struct {
+	Entries []CacheEntry `json:"entries"`
+}
+
+// CacheLookupRequest runs a router cache lookup without serving or recording it
+type CacheLookupRequest struct {
+	Prompt    string `json:"prompt"`
+	Namespace string `json:"namespace,omitzero"`
+	// Threshold defaults to the configured semantic threshold
+	Threshold float32 `json:"threshold,omitzero"`
+}
+
+// CacheLookupResponse reports what a lookup would return and the closest entries it considered
+type CacheLookupResponse struct {
+	Hit       bool         `json:"hit"`
+	CacheTier string       `json:"cache_tier,omitzero"`
+	Threshold float32      `json:"threshold"`
+	Entry     *CacheEntry  `json:"entry,omitzero"`
+	Matches   []CacheEntry `json:"matches"`
+}
+
+// CacheFlushRequest selects router cache entries to delete. Filters combine; All is required to
+// delete everything.
+type CacheFlushRequest struct {
+	// Namespace matches a full namespace or a tenant prefix such as "org:acme"
+	Namespace string `json:"namespace,omitzero"`
+	// Provider and Model match the primary selection or any alternative
+	Provider string `json:"provider,omitzero"`
+	Model    string `json:"model,omitzero"`
+	All      bool   `json:"all,omitzero"`
+}
+
+// CacheFlushResponse reports how many entries were deleted
+type CacheFlushResponse struct {
+	Deleted int `json:"deleted"`
+}
@@ -1,23 +1,99 @@
 package models
 
+import "time"
+
 // CacheBackendType represents the type of cache backend to use
 type CacheBackendType string
 
 const (
 	CacheBackendRedis  CacheBackendType = "redis"
 	CacheBackendMemory CacheBackendType = "memory"
+	// CacheBackendSQLite persists entries in a SQLite file, for single-node deployments without Redis
+	CacheBackendSQLite CacheBackendType = "sqlite"
+)
+
+// CacheScope controls which requests share router cache entries
+type CacheScope string
+
+const (
+	// CacheScopeShared shares entries between all callers
+	CacheScopeShared CacheScope = "shared"
+	// CacheScopeOrganization isolates entries per organization, or per API key without one (default)
+	CacheScopeOrganization CacheScope = "organization"
+	// CacheScopeProject isolates entries per project, falling back to the organization scope
+	CacheScopeProject CacheScope = "project"
+	// CacheScopeAPIKey isolates entries per API key
+	CacheScopeAPIKey CacheScope = "api_key"
+)
+
+// EmbeddingProviderType represents where semantic cache embeddings are computed
+type EmbeddingProviderType string
+
+const (
+	// EmbeddingProviderOpenAI uses the OpenAI embeddings API (default)
+	EmbeddingProviderOpenAI EmbeddingProviderType = "openai"
+	// EmbeddingProviderOpenAICompatible uses any OpenAI-compatible embeddings server (vLLM, Ollama, TEI)
+	EmbeddingProviderOpenAICompatible EmbeddingProviderType = "openai_compatible"
+	// EmbeddingProviderConfigured uses the embeddings endpoint of a configured provider
+	EmbeddingProviderConfigured EmbeddingProviderType = "provider"
+	// EmbeddingProviderLocal uses a deterministic in-process embedder that needs no network (for tests)
+	EmbeddingProviderLocal EmbeddingProviderType = "local"
 )
 
 // CacheConfig holds configuration for model router caching
 type CacheConfig struct {
 	// Backend configuration
-	Backend  CacheBackendType `json:"backend,omitzero" yaml:"backend"`     // "redis" or "memory"
-	RedisURL string           `json:"redis_url,omitzero" yaml:"redis_url"` // Required if backend is "redis"
-	Capacity int              `json:"capacity,omitzero" yaml:"capacity"`   // Required if backend is "memory" (LRU cache size)
+	Backend    CacheBackendType `json:"backend,omitzero" yaml:"backend"`         // "redis", "memory" or "sqlite"
+	RedisURL   string           `json:"redis_url,omitzero" yaml:"redis_url"`     // Required if backend is "redis"
+	SQLitePath string           `json:"sqlite_path,omitzero" yaml:"sqlite_path"` // Required if backend is "sqlite"
+	Capacity   int              `json:"capacity,omitzero" yaml:"capacity"`       // Maximum entries for "memory" and "sqlite" (LRU eviction)
 
 	// Cache behavior
 	Enabled           bool    `json:"enabled,omitzero" yaml:"enabled"`
 	SemanticThreshold float64 `json:"semantic_threshold,omitzero" yaml:"semantic_threshold"`
 	OpenAIAPIKey      string  `json:"openai_api_key,omitzero" yaml:"openai_api_key"`
 	EmbeddingModel    string  `json:"embedding_model,omitzero" yaml:"embedding_model"`
+	// Scope isolates router cache entries between tenants; it is only read from YAML config
+	Scope CacheScope `json:"scope,omitzero" yaml:"scope"`
+
+	// ExactTTLSeconds is how long an entry is served for identical prompts; zero keeps entries
+	// until the backend evicts them
+	ExactTTLSeconds int `json:"exact_ttl_seconds,omitzero" yaml:"exact_ttl_seconds"`
+	// SemanticTTLSeconds is how long an entry is served for similar prompts; defaults to ExactTTLSeconds
+	SemanticTTLSeconds int `json:"semantic_ttl_seconds,omitzero" yaml:"semantic_ttl_seconds"`
+	// Version is mixed into the router cache generation; changing it retires all cached selections
+	Version string `json:"version,omitzero" yaml:"version"`
+
+	// Embedding selects the embedding source; when unset, OpenAI is used with OpenAIAPIKey
+	Embedding *EmbeddingConfig `json:"embedding,omitzero" yaml:"embedding,omitempty"`
+}
+
+// TierTTLs returns how long entries are served on exact and on semantic hits; zero means no expiry
+func (c CacheConfig) TierTTLs() (exact, semantic time.Duration) {
+	exact = time.Duration(c.ExactTTLSeconds) * time.Second
+	semantic = exact
+	if c.SemanticTTLSeconds > 0 {
+		semantic = time.Duration(c.SemanticTTLSeconds) * time.Second
+	}
+	return exact, semantic
+}
+
+// Expired reports whether an entry cached at cachedAt has outlived ttl. Entries without a
+// timestamp predate TTLs and are treated as expired once a TTL is configured.
+func Expired(cachedAt time.Time, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(cachedAt) > ttl
+}
+
+// EmbeddingConfig configures the embedding source of a semantic cache
+type EmbeddingConfig struct {
+	Type EmbeddingProviderType `json:"type,omitzero" yaml:"type"` // "openai", "openai_compatible", "provider" or "local"
+	// BaseURL is the embeddings server for openai_compatible, e.g. http://localhost:11434/v1
+	BaseURL string `json:"base_url,omitzero" yaml:"base_url"`
+	APIKey  string `json:"api_key,omitzero" yaml:"api_key"`
+	// Provider names a configured provider whose base URL and API key are used, for type provider
+	Provider string `json:"provider,omitzero" yaml:"provider"`
+	Model    string `json:"model,omitzero" yaml:"model"`
+	// Dimensions is the embedding size, sent as the dimensions parameter when set and
+	// otherwise detected at startup
+	Dimensions int `json:"dimensions,omitzero" yaml:"dimensions"`
 }
@@ -193,8 +193,11 @@ type ChatCompletionRequest struct {
 	WebSearchOptions  openai.ChatCompletionNewParamsWebSearchOptions `json:"web_search_options,omitzero"`
 	Stream            bool                                           `json:"stream,omitzero"` // Whether to stream the response or not
 	ModelRouterConfig *ModelRouterConfig                             `json:"model_router,omitzero"`
+	Models            []string                                       `json:"models,omitzero"`           // Fallback list of "provider:model" entries tried after Model, in order
 	Fallback          *FallbackConfig                                `json:"fallback,omitzero"`         // Fallback configuration with enabled toggle
 	ProviderConfigs   map[string]*ProviderConfig                     `json:"provider_configs,omitzero"` // Custom provider configurations by provider name
+	IncludeRouting    bool                                           `json:"include_routing,omitzero"`  // Return the routing trace in the response body
+	CacheResponse     *bool                                          `json:"cache_response,omitzero"`   // Force (true) or skip (false) the response cache
 }
 
 // AdaptiveUsage extends OpenAI's CompletionUsage with cache tier information
@@ -250,6 +253,7 @@ type ChatCompletion struct {
 	ServiceTier openai.ChatCompletionServiceTier `json:"service_tier,omitzero"`
 	Usage       AdaptiveUsage                    `json:"usage"`
 	Provider    string                           `json:"provider,omitzero"`
+	Routing     *RoutingTrace                    `json:"routing,omitzero"` // Only set when the request has include_routing
 }
 
 // ChatCompletionChunk extends OpenAI's ChatCompletionChunk with enhanced usage
@@ -3,6 +3,7 @@ package models
 // EndpointConfig holds endpoint-specific provider configurations
 type EndpointConfig struct {
 	Providers map[string]ProviderConfig `yaml:"providers"`
+	Shadow    *ShadowConfig             `yaml:"shadow,omitempty"`
 }
 
 // EndpointsConfig holds all endpoint configurations
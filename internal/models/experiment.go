@@ -0,0 +1,37 @@
+package models
+
+// Experiment sticky assignment subjects
+const (
+	ExperimentStickyUser   = "user"
+	ExperimentStickyAPIKey = "api_key"
+)
+
+// Experiment splits traffic between models by weight for a named A/B test.
+// Assignment is sticky: the same user (or API key) always lands in the same arm.
+type Experiment struct {
+	Name string `json:"name" yaml:"name"`
+	// Model is the requested model (or alias) the experiment applies to; empty applies to routed requests without a model
+	Model string `json:"model,omitzero" yaml:"model,omitempty"`
+	// Endpoints limits the experiment to these endpoints (chat_completions, messages, generate, select_model); empty = all
+	Endpoints []string `json:"endpoints,omitzero" yaml:"endpoints,omitempty"`
+	// ProjectIDs limits the experiment to these projects; empty = all
+	ProjectIDs []uint `json:"project_ids,omitzero" yaml:"project_ids,omitempty"`
+	// StickyBy selects the assignment subject: "user" (falls back to the API key) or "api_key"
+	StickyBy string          `json:"sticky_by,omitzero" yaml:"sticky_by,omitempty"`
+	Arms     []ExperimentArm `json:"arms" yaml:"arms"`
+}
+
+// ExperimentArm is one variant of an experiment
+type ExperimentArm struct {
+	Name string `json:"name" yaml:"name"`
+	// Model is a "provider:model" or a model alias
+	Model  string `json:"model" yaml:"model"`
+	Weight int    `json:"weight" yaml:"weight"`
+}
+
+// ExperimentAssignment records which arm a request was assigned to
+type ExperimentAssignment struct {
+	Experiment string `json:"experiment"`
+	Arm        string `json:"arm"`
+	Model      string `json:"model"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,6 +20,9 @@ type FallbackMode string
 const (
 	FallbackModeSequential FallbackMode = "sequential"
 	FallbackModeRace       FallbackMode = "race"
+	// FallbackModeHedge starts the primary alone and adds the next provider only when no
+	// response has arrived within the hedge delay
+	FallbackModeHedge FallbackMode = "hedge"
 )
 
 // CircuitBreakerConfig holds circuit breaker configuration
@@ -32,15 +36,43 @@ type CircuitBreakerConfig struct {
 // FallbackConfig holds the fallback configuration
 // Fallback is enabled when Mode is non-empty, disabled when Mode is empty
 type FallbackConfig struct {
-	Mode           FallbackMode          `json:"mode,omitzero" yaml:"mode,omitempty"`                       // Fallback mode (sequential/race). Empty = disabled, non-empty = enabled
+	Mode           FallbackMode          `json:"mode,omitzero" yaml:"mode,omitempty"`                       // Fallback mode (sequential/race/hedge). Empty = disabled, non-empty = enabled
 	TimeoutMs      int                   `json:"timeout_ms,omitzero" yaml:"timeout_ms,omitempty"`           // Timeout in milliseconds
-	MaxRetries     int                   `json:"max_retries,omitzero" yaml:"max_retries,omitempty"`         // Maximum number of retries
+	MaxRetries     int                   `json:"max_retries,omitzero" yaml:"max_retries,omitempty"`         // Retries per provider before falling back
 	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitzero" yaml:"circuit_breaker,omitempty"` // Circuit breaker configuration
+	HedgeDelayMs   int                   `json:"hedge_delay_ms,omitzero" yaml:"hedge_delay_ms,omitempty"`   // Hedge mode: wait before adding the next provider. 0 = the model's observed p95
+	// MaxRetryDelayMs caps every wait before a retry, including a provider's Retry-After, since
+	// the handler is held for the whole wait. 0 = 30s. Only read from YAML config.
+	MaxRetryDelayMs int `json:"max_retry_delay_ms,omitzero" yaml:"max_retry_delay_ms,omitempty"`
+	// MidStreamRecovery continues a stream that fails after output was sent on the next
+	// alternative, which picks up from the partial output
+	MidStreamRecovery bool `json:"mid_stream_recovery,omitzero" yaml:"mid_stream_recovery,omitempty"`
 }
 
 // ExecutionFunc is the function signature for executing a completion with a specific provider
 type ExecutionFunc func(c *fiber.Ctx, provider Alternative, requestID string) error
 
+// StreamOpenFunc opens a provider's stream and reads it up to its first content chunk without
+// touching the client response, so a streaming race can open every provider at once.
+// Cancelling ctx aborts the upstream request.
+type StreamOpenFunc func(ctx context.Context, provider Alternative, requestID string) (PendingStream, error)
+
+// StreamContinueFunc opens a provider's continuation of a stream that failed after partial, the
+// assistant text already sent to the client, like StreamOpenFunc does for the original request
+type StreamContinueFunc func(ctx context.Context, provider Alternative, partial string, requestID string) (PendingStream, error)
+
+// MidStreamContinuePrompt asks a provider that cannot prefill its reply to continue the partial
+// reply instead of starting over
+const MidStreamContinuePrompt = "Your previous reply was cut off. Continue it from exactly where it stopped, without repeating any of it or commenting on the interruption."
+
+// PendingStream is an opened provider stream that has not been sent to the client yet
+type PendingStream interface {
+	// Commit sends the stream to the client
+	Commit(c *fiber.Ctx) error
+	// Abort closes the upstream stream without sending it
+	Abort()
+}
+
 // FallbackResult represents the result of a provider execution attempt
 type FallbackResult struct {
 	Success  bool
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Thumbs values accepted by the feedback endpoint
+const (
+	ThumbsUp   = "up"
+	ThumbsDown = "down"
+)
+
+// Task outcomes accepted by the feedback endpoint
+const (
+	OutcomeSuccess = "success"
+	OutcomePartial = "partial"
+	OutcomeFailure = "failure"
+)
+
+// FeedbackConfig controls what happens with feedback submitted to /v1/feedback
+type FeedbackConfig struct {
+	// InvalidateCache deletes the semantic cache entry that served a request receiving negative feedback (default true)
+	InvalidateCache *bool `yaml:"invalidate_cache,omitempty" json:"invalidate_cache,omitzero"`
+	// ForwardToRouter sends feedback in batches to the adaptive_router service as training signal
+	ForwardToRouter bool `yaml:"forward_to_router,omitempty" json:"forward_to_router,omitzero"`
+	// BatchSize is the number of feedback entries sent per batch (default 50)
+	BatchSize int `yaml:"batch_size,omitempty" json:"batch_size,omitzero"`
+	// FlushIntervalMs flushes a partial batch after this long (default 10s)
+	FlushIntervalMs int `yaml:"flush_interval_ms,omitempty" json:"flush_interval_ms,omitzero"`
+}
+
+// FeedbackRequest is the body of POST /v1/feedback. At least one of rating, thumbs or outcome is required.
+type FeedbackRequest struct {
+	RequestID string `json:"request_id"`
+	// Rating from 1 (worst) to 5 (best)
+	Rating  *int   `json:"rating,omitzero"`
+	Thumbs  string `json:"thumbs,omitzero"`  // up, down
+	Outcome string `json:"outcome,omitzero"` // success, partial, failure
+	Comment string `json:"comment,omitzero"`
+}
+
+// IsNegative reports whether the feedback signals a bad routing decision
+func (r *FeedbackRequest) IsNegative() bool {
+	return r.Thumbs == ThumbsDown || r.Outcome == OutcomeFailure || (r.Rating != nil && *r.Rating <= 2)
+}
+
+// RoutingFeedback stores feedback next to the usage row of the request it rates
+type RoutingFeedback struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RequestID        string    `gorm:"index" json:"request_id"`
+	UsageID          uint      `gorm:"index" json:"usage_id"`
+	APIKeyID         uint      `gorm:"index" json:"api_key_id"`
+	Endpoint         string    `json:"endpoint"`
+	Provider         string    `gorm:"index" json:"provider"`
+	Model            string    `gorm:"index" json:"model"`
+	Rating           *int      `json:"rating,omitempty"`
+	Thumbs           string    `json:"thumbs,omitempty"`
+	Outcome          string    `json:"outcome,omitempty"`
+	Comment          string    `json:"comment,omitempty"`
+	Negative         bool      `json:"negative"`
+	CacheInvalidated bool      `json:"cache_invalidated"`
+	CreatedAt        time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func (RoutingFeedback) TableName() string {
+	return "routing_feedback"
+}
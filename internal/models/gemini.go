@@ -20,8 +20,10 @@ type GeminiGenerateRequest struct {
 
 	// Custom fields for our internal processing
 	ModelRouterConfig *ModelRouterConfig         `json:"model_router,omitzero"`
+	Models            []string                   `json:"models,omitzero"` // Fallback list of "provider:model" entries tried after Model, in order
 	Fallback          *FallbackConfig            `json:"fallback,omitzero"`
 	ProviderConfigs   map[string]*ProviderConfig `json:"provider_configs,omitzero"`
+	CacheResponse     *bool                      `json:"cache_response,omitzero"` // Force (true) or skip (false) the response cache
 }
 
 // AdaptiveGeminiUsage extends genai.UsageMetadata with cache tier information
@@ -25,8 +25,10 @@ type AnthropicMessageRequest struct {
 
 	// Custom fields for our internal processing
 	ModelRouterConfig *ModelRouterConfig         `json:"model_router,omitzero"`
+	Models            []string                   `json:"models,omitzero"`           // Fallback list of "provider:model" entries tried after Model, in order
 	Fallback          *FallbackConfig            `json:"fallback,omitzero"`         // Fallback configuration with enabled toggle
 	ProviderConfigs   map[string]*ProviderConfig `json:"provider_configs,omitzero"` // Custom provider configurations by provider name
+	CacheResponse     *bool                      `json:"cache_response,omitzero"`   // Force (true) or skip (false) the response cache
 }
 
 // AdaptiveAnthropicUsage extends Anthropic's Usage with cache tier information
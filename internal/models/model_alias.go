@@ -0,0 +1,26 @@
+package models
+
+// ModelAlias maps a virtual model name (e.g. "fast") to an ordered chain of "provider:model" targets.
+// The first target is the primary; the rest are tried in order as alternatives.
+type ModelAlias struct {
+	Models []string `json:"models" yaml:"models"`
+	// FallbackMode used for the chain when the request does not set one (default: sequential)
+	FallbackMode FallbackMode `json:"fallback_mode,omitzero" yaml:"fallback_mode,omitempty"`
+}
+
+// ApplyFallback returns the request fallback config with the alias's fallback mode applied
+// when the request does not set a mode itself
+func (a *ModelAlias) ApplyFallback(fallback *FallbackConfig) *FallbackConfig {
+	if a == nil || (fallback != nil && fallback.Mode != "") {
+		return fallback
+	}
+	applied := FallbackConfig{}
+	if fallback != nil {
+		applied = *fallback
+	}
+	applied.Mode = a.FallbackMode
+	if applied.Mode == "" {
+		applied.Mode = FallbackModeSequential
+	}
+	return &applied
+}
@@ -1,18 +1,57 @@
 package models
 
+// Request features that need explicit model support
+const (
+	FeatureVision            = "vision"
+	FeatureAudio             = "audio"
+	FeatureStructuredOutputs = "structured_outputs"
+	FeatureReasoning         = "reasoning"
+	FeatureWebSearch         = "web_search"
+	FeaturePromptCaching     = "prompt_caching"
+)
+
 // ModelCapability represents a model with its capabilities and constraints
 type ModelCapability struct {
-	Description           string   `json:"description,omitzero"`
-	Provider              string   `json:"provider,omitzero"`
-	ModelName             string   `json:"model_name,omitzero"`
-	CostPer1MInputTokens  float64  `json:"cost_per_1m_input_tokens,omitzero"`
-	CostPer1MOutputTokens float64  `json:"cost_per_1m_output_tokens,omitzero"`
-	MaxContextTokens      int      `json:"max_context_tokens,omitzero"`
-	MaxOutputTokens       int      `json:"max_output_tokens,omitzero"`
-	SupportsToolCalling   bool     `json:"supports_tool_calling,omitzero"`
-	LanguagesSupported    []string `json:"languages_supported,omitzero"`
-	ModelSizeParams       string   `json:"model_size_params,omitzero"`
-	LatencyTier           string   `json:"latency_tier,omitzero"`
-	TaskType              string   `json:"task_type,omitzero"`
-	Complexity            string   `json:"complexity,omitzero"`
+	Description           string   `yaml:"description" json:"description,omitzero"`
+	Provider              string   `yaml:"provider" json:"provider,omitzero"`
+	ModelName             string   `yaml:"model_name" json:"model_name,omitzero"`
+	CostPer1MInputTokens  float64  `yaml:"cost_per_1m_input_tokens" json:"cost_per_1m_input_tokens,omitzero"`
+	CostPer1MOutputTokens float64  `yaml:"cost_per_1m_output_tokens" json:"cost_per_1m_output_tokens,omitzero"`
+	MaxContextTokens      int      `yaml:"max_context_tokens" json:"max_context_tokens,omitzero"`
+	MaxOutputTokens       int      `yaml:"max_output_tokens" json:"max_output_tokens,omitzero"`
+	SupportsToolCalling   bool     `yaml:"supports_tool_calling" json:"supports_tool_calling,omitzero"`
+	LanguagesSupported    []string `yaml:"languages_supported" json:"languages_supported,omitzero"`
+	ModelSizeParams       string   `yaml:"model_size_params" json:"model_size_params,omitzero"`
+	LatencyTier           string   `yaml:"latency_tier" json:"latency_tier,omitzero"`
+	TaskType              string   `yaml:"task_type" json:"task_type,omitzero"`
+	Complexity            string   `yaml:"complexity" json:"complexity,omitzero"`
+
+	// Feature support flags. Unset flags are treated as supported, so models without
+	// capability metadata are never filtered out.
+	SupportsVision            *bool `yaml:"supports_vision" json:"supports_vision,omitzero"`
+	SupportsAudio             *bool `yaml:"supports_audio" json:"supports_audio,omitzero"`
+	SupportsStructuredOutputs *bool `yaml:"supports_structured_outputs" json:"supports_structured_outputs,omitzero"`
+	SupportsReasoning         *bool `yaml:"supports_reasoning" json:"supports_reasoning,omitzero"`
+	SupportsWebSearch         *bool `yaml:"supports_web_search" json:"supports_web_search,omitzero"`
+	SupportsPromptCaching     *bool `yaml:"supports_prompt_caching" json:"supports_prompt_caching,omitzero"`
+}
+
+// Supports reports whether the model can serve a request needing the feature
+func (m ModelCapability) Supports(feature string) bool {
+	var flag *bool
+	switch feature {
+	case FeatureVision:
+		flag = m.SupportsVision
+	case FeatureAudio:
+		flag = m.SupportsAudio
+	case FeatureStructuredOutputs:
+		flag = m.SupportsStructuredOutputs
+	case FeatureReasoning:
+		flag = m.SupportsReasoning
+	case FeatureWebSearch:
+		flag = m.SupportsWebSearch
+	case FeaturePromptCaching:
+		flag = m.SupportsPromptCaching
+	}
+	return flag == nil || *flag
 }
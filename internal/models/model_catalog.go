@@ -0,0 +1,33 @@
+package models
+
+// CatalogModel is a catalog entry in the OpenAI model object format, extended with the
+// model's capabilities and pricing
+type CatalogModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+	ModelCapability
+}
+
+// CatalogModelList is the OpenAI list models response
+type CatalogModelList struct {
+	Object string         `json:"object"`
+	Data   []CatalogModel `json:"data"`
+}
+
+// GeminiCatalogModel is a catalog entry in the Gemini model format
+type GeminiCatalogModel struct {
+	Name                       string   `json:"name"`
+	BaseModelID                string   `json:"baseModelId"`
+	DisplayName                string   `json:"displayName"`
+	Description                string   `json:"description,omitzero"`
+	InputTokenLimit            int      `json:"inputTokenLimit,omitzero"`
+	OutputTokenLimit           int      `json:"outputTokenLimit,omitzero"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+// GeminiCatalogModelList is the Gemini list models response
+type GeminiCatalogModelList struct {
+	Models []GeminiCatalogModel `json:"models"`
+}
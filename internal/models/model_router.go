@@ -1,16 +1,59 @@
 // Package models defines core types for model routing and selection.
 package models
 
+import "time"
+
 // ModelRouterConfig holds configuration for the model router
 type ModelRouterConfig struct {
 	Cache    CacheConfig             `json:"cache" yaml:"cache"`
 	Client   ModelRouterClientConfig `json:"client" yaml:"client"`
 	CostBias float32                 `json:"cost_bias,omitzero" yaml:"cost_bias"`
 	Models   []ModelCapability       `json:"models,omitzero"`
+	// PromptExtraction selects how a conversation is reduced to the prompt used for routing and caching
+	PromptExtraction PromptExtractionConfig `json:"-" yaml:"prompt_extraction,omitempty"`
+
+	// RestrictedBy names the routing rule that limited Models; selections outside Models are then rejected
+	RestrictedBy string `json:"-" yaml:"-"`
+}
+
+// ModelRouterMode selects which engine performs model selection
+type ModelRouterMode string
+
+const (
+	// ModelRouterModeRemote calls the adaptive_router service and falls back to the
+	// in-process heuristic router when the service is unavailable (default)
+	ModelRouterModeRemote ModelRouterMode = "remote"
+	// ModelRouterModeHeuristic only uses the in-process heuristic router
+	ModelRouterModeHeuristic ModelRouterMode = "heuristic"
+)
+
+// PromptStrategy selects which parts of a conversation make up the routing prompt
+type PromptStrategy string
+
+const (
+	// PromptStrategyLastMessage keeps each API's original extraction: the last message for chat
+	// completions, the last user text for messages and the user turns for generate (default)
+	PromptStrategyLastMessage PromptStrategy = "last_message"
+	// PromptStrategyLastUser uses the last user turn written by a person, skipping tool results
+	PromptStrategyLastUser PromptStrategy = "last_user"
+	// PromptStrategyRecentTurns uses the system prompt plus the last N turns
+	PromptStrategyRecentTurns PromptStrategy = "recent_turns"
+	// PromptStrategySummary uses a condensed summary of the whole conversation
+	PromptStrategySummary PromptStrategy = "summary"
+)
+
+// PromptExtractionConfig configures the routing prompt extraction
+type PromptExtractionConfig struct {
+	Strategy PromptStrategy `json:"strategy,omitzero" yaml:"strategy,omitempty"`
+	// Turns is the number of trailing turns used by recent_turns (default 4)
+	Turns int `json:"turns,omitzero" yaml:"turns,omitempty"`
+	// MaxChars caps the extracted prompt for recent_turns and summary (default 8000)
+	MaxChars int `json:"max_chars,omitzero" yaml:"max_chars,omitempty"`
 }
 
 // ModelRouterClientConfig holds client configuration for model router
 type ModelRouterClientConfig struct {
+	Mode              ModelRouterMode       `json:"mode,omitzero" yaml:"mode,omitempty"` // "remote" (default) or "heuristic"
 	AdaptiveRouterURL string                `json:"adaptive_router_url,omitzero" yaml:"adaptive_router_url"`
 	JWTSecret         string                `json:"jwt_secret,omitzero" yaml:"jwt_secret"`
 	TimeoutMs         int                   `json:"timeout_ms,omitzero" yaml:"timeout_ms"`
@@ -45,12 +88,22 @@ type SelectModelRequest struct {
 	Models []ModelCapability `json:"models"`
 	// The prompt text to analyze for optimal model selection
 	Prompt string `json:"prompt"`
+	// Optional model alias or provider:model; when set, it is resolved instead of running the router
+	Model string `json:"model,omitzero"`
 	// Optional user identifier for tracking and personalization
 	User *string `json:"user,omitzero"`
 	// Cost bias for model selection (0.0 = cheapest, 1.0 = best performance)
 	CostBias *float32 `json:"cost_bias,omitzero"`
 	// Model router cache configuration
 	ModelRouterCache *CacheConfig `json:"model_router_cache,omitzero"`
+	// Maximum output tokens the caller intends to request, used for context-window filtering
+	MaxOutputTokens int `json:"max_output_tokens,omitzero"`
+	// Features the selected model must support (vision, audio, structured_outputs, reasoning, web_search, prompt_caching)
+	Features []string `json:"features,omitzero"`
+	// Request metadata matched against routing_rules
+	Metadata map[string]string `json:"metadata,omitzero"`
+	// Return the routing trace in the response
+	IncludeRouting bool `json:"include_routing,omitzero"`
 
 	// Tool-related fields for function calling detection
 	ToolCall any `json:"tool_call,omitzero"` // Current tool call being made
@@ -66,6 +119,10 @@ type SelectModelResponse struct {
 	// Alternative provider/model combinations
 	Alternatives []Alternative `json:"alternatives,omitzero"`
 	CacheTier    string        `json:"cache_tier,omitzero"`
+	// Experiment arm the request was assigned to, if any
+	Experiment *ExperimentAssignment `json:"experiment,omitzero"`
+	// Routing trace, only set when the request has include_routing
+	Routing *RoutingTrace `json:"routing,omitzero"`
 }
 
 // ModelSelectionRequest represents a request for model selection.
@@ -82,6 +139,12 @@ type ModelSelectionRequest struct {
 	UserID   string            `json:"user_id,omitzero"`
 	Models   []ModelCapability `json:"models,omitzero"`
 	CostBias *float32          `json:"cost_bias,omitzero"`
+
+	// Conversation signals, so the router sees the whole task rather than only the prompt
+	TurnCount       int      `json:"turn_count,omitzero"`
+	EstimatedTokens int      `json:"estimated_tokens,omitzero"`
+	Modalities      []string `json:"modalities,omitzero"`
+	ToolCount       int      `json:"tool_count,omitzero"`
 }
 
 // Alternative represents a provider+model fallback candidate.
@@ -95,6 +158,14 @@ type ModelSelectionResponse struct {
 	Provider     string        `json:"provider"`
 	Model        string        `json:"model"`
 	Alternatives []Alternative `json:"alternatives,omitzero"`
+	// CacheKey is the key a cached selection was stored under, so semantic hits can be traced back to their entry
+	CacheKey string `json:"cache_key,omitzero"`
+	// CacheNamespace is the tenant and candidate set the selection was cached for
+	CacheNamespace string `json:"cache_namespace,omitzero"`
+	// CacheGeneration identifies the model catalog and router version the selection was cached under
+	CacheGeneration string `json:"cache_generation,omitzero"`
+	// CachedAt is when the selection was cached, for TTLs
+	CachedAt time.Time `json:"cached_at,omitzero"`
 }
 
 // IsValid validates that the ModelSelectionResponse has required fields
@@ -104,7 +175,63 @@ func (m *ModelSelectionResponse) IsValid() bool {
 
 // CacheResult represents the result of a cache lookup operation
 type CacheResult struct {
-	Response *ModelSelectionResponse `json:"response,omitzero"`
-	Source   string                  `json:"source,omitzero"`
-	Hit      bool                    `json:"hit"`
+	Response   *ModelSelectionResponse `json:"response,omitzero"`
+	Source     string                  `json:"source,omitzero"`
+	Similarity float32                 `json:"similarity,omitzero"`
+	Key        string                  `json:"key,omitzero"`
+	Hit        bool                    `json:"hit"`
+}
+
+// Content modalities reported in RequestProfile.Modalities
+const (
+	ModalityText     = "text"
+	ModalityImage    = "image"
+	ModalityAudio    = "audio"
+	ModalityVideo    = "video"
+	ModalityDocument = "document"
+)
+
+// RequestProfile describes the size and shape of an inbound request so the router can
+// drop candidate models that cannot hold it and route on the whole conversation.
+type RequestProfile struct {
+	// Estimated input tokens of the full request (messages, system prompt and tools)
+	EstimatedInputTokens int `json:"estimated_input_tokens,omitzero"`
+	// Maximum output tokens requested by the client (0 when unspecified)
+	MaxOutputTokens int `json:"max_output_tokens,omitzero"`
+	// Number of conversation turns, excluding the system prompt
+	TurnCount int `json:"turn_count,omitzero"`
+	// Content modalities present in the conversation (text, image, audio, video, document)
+	Modalities []string `json:"modalities,omitzero"`
+	// Number of tool definitions available to the model
+	ToolCount int `json:"tool_count,omitzero"`
+	// Features the serving model must support (vision, audio, structured_outputs, ...)
+	Features []string `json:"features,omitzero"`
+}
+
+// Fits reports whether a model with the given capability can serve a request of this profile.
+// Models without context or output limits configured are assumed to fit.
+func (p *RequestProfile) Fits(model ModelCapability) bool {
+	if p == nil {
+		return true
+	}
+	if model.MaxOutputTokens > 0 && p.MaxOutputTokens > model.MaxOutputTokens {
+		return false
+	}
+	if model.MaxContextTokens > 0 && p.EstimatedInputTokens+p.MaxOutputTokens > model.MaxContextTokens {
+		return false
+	}
+	return true
+}
+
+// UnsupportedFeature returns the first feature of this profile the model does not support, or "".
+func (p *RequestProfile) UnsupportedFeature(model ModelCapability) string {
+	if p == nil {
+		return ""
+	}
+	for _, feature := range p.Features {
+		if !model.Supports(feature) {
+			return feature
+		}
+	}
+	return ""
 }
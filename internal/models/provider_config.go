@@ -11,4 +11,22 @@ type ProviderConfig struct {
 	TimeoutMs      int               `yaml:"timeout_ms" json:"timeout_ms,omitzero"`             // Optional timeout in milliseconds
 	RetryConfig    map[string]any    `yaml:"retry_config" json:"retry_config,omitzero"`         // Retry configuration
 	Headers        map[string]string `yaml:"headers" json:"headers,omitzero"`                   // Optional custom headers
+	// Protocol is the API the provider speaks. It defaults to the protocol of the endpoint the
+	// provider is configured under; other protocols are served through format conversion.
+	Protocol ProviderProtocol `yaml:"protocol,omitempty" json:"protocol,omitzero"`
+	// Models is the catalog of models served by this provider. It is the default candidate set
+	// for routing and is listed by /v1/models.
+	Models []ModelCapability `yaml:"models,omitempty" json:"models,omitzero"`
 }
+
+// ProviderProtocol identifies the API a provider speaks
+type ProviderProtocol string
+
+const (
+	// ProtocolOpenAI is the OpenAI Chat Completions API, also spoken by OpenAI-compatible providers
+	ProtocolOpenAI ProviderProtocol = "openai"
+	// ProtocolAnthropic is the Anthropic Messages API
+	ProtocolAnthropic ProviderProtocol = "anthropic"
+	// ProtocolGemini is the Gemini GenerateContent API
+	ProtocolGemini ProviderProtocol = "gemini"
+)
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CachedResponse is a complete response stored by the response cache
+type CachedResponse struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Context identifies the request without its final message; semantic hits must match it
+	Context string `json:"context"`
+	// Body is the response as it was sent to the client, in the endpoint's API format
+	Body     json.RawMessage `json:"body"`
+	CachedAt time.Time       `json:"cached_at"`
+}
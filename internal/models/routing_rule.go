@@ -0,0 +1,110 @@
+package models
+
+import "strings"
+
+// RoutingRule is a declarative policy evaluated before the model router.
+// Rules are checked in order and the first matching rule is applied.
+type RoutingRule struct {
+	Name  string           `json:"name" yaml:"name"`
+	Match RoutingRuleMatch `json:"match" yaml:"match"`
+
+	// Model pins the request to a "provider:model", bypassing the model router
+	Model string `json:"model,omitzero" yaml:"model,omitempty"`
+	// AllowedProviders restricts the candidate set to these providers
+	AllowedProviders []string `json:"allowed_providers,omitzero" yaml:"allowed_providers,omitempty"`
+	// AllowedModels restricts the candidate set to these "provider:model" entries ("provider:*" allows any model of a provider)
+	AllowedModels []string `json:"allowed_models,omitzero" yaml:"allowed_models,omitempty"`
+	// CostBias overrides the cost bias (0.0 = cheapest, 1.0 = best performance)
+	CostBias *float32 `json:"cost_bias,omitzero" yaml:"cost_bias,omitempty"`
+	// FallbackMode overrides the fallback mode (sequential/race/hedge)
+	FallbackMode FallbackMode `json:"fallback_mode,omitzero" yaml:"fallback_mode,omitempty"`
+}
+
+// RoutingRuleMatch holds the conditions of a routing rule. All set conditions must match;
+// list conditions match when any of their values matches.
+type RoutingRuleMatch struct {
+	APIKeyIDs       []uint            `json:"api_key_ids,omitzero" yaml:"api_key_ids,omitempty"`
+	APIKeyPrefixes  []string          `json:"api_key_prefixes,omitzero" yaml:"api_key_prefixes,omitempty"`
+	ProjectIDs      []uint            `json:"project_ids,omitzero" yaml:"project_ids,omitempty"`
+	OrganizationIDs []string          `json:"organization_ids,omitzero" yaml:"organization_ids,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitzero" yaml:"metadata,omitempty"`
+	HasTools        *bool             `json:"has_tools,omitzero" yaml:"has_tools,omitempty"`
+	Endpoints       []string          `json:"endpoints,omitzero" yaml:"endpoints,omitempty"` // chat_completions, messages, generate, select_model
+	PromptRegex     string            `json:"prompt_regex,omitzero" yaml:"prompt_regex,omitempty"`
+	MinTokens       int               `json:"min_tokens,omitzero" yaml:"min_tokens,omitempty"`
+	MaxTokens       int               `json:"max_tokens,omitzero" yaml:"max_tokens,omitempty"`
+}
+
+// RoutingContext carries the request attributes routing rules are matched against
+type RoutingContext struct {
+	Endpoint        string
+	APIKeyID        uint
+	APIKeyPrefix    string
+	ProjectID       uint
+	OrganizationID  string
+	User            string
+	Metadata        map[string]string
+	HasTools        bool
+	Prompt          string
+	EstimatedTokens int
+}
+
+// RestrictsCandidates reports whether the rule limits which models may be used
+func (r *RoutingRule) RestrictsCandidates() bool {
+	return r != nil && (len(r.AllowedProviders) > 0 || len(r.AllowedModels) > 0)
+}
+
+// AllowsProvider reports whether every model of the provider is allowed by the rule
+func (r *RoutingRule) AllowsProvider(provider string) bool {
+	if !r.RestrictsCandidates() {
+		return true
+	}
+	for _, allowed := range r.AllowedProviders {
+		if strings.EqualFold(allowed, provider) {
+			return true
+		}
+	}
+	for _, allowed := range r.AllowedModels {
+		if p, m, ok := strings.Cut(allowed, ":"); ok && m == "*" && strings.EqualFold(p, provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsModel reports whether the rule permits the provider/model pair
+func (r *RoutingRule) AllowsModel(provider, model string) bool {
+	if r.AllowsProvider(provider) {
+		return true
+	}
+	for _, allowed := range r.AllowedModels {
+		if p, m, ok := strings.Cut(allowed, ":"); ok && strings.EqualFold(p, provider) && m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedModelsFor returns the explicitly allowed model names for a provider
+func (r *RoutingRule) AllowedModelsFor(provider string) []string {
+	var names []string
+	for _, allowed := range r.AllowedModels {
+		if p, m, ok := strings.Cut(allowed, ":"); ok && m != "*" && strings.EqualFold(p, provider) {
+			names = append(names, m)
+		}
+	}
+	return names
+}
+
+// OverrideFallback returns the request fallback config with the rule's fallback mode applied
+func (r *RoutingRule) OverrideFallback(fallback *FallbackConfig) *FallbackConfig {
+	if r == nil || r.FallbackMode == "" {
+		return fallback
+	}
+	overridden := FallbackConfig{}
+	if fallback != nil {
+		overridden = *fallback
+	}
+	overridden.Mode = r.FallbackMode
+	return &overridden
+}
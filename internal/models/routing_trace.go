@@ -0,0 +1,65 @@
+package models
+
+// Reasons a candidate model was filtered out during routing
+const (
+	FilterReasonCircuitBreaker = "circuit_breaker_open"
+	FilterReasonContextWindow  = "context_window"
+	FilterReasonRoutingRule    = "routing_rule"
+	// FilterReasonUnsupported is followed by ":<feature>", e.g. "unsupported:vision"
+	FilterReasonUnsupported = "unsupported"
+)
+
+// RoutingTrace explains how a request was routed: the candidates considered, the ones filtered
+// out and why, the cache tier, router latency and the fallbacks attempted before the model served
+type RoutingTrace struct {
+	// Provider and model that served (or, for /v1/select-model, were selected for) the request
+	Provider string `json:"provider,omitzero"`
+	Model    string `json:"model,omitzero"`
+	// Candidates considered by the router; provider-only entries have an empty model
+	Candidates []Alternative         `json:"candidates,omitzero"`
+	Filtered   []FilteredCandidate   `json:"filtered,omitzero"`
+	Rule       string                `json:"rule,omitzero"`
+	Alias      string                `json:"alias,omitzero"`
+	Experiment *ExperimentAssignment `json:"experiment,omitzero"`
+	// CacheTier is the cache tier that produced the selection (semantic_exact, semantic_similar,
+	// prompt_response)
+	CacheTier       string  `json:"cache_tier,omitzero"`
+	CacheSimilarity float32 `json:"cache_similarity,omitzero"`
+	RouterLatencyMs int64   `json:"router_latency_ms,omitzero"`
+	// Fallbacks lists the failed attempts made before the serving model
+	Fallbacks []FallbackAttempt `json:"fallbacks,omitzero"`
+	// Race lists the contenders of a streaming race and how soon each produced content
+	Race []RaceContender `json:"race,omitzero"`
+}
+
+// Race contender outcomes
+const (
+	RaceOutcomeWon    = "won"
+	RaceOutcomeFailed = "failed"
+	// RaceOutcomeCancelled is a contender still connecting when the race was decided
+	RaceOutcomeCancelled = "cancelled"
+)
+
+// RaceContender is a provider that took part in a streaming race
+type RaceContender struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Outcome  string `json:"outcome"`
+	// TimeToFirstTokenMs is set for the winner; the losers' are recorded in provider stats
+	TimeToFirstTokenMs int64 `json:"time_to_first_token_ms,omitzero"`
+}
+
+// FilteredCandidate is a candidate model removed during routing
+type FilteredCandidate struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitzero"`
+	Reason   string `json:"reason"`
+}
+
+// FallbackAttempt is a failed provider attempt
+type FallbackAttempt struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	Error     string `json:"error"`
+	LatencyMs int64  `json:"latency_ms"`
+}
@@ -5,4 +5,5 @@ type ServerConfig struct {
 	AllowedOrigins string `json:"allowed_origins,omitzero" yaml:"allowed_origins"`
 	Environment    string `json:"environment,omitzero" yaml:"environment"`
 	LogLevel       string `json:"log_level,omitzero" yaml:"log_level"`
+	AdminToken     string `json:"-" yaml:"admin_token"`
 }
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ShadowConfig mirrors a sample of an endpoint's live traffic to a candidate model
+type ShadowConfig struct {
+	// Model is the "provider:model" receiving mirrored requests
+	Model string `yaml:"model" json:"model"`
+	// SampleRate is the fraction of successful requests mirrored (0.0-1.0)
+	SampleRate float64 `yaml:"sample_rate" json:"sample_rate"`
+	// TimeoutMs bounds each shadow call (default 60s)
+	TimeoutMs int `yaml:"timeout_ms,omitempty" json:"timeout_ms,omitzero"`
+}
+
+// ShadowResult describes one side of a shadow comparison
+type ShadowResult struct {
+	Provider     string
+	Model        string
+	LatencyMs    int
+	TokensInput  int
+	TokensOutput int
+	Cost         float64
+	Output       string
+	Error        string
+}
+
+// ShadowComparison stores a shadow call next to the primary response it mirrored
+type ShadowComparison struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	RequestID string `gorm:"index"`
+	Endpoint  string `gorm:"index"`
+	APIKeyID  uint   `gorm:"index"`
+	Streamed  bool
+
+	PrimaryProvider     string
+	PrimaryModel        string
+	PrimaryLatencyMs    int
+	PrimaryTokensInput  int
+	PrimaryTokensOutput int
+	PrimaryCost         float64
+	PrimaryOutput       string
+
+	ShadowProvider     string
+	ShadowModel        string `gorm:"index"`
+	ShadowLatencyMs    int
+	ShadowTokensInput  int
+	ShadowTokensOutput int
+	ShadowCost         float64
+	ShadowOutput       string
+	ShadowError        string
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
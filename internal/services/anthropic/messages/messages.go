@@ -5,10 +5,16 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/models"
 	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils/clientcache"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -30,6 +36,36 @@ func NewMessagesService() *MessagesService {
 	}
 }
 
+// Client sends Messages API requests to a provider. Providers speaking the Messages API are
+// called natively; others are served through format conversion.
+type Client interface {
+	SendMessage(ctx context.Context, req *models.AnthropicMessageRequest, requestID string) (*anthropic.Message, error)
+	SendStreamingMessage(ctx context.Context, req *models.AnthropicMessageRequest, requestID string) (contracts.EventStream[anthropic.MessageStreamEventUnion], error)
+}
+
+// nativeClient sends requests with the Anthropic SDK
+type nativeClient struct {
+	ms     *MessagesService
+	client *anthropic.Client
+}
+
+// Client returns a client calling the provider natively with the Anthropic SDK
+func (ms *MessagesService) Client(providerConfig models.ProviderConfig) Client {
+	return &nativeClient{ms: ms, client: ms.CreateClient(providerConfig)}
+}
+
+func (n *nativeClient) SendMessage(ctx context.Context, req *models.AnthropicMessageRequest, requestID string) (*anthropic.Message, error) {
+	return n.ms.SendMessage(ctx, n.client, req, requestID)
+}
+
+func (n *nativeClient) SendStreamingMessage(ctx context.Context, req *models.AnthropicMessageRequest, requestID string) (contracts.EventStream[anthropic.MessageStreamEventUnion], error) {
+	stream, err := n.ms.SendStreamingMessage(ctx, n.client, req, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
 // generateConfigHash creates a hash of the provider config to detect changes
 func (ms *MessagesService) generateConfigHash(providerConfig models.ProviderConfig) (string, error) {
 	// Hash the entire provider config for consistent cache key generation
@@ -70,6 +106,8 @@ func (ms *MessagesService) CreateClient(providerConfig models.ProviderConfig) *a
 func (ms *MessagesService) buildClient(providerConfig models.ProviderConfig) *anthropic.Client {
 	clientOpts := []option.RequestOption{
 		option.WithAPIKey(providerConfig.APIKey),
+		// Retries are applied by the fallback service, per provider retry_config
+		option.WithMaxRetries(0),
 	}
 
 	// Set custom base URL if provided
@@ -162,37 +200,78 @@ func (ms *MessagesService) SendStreamingMessage(
 	return streamResp, nil
 }
 
-// handleAnthropicProvider handles requests using native Anthropic client
-func (ms *MessagesService) HandleAnthropicProvider(
+// HandleProvider handles requests using the provider's client
+func (ms *MessagesService) HandleProvider(
 	c *fiber.Ctx,
 	req *models.AnthropicMessageRequest,
-	providerConfig models.ProviderConfig,
+	client Client,
 	isStreaming bool,
 	requestID string,
 	responseSvc *ResponseService,
 	provider string,
 	cacheSource string,
+	observation *provider_stats.Observation,
 ) error {
-	fiberlog.Debugf("[%s] Using native Anthropic provider", requestID)
-	client := ms.CreateClient(providerConfig)
+	fiberlog.Debugf("[%s] Using provider %s", requestID, provider)
 
 	if isStreaming {
 		// Use context.Background() for streaming - c.Context() gets canceled too early
 		// The stream handler will monitor fasthttpCtx for actual client disconnects
-		stream, err := ms.SendStreamingMessage(context.Background(), client, req, requestID)
+		stream, err := client.SendStreamingMessage(context.Background(), req, requestID)
 		if err != nil {
-			return responseSvc.HandleError(c, err, requestID)
+			return err
 		}
 
 		// Extract API key from context
 		apiKey, _ := auth.GetAPIKey(c)
 
-		return responseSvc.HandleStreamingResponse(c, stream, requestID, provider, cacheSource, string(req.Model), "/v1/messages", responseSvc.usageService, apiKey)
+		if err := responseSvc.HandleStreamingResponse(c, stream, requestID, provider, cacheSource, string(req.Model), "/v1/messages", responseSvc.usageService, apiKey, observation); err != nil {
+			return err
+		}
+		routing_trace.From(c).Select(provider, string(req.Model))
+		shadow.SetPrimary(c, models.ShadowResult{Provider: provider, Model: string(req.Model)})
+		return nil
 	}
 
-	message, err := ms.SendMessage(c.Context(), client, req, requestID)
+	message, err := client.SendMessage(c.UserContext(), req, requestID)
 	if err != nil {
-		return responseSvc.HandleError(c, err, requestID)
+		return err
 	}
 	return responseSvc.HandleNonStreamingResponse(c, message, requestID, provider, cacheSource)
 }
+
+// SendShadowMessage replays a request against a shadow provider/model without streaming.
+// No usage is recorded: shadow calls are never billed.
+func (ms *MessagesService) SendShadowMessage(
+	ctx context.Context,
+	req models.AnthropicMessageRequest,
+	client Client,
+	provider, model string,
+	requestID string,
+) models.ShadowResult {
+	result := models.ShadowResult{Provider: provider, Model: model}
+	req.Model = anthropic.Model(model)
+
+	message, err := client.SendMessage(ctx, &req, requestID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.TokensInput = int(message.Usage.InputTokens)
+	result.TokensOutput = int(message.Usage.OutputTokens)
+	result.Cost = usage.CalculateCost(provider, model, result.TokensInput, result.TokensOutput)
+	result.Output = MessageText(message)
+	return result
+}
+
+// MessageText concatenates the text blocks of a message
+func MessageText(message *anthropic.Message) string {
+	var text strings.Builder
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
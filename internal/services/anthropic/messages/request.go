@@ -4,7 +4,9 @@ import (
 	"fmt"
 
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
+	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
 )
@@ -27,6 +29,10 @@ func (rs *RequestService) ParseRequest(c *fiber.Ctx) (*models.AnthropicMessageRe
 		return nil, fmt.Errorf("invalid JSON in request body: %w", err)
 	}
 
+	// An OpenRouter-style models array is handled as a comma-separated model list
+	req.Model = anthropic.Model(utils.JoinModelList(string(req.Model), req.Models))
+	req.Models = nil
+
 	return &req, nil
 }
 
@@ -8,12 +8,16 @@ import (
 	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
 	"github.com/Egham-7/adaptive-proxy/internal/services/format_adapter"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
 )
@@ -47,12 +51,7 @@ func (rs *ResponseService) HandleNonStreamingResponse(
 	adaptiveResponse, err := format_adapter.AnthropicToAdaptive.ConvertResponse(message, provider, cacheSource)
 	if err != nil {
 		fiberlog.Errorf("[%s] Failed to convert Anthropic response: %v", requestID, err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fiber.Map{
-				"type":    "internal_server_error",
-				"message": fmt.Sprintf("Response conversion error: %v", err),
-			},
-		})
+		return rs.HandleError(c, fmt.Errorf("response conversion error: %w", err), requestID)
 	}
 
 	// Record usage if usage service is available
@@ -75,15 +74,25 @@ func (rs *ResponseService) HandleNonStreamingResponse(
 				Cost:           usage.CalculateCost(provider, string(message.Model), inputTokens, outputTokens),
 				StatusCode:     200,
 				RequestID:      requestID,
+				Metadata:       usage.UsageMetadata(c),
 			}
 
-			_, err := rs.usageService.RecordUsage(c.UserContext(), usageParams)
-			if err != nil {
-				fiberlog.Errorf("[%s] Failed to record usage: %v", requestID, err)
-			}
+			rs.usageService.Record(c, usageParams)
 		}
 	}
 
+	routing_trace.From(c).Select(provider, string(message.Model))
+	inputTokens := int(message.Usage.InputTokens)
+	outputTokens := int(message.Usage.OutputTokens)
+	shadow.SetPrimary(c, models.ShadowResult{
+		Provider:     provider,
+		Model:        string(message.Model),
+		TokensInput:  inputTokens,
+		TokensOutput: outputTokens,
+		Cost:         usage.CalculateCost(provider, string(message.Model), inputTokens, outputTokens),
+		Output:       MessageText(message),
+	})
+
 	fiberlog.Infof("[%s] Response converted successfully, sending to client", requestID)
 	return c.JSON(adaptiveResponse)
 }
@@ -91,7 +100,7 @@ func (rs *ResponseService) HandleNonStreamingResponse(
 // HandleStreamingResponse processes a streaming Anthropic response using the optimized stream handler
 func (rs *ResponseService) HandleStreamingResponse(
 	c *fiber.Ctx,
-	anthropicStream *ssestream.Stream[anthropic.MessageStreamEventUnion],
+	anthropicStream contracts.EventStream[anthropic.MessageStreamEventUnion],
 	requestID string,
 	provider string,
 	cacheSource string,
@@ -99,25 +108,36 @@ func (rs *ResponseService) HandleStreamingResponse(
 	endpoint string,
 	usageService *usage.Service,
 	apiKey *models.APIKey,
+	observation *provider_stats.Observation,
 ) error {
 	fiberlog.Infof("[%s] Starting Anthropic streaming response handling", requestID)
 
 	// Use the optimized stream handler that properly handles native Anthropic streams
-	return handlers.HandleAnthropicNative(c, anthropicStream, requestID, provider, cacheSource, model, endpoint, usageService, apiKey, rs.usageWorker)
+	return handlers.HandleAnthropicNative(c, anthropicStream, requestID, provider, cacheSource, model, endpoint, usageService, apiKey, rs.usageWorker, observation)
 }
 
-// HandleError handles error responses for Anthropic Messages API
-func (rs *ResponseService) HandleError(c *fiber.Ctx, err error, requestID string) error {
-	fiberlog.Errorf("[%s] anthropic messages error: %v", requestID, err)
-
-	response := fiber.Map{
-		"error": fiber.Map{
-			"message":    err.Error(),
-			"request_id": requestID,
-		},
-	}
+// PrepareStreamingResponse validates an Anthropic stream without touching the response, for
+// streaming races; see handlers.PrepareAnthropicNative
+func (rs *ResponseService) PrepareStreamingResponse(
+	anthropicStream contracts.EventStream[anthropic.MessageStreamEventUnion],
+	requestID string,
+	provider string,
+	cacheSource string,
+	model string,
+	endpoint string,
+	apiKey *models.APIKey,
+	attempt usage.Attempt,
+	observation *provider_stats.Observation,
+) (*handlers.PendingStream, error) {
+	return handlers.PrepareAnthropicNative(anthropicStream, requestID, provider, cacheSource, model, endpoint, rs.usageService, apiKey, attempt, rs.usageWorker, observation)
+}
 
-	return c.Status(fiber.StatusInternalServerError).JSON(response)
+// HandleError sends the failure as an Anthropic error with the upstream status, see
+// response.ClassifyError
+func (rs *ResponseService) HandleError(c *fiber.Ctx, err error, requestID string) error {
+	apiErr := response.ClassifyError(err)
+	fiberlog.Errorf("[%s] anthropic messages error %d: %v", requestID, apiErr.Status, err)
+	return response.SendError(c, response.FormatAnthropic, apiErr, requestID)
 }
 
 // StoreSuccessfulSemanticCache stores the model response in semantic cache after successful completion
@@ -133,7 +153,7 @@ func (rs *ResponseService) StoreSuccessfulSemanticCache(
 	}
 
 	// Extract prompt for cache storage from Anthropic messages
-	prompt, err := utils.ExtractPromptFromAnthropicMessages(req.Messages)
+	prompt, err := utils.ExtractAnthropicPrompt(req.System, req.Messages, rs.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Errorf("[%s] Failed to extract prompt for semantic cache: %v", requestID, err)
 		return
@@ -148,22 +168,18 @@ func (rs *ResponseService) StoreSuccessfulSemanticCache(
 // HandleBadRequest handles validation and request parsing errors
 func (rs *ResponseService) HandleBadRequest(c *fiber.Ctx, message, requestID string) error {
 	fiberlog.Warnf("[%s] bad request: %s", requestID, message)
-	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-		"error": fiber.Map{
-			"type":    "invalid_request_error",
-			"message": message,
-		},
-	})
+	return response.SendError(c, response.FormatAnthropic, response.NewAPIError(fiber.StatusBadRequest, message), requestID)
+}
+
+// HandleForbidden handles requests rejected by routing policy
+func (rs *ResponseService) HandleForbidden(c *fiber.Ctx, message, requestID string) error {
+	fiberlog.Warnf("[%s] forbidden: %s", requestID, message)
+	return response.SendError(c, response.FormatAnthropic, response.NewAPIError(fiber.StatusForbidden, message), requestID)
 }
 
 // HandleProviderNotConfigured handles cases where the provider is not available
 func (rs *ResponseService) HandleProviderNotConfigured(c *fiber.Ctx, provider, requestID string) error {
 	message := fmt.Sprintf("Provider '%s' is not configured for messages endpoint", provider)
 	fiberlog.Warnf("[%s] %s", requestID, message)
-	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-		"error": fiber.Map{
-			"type":    "invalid_request_error",
-			"message": message,
-		},
-	})
+	return response.SendError(c, response.FormatAnthropic, response.NewAPIError(fiber.StatusBadRequest, message), requestID)
 }
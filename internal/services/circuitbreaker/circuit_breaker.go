@@ -2,6 +2,7 @@ package circuitbreaker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -10,6 +11,9 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrOpen is returned for providers skipped because their circuit breaker is open
+var ErrOpen = errors.New("circuit breaker is open")
+
 type State int
 
 const (
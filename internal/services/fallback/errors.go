@@ -0,0 +1,215 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+// ErrorClass tells the fallback service what to do after a provider attempt fails
+type ErrorClass int
+
+const (
+	// ErrorRetryable failures (rate limits, 5xx, timeouts and network errors) are retried on the
+	// same provider with backoff, then fall back to the next alternative
+	ErrorRetryable ErrorClass = iota
+	// ErrorProvider failures (authentication, unknown model, open circuit breaker) are specific to
+	// the provider; they fall back to the next alternative without retrying
+	ErrorProvider
+	// ErrorClient failures (malformed or oversized requests) would fail on every provider and are
+	// returned to the client without trying alternatives
+	ErrorClient
+	// ErrorCancelled failures happened because the client went away or the request's deadline
+	// passed; nothing more is tried for the request
+	ErrorCancelled
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorRetryable:
+		return "retryable"
+	case ErrorClient:
+		return "client"
+	case ErrorCancelled:
+		return "cancelled"
+	default:
+		return "provider"
+	}
+}
+
+// ClassifyError classifies a provider failure by its HTTP status or, without one, by whether
+// it is a cancellation or a network error. Unrecognised errors are treated as provider failures.
+// A deadline is a provider timeout here; ClassifyAttempt tells the request's own deadline apart.
+func ClassifyError(err error) ErrorClass {
+	if errors.Is(err, context.Canceled) {
+		return ErrorCancelled
+	}
+	if status := StatusCode(err); status != 0 {
+		switch {
+		case status == http.StatusRequestTimeout, status == http.StatusConflict, status == http.StatusTooEarly,
+			status == http.StatusTooManyRequests, status >= http.StatusInternalServerError:
+			return ErrorRetryable
+		case status == http.StatusBadRequest, status == http.StatusRequestEntityTooLarge,
+			status == http.StatusUnprocessableEntity:
+			return ErrorClient
+		default:
+			return ErrorProvider
+		}
+	}
+	if isNetworkError(err) {
+		return ErrorRetryable
+	}
+	return ErrorProvider
+}
+
+// ClassifyAttempt classifies the failure of an attempt made for the request whose context is
+// ctx. Once ctx is done the client is gone or the request's deadline passed, so the failure is
+// ErrorCancelled whatever caused it.
+func ClassifyAttempt(ctx context.Context, err error) ErrorClass {
+	if ctx.Err() != nil {
+		return ErrorCancelled
+	}
+	return ClassifyError(err)
+}
+
+// ShouldFallback reports whether a failure of an attempt for the request whose context is ctx
+// may succeed on another provider
+func ShouldFallback(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	class := ClassifyAttempt(ctx, err)
+	return class != ErrorClient && class != ErrorCancelled
+}
+
+// ProvidersError reports that every provider failed. It unwraps to the last failure, whose
+// status and Retry-After are what the client gets back.
+type ProvidersError struct {
+	message string
+	Errors  []error
+}
+
+func (e *ProvidersError) Error() string {
+	return fmt.Sprintf("%s: %v", e.message, e.Errors)
+}
+
+func (e *ProvidersError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[len(e.Errors)-1]
+}
+
+func allFailed(message string, errs []error) error {
+	return &ProvidersError{message: message, Errors: errs}
+}
+
+// StatusCode returns the HTTP status of a provider SDK error, or 0 when err carries none
+func StatusCode(err error) int {
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode
+	}
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code
+	}
+	var geminiErrPtr *genai.APIError
+	if errors.As(err, &geminiErrPtr) && geminiErrPtr != nil {
+		return geminiErrPtr.Code
+	}
+	return 0
+}
+
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RetryAfter returns how long the provider asked to wait before the next attempt: Retry-After
+// (or retry-after-ms), the OpenAI rate-limit reset of an exhausted limit, or the Gemini
+// RetryInfo delay. It returns 0 when the provider gave no hint.
+func RetryAfter(err error, now time.Time) time.Duration {
+	var resp *http.Response
+	var openaiErr *openai.Error
+	var anthropicErr *anthropic.Error
+	switch {
+	case errors.As(err, &openaiErr):
+		resp = openaiErr.Response
+	case errors.As(err, &anthropicErr):
+		resp = anthropicErr.Response
+	}
+	if resp != nil {
+		return retryAfterHeaders(resp.Header, now)
+	}
+
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiRetryDelay(geminiErr.Details)
+	}
+	var geminiErrPtr *genai.APIError
+	if errors.As(err, &geminiErrPtr) && geminiErrPtr != nil {
+		return geminiRetryDelay(geminiErrPtr.Details)
+	}
+	return 0
+}
+
+func retryAfterHeaders(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if at, err := http.ParseTime(value); err == nil && at.After(now) {
+			return at.Sub(now)
+		}
+	}
+
+	// OpenAI reports when each limit resets, e.g. "6m0s" or "20ms"; only an exhausted limit matters
+	var wait time.Duration
+	for _, limit := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+limit) != "0" {
+			continue
+		}
+		if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + limit)); err == nil {
+			wait = max(wait, reset)
+		}
+	}
+	return wait
+}
+
+// geminiRetryDelay reads the retryDelay of a google.rpc.RetryInfo error detail, e.g. "30s"
+func geminiRetryDelay(details []map[string]any) time.Duration {
+	for _, detail := range details {
+		if typ, _ := detail["@type"].(string); !strings.HasSuffix(typ, "google.rpc.RetryInfo") {
+			continue
+		}
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
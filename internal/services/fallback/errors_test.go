@@ -0,0 +1,192 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+// openaiError is the error the OpenAI SDK returns for a response with status and header
+func openaiError(status int, header http.Header) error {
+	return &openai.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil),
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+// anthropicError is the error the Anthropic SDK returns for a response with status and header
+func anthropicError(status int, header http.Header) error {
+	return &anthropic.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", nil),
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{name: "rate limited", err: openaiError(http.StatusTooManyRequests, nil), want: ErrorRetryable},
+		{name: "server error", err: anthropicError(http.StatusInternalServerError, nil), want: ErrorRetryable},
+		{name: "overloaded", err: anthropicError(529, nil), want: ErrorRetryable},
+		{name: "request timeout", err: openaiError(http.StatusRequestTimeout, nil), want: ErrorRetryable},
+		{name: "conflict", err: openaiError(http.StatusConflict, nil), want: ErrorRetryable},
+		{name: "gemini unavailable", err: genai.APIError{Code: http.StatusServiceUnavailable}, want: ErrorRetryable},
+		{name: "gemini pointer", err: &genai.APIError{Code: http.StatusTooManyRequests}, want: ErrorRetryable},
+		{name: "bad request", err: openaiError(http.StatusBadRequest, nil), want: ErrorClient},
+		{name: "too large", err: anthropicError(http.StatusRequestEntityTooLarge, nil), want: ErrorClient},
+		{name: "unprocessable", err: genai.APIError{Code: http.StatusUnprocessableEntity}, want: ErrorClient},
+		{name: "unauthorized", err: openaiError(http.StatusUnauthorized, nil), want: ErrorProvider},
+		{name: "unknown model", err: anthropicError(http.StatusNotFound, nil), want: ErrorProvider},
+		{name: "wrapped status", err: fmt.Errorf("stream: %w", openaiError(http.StatusBadGateway, nil)), want: ErrorRetryable},
+		{name: "deadline", err: fmt.Errorf("call: %w", context.DeadlineExceeded), want: ErrorRetryable},
+		{name: "cancelled", err: fmt.Errorf("call: %w", context.Canceled), want: ErrorCancelled},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: ErrorRetryable},
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, want: ErrorRetryable},
+		{name: "connection refused", err: syscall.ECONNREFUSED, want: ErrorRetryable},
+		{name: "dns failure", err: &net.DNSError{Err: "no such host", Name: "api.example.com"}, want: ErrorRetryable},
+		{name: "unrecognised", err: errors.New("circuit breaker open"), want: ErrorProvider},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+			if got, want := ShouldFallback(context.Background(), tt.err), tt.want != ErrorClient && tt.want != ErrorCancelled; got != want {
+				t.Errorf("ShouldFallback(%v) = %v, want %v", tt.err, got, want)
+			}
+		})
+	}
+
+	if ShouldFallback(context.Background(), nil) {
+		t.Errorf("ShouldFallback(nil) = true, want false")
+	}
+}
+
+func TestClassifyAttempt(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want ErrorClass
+	}{
+		{name: "provider timeout", ctx: context.Background(), err: context.DeadlineExceeded, want: ErrorRetryable},
+		{name: "client gone", ctx: cancelled, err: openaiError(http.StatusServiceUnavailable, nil), want: ErrorCancelled},
+		{name: "request deadline", ctx: expired, err: fmt.Errorf("call: %w", context.DeadlineExceeded), want: ErrorCancelled},
+		{name: "live request", ctx: context.Background(), err: openaiError(http.StatusServiceUnavailable, nil), want: ErrorRetryable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyAttempt(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("ClassifyAttempt(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+			if got, want := ShouldFallback(tt.ctx, tt.err), tt.want != ErrorCancelled; got != want {
+				t.Errorf("ShouldFallback(%v) = %v, want %v", tt.err, got, want)
+			}
+		})
+	}
+}
+
+func TestProvidersError(t *testing.T) {
+	last := openaiError(http.StatusServiceUnavailable, nil)
+	err := allFailed("all providers failed", []error{errors.New("first"), last})
+
+	if got := StatusCode(err); got != http.StatusServiceUnavailable {
+		t.Errorf("StatusCode() = %d, want the last failure's %d", got, http.StatusServiceUnavailable)
+	}
+	if !errors.Is(err, last) {
+		t.Errorf("errors.Is(err, last) = false, want the last failure unwrapped")
+	}
+	if (&ProvidersError{}).Unwrap() != nil {
+		t.Errorf("Unwrap() without failures = non-nil, want nil")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	header := func(pairs ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i], pairs[i+1])
+		}
+		return h
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{name: "seconds", err: openaiError(429, header("Retry-After", "3")), want: 3 * time.Second},
+		{name: "fractional seconds", err: anthropicError(429, header("Retry-After", "0.5")), want: 500 * time.Millisecond},
+		{name: "milliseconds win", err: openaiError(429, header("retry-after-ms", "250", "Retry-After", "3")), want: 250 * time.Millisecond},
+		{
+			name: "http date",
+			err:  anthropicError(503, header("Retry-After", now.Add(90*time.Second).Format(http.TimeFormat))),
+			want: 90 * time.Second,
+		},
+		{name: "past date", err: openaiError(503, header("Retry-After", now.Add(-time.Minute).Format(http.TimeFormat)))},
+		{name: "invalid", err: openaiError(429, header("Retry-After", "soon"))},
+		{
+			name: "exhausted rate limits",
+			err: openaiError(429, header(
+				"x-ratelimit-remaining-requests", "0", "x-ratelimit-reset-requests", "20ms",
+				"x-ratelimit-remaining-tokens", "0", "x-ratelimit-reset-tokens", "6m0s",
+			)),
+			want: 6 * time.Minute,
+		},
+		{
+			name: "remaining rate limit ignored",
+			err: openaiError(429, header(
+				"x-ratelimit-remaining-requests", "0", "x-ratelimit-reset-requests", "1s",
+				"x-ratelimit-remaining-tokens", "1200", "x-ratelimit-reset-tokens", "6m0s",
+			)),
+			want: time.Second,
+		},
+		{
+			name: "gemini retry info",
+			err: genai.APIError{Code: 429, Details: []map[string]any{
+				{"@type": "type.googleapis.com/google.rpc.QuotaFailure"},
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "30s"},
+			}},
+			want: 30 * time.Second,
+		},
+		{
+			name: "gemini pointer",
+			err:  &genai.APIError{Code: 429, Details: []map[string]any{{"@type": "google.rpc.RetryInfo", "retryDelay": "1.5s"}}},
+			want: 1500 * time.Millisecond,
+		},
+		{name: "no hint", err: openaiError(500, nil)},
+		{name: "not a provider error", err: io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryAfter(tt.err, now); got != tt.want {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
 
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
@@ -16,16 +17,24 @@ import (
 // FallbackService provides reusable fallback logic for any API endpoint
 type FallbackService struct {
 	cfg *config.Config
+	// endpoint selects the provider configs whose retry_config applies
+	endpoint string
+	// statsTracker provides the observed p95s hedge delays are derived from; may be nil
+	statsTracker *provider_stats.Tracker
 }
 
-// NewFallbackService creates a new fallback service
-func NewFallbackService(cfg *config.Config) *FallbackService {
+// NewFallbackService creates a new fallback service for an endpoint
+func NewFallbackService(cfg *config.Config, endpoint string, statsTracker *provider_stats.Tracker) *FallbackService {
 	return &FallbackService{
-		cfg: cfg,
+		cfg:          cfg,
+		endpoint:     endpoint,
+		statsTracker: statsTracker,
 	}
 }
 
-// Execute runs the providers with the specified fallback configuration
+// Execute runs the providers with the specified fallback configuration. executeFunc is expected
+// to be wrapped by WithRetries. A client error stops sequential fallback, since every provider
+// would reject the request the same way.
 func (fs *FallbackService) Execute(
 	c *fiber.Ctx,
 	providers []models.Alternative,
@@ -52,6 +61,12 @@ func (fs *FallbackService) Execute(
 		return fs.executeSequential(c, providers, executeFunc, requestID)
 	case models.FallbackModeRace:
 		return fs.executeRace(c, providers, fallbackConfig, executeFunc, requestID, isStream)
+	case models.FallbackModeHedge:
+		if isStream {
+			fiberlog.Infof("[%s] Streams hedge through RaceStreams, trying %d providers in order", requestID, len(providers))
+			return fs.executeSequential(c, providers, executeFunc, requestID)
+		}
+		return fs.executeHedge(c, providers, fallbackConfig, executeFunc, requestID)
 	default:
 		fiberlog.Warnf("[%s] Unknown fallback mode %s, using sequential", requestID, fallbackConfig.Mode)
 		return fs.executeSequential(c, providers, executeFunc, requestID)
@@ -96,13 +111,17 @@ func (fs *FallbackService) executeSequential(
 		} else {
 			fiberlog.Warnf("[%s] ❌ FAILED %s provider %s/%s: %v",
 				requestID, providerType, provider.Provider, provider.Model, err)
+			if !ShouldFallback(c.UserContext(), err) {
+				fiberlog.Warnf("[%s] 🛑 %s error, not trying remaining providers", requestID, ClassifyAttempt(c.UserContext(), err))
+				return err
+			}
 			errors = append(errors, err)
 		}
 	}
 
 	fiberlog.Errorf("[%s] 💥 All %d providers failed: %v", requestID, len(providers), errors)
 	fiberlog.Infof("[%s] ═══ Sequential Fallback Complete (All Failed) ═══", requestID)
-	return fmt.Errorf("all providers failed: %v", errors)
+	return allFailed("all providers failed", errors)
 }
 
 // executeRace tries all providers in parallel and returns the first successful result
@@ -114,10 +133,10 @@ func (fs *FallbackService) executeRace(
 	requestID string,
 	isStream bool,
 ) error {
-	// For streaming, race to establish connection first, then stream from winner
+	// Concurrent executeFuncs would all write to c; streams race through RaceStreams instead
 	if isStream {
-		fiberlog.Infof("[%s] Racing %d providers for streaming (first to connect wins)", requestID, len(providers))
-		return fs.executeStreamingRace(c, providers, fallbackConfig, executeFunc, requestID)
+		fiberlog.Infof("[%s] Streams race through RaceStreams, trying %d providers in order", requestID, len(providers))
+		return fs.executeSequential(c, providers, executeFunc, requestID)
 	}
 
 	fiberlog.Infof("[%s] ═══ Race Fallback Started (%d providers) ═══", requestID, len(providers))
@@ -134,8 +153,8 @@ func (fs *FallbackService) executeRace(
 
 	resultCh := make(chan models.FallbackResult, len(providers))
 
-	// Create context with timeout if specified
-	ctx := context.Background()
+	// Create context with timeout if specified; the race ends early when the client goes away
+	ctx := c.UserContext()
 	if fallbackConfig.TimeoutMs > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(fallbackConfig.TimeoutMs)*time.Millisecond)
@@ -186,30 +205,16 @@ func (fs *FallbackService) executeRace(
 		}(provider)
 	}
 
-	// Context-aware cleanup with done channel coordination
-	done := make(chan struct{})
-	var closeOnce sync.Once
-
-	// Goroutine 1: Wait for all provider goroutines to finish
+	// Close the results once every provider has reported. resultCh holds a result per provider,
+	// so providers still running after the race ended early never block on it.
 	go func() {
 		wg.Wait()
-		close(done)
-	}()
-
-	// Goroutine 2: Coordinate cleanup respecting context cancellation
-	go func() {
-		select {
-		case <-done:
-			// All provider goroutines completed normally
-			closeOnce.Do(func() { close(resultCh) })
-		case <-ctx.Done():
-			// Context cancelled, cleanup and return early
-			closeOnce.Do(func() { close(resultCh) })
-		}
+		close(resultCh)
 	}()
 
 	// Wait for results with proper context handling
-	var errors []string
+	var errors []error
+	var clientErr error
 	failureCount := 0
 
 	for {
@@ -227,7 +232,10 @@ func (fs *FallbackService) executeRace(
 			}
 
 			failureCount++
-			errors = append(errors, fmt.Sprintf("%s(%s): %v", result.Provider.Provider, result.Provider.Model, result.Error))
+			if !ShouldFallback(c.UserContext(), result.Error) {
+				clientErr = result.Error
+			}
+			errors = append(errors, fmt.Errorf("%s(%s): %w", result.Provider.Provider, result.Provider.Model, result.Error))
 
 			// Check if we've received all results
 			if failureCount == len(providers) {
@@ -240,170 +248,11 @@ func (fs *FallbackService) executeRace(
 	}
 
 raceComplete:
-	// All providers failed
-	return fmt.Errorf("all providers failed in race: %v", errors)
-}
-
-// executeStreamingRace races providers for streaming with mutex protection
-// Only the first successful provider gets to execute and stream
-func (fs *FallbackService) executeStreamingRace(
-	c *fiber.Ctx,
-	providers []models.Alternative,
-	fallbackConfig models.FallbackConfig,
-	executeFunc models.ExecutionFunc,
-	requestID string,
-) error {
-	fiberlog.Infof("[%s] ═══ Streaming Race Started (%d providers) ═══", requestID, len(providers))
-	fs.logProviders(providers, requestID)
-
-	race := newStreamingRace(fallbackConfig.TimeoutMs)
-	defer race.cleanup()
-
-	// Start all providers racing
-	for _, provider := range providers {
-		race.wg.Add(1)
-		go fs.raceProvider(c, provider, executeFunc, race, requestID)
-	}
-
-	// Wait for completion
-	go race.waitForCompletion()
-
-	return race.awaitResult(requestID)
-}
-
-// streamingRace encapsulates the race state and synchronization
-type streamingRace struct {
-	mu        sync.Mutex
-	winner    *models.Alternative
-	winnerErr error
-	doneCh    chan struct{}
-	ctx       context.Context
-	cancel    context.CancelFunc
-	wg        sync.WaitGroup
-	doneOnce  sync.Once
-}
-
-func newStreamingRace(timeoutMs int) *streamingRace {
-	ctx, cancel := context.WithCancel(context.Background())
-	if timeoutMs > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeoutMs)*time.Millisecond)
-	}
-
-	return &streamingRace{
-		doneCh: make(chan struct{}),
-		ctx:    ctx,
-		cancel: cancel,
-	}
-}
-
-func (r *streamingRace) cleanup() {
-	r.cancel()
-}
-
-func (r *streamingRace) waitForCompletion() {
-	r.wg.Wait()
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if r.winner == nil {
-		r.doneOnce.Do(func() { close(r.doneCh) })
-	}
-}
-
-func (r *streamingRace) tryExecute(c *fiber.Ctx, provider models.Alternative, executeFunc models.ExecutionFunc) (bool, time.Duration, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// Check if cancelled or already won
-	select {
-	case <-r.ctx.Done():
-		return false, 0, nil
-	default:
-	}
-
-	if r.winner != nil {
-		return false, 0, nil
-	}
-
-	// Execute while holding lock (prevents concurrent Fiber context access)
-	start := time.Now()
-	err := executeFunc(c, provider, "")
-	return true, time.Since(start), err
-}
-
-func (r *streamingRace) recordWin(provider models.Alternative) {
-	r.winner = &provider
-	r.winnerErr = nil
-	r.doneOnce.Do(func() { close(r.doneCh) })
-	r.cancel() // Cancel other goroutines
-}
-
-func (r *streamingRace) awaitResult(requestID string) error {
-	select {
-	case <-r.doneCh:
-		r.mu.Lock()
-		defer r.mu.Unlock()
-		if r.winner != nil {
-			fiberlog.Infof("[%s] ═══ Streaming Race Complete (Winner: %s/%s) ═══",
-				requestID, r.winner.Provider, r.winner.Model)
-			return r.winnerErr
-		}
-		fiberlog.Errorf("[%s] ❌ All streaming race providers failed", requestID)
-		return fmt.Errorf("all streaming race providers failed")
-	case <-r.ctx.Done():
-		// Double-check for race between doneCh and timeout
-		select {
-		case <-r.doneCh:
-			r.mu.Lock()
-			defer r.mu.Unlock()
-			if r.winner != nil {
-				return r.winnerErr
-			}
-		default:
-			fiberlog.Errorf("[%s] ❌ Streaming race timeout: %v", requestID, r.ctx.Err())
-			return fmt.Errorf("streaming race timeout: %w", r.ctx.Err())
-		}
-		return fmt.Errorf("all streaming race providers failed")
-	}
-}
-
-func (fs *FallbackService) raceProvider(
-	c *fiber.Ctx,
-	provider models.Alternative,
-	executeFunc models.ExecutionFunc,
-	race *streamingRace,
-	requestID string,
-) {
-	defer race.wg.Done()
-
-	start := time.Now()
-	fiberlog.Infof("[%s] 🏃 Racing streaming provider %s/%s", requestID, provider.Provider, provider.Model)
-
-	executed, duration, err := race.tryExecute(c, provider, executeFunc)
-
-	if !executed {
-		fiberlog.Debugf("[%s] Provider %s/%s skipped (race won or cancelled)", requestID, provider.Provider, provider.Model)
-		return
-	}
-
-	if err == nil {
-		fiberlog.Infof("[%s] 🏆 STREAMING RACE WINNER: %s/%s (connected in %v)",
-			requestID, provider.Provider, provider.Model, time.Since(start))
-		race.recordWin(provider)
-	} else {
-		fiberlog.Warnf("[%s] ❌ Streaming provider %s/%s failed in %v: %v",
-			requestID, provider.Provider, provider.Model, duration, err)
-	}
-}
-
-func (fs *FallbackService) logProviders(providers []models.Alternative, requestID string) {
-	fiberlog.Infof("[%s] 🏁 Racing streaming providers:", requestID)
-	for i, p := range providers {
-		prefix := "ALTERNATIVE"
-		if i == 0 {
-			prefix = "PRIMARY"
-		}
-		fiberlog.Infof("[%s]    • %s: %s/%s", requestID, prefix, p.Provider, p.Model)
+	// All providers failed; a rejected request is reported as such rather than as an outage
+	if clientErr != nil {
+		return clientErr
 	}
+	return allFailed("all providers failed in race", errors)
 }
 
 // GetFallbackConfig gets the merged fallback configuration from config and request
@@ -0,0 +1,168 @@
+package fallback
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// defaultHedgeDelay applies until a model has enough samples for a meaningful p95
+	defaultHedgeDelay = 2 * time.Second
+	minHedgeDelay     = 50 * time.Millisecond
+	minHedgeSamples   = 10
+)
+
+// hedgeDelay returns how long the provider may stay quiet before the next one is started:
+// fallback.hedge_delay_ms when set, otherwise the model's observed p95 time to first token for
+// streams or p95 latency for complete responses
+func (fs *FallbackService) hedgeDelay(provider models.Alternative, fallbackConfig models.FallbackConfig, isStream bool) time.Duration {
+	if fallbackConfig.HedgeDelayMs > 0 {
+		return time.Duration(fallbackConfig.HedgeDelayMs) * time.Millisecond
+	}
+	stats := fs.statsTracker.Snapshot(provider.Provider, provider.Model)
+	p95 := stats.P95Latency
+	if isStream {
+		p95 = stats.P95TimeToFirstToken
+	}
+	if stats.Samples < minHedgeSamples || p95 <= 0 {
+		return defaultHedgeDelay
+	}
+	return max(p95, minHedgeDelay)
+}
+
+// executeHedge runs a hedged non-streaming request. Attempts run concurrently, so each one
+// writes to its own copy of c and only the winner's response is copied to the client.
+func (fs *FallbackService) executeHedge(
+	c *fiber.Ctx,
+	providers []models.Alternative,
+	fallbackConfig models.FallbackConfig,
+	executeFunc models.ExecutionFunc,
+	requestID string,
+) error {
+	return fs.runContenders(c, providers, fallbackConfig, detachedAttempts(c, executeFunc), requestID, false)
+}
+
+// userValue is a fasthttp user value, which backs fiber locals
+type userValue struct {
+	key   any
+	value any
+}
+
+// detachedAttempts adapts executeFunc to run each attempt on its own fiber.Ctx over a copy of
+// the request, its locals and its user context. Cancelling an attempt's ctx cancels the user
+// context the attempt sees, and with it the provider call. Usage the attempt records is held
+// until it is committed, so losers that completed anyway are not billed, and its routing trace
+// and usage metadata are its own, since attempts record at the same time.
+func detachedAttempts(c *fiber.Ctx, executeFunc models.ExecutionFunc) models.StreamOpenFunc {
+	app := c.App()
+	userCtx := c.UserContext()
+
+	// Snapshot c now: attempts run concurrently with the handler and must not read it
+	var mu sync.Mutex
+	var request fasthttp.Request
+	c.Request().CopyTo(&request)
+	remoteAddr := c.Context().RemoteAddr()
+	trace := routing_trace.From(c)
+	var locals []userValue
+	c.Context().VisitUserValuesAll(func(key, value any) {
+		locals = append(locals, userValue{key: key, value: value})
+	})
+
+	return func(ctx context.Context, provider models.Alternative, requestID string) (models.PendingStream, error) {
+		fctx := &fasthttp.RequestCtx{}
+		mu.Lock()
+		fctx.Init(&request, remoteAddr, nil)
+		mu.Unlock()
+		for _, local := range locals {
+			fctx.SetUserValue(local.key, local.value)
+		}
+
+		attemptCtx, cancel := context.WithCancel(userCtx)
+		attempt := &detachedAttempt{
+			app:      app,
+			ctx:      app.AcquireCtx(fctx),
+			provider: provider,
+			cancel:   cancel,
+			detach:   context.AfterFunc(ctx, cancel),
+			trace:    trace,
+			fork:     trace.Fork(),
+		}
+		attempt.ctx.SetUserContext(attemptCtx)
+		routing_trace.Attach(attempt.ctx, attempt.fork)
+		usage.Detach(attempt.ctx)
+
+		err := executeFunc(attempt.ctx, provider, requestID)
+		if err == nil && attempt.ctx.Response().StatusCode() >= fiber.StatusBadRequest {
+			// Some handlers write provider errors as responses instead of returning them
+			err = fmt.Errorf("provider %s responded with status %d", provider.Provider, attempt.ctx.Response().StatusCode())
+		}
+		if err != nil {
+			attempt.Abort()
+			return nil, err
+		}
+		return attempt, nil
+	}
+}
+
+// detachedAttempt is a completed attempt whose response has not been sent to the client yet
+type detachedAttempt struct {
+	app      *fiber.App
+	ctx      *fiber.Ctx
+	provider models.Alternative
+	cancel   context.CancelFunc
+	// detach stops ctx cancellation from reaching the attempt
+	detach func() bool
+	// trace is the request's routing trace; fork is the attempt's own, merged into trace
+	trace *routing_trace.Recorder
+	fork  *routing_trace.Recorder
+}
+
+// Commit records the attempt's held usage, merges its routing trace and copies its response and
+// the locals it recorded, such as the shadow primary, to c. The attempt's user context stays live for work it started in
+// the background, such as storing the selection in the semantic cache.
+func (a *detachedAttempt) Commit(c *fiber.Ctx) error {
+	a.detach()
+	defer a.app.ReleaseCtx(a.ctx)
+	usage.Commit(a.ctx)
+	a.trace.Merge(a.fork)
+
+	resp := a.ctx.Response()
+	for key, value := range resp.Header.All() {
+		if !bytes.EqualFold(key, []byte(fiber.HeaderContentLength)) {
+			c.Response().Header.SetBytesKV(key, value)
+		}
+	}
+	c.Status(resp.StatusCode())
+	c.Response().SetBody(resp.Body())
+
+	userCtx := c.UserContext()
+	a.ctx.Context().VisitUserValuesAll(func(key, value any) {
+		if fork, ok := value.(*routing_trace.Recorder); ok && fork == a.fork {
+			return
+		}
+		c.Context().SetUserValue(key, value)
+	})
+	c.SetUserContext(userCtx)
+
+	a.trace.Select(a.provider.Provider, a.provider.Model)
+	return nil
+}
+
+// Abort cancels the attempt, merges the failures it recorded into the request's routing trace
+// and releases its context
+func (a *detachedAttempt) Abort() {
+	a.detach()
+	a.cancel()
+	a.trace.Merge(a.fork)
+	a.app.ReleaseCtx(a.ctx)
+}
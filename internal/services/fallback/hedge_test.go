@@ -0,0 +1,166 @@
+package fallback
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestHedgeDelay(t *testing.T) {
+	tracker := provider_stats.NewTracker(nil)
+	record := func(model string, n int, latency, ttft time.Duration) {
+		for range n {
+			tracker.Record("acme", model, provider_stats.Sample{Latency: latency, TimeToFirstToken: ttft})
+		}
+	}
+	record("observed", minHedgeSamples, 800*time.Millisecond, 300*time.Millisecond)
+	record("fast", minHedgeSamples, time.Millisecond, time.Millisecond)
+	record("new", minHedgeSamples-1, 800*time.Millisecond, 300*time.Millisecond)
+	fs := &FallbackService{statsTracker: tracker}
+
+	tests := []struct {
+		name           string
+		model          string
+		fallbackConfig models.FallbackConfig
+		isStream       bool
+		want           time.Duration
+	}{
+		{name: "configured delay", model: "observed", fallbackConfig: models.FallbackConfig{HedgeDelayMs: 150}, want: 150 * time.Millisecond},
+		{name: "p95 latency", model: "observed", want: 800 * time.Millisecond},
+		{name: "p95 time to first token for streams", model: "observed", isStream: true, want: 300 * time.Millisecond},
+		{name: "too few samples", model: "new", want: defaultHedgeDelay},
+		{name: "no samples", model: "unknown", isStream: true, want: defaultHedgeDelay},
+		{name: "floored", model: "fast", want: minHedgeDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := models.Alternative{Provider: "acme", Model: tt.model}
+			if got := fs.hedgeDelay(provider, tt.fallbackConfig, tt.isStream); got != tt.want {
+				t.Errorf("hedgeDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (&FallbackService{}).hedgeDelay(models.Alternative{Provider: "acme"}, models.FallbackConfig{}, false); got != defaultHedgeDelay {
+		t.Errorf("hedgeDelay() without a tracker = %v, want %v", got, defaultHedgeDelay)
+	}
+}
+
+func TestExecuteHedge(t *testing.T) {
+	fs := newTestService(nil)
+	app := fiber.New()
+	c := newTestCtx(t, app)
+	c.Request().SetBodyString(`{"model":"auto"}`)
+	c.Locals("api_key", "key-1")
+
+	var seen []string
+	execute := func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+		if provider.Provider == "slow" {
+			// The primary outlives the hedge delay and is cancelled once the hedge wins
+			select {
+			case <-c.UserContext().Done():
+				return c.UserContext().Err()
+			case <-time.After(time.Second):
+			}
+		}
+		seen = append(seen, string(c.Body())+" "+c.Locals("api_key").(string))
+		c.Set("X-Served-By", provider.Provider)
+		c.Locals("served_by", provider.Provider)
+		return c.Status(http.StatusCreated).SendString("response from " + provider.Provider)
+	}
+	providers := []models.Alternative{{Provider: "slow", Model: "m"}, {Provider: "hedge", Model: "m"}}
+	fallbackConfig := models.FallbackConfig{Mode: models.FallbackModeHedge, HedgeDelayMs: 20}
+
+	if err := fs.Execute(c, providers, fallbackConfig, execute, "req", false); err != nil {
+		t.Fatalf("Execute() = %v", err)
+	}
+	if got := string(c.Response().Body()); got != "response from hedge" {
+		t.Errorf("body = %q, want the hedge's response", got)
+	}
+	if got := c.Response().StatusCode(); got != http.StatusCreated {
+		t.Errorf("status = %d, want %d", got, http.StatusCreated)
+	}
+	if got := c.GetRespHeader("X-Served-By"); got != "hedge" {
+		t.Errorf("X-Served-By = %q, want hedge", got)
+	}
+	if got := c.Locals("served_by"); got != "hedge" {
+		t.Errorf("served_by local = %v, want hedge", got)
+	}
+	if len(seen) != 1 || seen[0] != `{"model":"auto"} key-1` {
+		t.Errorf("attempts saw %q, want the request body and locals", seen)
+	}
+}
+
+func TestExecuteHedgeFailedResponse(t *testing.T) {
+	fs := newTestService(nil)
+	c := newTestCtx(t, fiber.New())
+
+	// Handlers that write a provider error as the response instead of returning it still fail over
+	execute := func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+		if provider.Provider == "broken" {
+			return c.Status(http.StatusBadGateway).SendString("upstream failed")
+		}
+		return c.SendString("ok")
+	}
+	providers := []models.Alternative{{Provider: "broken"}, {Provider: "healthy"}}
+	fallbackConfig := models.FallbackConfig{Mode: models.FallbackModeHedge, HedgeDelayMs: 10000}
+
+	if err := fs.Execute(c, providers, fallbackConfig, execute, "req", false); err != nil {
+		t.Fatalf("Execute() = %v", err)
+	}
+	if got := string(c.Response().Body()); got != "ok" || c.Response().StatusCode() != http.StatusOK {
+		t.Errorf("response = %d %q, want 200 ok", c.Response().StatusCode(), got)
+	}
+}
+
+func TestExecuteHedgeBillsServedAttempt(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.APIKeyUsage{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	usageService := usage.NewService(db, nil)
+
+	fs := newTestService(nil)
+	c := newTestCtx(t, fiber.New())
+
+	finished := make(chan struct{})
+	execute := func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+		if provider.Provider == "slow" {
+			// The losing primary completes anyway, after the hedge was served
+			defer close(finished)
+			time.Sleep(50 * time.Millisecond)
+		}
+		usageService.Record(c, models.RecordUsageParams{Provider: provider.Provider, Model: provider.Model, RequestID: requestID})
+		return c.SendString(provider.Provider)
+	}
+	providers := []models.Alternative{{Provider: "slow", Model: "m"}, {Provider: "hedge", Model: "m"}}
+	fallbackConfig := models.FallbackConfig{Mode: models.FallbackModeHedge, HedgeDelayMs: 10}
+
+	if err := fs.Execute(c, providers, fallbackConfig, execute, "req", false); err != nil {
+		t.Fatalf("Execute() = %v", err)
+	}
+	<-finished
+
+	var records []models.APIKeyUsage
+	if err := db.Find(&records).Error; err != nil {
+		t.Fatalf("read usage: %v", err)
+	}
+	if len(records) != 1 || records[0].Provider != "hedge" {
+		t.Errorf("usage recorded for %+v, want only the served hedge", records)
+	}
+}
@@ -0,0 +1,71 @@
+package fallback
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// EnableStreamResume turns on mid-stream recovery for the stream committed to c when
+// fallback.mid_stream_recovery is set: if the stream fails after output was sent, the providers
+// after the one serving it are asked in order to continue from the partial output, and the first
+// to open a stream is spliced into the response. continueFunc runs after the handler returned,
+// so like a StreamOpenFunc it must not touch c.
+func (fs *FallbackService) EnableStreamResume(
+	c *fiber.Ctx,
+	providers []models.Alternative,
+	fallbackConfig models.FallbackConfig,
+	continueFunc models.StreamContinueFunc,
+	requestID string,
+) {
+	if !fallbackConfig.MidStreamRecovery || len(providers) < 2 || continueFunc == nil {
+		return
+	}
+	handlers.SetResumer(c, func(provider, model string) handlers.ResumeFunc {
+		return resumeAfter(providers, provider, model, continueFunc, requestID)
+	})
+}
+
+// resumeAfter returns the ResumeFunc of a stream served by provider/model. Each call moves on
+// through the providers after it, so a continuation that fails as well resumes on the next one.
+func resumeAfter(
+	providers []models.Alternative,
+	provider, model string,
+	continueFunc models.StreamContinueFunc,
+	requestID string,
+) handlers.ResumeFunc {
+	next := 0
+	for i, p := range providers {
+		if p.Provider == provider && p.Model == model {
+			next = i + 1
+			break
+		}
+	}
+
+	return func(ctx context.Context, partial string) (*handlers.PendingStream, error) {
+		for next < len(providers) {
+			p := providers[next]
+			next++
+			if p.Provider == provider && p.Model == model {
+				continue
+			}
+
+			fiberlog.Infof("[%s] 🔄 Resuming stream on %s/%s", requestID, p.Provider, p.Model)
+			stream, err := continueFunc(ctx, p, partial, requestID)
+			if err != nil {
+				fiberlog.Warnf("[%s] ❌ Could not resume stream on %s/%s: %v", requestID, p.Provider, p.Model, err)
+				continue
+			}
+			if pending, ok := stream.(*pendingStream); ok {
+				return pending.stream, nil
+			}
+			stream.Abort()
+		}
+		return nil, fmt.Errorf("no provider left to resume the stream")
+	}
+}
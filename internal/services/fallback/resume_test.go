@@ -0,0 +1,69 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
+)
+
+func TestResumeAfter(t *testing.T) {
+	providers := []models.Alternative{
+		{Provider: "a", Model: "m"},
+		{Provider: "b", Model: "m"},
+		{Provider: "c", Model: "m"},
+		{Provider: "d", Model: "m"},
+	}
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name       string
+		serving    models.Alternative
+		failing    []string
+		wantTried  []string
+		wantResume string
+	}{
+		{name: "next provider continues", serving: providers[0], wantTried: []string{"b"}, wantResume: "b"},
+		{name: "failed continuation moves on", serving: providers[0], failing: []string{"b"}, wantTried: []string{"b", "c"}, wantResume: "c"},
+		{name: "only providers after the serving one", serving: providers[2], wantTried: []string{"d"}, wantResume: "d"},
+		{name: "none left", serving: providers[3]},
+		{name: "all fail", serving: providers[1], failing: []string{"c", "d"}, wantTried: []string{"c", "d"}},
+		{name: "unknown serving provider starts over", serving: models.Alternative{Provider: "x"}, wantTried: []string{"a"}, wantResume: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []string
+			streams := map[string]*handlers.PendingStream{}
+			continueFunc := func(ctx context.Context, provider models.Alternative, partial string, requestID string) (models.PendingStream, error) {
+				if partial != "Hello, wor" {
+					t.Errorf("partial = %q, want the output sent so far", partial)
+				}
+				tried = append(tried, provider.Provider)
+				if slices.Contains(tt.failing, provider.Provider) {
+					return nil, errUnavailable
+				}
+				streams[provider.Provider] = &handlers.PendingStream{}
+				return NewPendingStream(streams[provider.Provider], nil, nil), nil
+			}
+
+			resume := resumeAfter(providers, tt.serving.Provider, tt.serving.Model, continueFunc, "req")
+			stream, err := resume(context.Background(), "Hello, wor")
+			if !slices.Equal(tried, tt.wantTried) {
+				t.Errorf("tried %v, want %v", tried, tt.wantTried)
+			}
+			if tt.wantResume == "" {
+				if err == nil {
+					t.Errorf("resume() = %v, want an error", stream)
+				}
+				return
+			}
+			if err != nil || stream != streams[tt.wantResume] {
+				t.Errorf("resume() = (%p, %v), want the stream of %s", stream, err, tt.wantResume)
+			}
+		})
+	}
+}
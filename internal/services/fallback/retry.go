@@ -0,0 +1,123 @@
+package fallback
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	defaultMaxRetryDelay  = 30 * time.Second
+)
+
+// retryPolicy controls how often and how long apart one provider is retried
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	// maxBackoff caps the backoff; a provider asking to wait longer is not retried
+	maxBackoff time.Duration
+}
+
+// retryPolicy returns the policy of a provider: fallback.max_retries, overridden by the
+// provider's retry_config (max_retries, initial_backoff_ms, max_backoff_ms). The backoff never
+// exceeds fallback.max_retry_delay_ms: fasthttp does not cancel the request context when the
+// client disconnects, so a wait holds the handler until it ends.
+func (fs *FallbackService) retryPolicy(provider string, fallbackConfig models.FallbackConfig) retryPolicy {
+	policy := retryPolicy{
+		maxRetries:     fallbackConfig.MaxRetries,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+	}
+	if providerConfig, ok := fs.cfg.ServingProviderConfig(provider, fs.endpoint); ok {
+		if n, ok := intOption(providerConfig.RetryConfig, "max_retries"); ok {
+			policy.maxRetries = n
+		}
+		if ms, ok := intOption(providerConfig.RetryConfig, "initial_backoff_ms"); ok && ms > 0 {
+			policy.initialBackoff = time.Duration(ms) * time.Millisecond
+		}
+		if ms, ok := intOption(providerConfig.RetryConfig, "max_backoff_ms"); ok && ms > 0 {
+			policy.maxBackoff = time.Duration(ms) * time.Millisecond
+		}
+	}
+	maxRetryDelay := defaultMaxRetryDelay
+	if fallbackConfig.MaxRetryDelayMs > 0 {
+		maxRetryDelay = time.Duration(fallbackConfig.MaxRetryDelayMs) * time.Millisecond
+	}
+	policy.maxBackoff = min(policy.maxBackoff, maxRetryDelay)
+	return policy
+}
+
+// intOption reads an integer from retry_config, which holds YAML ints or JSON numbers
+func intOption(options map[string]any, key string) (int, bool) {
+	switch v := options[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// backoff returns the delay before retry number attempt (starting at 0): exponential growth
+// from initialBackoff capped at maxBackoff, with jitter over its upper half
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.initialBackoff << min(attempt, 20)
+	if delay <= 0 || delay > p.maxBackoff {
+		delay = p.maxBackoff
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// WithRetries wraps executeFunc so each provider is retried on rate limits, server and network
+// errors before the caller moves on to the next alternative. Retries wait for the provider's
+// Retry-After or rate-limit reset when given, and use exponential backoff with jitter otherwise.
+// Streams are only retried before anything was sent to the client, since providers fail a
+// stream before its first chunk is written.
+func (fs *FallbackService) WithRetries(executeFunc models.ExecutionFunc, fallbackConfig models.FallbackConfig) models.ExecutionFunc {
+	return func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+		policy := fs.retryPolicy(provider.Provider, fallbackConfig)
+
+		for attempt := 0; ; attempt++ {
+			usage.StartAttempt(c)
+			err := executeFunc(c, provider, requestID)
+			if err == nil || attempt >= policy.maxRetries {
+				return err
+			}
+			if class := ClassifyAttempt(c.UserContext(), err); class != ErrorRetryable {
+				fiberlog.Debugf("[%s] Not retrying %s/%s (%s error)", requestID, provider.Provider, provider.Model, class)
+				return err
+			}
+
+			delay := policy.backoff(attempt)
+			if wait := RetryAfter(err, time.Now()); wait > 0 {
+				if wait > policy.maxBackoff {
+					fiberlog.Warnf("[%s] ⏳ %s/%s asked to retry after %v (max %v), moving on",
+						requestID, provider.Provider, provider.Model, wait, policy.maxBackoff)
+					return err
+				}
+				delay = wait
+			}
+
+			fiberlog.Infof("[%s] 🔁 Retrying %s/%s in %v (retry %d/%d): %v",
+				requestID, provider.Provider, provider.Model, delay, attempt+1, policy.maxRetries, err)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.UserContext().Done():
+				timer.Stop()
+				return fmt.Errorf("request cancelled while waiting to retry %s: %w", provider.Provider, err)
+			}
+		}
+	}
+}
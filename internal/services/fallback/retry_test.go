@@ -0,0 +1,288 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/config"
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// newTestService returns a chat completions fallback service whose providers have retryConfigs
+func newTestService(retryConfigs map[string]map[string]any) *FallbackService {
+	providers := make(map[string]models.ProviderConfig, len(retryConfigs))
+	for provider, retryConfig := range retryConfigs {
+		providers[provider] = models.ProviderConfig{RetryConfig: retryConfig}
+	}
+	cfg := &config.Config{}
+	cfg.Endpoints.ChatCompletions.Providers = providers
+	return NewFallbackService(cfg, "chat_completions", nil)
+}
+
+// newTestCtx returns a request context for app, released when the test ends
+func newTestCtx(t *testing.T, app *fiber.App) *fiber.Ctx {
+	t.Helper()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	t.Cleanup(func() { app.ReleaseCtx(c) })
+	return c
+}
+
+func TestRetryPolicy(t *testing.T) {
+	fs := newTestService(map[string]map[string]any{
+		"tuned":   {"max_retries": 5, "initial_backoff_ms": 100, "max_backoff_ms": 2000},
+		"json":    {"max_retries": float64(1), "initial_backoff_ms": int64(50)},
+		"invalid": {"max_retries": "3", "initial_backoff_ms": -1, "max_backoff_ms": 0},
+		"patient": {"max_backoff_ms": 120000},
+	})
+
+	tests := []struct {
+		name           string
+		provider       string
+		fallbackConfig models.FallbackConfig
+		want           retryPolicy
+	}{
+		{
+			name:           "defaults",
+			provider:       "unconfigured",
+			fallbackConfig: models.FallbackConfig{MaxRetries: 2},
+			want:           retryPolicy{maxRetries: 2, initialBackoff: defaultInitialBackoff, maxBackoff: defaultMaxBackoff},
+		},
+		{
+			name:           "provider overrides",
+			provider:       "tuned",
+			fallbackConfig: models.FallbackConfig{MaxRetries: 2},
+			want:           retryPolicy{maxRetries: 5, initialBackoff: 100 * time.Millisecond, maxBackoff: 2 * time.Second},
+		},
+		{
+			name:     "json numbers",
+			provider: "json",
+			want:     retryPolicy{maxRetries: 1, initialBackoff: 50 * time.Millisecond, maxBackoff: defaultMaxBackoff},
+		},
+		{
+			name:           "invalid options ignored",
+			provider:       "invalid",
+			fallbackConfig: models.FallbackConfig{MaxRetries: 1},
+			want:           retryPolicy{maxRetries: 1, initialBackoff: defaultInitialBackoff, maxBackoff: defaultMaxBackoff},
+		},
+		{
+			name:     "capped by the default max retry delay",
+			provider: "patient",
+			want:     retryPolicy{initialBackoff: defaultInitialBackoff, maxBackoff: defaultMaxRetryDelay},
+		},
+		{
+			name:           "capped by max_retry_delay_ms",
+			provider:       "tuned",
+			fallbackConfig: models.FallbackConfig{MaxRetryDelayMs: 500},
+			want:           retryPolicy{maxRetries: 5, initialBackoff: 100 * time.Millisecond, maxBackoff: 500 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fs.retryPolicy(tt.provider, tt.fallbackConfig); got != tt.want {
+				t.Errorf("retryPolicy(%q) = %+v, want %+v", tt.provider, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := retryPolicy{initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 0, ceiling: 100 * time.Millisecond},
+		{attempt: 1, ceiling: 200 * time.Millisecond},
+		{attempt: 3, ceiling: 800 * time.Millisecond},
+		{attempt: 4, ceiling: time.Second},
+		{attempt: 100, ceiling: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			// Jitter spreads the delay over the upper half of the exponential step
+			if got := policy.backoff(tt.attempt); got < tt.ceiling/2 || got > tt.ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, got, tt.ceiling/2, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	errRateLimited := openaiError(http.StatusTooManyRequests, nil)
+	errRetryAfter := func(value string) error {
+		return openaiError(http.StatusTooManyRequests, http.Header{"Retry-After": []string{value}})
+	}
+
+	tests := []struct {
+		name           string
+		failures       []error
+		fallbackConfig models.FallbackConfig
+		wantAttempts   int
+		wantErr        bool
+		minElapsed     time.Duration
+	}{
+		{
+			name:           "succeeds first time",
+			fallbackConfig: models.FallbackConfig{MaxRetries: 3},
+			wantAttempts:   1,
+		},
+		{
+			name:           "retries until success",
+			failures:       []error{errRateLimited, openaiError(http.StatusBadGateway, nil)},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 3},
+			wantAttempts:   3,
+		},
+		{
+			name:           "gives up after max retries",
+			failures:       []error{errRateLimited, errRateLimited, errRateLimited, errRateLimited},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 2},
+			wantAttempts:   3,
+			wantErr:        true,
+		},
+		{
+			name:         "retries disabled",
+			failures:     []error{errRateLimited},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:           "client error not retried",
+			failures:       []error{openaiError(http.StatusBadRequest, nil)},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 3},
+			wantAttempts:   1,
+			wantErr:        true,
+		},
+		{
+			name:           "provider error not retried",
+			failures:       []error{openaiError(http.StatusUnauthorized, nil)},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 3},
+			wantAttempts:   1,
+			wantErr:        true,
+		},
+		{
+			name:           "cancellation not retried",
+			failures:       []error{fmt.Errorf("read body: %w", context.Canceled)},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 3},
+			wantAttempts:   1,
+			wantErr:        true,
+		},
+		{
+			name:           "waits for Retry-After",
+			failures:       []error{errRetryAfter("0.05")},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 1},
+			wantAttempts:   2,
+			minElapsed:     50 * time.Millisecond,
+		},
+		{
+			name:           "Retry-After beyond the max retry delay moves on",
+			failures:       []error{errRetryAfter("0.05")},
+			fallbackConfig: models.FallbackConfig{MaxRetries: 3, MaxRetryDelayMs: 20},
+			wantAttempts:   1,
+			wantErr:        true,
+		},
+	}
+
+	fs := newTestService(map[string]map[string]any{
+		"acme": {"initial_backoff_ms": 1, "max_backoff_ms": 100},
+	})
+	app := fiber.New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCtx(t, app)
+			attempts := 0
+			execute := func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+				attempts++
+				if attempts <= len(tt.failures) {
+					return tt.failures[attempts-1]
+				}
+				return nil
+			}
+
+			start := time.Now()
+			err := fs.WithRetries(execute, tt.fallbackConfig)(c, models.Alternative{Provider: "acme", Model: "m"}, "req")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("elapsed = %v, want at least %v", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestWithRetriesCancelledWhileWaiting(t *testing.T) {
+	fs := newTestService(nil)
+	c := newTestCtx(t, fiber.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	c.SetUserContext(ctx)
+
+	errUnavailable := openaiError(http.StatusServiceUnavailable, nil)
+	attempts := 0
+	execute := func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+		attempts++
+		cancel()
+		return errUnavailable
+	}
+
+	err := fs.WithRetries(execute, models.FallbackConfig{MaxRetries: 3})(c, models.Alternative{Provider: "acme"}, "req")
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("err = %v, want the provider error wrapped", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
+
+func TestExecuteStopsWhenCancelled(t *testing.T) {
+	fs := newTestService(nil)
+	providers := []models.Alternative{{Provider: "primary"}, {Provider: "alternative"}}
+
+	for _, mode := range []models.FallbackMode{models.FallbackModeSequential, models.FallbackModeRace} {
+		t.Run(string(mode), func(t *testing.T) {
+			c := newTestCtx(t, fiber.New())
+			ctx, cancel := context.WithCancel(context.Background())
+			c.SetUserContext(ctx)
+
+			var mu sync.Mutex
+			var tried []string
+			execute := func(c *fiber.Ctx, provider models.Alternative, requestID string) error {
+				mu.Lock()
+				tried = append(tried, provider.Provider)
+				mu.Unlock()
+				if provider.Provider == "primary" {
+					// The client disconnects while the primary is in flight
+					cancel()
+					return fmt.Errorf("read response: %w", context.Canceled)
+				}
+				// Execute may return before this attempt does, so it leaves c alone
+				<-ctx.Done()
+				return ctx.Err()
+			}
+
+			err := fs.Execute(c, providers, models.FallbackConfig{Mode: mode}, execute, "req", false)
+			if ClassifyError(err) != ErrorCancelled {
+				t.Errorf("Execute() = %v, want a cancellation", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if mode == models.FallbackModeSequential && len(tried) != 1 {
+				t.Errorf("tried %v, want only the primary", tried)
+			}
+		})
+	}
+}
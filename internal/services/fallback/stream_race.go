@@ -0,0 +1,285 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// pendingStream adapts a prepared stream pipeline to models.PendingStream
+type pendingStream struct {
+	stream      *handlers.PendingStream
+	observation *provider_stats.Observation
+	onCommit    func(c *fiber.Ctx)
+}
+
+// NewPendingStream wraps a prepared stream for RaceStreams. onCommit runs on the request
+// goroutine once the stream wins, to record the selection against c; a losing stream is closed
+// and only its time to first token is recorded through observation.
+func NewPendingStream(stream *handlers.PendingStream, observation *provider_stats.Observation, onCommit func(c *fiber.Ctx)) models.PendingStream {
+	return &pendingStream{stream: stream, observation: observation, onCommit: onCommit}
+}
+
+func (p *pendingStream) Commit(c *fiber.Ctx) error {
+	if p.onCommit != nil {
+		p.onCommit(c)
+	}
+	return p.stream.Commit(c)
+}
+
+func (p *pendingStream) Abort() {
+	p.stream.Abort()
+	p.observation.Abandon()
+}
+
+// contender is the outcome of opening one provider's stream in a race
+type contender struct {
+	index    int
+	provider models.Alternative
+	stream   models.PendingStream
+	err      error
+	// elapsed is the time to first content for opened streams
+	elapsed time.Duration
+}
+
+// RaceStreams opens the streams of the providers and sends the first one to produce content to
+// the client. In race mode all providers are opened at once; in hedge mode the primary starts
+// alone and the next provider is only opened when the previous ones have produced nothing within
+// the hedge delay, or have failed. The losers' upstream requests are cancelled and their streams
+// closed; only the winner ever touches c. fallback.timeout_ms bounds the wait for a winner.
+// Contenders are not retried, since the other contenders are the fallback.
+func (fs *FallbackService) RaceStreams(
+	c *fiber.Ctx,
+	providers []models.Alternative,
+	fallbackConfig models.FallbackConfig,
+	openFunc models.StreamOpenFunc,
+	requestID string,
+) error {
+	if c == nil || openFunc == nil || requestID == "" {
+		return fmt.Errorf("invalid input parameters")
+	}
+	if len(providers) == 0 {
+		return fmt.Errorf("no providers available")
+	}
+	return fs.runContenders(c, providers, fallbackConfig, openFunc, requestID, true)
+}
+
+// runContenders runs openFunc for the providers, all at once or hedged depending on the mode,
+// and commits the first to succeed. isStream selects the hedge delay statistic and log wording.
+func (fs *FallbackService) runContenders(
+	c *fiber.Ctx,
+	providers []models.Alternative,
+	fallbackConfig models.FallbackConfig,
+	openFunc models.StreamOpenFunc,
+	requestID string,
+	isStream bool,
+) error {
+	hedged := fallbackConfig.Mode == models.FallbackModeHedge
+	kind := "Race"
+	if hedged {
+		kind = "Hedge"
+	}
+	if isStream {
+		kind = "Streaming " + kind
+	}
+	fiberlog.Infof("[%s] ═══ %s Started (%d providers) ═══", requestID, kind, len(providers))
+	fs.logProviders(providers, kind, requestID)
+
+	// Each contender has its own context so cancelling the losers leaves the winner running
+	results := make(chan contender, len(providers))
+	cancels := make([]context.CancelFunc, 0, len(providers))
+	launch := func() {
+		i := len(cancels)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels = append(cancels, cancel)
+		go fs.openContender(ctx, i, providers[i], openFunc, results, kind, requestID)
+	}
+
+	// hedge fires when the most recently started contender has been quiet for its hedge delay
+	var hedge <-chan time.Time
+	startNext := func() {
+		launch()
+		hedge = nil
+		if len(cancels) < len(providers) {
+			hedge = time.After(fs.hedgeDelay(providers[len(cancels)-1], fallbackConfig, isStream))
+		}
+	}
+	if hedged {
+		startNext()
+	} else {
+		for range providers {
+			launch()
+		}
+	}
+
+	var timeout <-chan time.Time
+	if fallbackConfig.TimeoutMs > 0 {
+		timer := time.NewTimer(time.Duration(fallbackConfig.TimeoutMs) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	trace := routing_trace.From(c)
+	reported := make([]bool, len(providers))
+	var errs []error
+	var clientErr error
+
+	// The contenders' contexts are detached from the request, so the race watches it itself
+	requestCtx := c.UserContext()
+	var received int
+	// abandon ends the race without a winner, cancelling every contender still running
+	abandon := func() {
+		fs.dismissLosers(providers, cancels, reported, trace, -1)
+		go drainContenders(results, len(cancels)-received, requestID)
+	}
+
+	for received < len(cancels) {
+		select {
+		case result := <-results:
+			received++
+			reported[result.index] = true
+			if result.err != nil {
+				fiberlog.Warnf("[%s] ❌ %s provider %s/%s failed in %v: %v",
+					requestID, kind, result.provider.Provider, result.provider.Model, result.elapsed, result.err)
+				trace.Contend(result.provider.Provider, result.provider.Model, models.RaceOutcomeFailed, 0)
+				if ClassifyAttempt(requestCtx, result.err) == ErrorCancelled {
+					// The client is gone or out of time; nothing else is worth waiting for or starting
+					abandon()
+					fiberlog.Warnf("[%s] 🛑 %s stopped, the request is done", requestID, kind)
+					return result.err
+				}
+				if !ShouldFallback(requestCtx, result.err) {
+					// Every provider would reject the request the same way; start no more
+					clientErr = result.err
+				}
+				errs = append(errs, fmt.Errorf("%s(%s): %w", result.provider.Provider, result.provider.Model, result.err))
+				// A failed hedge is replaced right away rather than after the delay
+				if hedged && clientErr == nil && len(cancels) < len(providers) {
+					startNext()
+				}
+				continue
+			}
+
+			fiberlog.Infof("[%s] 🏆 %s WINNER: %s/%s (first response in %v)",
+				requestID, strings.ToUpper(kind), result.provider.Provider, result.provider.Model, result.elapsed)
+			trace.Contend(result.provider.Provider, result.provider.Model, models.RaceOutcomeWon, result.elapsed)
+			fs.dismissLosers(providers, cancels, reported, trace, result.index)
+			go drainContenders(results, len(cancels)-received, requestID)
+
+			fiberlog.Infof("[%s] ═══ %s Complete (Winner: %s/%s) ═══",
+				requestID, kind, result.provider.Provider, result.provider.Model)
+			return result.stream.Commit(c)
+
+		case <-hedge:
+			hedge = nil
+			if clientErr == nil {
+				fiberlog.Infof("[%s] ⏱️  No response yet, hedging with %s/%s",
+					requestID, providers[len(cancels)].Provider, providers[len(cancels)].Model)
+				startNext()
+			}
+
+		case <-requestCtx.Done():
+			abandon()
+			fiberlog.Warnf("[%s] 🛑 %s stopped, the request is done: %v", requestID, kind, requestCtx.Err())
+			return fmt.Errorf("%s cancelled: %w", strings.ToLower(kind), requestCtx.Err())
+
+		case <-timeout:
+			abandon()
+			fiberlog.Errorf("[%s] ❌ %s timeout after %dms", requestID, kind, fallbackConfig.TimeoutMs)
+			return fmt.Errorf("%s timeout: %w", strings.ToLower(kind), context.DeadlineExceeded)
+		}
+	}
+
+	fiberlog.Errorf("[%s] ❌ All %s providers failed", requestID, strings.ToLower(kind))
+	// A rejected request is reported as such rather than as an outage
+	if clientErr != nil {
+		return clientErr
+	}
+	return allFailed(fmt.Sprintf("all %s providers failed", strings.ToLower(kind)), errs)
+}
+
+// openContender opens one provider's stream, or runs its detached attempt, and reports the
+// outcome on results
+func (fs *FallbackService) openContender(
+	ctx context.Context,
+	index int,
+	provider models.Alternative,
+	openFunc models.StreamOpenFunc,
+	results chan<- contender,
+	kind string,
+	requestID string,
+) {
+	start := time.Now()
+	result := contender{index: index, provider: provider}
+	defer func() {
+		if r := recover(); r != nil {
+			fiberlog.Errorf("[%s] Panic in %s provider %s: %v", requestID, strings.ToLower(kind), provider.Provider, r)
+			result.stream, result.err = nil, fmt.Errorf("panic: %v", r)
+		}
+		result.elapsed = time.Since(start)
+		results <- result
+	}()
+
+	fiberlog.Infof("[%s] 🏃 %s provider %s/%s started", requestID, kind, provider.Provider, provider.Model)
+	result.stream, result.err = openFunc(ctx, provider, requestID)
+}
+
+// dismissLosers cancels every contender except the winner (-1 for none). Those that have not
+// reported yet are recorded as cancelled now, while the trace is still written to the response.
+func (fs *FallbackService) dismissLosers(
+	providers []models.Alternative,
+	cancels []context.CancelFunc,
+	reported []bool,
+	trace *routing_trace.Recorder,
+	winner int,
+) {
+	for i, cancel := range cancels {
+		if i == winner {
+			continue
+		}
+		cancel()
+		if !reported[i] {
+			trace.Contend(providers[i].Provider, providers[i].Model, models.RaceOutcomeCancelled, 0)
+		}
+	}
+}
+
+// drainContenders waits for the remaining contenders of a decided race and closes any stream
+// that opened anyway
+func drainContenders(results <-chan contender, remaining int, requestID string) {
+	for range remaining {
+		result := <-results
+		switch {
+		case result.err == nil:
+			fiberlog.Debugf("[%s] Discarding losing response %s/%s (first content in %v)",
+				requestID, result.provider.Provider, result.provider.Model, result.elapsed)
+			result.stream.Abort()
+		case errors.Is(result.err, context.Canceled):
+			fiberlog.Debugf("[%s] Cancelled losing stream %s/%s", requestID, result.provider.Provider, result.provider.Model)
+		default:
+			fiberlog.Debugf("[%s] Losing stream %s/%s failed: %v",
+				requestID, result.provider.Provider, result.provider.Model, result.err)
+		}
+	}
+}
+
+func (fs *FallbackService) logProviders(providers []models.Alternative, kind, requestID string) {
+	fiberlog.Infof("[%s] 🏁 %s providers:", requestID, kind)
+	for i, p := range providers {
+		prefix := "ALTERNATIVE"
+		if i == 0 {
+			prefix = "PRIMARY"
+		}
+		fiberlog.Infof("[%s]    • %s: %s/%s", requestID, prefix, p.Provider, p.Model)
+	}
+}
@@ -0,0 +1,280 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeStream is a PendingStream that writes its provider's name as the response
+type fakeStream struct {
+	provider string
+}
+
+func (s *fakeStream) Commit(c *fiber.Ctx) error {
+	return c.SendString(s.provider)
+}
+
+func (s *fakeStream) Abort() {}
+
+// contenderScript is how a provider behaves when its stream is opened
+type contenderScript struct {
+	delay time.Duration
+	err   error
+}
+
+// scriptedOpen returns a StreamOpenFunc following scripts, and the providers it opened in order
+func scriptedOpen(scripts map[string]contenderScript) (models.StreamOpenFunc, func() []string) {
+	var mu sync.Mutex
+	var opened []string
+	open := func(ctx context.Context, provider models.Alternative, requestID string) (models.PendingStream, error) {
+		mu.Lock()
+		opened = append(opened, provider.Provider)
+		mu.Unlock()
+
+		script := scripts[provider.Provider]
+		select {
+		case <-time.After(script.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if script.err != nil {
+			return nil, script.err
+		}
+		return &fakeStream{provider: provider.Provider}, nil
+	}
+	return open, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(opened)
+	}
+}
+
+func TestRaceStreams(t *testing.T) {
+	errUnavailable := openaiError(http.StatusServiceUnavailable, nil)
+	errBadRequest := openaiError(http.StatusBadRequest, nil)
+	race := models.FallbackConfig{Mode: models.FallbackModeRace}
+	hedge := func(delayMs int) models.FallbackConfig {
+		return models.FallbackConfig{Mode: models.FallbackModeHedge, HedgeDelayMs: delayMs}
+	}
+
+	tests := []struct {
+		name           string
+		scripts        map[string]contenderScript
+		fallbackConfig models.FallbackConfig
+		wantWinner     string
+		wantErr        error
+		wantStatus     int
+		wantOpened     []string
+	}{
+		{
+			name:           "race: first to produce content wins",
+			scripts:        map[string]contenderScript{"a": {delay: time.Second}, "b": {}, "c": {delay: time.Second}},
+			fallbackConfig: race,
+			wantWinner:     "b",
+			wantOpened:     []string{"a", "b", "c"},
+		},
+		{
+			name:           "race: failures are skipped",
+			scripts:        map[string]contenderScript{"a": {err: errUnavailable}, "b": {delay: 10 * time.Millisecond}},
+			fallbackConfig: race,
+			wantWinner:     "b",
+			wantOpened:     []string{"a", "b"},
+		},
+		{
+			name:           "race: all failed",
+			scripts:        map[string]contenderScript{"a": {err: errUnavailable}, "b": {err: errUnavailable}},
+			fallbackConfig: race,
+			wantStatus:     http.StatusServiceUnavailable,
+			wantOpened:     []string{"a", "b"},
+		},
+		{
+			name:           "race: rejected request reported as such",
+			scripts:        map[string]contenderScript{"a": {err: errBadRequest}, "b": {err: errUnavailable, delay: 10 * time.Millisecond}},
+			fallbackConfig: race,
+			wantErr:        errBadRequest,
+			wantOpened:     []string{"a", "b"},
+		},
+		{
+			name:           "race: timeout",
+			scripts:        map[string]contenderScript{"a": {delay: time.Second}, "b": {delay: time.Second}},
+			fallbackConfig: models.FallbackConfig{Mode: models.FallbackModeRace, TimeoutMs: 20},
+			wantErr:        context.DeadlineExceeded,
+			wantOpened:     []string{"a", "b"},
+		},
+		{
+			name:           "hedge: fast primary is not hedged",
+			scripts:        map[string]contenderScript{"a": {delay: 5 * time.Millisecond}, "b": {}},
+			fallbackConfig: hedge(500),
+			wantWinner:     "a",
+			wantOpened:     []string{"a"},
+		},
+		{
+			name:           "hedge: quiet primary is hedged after the delay",
+			scripts:        map[string]contenderScript{"a": {delay: time.Second}, "b": {}, "c": {}},
+			fallbackConfig: hedge(20),
+			wantWinner:     "b",
+			wantOpened:     []string{"a", "b"},
+		},
+		{
+			name:           "hedge: failed primary is replaced right away",
+			scripts:        map[string]contenderScript{"a": {err: errUnavailable}, "b": {}},
+			fallbackConfig: hedge(10000),
+			wantWinner:     "b",
+			wantOpened:     []string{"a", "b"},
+		},
+		{
+			name:           "hedge: rejected request starts no more providers",
+			scripts:        map[string]contenderScript{"a": {err: errBadRequest}, "b": {}},
+			fallbackConfig: hedge(10000),
+			wantErr:        errBadRequest,
+			wantOpened:     []string{"a"},
+		},
+	}
+
+	fs := newTestService(nil)
+	app := fiber.New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCtx(t, app)
+			var providers []models.Alternative
+			for _, name := range []string{"a", "b", "c"} {
+				if _, ok := tt.scripts[name]; ok {
+					providers = append(providers, models.Alternative{Provider: name, Model: "m"})
+				}
+			}
+			open, opened := scriptedOpen(tt.scripts)
+
+			err := fs.RaceStreams(c, providers, tt.fallbackConfig, open, "req")
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantStatus != 0:
+				if got := StatusCode(err); got != tt.wantStatus {
+					t.Errorf("err = %v with status %d, want status %d", err, got, tt.wantStatus)
+				}
+			case err != nil:
+				t.Errorf("err = %v, want nil", err)
+			}
+			if got := string(c.Response().Body()); got != tt.wantWinner {
+				t.Errorf("served %q, want %q", got, tt.wantWinner)
+			}
+			got := opened()
+			// A race can be decided before every contender has started opening
+			for deadline := time.Now().Add(time.Second); tt.fallbackConfig.Mode == models.FallbackModeRace &&
+				len(got) < len(tt.wantOpened) && time.Now().Before(deadline); got = opened() {
+				time.Sleep(time.Millisecond)
+			}
+			if !slices.Equal(got, tt.wantOpened) {
+				// Racing contenders start in any order
+				slices.Sort(got)
+				if tt.fallbackConfig.Mode != models.FallbackModeRace || !slices.Equal(got, tt.wantOpened) {
+					t.Errorf("opened %v, want %v", got, tt.wantOpened)
+				}
+			}
+		})
+	}
+}
+
+func TestRaceStreamsCancelsLosers(t *testing.T) {
+	fs := newTestService(nil)
+	c := newTestCtx(t, fiber.New())
+
+	cancelled := make(chan string, 2)
+	open := func(ctx context.Context, provider models.Alternative, requestID string) (models.PendingStream, error) {
+		if provider.Provider == "fast" {
+			return &fakeStream{provider: provider.Provider}, nil
+		}
+		<-ctx.Done()
+		cancelled <- provider.Provider
+		return nil, ctx.Err()
+	}
+	providers := []models.Alternative{{Provider: "slow"}, {Provider: "fast"}, {Provider: "slower"}}
+
+	if err := fs.RaceStreams(c, providers, models.FallbackConfig{Mode: models.FallbackModeRace}, open, "req"); err != nil {
+		t.Fatalf("RaceStreams() = %v", err)
+	}
+	for range 2 {
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatalf("losing contenders were not cancelled")
+		}
+	}
+}
+
+func TestRaceStreamsStopsWhenCancelled(t *testing.T) {
+	fs := newTestService(nil)
+
+	tests := []struct {
+		name           string
+		fallbackConfig models.FallbackConfig
+		// failFirst makes the first contender fail with the cancellation instead of hanging
+		failFirst bool
+	}{
+		{name: "race", fallbackConfig: models.FallbackConfig{Mode: models.FallbackModeRace}},
+		{name: "hedge", fallbackConfig: models.FallbackConfig{Mode: models.FallbackModeHedge, HedgeDelayMs: 10}},
+		{name: "hedge after a cancelled attempt", fallbackConfig: models.FallbackConfig{Mode: models.FallbackModeHedge, HedgeDelayMs: 10000}, failFirst: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCtx(t, fiber.New())
+			ctx, cancel := context.WithCancel(context.Background())
+			c.SetUserContext(ctx)
+
+			var mu sync.Mutex
+			var opened []string
+			cancelled := make(chan string, 2)
+			open := func(streamCtx context.Context, provider models.Alternative, requestID string) (models.PendingStream, error) {
+				mu.Lock()
+				opened = append(opened, provider.Provider)
+				first := len(opened) == 1
+				mu.Unlock()
+				if first {
+					// The client disconnects while the first contender is opening
+					cancel()
+					if tt.failFirst {
+						return nil, errors.Join(errors.New("read response"), context.Canceled)
+					}
+				}
+				<-streamCtx.Done()
+				cancelled <- provider.Provider
+				return nil, streamCtx.Err()
+			}
+			providers := []models.Alternative{{Provider: "primary"}, {Provider: "alternative"}}
+
+			err := fs.RaceStreams(c, providers, tt.fallbackConfig, open, "req")
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("RaceStreams() = %v, want the cancellation", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.fallbackConfig.Mode == models.FallbackModeHedge && len(opened) != 1 {
+				t.Errorf("opened %v, want no hedge after the cancellation", opened)
+			}
+			waiting := len(opened)
+			if tt.failFirst {
+				waiting--
+			}
+			for range waiting {
+				select {
+				case <-cancelled:
+				case <-time.After(time.Second):
+					t.Fatalf("contenders were not cancelled")
+				}
+			}
+		})
+	}
+}
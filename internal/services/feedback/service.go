@@ -0,0 +1,239 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBatchSize     = 50
+	defaultFlushInterval = 10 * time.Second
+	// queueSize caps feedback waiting to be forwarded; entries beyond it are dropped
+	queueSize = 1000
+	// maxCommentLength truncates stored comments
+	maxCommentLength = 4 * 1024
+)
+
+var (
+	// ErrInvalidFeedback is returned when the feedback request is malformed
+	ErrInvalidFeedback = errors.New("invalid feedback")
+	// ErrRequestNotFound is returned when no usage was recorded for the rated request
+	ErrRequestNotFound = errors.New("request not found")
+)
+
+// Service stores feedback next to the usage row of the rated request, invalidates the semantic
+// cache entries behind negatively rated routing decisions and optionally forwards feedback to
+// the adaptive_router service in batches.
+type Service struct {
+	db              *gorm.DB
+	usageService    *usage.Service
+	authProvider    auth.AuthProvider
+	router          *model_router.ModelRouter
+	invalidateCache bool
+	queue           chan models.RoutingFeedback
+	batchSize       int
+	flushInterval   time.Duration
+}
+
+// NewService creates a feedback service. authProvider, router and cfg may be nil; without an
+// auth provider users can only rate requests made with their own API keys. Forwarding starts a
+// background worker that runs for the lifetime of the process.
+func NewService(db *gorm.DB, usageService *usage.Service, authProvider auth.AuthProvider, router *model_router.ModelRouter, cfg *models.FeedbackConfig) *Service {
+	s := &Service{
+		db:              db,
+		usageService:    usageService,
+		authProvider:    authProvider,
+		router:          router,
+		invalidateCache: true,
+		batchSize:       defaultBatchSize,
+		flushInterval:   defaultFlushInterval,
+	}
+	if cfg == nil {
+		return s
+	}
+
+	if cfg.InvalidateCache != nil {
+		s.invalidateCache = *cfg.InvalidateCache
+	}
+	if cfg.BatchSize > 0 {
+		s.batchSize = cfg.BatchSize
+	}
+	if cfg.FlushIntervalMs > 0 {
+		s.flushInterval = time.Duration(cfg.FlushIntervalMs) * time.Millisecond
+	}
+	if cfg.ForwardToRouter && router != nil {
+		s.queue = make(chan models.RoutingFeedback, queueSize)
+		go s.forwardLoop()
+		fiberlog.Infof("Feedback: Forwarding to adaptive_router enabled (batch size: %d, flush interval: %v)",
+			s.batchSize, s.flushInterval)
+	}
+	return s
+}
+
+// AutoMigrate creates the feedback table
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&models.RoutingFeedback{})
+}
+
+// Validate checks that the request rates a request with a supported signal
+func Validate(req *models.FeedbackRequest) error {
+	if req.RequestID == "" {
+		return fmt.Errorf("%w: request_id is required", ErrInvalidFeedback)
+	}
+	if req.Rating == nil && req.Thumbs == "" && req.Outcome == "" {
+		return fmt.Errorf("%w: one of rating, thumbs or outcome is required", ErrInvalidFeedback)
+	}
+	if req.Rating != nil && (*req.Rating < 1 || *req.Rating > 5) {
+		return fmt.Errorf("%w: rating must be between 1 and 5", ErrInvalidFeedback)
+	}
+	switch req.Thumbs {
+	case "", models.ThumbsUp, models.ThumbsDown:
+	default:
+		return fmt.Errorf("%w: thumbs must be %q or %q", ErrInvalidFeedback, models.ThumbsUp, models.ThumbsDown)
+	}
+	switch req.Outcome {
+	case "", models.OutcomeSuccess, models.OutcomePartial, models.OutcomeFailure:
+	default:
+		return fmt.Errorf("%w: outcome must be %q, %q or %q",
+			ErrInvalidFeedback, models.OutcomeSuccess, models.OutcomePartial, models.OutcomeFailure)
+	}
+	return nil
+}
+
+// Caller identifies who submits feedback. The zero value, used when authentication is disabled,
+// can rate any request.
+type Caller struct {
+	// APIKeyID is set for callers authenticated with an API key, who can only rate the requests
+	// made with that key
+	APIKeyID *uint
+	// UserID is set for users, who can rate the requests made with API keys of their own or of
+	// their organizations
+	UserID string
+}
+
+// Submit records feedback for a request owned by caller; requests of others are reported as not
+// found.
+func (s *Service) Submit(ctx context.Context, req models.FeedbackRequest, caller Caller) (*models.RoutingFeedback, error) {
+	if err := Validate(&req); err != nil {
+		return nil, err
+	}
+
+	owner, err := s.owner(ctx, caller)
+	if err != nil {
+		return nil, err
+	}
+	usageRow, err := s.usageService.GetUsageByRequestID(ctx, req.RequestID, owner)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRequestNotFound
+		}
+		return nil, err
+	}
+
+	comment := req.Comment
+	if len(comment) > maxCommentLength {
+		comment = comment[:maxCommentLength]
+	}
+
+	feedback := &models.RoutingFeedback{
+		RequestID: req.RequestID,
+		UsageID:   usageRow.ID,
+		APIKeyID:  usageRow.APIKeyID,
+		Endpoint:  usageRow.Endpoint,
+		Provider:  usageRow.Provider,
+		Model:     usageRow.Model,
+		Rating:    req.Rating,
+		Thumbs:    req.Thumbs,
+		Outcome:   req.Outcome,
+		Comment:   comment,
+		Negative:  req.IsNegative(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to store feedback: %w", err)
+	}
+
+	// The cache is only invalidated for feedback that was stored
+	if feedback.Negative && s.invalidateCache && s.router != nil && s.router.InvalidateRequest(ctx, req.RequestID) {
+		feedback.CacheInvalidated = true
+		if err := s.db.WithContext(ctx).Model(feedback).Update("cache_invalidated", true).Error; err != nil {
+			fiberlog.Warnf("[%s] Feedback: Failed to mark cache invalidation: %v", req.RequestID, err)
+		}
+	}
+
+	fiberlog.Infof("[%s] 📝 Feedback recorded for %s/%s (negative: %t, cache invalidated: %t)",
+		req.RequestID, feedback.Provider, feedback.Model, feedback.Negative, feedback.CacheInvalidated)
+
+	s.enqueue(*feedback)
+	return feedback, nil
+}
+
+// owner returns the usage rows caller may rate
+func (s *Service) owner(ctx context.Context, caller Caller) (usage.UsageOwner, error) {
+	owner := usage.UsageOwner{APIKeyID: caller.APIKeyID, UserID: caller.UserID}
+	if caller.APIKeyID != nil || caller.UserID == "" || s.authProvider == nil {
+		return owner, nil
+	}
+	organizationIDs, err := s.authProvider.GetUserOrganizations(ctx, caller.UserID)
+	if err != nil {
+		return owner, fmt.Errorf("failed to get organizations of user %s: %w", caller.UserID, err)
+	}
+	owner.OrganizationIDs = organizationIDs
+	return owner, nil
+}
+
+// enqueue hands feedback to the forwarding worker without blocking the request
+func (s *Service) enqueue(feedback models.RoutingFeedback) {
+	if s.queue == nil {
+		return
+	}
+	select {
+	case s.queue <- feedback:
+	default:
+		fiberlog.Warnf("[%s] Feedback: Forwarding queue full, dropping feedback", feedback.RequestID)
+	}
+}
+
+// forwardLoop sends queued feedback to the adaptive_router service once a batch is full or the
+// flush interval elapses
+func (s *Service) forwardLoop() {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.RoutingFeedback, 0, s.batchSize)
+	for {
+		select {
+		case feedback := <-s.queue:
+			batch = append(batch, feedback)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		s.forward(batch)
+		batch = make([]models.RoutingFeedback, 0, s.batchSize)
+	}
+}
+
+// forward sends one batch; failed batches are logged and dropped since feedback is best effort
+func (s *Service) forward(batch []models.RoutingFeedback) {
+	if err := s.router.ForwardFeedback(context.Background(), batch); err != nil {
+		fiberlog.Warnf("Feedback: Failed to forward %d entries to adaptive_router: %v", len(batch), err)
+		return
+	}
+	fiberlog.Debugf("Feedback: Forwarded %d entries to adaptive_router", len(batch))
+}
@@ -0,0 +1,106 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// memberships is an auth provider that knows which organizations users belong to
+type memberships map[string][]string
+
+func (m memberships) ValidateOrganizationAccess(ctx context.Context, userID, organizationID string) (bool, error) {
+	for _, id := range m[userID] {
+		if id == organizationID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m memberships) ValidateProjectAccess(ctx context.Context, userID string, projectID uint, requiredRole auth.Role) (bool, error) {
+	return false, nil
+}
+
+func (m memberships) GetUserOrganizations(ctx context.Context, userID string) ([]string, error) {
+	return m[userID], nil
+}
+
+func (m memberships) GetOrganizationRole(ctx context.Context, userID, organizationID string) (string, error) {
+	return "", nil
+}
+
+func TestSubmitOwnership(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.APIKey{}, &models.APIKeyUsage{}, &models.RoutingFeedback{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	// alice's key belongs to acme, bob's to no organization
+	keys := []models.APIKey{
+		{ID: 1, KeyHash: "alice", UserID: "alice", OrganizationID: "acme"},
+		{ID: 2, KeyHash: "bob", UserID: "bob"},
+	}
+	usageRows := []models.APIKeyUsage{
+		{APIKeyID: 1, RequestID: "req-alice", Provider: "openai", StatusCode: 200},
+		{APIKeyID: 2, RequestID: "req-bob", Provider: "anthropic", StatusCode: 200},
+		// A failed attempt of the same request is not what was rated
+		{APIKeyID: 2, RequestID: "req-bob", Provider: "gemini", StatusCode: 502},
+	}
+	if err := db.Create(&keys).Error; err != nil {
+		t.Fatalf("create keys: %v", err)
+	}
+	if err := db.Create(&usageRows).Error; err != nil {
+		t.Fatalf("create usage: %v", err)
+	}
+
+	s := NewService(db, usage.NewService(db, nil), memberships{"carol": {"acme"}}, nil, nil)
+	keyID := func(id uint) *uint { return &id }
+
+	tests := []struct {
+		name         string
+		requestID    string
+		caller       Caller
+		wantProvider string
+		wantErr      error
+	}{
+		{name: "own API key", requestID: "req-alice", caller: Caller{APIKeyID: keyID(1)}, wantProvider: "openai"},
+		{name: "other API key", requestID: "req-bob", caller: Caller{APIKeyID: keyID(1)}, wantErr: ErrRequestNotFound},
+		{name: "user's own key", requestID: "req-bob", caller: Caller{UserID: "bob"}, wantProvider: "anthropic"},
+		{name: "user of the key's organization", requestID: "req-alice", caller: Caller{UserID: "carol"}, wantProvider: "openai"},
+		{name: "user of another organization", requestID: "req-bob", caller: Caller{UserID: "carol"}, wantErr: ErrRequestNotFound},
+		{name: "unknown request", requestID: "req-none", caller: Caller{UserID: "bob"}, wantErr: ErrRequestNotFound},
+		{name: "authentication disabled", requestID: "req-bob", wantProvider: "anthropic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Submit(context.Background(), models.FeedbackRequest{RequestID: tt.requestID, Thumbs: models.ThumbsUp}, tt.caller)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Submit() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Submit() error = %v", err)
+			}
+			if got.Provider != tt.wantProvider {
+				t.Errorf("Submit() rated the request served by %q, want %q", got.Provider, tt.wantProvider)
+			}
+		})
+	}
+}
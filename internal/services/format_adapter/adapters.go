@@ -10,7 +10,9 @@ var (
 	AdaptiveToAnthropic *AdaptiveToAnthropicConverter
 	AnthropicToAdaptive *AnthropicToAdaptiveConverter
 
-	// OpenAI to Anthropic adapters
+	// Cross-protocol adapters, with OpenAI chat completions as the hub
+	AnthropicOpenAI *AnthropicOpenAIConverter
+	GeminiOpenAI    *GeminiOpenAIConverter
 
 	// Gemini adapters
 	AdaptiveToGemini *AdaptiveToGeminiConverter
@@ -25,4 +27,6 @@ func init() {
 	AnthropicToAdaptive = &AnthropicToAdaptiveConverter{}
 	AdaptiveToGemini = &AdaptiveToGeminiConverter{}
 	GeminiToAdaptive = &GeminiToAdaptiveConverter{}
+	AnthropicOpenAI = &AnthropicOpenAIConverter{}
+	GeminiOpenAI = &GeminiOpenAIConverter{}
 }
@@ -0,0 +1,648 @@
+package format_adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicparam "github.com/anthropics/anthropic-sdk-go/packages/param"
+	"github.com/openai/openai-go/v2"
+	openaiparam "github.com/openai/openai-go/v2/packages/param"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// AnthropicOpenAIConverter converts between the Anthropic Messages API and OpenAI chat
+// completions, so either API can be served by a provider speaking the other
+type AnthropicOpenAIConverter struct{}
+
+// RequestToOpenAI converts a Messages request to chat completion parameters
+func (c *AnthropicOpenAIConverter) RequestToOpenAI(req *models.AnthropicMessageRequest) (*openai.ChatCompletionNewParams, error) {
+	if req == nil {
+		return nil, fmt.Errorf("anthropic message request cannot be nil")
+	}
+
+	params := &openai.ChatCompletionNewParams{
+		Model:       shared.ChatModel(req.Model),
+		Temperature: optToOpenAI(req.Temperature),
+		TopP:        optToOpenAI(req.TopP),
+		User:        optToOpenAI(req.Metadata.UserID),
+	}
+	if req.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(req.MaxTokens)
+	}
+	if len(req.StopSequences) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: req.StopSequences}
+	}
+
+	var system strings.Builder
+	for _, block := range req.System {
+		if system.Len() > 0 {
+			system.WriteString("\n\n")
+		}
+		system.WriteString(block.Text)
+	}
+	if system.Len() > 0 {
+		params.Messages = append(params.Messages, openai.SystemMessage(system.String()))
+	}
+
+	for i, message := range req.Messages {
+		converted, err := c.messageToOpenAI(message)
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		params.Messages = append(params.Messages, converted...)
+	}
+
+	for _, tool := range req.Tools {
+		if tool.OfTool == nil {
+			return nil, fmt.Errorf("anthropic server tools cannot be served by an OpenAI provider")
+		}
+		params.Tools = append(params.Tools, openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+			Name:        tool.OfTool.Name,
+			Description: optToOpenAI(tool.OfTool.Description),
+			Parameters:  inputSchemaToOpenAI(tool.OfTool.InputSchema),
+		}))
+	}
+	if len(params.Tools) > 0 {
+		params.ToolChoice, params.ParallelToolCalls = toolChoiceToOpenAI(req.ToolChoice)
+	}
+
+	return params, nil
+}
+
+// messageToOpenAI converts a Messages API message. Tool results become tool messages, which
+// precede the rest of the user turn they were sent in.
+func (c *AnthropicOpenAIConverter) messageToOpenAI(message anthropic.MessageParam) ([]openai.ChatCompletionMessageParamUnion, error) {
+	if message.Role == anthropic.MessageParamRoleAssistant {
+		var text strings.Builder
+		var toolCalls []openai.ChatCompletionMessageToolCallUnionParam
+		for _, block := range message.Content {
+			switch {
+			case block.OfText != nil:
+				text.WriteString(block.OfText.Text)
+			case block.OfToolUse != nil:
+				arguments, err := json.Marshal(block.OfToolUse.Input)
+				if err != nil {
+					return nil, fmt.Errorf("invalid tool_use input: %w", err)
+				}
+				toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID: block.OfToolUse.ID,
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+							Name:      block.OfToolUse.Name,
+							Arguments: string(toolArguments(string(arguments))),
+						},
+					},
+				})
+			}
+		}
+		assistant := openai.ChatCompletionAssistantMessageParam{ToolCalls: toolCalls}
+		if text.Len() > 0 {
+			assistant.Content.OfString = openai.String(text.String())
+		}
+		return []openai.ChatCompletionMessageParamUnion{{OfAssistant: &assistant}}, nil
+	}
+
+	var converted []openai.ChatCompletionMessageParamUnion
+	var parts []openai.ChatCompletionContentPartUnionParam
+	for _, block := range message.Content {
+		switch {
+		case block.OfText != nil:
+			parts = append(parts, openai.TextContentPart(block.OfText.Text))
+		case block.OfImage != nil:
+			source := block.OfImage.Source
+			url := ""
+			switch {
+			case source.OfBase64 != nil:
+				url = dataURL(string(source.OfBase64.MediaType), source.OfBase64.Data)
+			case source.OfURL != nil:
+				url = source.OfURL.URL
+			}
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url}))
+		case block.OfDocument != nil:
+			source := block.OfDocument.Source
+			switch {
+			case source.OfBase64 != nil:
+				parts = append(parts, openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+					FileData: openai.String(dataURL("application/pdf", source.OfBase64.Data)),
+					Filename: openai.String("document.pdf"),
+				}))
+			case source.OfText != nil:
+				parts = append(parts, openai.TextContentPart(source.OfText.Data))
+			default:
+				return nil, fmt.Errorf("only base64 and plain text documents can be served by an OpenAI provider")
+			}
+		case block.OfToolResult != nil:
+			converted = append(converted, openai.ToolMessage(toolResultText(block.OfToolResult), block.OfToolResult.ToolUseID))
+		}
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0].OfText != nil:
+		converted = append(converted, openai.UserMessage(parts[0].OfText.Text))
+	case len(parts) > 0:
+		converted = append(converted, openai.UserMessage(parts))
+	}
+	return converted, nil
+}
+
+// toolResultText flattens the text of a tool result; errors are marked as such since tool
+// messages have no error flag
+func toolResultText(result *anthropic.ToolResultBlockParam) string {
+	var text strings.Builder
+	for _, content := range result.Content {
+		if content.OfText != nil {
+			text.WriteString(content.OfText.Text)
+		}
+	}
+	if result.IsError.Valid() && result.IsError.Value {
+		return "Error: " + text.String()
+	}
+	return text.String()
+}
+
+// inputSchemaToOpenAI converts a tool input schema to JSON schema function parameters
+func inputSchemaToOpenAI(schema anthropic.ToolInputSchemaParam) shared.FunctionParameters {
+	parameters := shared.FunctionParameters{"type": "object"}
+	for key, value := range schema.ExtraFields {
+		parameters[key] = value
+	}
+	if schema.Properties != nil {
+		parameters["properties"] = schema.Properties
+	}
+	if len(schema.Required) > 0 {
+		parameters["required"] = schema.Required
+	}
+	return parameters
+}
+
+// toolChoiceToOpenAI converts an Anthropic tool choice and its parallel tool use setting
+func toolChoiceToOpenAI(choice anthropic.ToolChoiceUnionParam) (openai.ChatCompletionToolChoiceOptionUnionParam, openaiparam.Opt[bool]) {
+	var converted openai.ChatCompletionToolChoiceOptionUnionParam
+	var parallel openaiparam.Opt[bool]
+	disableParallel := func(disable anthropicparam.Opt[bool]) {
+		if disable.Valid() && disable.Value {
+			parallel = openai.Bool(false)
+		}
+	}
+
+	switch {
+	case choice.OfAuto != nil:
+		converted.OfAuto = openai.String("auto")
+		disableParallel(choice.OfAuto.DisableParallelToolUse)
+	case choice.OfAny != nil:
+		converted.OfAuto = openai.String("required")
+		disableParallel(choice.OfAny.DisableParallelToolUse)
+	case choice.OfTool != nil:
+		converted = openai.ToolChoiceOptionFunctionToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{Name: choice.OfTool.Name})
+		disableParallel(choice.OfTool.DisableParallelToolUse)
+	case choice.OfNone != nil:
+		converted.OfAuto = openai.String("none")
+	}
+	return converted, parallel
+}
+
+// RequestFromOpenAI converts chat completion parameters to a Messages request
+func (c *AnthropicOpenAIConverter) RequestFromOpenAI(params *openai.ChatCompletionNewParams) (*models.AnthropicMessageRequest, error) {
+	if params == nil {
+		return nil, fmt.Errorf("openai chat completion params cannot be nil")
+	}
+
+	req := &models.AnthropicMessageRequest{
+		Model:     anthropic.Model(params.Model),
+		MaxTokens: defaultMaxTokens,
+		TopP:      optFromOpenAI(params.TopP),
+		Metadata:  anthropic.MetadataParam{UserID: optFromOpenAI(params.User)},
+	}
+	switch {
+	case params.MaxCompletionTokens.Valid():
+		req.MaxTokens = params.MaxCompletionTokens.Value
+	case params.MaxTokens.Valid():
+		req.MaxTokens = params.MaxTokens.Value
+	}
+	// Anthropic temperatures range from 0 to 1 rather than 0 to 2
+	if params.Temperature.Valid() {
+		req.Temperature = anthropic.Float(min(params.Temperature.Value, 1))
+	}
+	if params.Stop.OfString.Valid() {
+		req.StopSequences = []string{params.Stop.OfString.Value}
+	} else {
+		req.StopSequences = params.Stop.OfStringArray
+	}
+
+	for i, message := range params.Messages {
+		if err := c.appendMessageFromOpenAI(req, message); err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+	}
+
+	for _, tool := range params.Tools {
+		if tool.OfFunction == nil {
+			return nil, fmt.Errorf("custom tools cannot be served by an Anthropic provider")
+		}
+		function := tool.OfFunction.Function
+		req.Tools = append(req.Tools, anthropic.ToolUnionParam{OfTool: &anthropic.ToolParam{
+			Name:        function.Name,
+			Description: optFromOpenAI(function.Description),
+			InputSchema: inputSchemaFromOpenAI(function.Parameters),
+		}})
+	}
+	if len(req.Tools) > 0 {
+		req.ToolChoice = toolChoiceFromOpenAI(params.ToolChoice, params.ParallelToolCalls)
+	}
+
+	return req, nil
+}
+
+// appendMessageFromOpenAI adds a chat message to a Messages request. System messages move to
+// the system prompt, tool messages become tool results, and consecutive turns of one role
+// are merged since the Messages API requires roles to alternate.
+func (c *AnthropicOpenAIConverter) appendMessageFromOpenAI(req *models.AnthropicMessageRequest, message openai.ChatCompletionMessageParamUnion) error {
+	var role anthropic.MessageParamRole
+	var blocks []anthropic.ContentBlockParamUnion
+
+	switch {
+	case message.OfSystem != nil:
+		content := message.OfSystem.Content
+		req.System = append(req.System, anthropic.TextBlockParam{Text: openAITextContent(content.OfString.Value, content.OfArrayOfContentParts)})
+		return nil
+	case message.OfDeveloper != nil:
+		content := message.OfDeveloper.Content
+		req.System = append(req.System, anthropic.TextBlockParam{Text: openAITextContent(content.OfString.Value, content.OfArrayOfContentParts)})
+		return nil
+	case message.OfUser != nil:
+		role = anthropic.MessageParamRoleUser
+		content := message.OfUser.Content
+		if content.OfString.Valid() {
+			blocks = append(blocks, anthropic.NewTextBlock(content.OfString.Value))
+		}
+		for _, part := range content.OfArrayOfContentParts {
+			block, err := contentPartToAnthropic(part)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+		}
+	case message.OfAssistant != nil:
+		role = anthropic.MessageParamRoleAssistant
+		content := message.OfAssistant.Content
+		if content.OfString.Valid() && content.OfString.Value != "" {
+			blocks = append(blocks, anthropic.NewTextBlock(content.OfString.Value))
+		}
+		for _, part := range content.OfArrayOfContentParts {
+			switch {
+			case part.OfText != nil && part.OfText.Text != "":
+				blocks = append(blocks, anthropic.NewTextBlock(part.OfText.Text))
+			case part.OfRefusal != nil:
+				blocks = append(blocks, anthropic.NewTextBlock(part.OfRefusal.Refusal))
+			}
+		}
+		for _, toolCall := range message.OfAssistant.ToolCalls {
+			if toolCall.OfFunction == nil {
+				return fmt.Errorf("custom tool calls cannot be served by an Anthropic provider")
+			}
+			function := toolCall.OfFunction.Function
+			blocks = append(blocks, anthropic.NewToolUseBlock(toolCall.OfFunction.ID, toolArguments(function.Arguments), function.Name))
+		}
+	case message.OfTool != nil:
+		role = anthropic.MessageParamRoleUser
+		content := message.OfTool.Content
+		blocks = append(blocks, anthropic.NewToolResultBlock(message.OfTool.ToolCallID, openAITextContent(content.OfString.Value, content.OfArrayOfContentParts), false))
+	default:
+		return fmt.Errorf("function messages cannot be served by an Anthropic provider")
+	}
+
+	if len(blocks) == 0 {
+		return nil
+	}
+	if last := len(req.Messages) - 1; last >= 0 && req.Messages[last].Role == role {
+		req.Messages[last].Content = append(req.Messages[last].Content, blocks...)
+		return nil
+	}
+	req.Messages = append(req.Messages, anthropic.MessageParam{Role: role, Content: blocks})
+	return nil
+}
+
+// contentPartToAnthropic converts a user content part
+func contentPartToAnthropic(part openai.ChatCompletionContentPartUnionParam) (anthropic.ContentBlockParamUnion, error) {
+	switch {
+	case part.OfText != nil:
+		return anthropic.NewTextBlock(part.OfText.Text), nil
+	case part.OfImageURL != nil:
+		url := part.OfImageURL.ImageURL.URL
+		if mediaType, data, ok := parseDataURL(url); ok {
+			return anthropic.NewImageBlockBase64(mediaType, data), nil
+		}
+		return anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: url}), nil
+	case part.OfFile != nil && part.OfFile.File.FileData.Valid():
+		if mediaType, data, ok := parseDataURL(part.OfFile.File.FileData.Value); ok && mediaType == "application/pdf" {
+			return anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: data}), nil
+		}
+		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("only PDF files can be served by an Anthropic provider")
+	default:
+		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("audio and uploaded files cannot be served by an Anthropic provider")
+	}
+}
+
+// inputSchemaFromOpenAI converts JSON schema function parameters to a tool input schema
+func inputSchemaFromOpenAI(parameters shared.FunctionParameters) anthropic.ToolInputSchemaParam {
+	schema := anthropic.ToolInputSchemaParam{ExtraFields: map[string]any{}}
+	for key, value := range parameters {
+		switch key {
+		case "type":
+		case "properties":
+			schema.Properties = value
+		case "required":
+			switch required := value.(type) {
+			case []string:
+				schema.Required = required
+			case []any:
+				for _, name := range required {
+					if name, ok := name.(string); ok {
+						schema.Required = append(schema.Required, name)
+					}
+				}
+			}
+		default:
+			schema.ExtraFields[key] = value
+		}
+	}
+	return schema
+}
+
+// toolChoiceFromOpenAI converts an OpenAI tool choice and parallel tool calls setting
+func toolChoiceFromOpenAI(choice openai.ChatCompletionToolChoiceOptionUnionParam, parallel openaiparam.Opt[bool]) anthropic.ToolChoiceUnionParam {
+	var disableParallel anthropicparam.Opt[bool]
+	if parallel.Valid() && !parallel.Value {
+		disableParallel = anthropic.Bool(true)
+	}
+
+	switch {
+	case choice.OfFunctionToolChoice != nil:
+		return anthropic.ToolChoiceUnionParam{OfTool: &anthropic.ToolChoiceToolParam{
+			Name:                   choice.OfFunctionToolChoice.Function.Name,
+			DisableParallelToolUse: disableParallel,
+		}}
+	case choice.OfAuto.Value == "none":
+		return anthropic.ToolChoiceUnionParam{OfNone: &anthropic.ToolChoiceNoneParam{}}
+	case choice.OfAuto.Value == "required":
+		return anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{DisableParallelToolUse: disableParallel}}
+	case choice.OfAuto.Valid() || disableParallel.Valid():
+		return anthropic.ToolChoiceUnionParam{OfAuto: &anthropic.ToolChoiceAutoParam{DisableParallelToolUse: disableParallel}}
+	default:
+		return anthropic.ToolChoiceUnionParam{}
+	}
+}
+
+// ResponseToOpenAI converts a Messages API response to a chat completion
+func (c *AnthropicOpenAIConverter) ResponseToOpenAI(message *anthropic.Message) (*openai.ChatCompletion, error) {
+	if message == nil {
+		return nil, fmt.Errorf("anthropic message cannot be nil")
+	}
+
+	var text strings.Builder
+	var toolCalls []openai.ChatCompletionMessageToolCallUnion
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCallUnion{
+				ID:   block.ID,
+				Type: "function",
+				Function: openai.ChatCompletionMessageFunctionToolCallFunction{
+					Name:      block.Name,
+					Arguments: string(toolArguments(string(block.Input))),
+				},
+			})
+		}
+	}
+
+	return &openai.ChatCompletion{
+		ID:      message.ID,
+		Created: time.Now().Unix(),
+		Model:   string(message.Model),
+		Object:  "chat.completion",
+		Choices: []openai.ChatCompletionChoice{{
+			FinishReason: finishReasonFromAnthropic(message.StopReason),
+			Message: openai.ChatCompletionMessage{
+				Content:   text.String(),
+				Role:      "assistant",
+				ToolCalls: toolCalls,
+			},
+		}},
+		Usage: usageFromAnthropic(message.Usage.InputTokens+message.Usage.CacheReadInputTokens+message.Usage.CacheCreationInputTokens,
+			message.Usage.OutputTokens, message.Usage.CacheReadInputTokens),
+	}, nil
+}
+
+// usageFromAnthropic builds OpenAI usage; Anthropic input tokens exclude cached tokens, so
+// promptTokens is expected to include them
+func usageFromAnthropic(promptTokens, completionTokens, cachedTokens int64) openai.CompletionUsage {
+	return openai.CompletionUsage{
+		PromptTokens:        promptTokens,
+		CompletionTokens:    completionTokens,
+		TotalTokens:         promptTokens + completionTokens,
+		PromptTokensDetails: openai.CompletionUsagePromptTokensDetails{CachedTokens: cachedTokens},
+	}
+}
+
+// ResponseFromOpenAI converts a chat completion to a Messages API response
+func (c *AnthropicOpenAIConverter) ResponseFromOpenAI(resp *openai.ChatCompletion) (*anthropic.Message, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("openai chat completion cannot be nil")
+	}
+
+	message := &anthropic.Message{
+		ID:      resp.ID,
+		Content: []anthropic.ContentBlockUnion{},
+		Model:   anthropic.Model(resp.Model),
+		Role:    "assistant",
+		Type:    "message",
+		Usage: anthropic.Usage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+	}
+	if len(resp.Choices) == 0 {
+		message.StopReason = anthropic.StopReasonEndTurn
+		return message, nil
+	}
+
+	choice := resp.Choices[0]
+	text := choice.Message.Content
+	if text == "" {
+		text = choice.Message.Refusal
+	}
+	if text != "" {
+		message.Content = append(message.Content, anthropic.ContentBlockUnion{Type: "text", Text: text})
+	}
+	for _, toolCall := range choice.Message.ToolCalls {
+		message.Content = append(message.Content, anthropic.ContentBlockUnion{
+			Type:  "tool_use",
+			ID:    toolCall.ID,
+			Name:  toolCall.Function.Name,
+			Input: toolArguments(toolCall.Function.Arguments),
+		})
+	}
+	message.StopReason = stopReasonFromOpenAI(choice.FinishReason)
+	return message, nil
+}
+
+// StreamToOpenAI converts a Messages API event stream to a chat completion chunk stream
+func (c *AnthropicOpenAIConverter) StreamToOpenAI(stream contracts.EventStream[anthropic.MessageStreamEventUnion]) contracts.EventStream[openai.ChatCompletionChunk] {
+	var id, model string
+	var promptTokens, cachedTokens int64
+	created := time.Now().Unix()
+	// Tool use blocks are numbered among all content blocks, tool calls among tool calls
+	toolIndexes := make(map[int64]int64)
+
+	chunk := func(delta openai.ChatCompletionChunkChoiceDelta, finishReason string) []openai.ChatCompletionChunk {
+		return []openai.ChatCompletionChunk{openAIChunk(id, model, created, delta, finishReason)}
+	}
+
+	return newConvertedStream(stream, func(event anthropic.MessageStreamEventUnion) []openai.ChatCompletionChunk {
+		switch event.Type {
+		case "message_start":
+			id, model = event.Message.ID, string(event.Message.Model)
+			usage := event.Message.Usage
+			promptTokens = usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+			cachedTokens = usage.CacheReadInputTokens
+			return chunk(openai.ChatCompletionChunkChoiceDelta{Role: "assistant"}, "")
+		case "content_block_start":
+			switch event.ContentBlock.Type {
+			case "text":
+				if event.ContentBlock.Text != "" {
+					return chunk(openai.ChatCompletionChunkChoiceDelta{Content: event.ContentBlock.Text}, "")
+				}
+			case "tool_use":
+				index := int64(len(toolIndexes))
+				toolIndexes[event.Index] = index
+				return chunk(openai.ChatCompletionChunkChoiceDelta{ToolCalls: []openai.ChatCompletionChunkChoiceDeltaToolCall{{
+					Index:    index,
+					ID:       event.ContentBlock.ID,
+					Type:     "function",
+					Function: openai.ChatCompletionChunkChoiceDeltaToolCallFunction{Name: event.ContentBlock.Name},
+				}}}, "")
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return chunk(openai.ChatCompletionChunkChoiceDelta{Content: event.Delta.Text}, "")
+			case "input_json_delta":
+				return chunk(openai.ChatCompletionChunkChoiceDelta{ToolCalls: []openai.ChatCompletionChunkChoiceDeltaToolCall{{
+					Index:    toolIndexes[event.Index],
+					Function: openai.ChatCompletionChunkChoiceDeltaToolCallFunction{Arguments: event.Delta.PartialJSON},
+				}}}, "")
+			}
+		case "message_delta":
+			// The OpenAI stream ends at the finish reason, so usage is sent with it
+			finish := openAIChunk(id, model, created, openai.ChatCompletionChunkChoiceDelta{}, finishReasonFromAnthropic(event.Delta.StopReason))
+			finish.Usage = usageFromAnthropic(max(promptTokens, event.Usage.InputTokens), event.Usage.OutputTokens, cachedTokens)
+			return []openai.ChatCompletionChunk{finish}
+		}
+		return nil
+	}, nil)
+}
+
+// StreamFromOpenAI converts a chat completion chunk stream to a Messages API event stream
+func (c *AnthropicOpenAIConverter) StreamFromOpenAI(stream contracts.EventStream[openai.ChatCompletionChunk]) contracts.EventStream[anthropic.MessageStreamEventUnion] {
+	started := false
+	blockOpen := false
+	blockIndex := int64(-1)
+	blockType := ""
+	// OpenAI tool call indexes mapped to the content blocks they are streamed into
+	toolBlocks := make(map[int64]int64)
+	stopReason := anthropic.StopReasonEndTurn
+	var usage openai.CompletionUsage
+
+	closeBlock := func(events []anthropic.MessageStreamEventUnion) []anthropic.MessageStreamEventUnion {
+		if !blockOpen {
+			return events
+		}
+		blockOpen = false
+		return append(events, anthropic.MessageStreamEventUnion{Type: "content_block_stop", Index: blockIndex})
+	}
+	openBlock := func(events []anthropic.MessageStreamEventUnion, block anthropic.ContentBlockStartEventContentBlockUnion) []anthropic.MessageStreamEventUnion {
+		events = closeBlock(events)
+		blockIndex++
+		blockOpen = true
+		blockType = block.Type
+		return append(events, anthropic.MessageStreamEventUnion{Type: "content_block_start", Index: blockIndex, ContentBlock: block})
+	}
+
+	return newConvertedStream(stream, func(chunk openai.ChatCompletionChunk) []anthropic.MessageStreamEventUnion {
+		var events []anthropic.MessageStreamEventUnion
+		if !started {
+			started = true
+			events = append(events, anthropic.MessageStreamEventUnion{
+				Type: "message_start",
+				Message: anthropic.Message{
+					ID:      chunk.ID,
+					Content: []anthropic.ContentBlockUnion{},
+					Model:   anthropic.Model(chunk.Model),
+					Role:    "assistant",
+					Type:    "message",
+				},
+			})
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			return events
+		}
+
+		choice := chunk.Choices[0]
+		text := choice.Delta.Content + choice.Delta.Refusal
+		if text != "" {
+			if !blockOpen || blockType != "text" {
+				events = openBlock(events, anthropic.ContentBlockStartEventContentBlockUnion{Type: "text"})
+			}
+			events = append(events, anthropic.MessageStreamEventUnion{
+				Type:  "content_block_delta",
+				Index: blockIndex,
+				Delta: anthropic.MessageStreamEventUnionDelta{Type: "text_delta", Text: text},
+			})
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			index, known := toolBlocks[toolCall.Index]
+			if !known {
+				events = openBlock(events, anthropic.ContentBlockStartEventContentBlockUnion{
+					Type:  "tool_use",
+					ID:    toolCall.ID,
+					Name:  toolCall.Function.Name,
+					Input: map[string]any{},
+				})
+				index = blockIndex
+				toolBlocks[toolCall.Index] = index
+			}
+			if toolCall.Function.Arguments != "" {
+				events = append(events, anthropic.MessageStreamEventUnion{
+					Type:  "content_block_delta",
+					Index: index,
+					Delta: anthropic.MessageStreamEventUnionDelta{Type: "input_json_delta", PartialJSON: toolCall.Function.Arguments},
+				})
+			}
+		}
+		if choice.FinishReason != "" {
+			stopReason = stopReasonFromOpenAI(choice.FinishReason)
+		}
+		return events
+	}, func() []anthropic.MessageStreamEventUnion {
+		events := closeBlock(nil)
+		return append(events,
+			anthropic.MessageStreamEventUnion{
+				Type:  "message_delta",
+				Delta: anthropic.MessageStreamEventUnionDelta{StopReason: stopReason},
+				Usage: anthropic.MessageDeltaUsage{InputTokens: usage.PromptTokens, OutputTokens: usage.CompletionTokens},
+			},
+			anthropic.MessageStreamEventUnion{Type: "message_stop"},
+		)
+	})
+}
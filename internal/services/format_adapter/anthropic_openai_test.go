@@ -0,0 +1,384 @@
+package format_adapter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+)
+
+func TestAnthropicRequestToOpenAI(t *testing.T) {
+	req := &models.AnthropicMessageRequest{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 1024,
+		System:    []anthropic.TextBlockParam{{Text: "Be brief."}, {Text: "Answer in French."}},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(
+				anthropic.NewTextBlock("What is in this picture?"),
+				anthropic.NewImageBlockBase64("image/png", "iVBORw0KGgo="),
+			),
+			anthropic.NewAssistantMessage(
+				anthropic.NewTextBlock("Let me look it up."),
+				anthropic.NewToolUseBlock("toolu_1", map[string]any{"query": "cat"}, "search"),
+			),
+			anthropic.NewUserMessage(
+				anthropic.NewTextBlock("Thanks"),
+				anthropic.ContentBlockParamUnion{OfToolResult: &anthropic.ToolResultBlockParam{
+					ToolUseID: "toolu_1",
+					IsError:   anthropic.Bool(true),
+					Content:   []anthropic.ToolResultBlockParamContentUnion{{OfText: &anthropic.TextBlockParam{Text: "timeout"}}},
+				}},
+			),
+		},
+		Tools: []anthropic.ToolUnionParam{{OfTool: &anthropic.ToolParam{
+			Name:        "search",
+			InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{"query": map[string]any{"type": "string"}}, Required: []string{"query"}},
+		}}},
+		ToolChoice: anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{DisableParallelToolUse: anthropic.Bool(true)}},
+	}
+
+	params, err := AnthropicOpenAI.RequestToOpenAI(req)
+	if err != nil {
+		t.Fatalf("RequestToOpenAI() error = %v", err)
+	}
+
+	if params.MaxCompletionTokens.Value != 1024 {
+		t.Errorf("max_completion_tokens = %d, want 1024", params.MaxCompletionTokens.Value)
+	}
+	if len(params.Messages) != 5 {
+		t.Fatalf("got %d messages, want 5: system, user, assistant, tool, user", len(params.Messages))
+	}
+	if system := params.Messages[0].OfSystem; system == nil || system.Content.OfString.Value != "Be brief.\n\nAnswer in French." {
+		t.Errorf("system message = %+v, want the system blocks joined", params.Messages[0])
+	}
+	user := params.Messages[1].OfUser
+	if user == nil || len(user.Content.OfArrayOfContentParts) != 2 {
+		t.Fatalf("user message = %+v, want text and image parts", params.Messages[1])
+	}
+	if image := user.Content.OfArrayOfContentParts[1].OfImageURL; image == nil || image.ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("image part = %+v, want a data URL", user.Content.OfArrayOfContentParts[1])
+	}
+	assistant := params.Messages[2].OfAssistant
+	if assistant == nil || assistant.Content.OfString.Value != "Let me look it up." || len(assistant.ToolCalls) != 1 {
+		t.Fatalf("assistant message = %+v, want text and one tool call", params.Messages[2])
+	}
+	if call := assistant.ToolCalls[0].OfFunction; call.ID != "toolu_1" || call.Function.Name != "search" || call.Function.Arguments != `{"query":"cat"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	// Tool results must directly follow the assistant turn that called the tools
+	if tool := params.Messages[3].OfTool; tool == nil || tool.ToolCallID != "toolu_1" || tool.Content.OfString.Value != "Error: timeout" {
+		t.Errorf("tool message = %+v, want the error result of toolu_1", params.Messages[3])
+	}
+	if user := params.Messages[4].OfUser; user == nil || user.Content.OfString.Value != "Thanks" {
+		t.Errorf("last message = %+v, want the user text", params.Messages[4])
+	}
+
+	if len(params.Tools) != 1 || params.Tools[0].OfFunction.Function.Parameters["required"] == nil {
+		t.Errorf("tools = %+v, want search with its schema", params.Tools)
+	}
+	if params.ToolChoice.OfAuto.Value != "required" {
+		t.Errorf("tool_choice = %q, want required", params.ToolChoice.OfAuto.Value)
+	}
+	if !params.ParallelToolCalls.Valid() || params.ParallelToolCalls.Value {
+		t.Errorf("parallel_tool_calls = %+v, want false", params.ParallelToolCalls)
+	}
+}
+
+func TestAnthropicRequestToOpenAIRejectsServerTools(t *testing.T) {
+	req := &models.AnthropicMessageRequest{
+		Model:    "claude-sonnet-4-5",
+		Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+		Tools:    []anthropic.ToolUnionParam{{OfWebSearchTool20250305: &anthropic.WebSearchTool20250305Param{}}},
+	}
+	if _, err := AnthropicOpenAI.RequestToOpenAI(req); err == nil {
+		t.Error("RequestToOpenAI() error = nil, want server tools rejected")
+	}
+}
+
+func TestAnthropicRequestFromOpenAI(t *testing.T) {
+	params := &openai.ChatCompletionNewParams{
+		Model:       "gpt-4o",
+		Temperature: openai.Float(1.5),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Be brief."),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart("Describe both."),
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: "data:image/jpeg;base64,/9j/4AAQ"}),
+			}),
+			{OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+				ToolCalls: []openai.ChatCompletionMessageToolCallUnionParam{
+					{OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID:       "call_1",
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{Name: "lookup", Arguments: `{"id":1}`},
+					}},
+					{OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID:       "call_2",
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{Name: "lookup", Arguments: `{"id":2}`},
+					}},
+				},
+			}},
+			openai.ToolMessage("first", "call_1"),
+			openai.ToolMessage("second", "call_2"),
+		},
+		Tools: []openai.ChatCompletionToolUnionParam{openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+			Name:       "lookup",
+			Parameters: openai.FunctionParameters{"type": "object", "required": []any{"id"}},
+		})},
+		ToolChoice: openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("auto")},
+	}
+
+	req, err := AnthropicOpenAI.RequestFromOpenAI(params)
+	if err != nil {
+		t.Fatalf("RequestFromOpenAI() error = %v", err)
+	}
+
+	if req.MaxTokens != defaultMaxTokens {
+		t.Errorf("max_tokens = %d, want the default %d", req.MaxTokens, defaultMaxTokens)
+	}
+	if req.Temperature.Value != 1 {
+		t.Errorf("temperature = %v, want it clamped to 1", req.Temperature.Value)
+	}
+	if len(req.System) != 1 || req.System[0].Text != "Be brief." {
+		t.Errorf("system = %+v, want the system message", req.System)
+	}
+	// The two tool messages are merged into one user turn, since roles must alternate
+	if len(req.Messages) != 3 {
+		t.Fatalf("got %d messages, want user, assistant and one user turn of tool results", len(req.Messages))
+	}
+	if image := req.Messages[0].Content[1].OfImage; image == nil || image.Source.OfBase64 == nil || image.Source.OfBase64.Data != "/9j/4AAQ" {
+		t.Errorf("image block = %+v, want base64 data", req.Messages[0].Content[1])
+	}
+	if blocks := req.Messages[1].Content; len(blocks) != 2 || blocks[0].OfToolUse == nil || blocks[0].OfToolUse.ID != "call_1" {
+		t.Errorf("assistant blocks = %+v, want two tool_use blocks", blocks)
+	}
+	results := req.Messages[2]
+	if results.Role != anthropic.MessageParamRoleUser || len(results.Content) != 2 ||
+		results.Content[0].OfToolResult.ToolUseID != "call_1" || results.Content[1].OfToolResult.ToolUseID != "call_2" {
+		t.Errorf("tool results = %+v, want both results in one user turn", results)
+	}
+	if len(req.Tools) != 1 || !reflect.DeepEqual(req.Tools[0].OfTool.InputSchema.Required, []string{"id"}) {
+		t.Errorf("tools = %+v, want lookup requiring id", req.Tools)
+	}
+	if req.ToolChoice.OfAuto == nil {
+		t.Errorf("tool_choice = %+v, want auto", req.ToolChoice)
+	}
+}
+
+func TestAnthropicResponseToOpenAI(t *testing.T) {
+	message := &anthropic.Message{
+		ID:    "msg_1",
+		Model: "claude-sonnet-4-5",
+		Content: []anthropic.ContentBlockUnion{
+			{Type: "text", Text: "Checking."},
+			{Type: "tool_use", ID: "toolu_1", Name: "search", Input: []byte(`{"query":"cat"}`)},
+		},
+		StopReason: anthropic.StopReasonToolUse,
+		Usage:      anthropic.Usage{InputTokens: 10, CacheReadInputTokens: 90, OutputTokens: 5},
+	}
+
+	completion, err := AnthropicOpenAI.ResponseToOpenAI(message)
+	if err != nil {
+		t.Fatalf("ResponseToOpenAI() error = %v", err)
+	}
+
+	choice := completion.Choices[0]
+	if choice.FinishReason != finishReasonToolCalls || choice.Message.Content != "Checking." {
+		t.Errorf("choice = %+v, want text with tool_calls finish", choice)
+	}
+	if len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Arguments != `{"query":"cat"}` {
+		t.Errorf("tool calls = %+v", choice.Message.ToolCalls)
+	}
+	// Cached tokens are part of the OpenAI prompt
+	want := openai.CompletionUsage{
+		PromptTokens:        100,
+		CompletionTokens:    5,
+		TotalTokens:         105,
+		PromptTokensDetails: openai.CompletionUsagePromptTokensDetails{CachedTokens: 90},
+	}
+	if !reflect.DeepEqual(completion.Usage, want) {
+		t.Errorf("usage = %+v, want %+v", completion.Usage, want)
+	}
+}
+
+func TestAnthropicResponseFromOpenAI(t *testing.T) {
+	tests := []struct {
+		name           string
+		resp           openai.ChatCompletion
+		wantStopReason anthropic.StopReason
+		wantTypes      []string
+	}{
+		{
+			name: "text",
+			resp: openai.ChatCompletion{Choices: []openai.ChatCompletionChoice{{
+				FinishReason: finishReasonLength,
+				Message:      openai.ChatCompletionMessage{Content: "Hello"},
+			}}},
+			wantStopReason: anthropic.StopReasonMaxTokens,
+			wantTypes:      []string{"text"},
+		},
+		{
+			name: "refusal",
+			resp: openai.ChatCompletion{Choices: []openai.ChatCompletionChoice{{
+				FinishReason: finishReasonContentFilter,
+				Message:      openai.ChatCompletionMessage{Refusal: "I can't help with that."},
+			}}},
+			wantStopReason: anthropic.StopReasonRefusal,
+			wantTypes:      []string{"text"},
+		},
+		{
+			name: "tool calls",
+			resp: openai.ChatCompletion{Choices: []openai.ChatCompletionChoice{{
+				FinishReason: finishReasonToolCalls,
+				Message: openai.ChatCompletionMessage{ToolCalls: []openai.ChatCompletionMessageToolCallUnion{{
+					ID:       "call_1",
+					Function: openai.ChatCompletionMessageFunctionToolCallFunction{Name: "search", Arguments: `{"query":`},
+				}}},
+			}}},
+			wantStopReason: anthropic.StopReasonToolUse,
+			wantTypes:      []string{"tool_use"},
+		},
+		{
+			name:           "no choices",
+			resp:           openai.ChatCompletion{},
+			wantStopReason: anthropic.StopReasonEndTurn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := AnthropicOpenAI.ResponseFromOpenAI(&tt.resp)
+			if err != nil {
+				t.Fatalf("ResponseFromOpenAI() error = %v", err)
+			}
+			if message.StopReason != tt.wantStopReason {
+				t.Errorf("stop_reason = %q, want %q", message.StopReason, tt.wantStopReason)
+			}
+			var types []string
+			for _, block := range message.Content {
+				types = append(types, block.Type)
+				// Unparseable tool arguments become an empty object rather than invalid JSON
+				if block.Type == "tool_use" && string(block.Input) != "{}" {
+					t.Errorf("tool_use input = %s, want {}", block.Input)
+				}
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("content types = %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestAnthropicStreamToOpenAI(t *testing.T) {
+	events := []anthropic.MessageStreamEventUnion{
+		{Type: "message_start", Message: anthropic.Message{ID: "msg_1", Model: "claude-sonnet-4-5", Usage: anthropic.Usage{InputTokens: 10, CacheReadInputTokens: 20}}},
+		{Type: "content_block_start", Index: 0, ContentBlock: anthropic.ContentBlockStartEventContentBlockUnion{Type: "text"}},
+		{Type: "content_block_delta", Index: 0, Delta: anthropic.MessageStreamEventUnionDelta{Type: "text_delta", Text: "Hi"}},
+		{Type: "content_block_stop", Index: 0},
+		{Type: "content_block_start", Index: 1, ContentBlock: anthropic.ContentBlockStartEventContentBlockUnion{Type: "tool_use", ID: "toolu_1", Name: "search"}},
+		{Type: "content_block_delta", Index: 1, Delta: anthropic.MessageStreamEventUnionDelta{Type: "input_json_delta", PartialJSON: `{"q":1}`}},
+		{Type: "content_block_stop", Index: 1},
+		{Type: "message_delta", Delta: anthropic.MessageStreamEventUnionDelta{StopReason: anthropic.StopReasonToolUse}, Usage: anthropic.MessageDeltaUsage{OutputTokens: 7}},
+		{Type: "message_stop"},
+	}
+
+	chunks := collect[openai.ChatCompletionChunk](AnthropicOpenAI.StreamToOpenAI(&sliceStream[anthropic.MessageStreamEventUnion]{events: events}))
+	if len(chunks) != 5 {
+		t.Fatalf("got %d chunks, want role, text, tool call start, arguments and finish", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.ID != "msg_1" || chunk.Model != "claude-sonnet-4-5" {
+			t.Errorf("chunk id/model = %q/%q, want those of message_start", chunk.ID, chunk.Model)
+		}
+	}
+	if delta := chunks[0].Choices[0].Delta; delta.Role != "assistant" {
+		t.Errorf("first delta = %+v, want the assistant role", delta)
+	}
+	if delta := chunks[1].Choices[0].Delta; delta.Content != "Hi" {
+		t.Errorf("text delta = %+v", delta)
+	}
+	// The tool_use block is content block 1 but the first tool call
+	start := chunks[2].Choices[0].Delta.ToolCalls[0]
+	if start.Index != 0 || start.ID != "toolu_1" || start.Function.Name != "search" {
+		t.Errorf("tool call start = %+v", start)
+	}
+	if arguments := chunks[3].Choices[0].Delta.ToolCalls[0]; arguments.Index != 0 || arguments.Function.Arguments != `{"q":1}` {
+		t.Errorf("tool call arguments = %+v", arguments)
+	}
+	finish := chunks[4]
+	if finish.Choices[0].FinishReason != finishReasonToolCalls {
+		t.Errorf("finish reason = %q, want tool_calls", finish.Choices[0].FinishReason)
+	}
+	if finish.Usage.PromptTokens != 30 || finish.Usage.CompletionTokens != 7 || finish.Usage.PromptTokensDetails.CachedTokens != 20 {
+		t.Errorf("finish usage = %+v, want 30 prompt (20 cached) and 7 completion tokens", finish.Usage)
+	}
+}
+
+func TestAnthropicStreamFromOpenAI(t *testing.T) {
+	chunk := func(delta openai.ChatCompletionChunkChoiceDelta, finishReason string) openai.ChatCompletionChunk {
+		return openAIChunk("chatcmpl-1", "gpt-4o", 0, delta, finishReason)
+	}
+	toolCall := func(index int64, id, name, arguments string) openai.ChatCompletionChunkChoiceDelta {
+		return openai.ChatCompletionChunkChoiceDelta{ToolCalls: []openai.ChatCompletionChunkChoiceDeltaToolCall{{
+			Index:    index,
+			ID:       id,
+			Function: openai.ChatCompletionChunkChoiceDeltaToolCallFunction{Name: name, Arguments: arguments},
+		}}}
+	}
+	usage := openai.ChatCompletionChunk{ID: "chatcmpl-1", Usage: openai.CompletionUsage{PromptTokens: 12, CompletionTokens: 8, TotalTokens: 20}}
+
+	chunks := []openai.ChatCompletionChunk{
+		chunk(openai.ChatCompletionChunkChoiceDelta{Role: "assistant"}, ""),
+		chunk(openai.ChatCompletionChunkChoiceDelta{Content: "Let me "}, ""),
+		chunk(openai.ChatCompletionChunkChoiceDelta{Content: "check."}, ""),
+		chunk(toolCall(0, "call_1", "search", ""), ""),
+		chunk(toolCall(0, "", "", `{"q":`), ""),
+		chunk(toolCall(0, "", "", `1}`), ""),
+		chunk(openai.ChatCompletionChunkChoiceDelta{}, finishReasonToolCalls),
+		usage,
+	}
+
+	events := collect[anthropic.MessageStreamEventUnion](AnthropicOpenAI.StreamFromOpenAI(&sliceStream[openai.ChatCompletionChunk]{events: chunks}))
+
+	type summary struct {
+		Type  string
+		Index int64
+		Value string
+	}
+	var got []summary
+	for _, event := range events {
+		s := summary{Type: event.Type, Index: event.Index}
+		switch event.Type {
+		case "content_block_start":
+			s.Value = event.ContentBlock.Type
+		case "content_block_delta":
+			s.Value = event.Delta.Text + event.Delta.PartialJSON
+		case "message_delta":
+			s.Value = string(event.Delta.StopReason)
+		}
+		got = append(got, s)
+	}
+	want := []summary{
+		{Type: "message_start"},
+		{Type: "content_block_start", Index: 0, Value: "text"},
+		{Type: "content_block_delta", Index: 0, Value: "Let me "},
+		{Type: "content_block_delta", Index: 0, Value: "check."},
+		{Type: "content_block_stop", Index: 0},
+		{Type: "content_block_start", Index: 1, Value: "tool_use"},
+		{Type: "content_block_delta", Index: 1, Value: `{"q":`},
+		{Type: "content_block_delta", Index: 1, Value: `1}`},
+		{Type: "content_block_stop", Index: 1},
+		{Type: "message_delta", Value: string(anthropic.StopReasonToolUse)},
+		{Type: "message_stop"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v\nwant %+v", got, want)
+	}
+
+	messageDelta := events[len(events)-2]
+	if messageDelta.Usage.InputTokens != 12 || messageDelta.Usage.OutputTokens != 8 {
+		t.Errorf("message_delta usage = %+v, want 12 input and 8 output tokens", messageDelta.Usage)
+	}
+}
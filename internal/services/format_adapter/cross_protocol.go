@@ -0,0 +1,156 @@
+package format_adapter
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicparam "github.com/anthropics/anthropic-sdk-go/packages/param"
+	"github.com/openai/openai-go/v2"
+	openaiparam "github.com/openai/openai-go/v2/packages/param"
+	"google.golang.org/genai"
+)
+
+// OpenAI chat completions are the hub between protocols: requests, responses and streams of
+// the Messages and GenerateContent APIs are converted to and from them, and converting
+// between those two goes through both converters.
+
+const (
+	finishReasonStop          = "stop"
+	finishReasonLength        = "length"
+	finishReasonToolCalls     = "tool_calls"
+	finishReasonContentFilter = "content_filter"
+
+	// defaultMaxTokens is sent to protocols that require max_tokens when the request has none
+	defaultMaxTokens = 4096
+)
+
+// finishReasonFromAnthropic maps an Anthropic stop reason to an OpenAI finish reason
+func finishReasonFromAnthropic(reason anthropic.StopReason) string {
+	switch reason {
+	case anthropic.StopReasonMaxTokens, anthropic.StopReasonModelContextWindowExceeded:
+		return finishReasonLength
+	case anthropic.StopReasonToolUse:
+		return finishReasonToolCalls
+	case anthropic.StopReasonRefusal:
+		return finishReasonContentFilter
+	default:
+		return finishReasonStop
+	}
+}
+
+// stopReasonFromOpenAI maps an OpenAI finish reason to an Anthropic stop reason
+func stopReasonFromOpenAI(reason string) anthropic.StopReason {
+	switch reason {
+	case finishReasonLength:
+		return anthropic.StopReasonMaxTokens
+	case finishReasonToolCalls, "function_call":
+		return anthropic.StopReasonToolUse
+	case finishReasonContentFilter:
+		return anthropic.StopReasonRefusal
+	default:
+		return anthropic.StopReasonEndTurn
+	}
+}
+
+// finishReasonFromGemini maps a Gemini finish reason to an OpenAI finish reason. Gemini
+// finishes tool calls with STOP, so toolCalls reports whether the reply made any.
+func finishReasonFromGemini(reason genai.FinishReason, toolCalls bool) string {
+	switch reason {
+	case genai.FinishReasonMaxTokens:
+		return finishReasonLength
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSPII, genai.FinishReasonImageSafety:
+		return finishReasonContentFilter
+	}
+	if toolCalls {
+		return finishReasonToolCalls
+	}
+	return finishReasonStop
+}
+
+// finishReasonToGemini maps an OpenAI finish reason to a Gemini finish reason
+func finishReasonToGemini(reason string) genai.FinishReason {
+	switch reason {
+	case finishReasonLength:
+		return genai.FinishReasonMaxTokens
+	case finishReasonContentFilter:
+		return genai.FinishReasonSafety
+	default:
+		return genai.FinishReasonStop
+	}
+}
+
+// dataURL encodes base64 data as a data URL
+func dataURL(mediaType, data string) string {
+	return "data:" + mediaType + ";base64," + data
+}
+
+// parseDataURL splits a base64 data URL into its media type and data
+func parseDataURL(url string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(header, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// toolArguments returns tool call arguments as JSON, replacing arguments that are not a JSON
+// object with an empty one
+func toolArguments(arguments string) json.RawMessage {
+	var object map[string]any
+	if err := json.Unmarshal([]byte(arguments), &object); err != nil || object == nil {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// openAITextContent returns the text of a system, developer or tool message's content
+func openAITextContent(text string, parts []openai.ChatCompletionContentPartTextParam) string {
+	if text != "" {
+		return text
+	}
+	var builder strings.Builder
+	for _, part := range parts {
+		builder.WriteString(part.Text)
+	}
+	return builder.String()
+}
+
+// openAIChunk builds a single-choice streaming chunk
+func openAIChunk(id, model string, created int64, delta openai.ChatCompletionChunkChoiceDelta, finishReason string) openai.ChatCompletionChunk {
+	return openai.ChatCompletionChunk{
+		ID:      id,
+		Created: created,
+		Model:   model,
+		Object:  "chat.completion.chunk",
+		Choices: []openai.ChatCompletionChunkChoice{{
+			Delta:        delta,
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+// optToOpenAI copies an optional Anthropic parameter to an OpenAI one
+func optToOpenAI[T comparable](opt anthropicparam.Opt[T]) openaiparam.Opt[T] {
+	if !opt.Valid() {
+		return openaiparam.Opt[T]{}
+	}
+	return openaiparam.NewOpt(opt.Value)
+}
+
+// optFromOpenAI copies an optional OpenAI parameter to an Anthropic one
+func optFromOpenAI[T comparable](opt openaiparam.Opt[T]) anthropicparam.Opt[T] {
+	if !opt.Valid() {
+		return anthropicparam.Opt[T]{}
+	}
+	return anthropicparam.NewOpt(opt.Value)
+}
@@ -0,0 +1,146 @@
+package format_adapter
+
+import (
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"google.golang.org/genai"
+)
+
+func TestFinishReasonFromAnthropic(t *testing.T) {
+	tests := []struct {
+		reason anthropic.StopReason
+		want   string
+	}{
+		{anthropic.StopReasonEndTurn, finishReasonStop},
+		{anthropic.StopReasonStopSequence, finishReasonStop},
+		{anthropic.StopReasonMaxTokens, finishReasonLength},
+		{anthropic.StopReasonModelContextWindowExceeded, finishReasonLength},
+		{anthropic.StopReasonToolUse, finishReasonToolCalls},
+		{anthropic.StopReasonRefusal, finishReasonContentFilter},
+		{"", finishReasonStop},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			if got := finishReasonFromAnthropic(tt.reason); got != tt.want {
+				t.Errorf("finishReasonFromAnthropic(%q) = %q, want %q", tt.reason, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopReasonFromOpenAI(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   anthropic.StopReason
+	}{
+		{finishReasonStop, anthropic.StopReasonEndTurn},
+		{finishReasonLength, anthropic.StopReasonMaxTokens},
+		{finishReasonToolCalls, anthropic.StopReasonToolUse},
+		{"function_call", anthropic.StopReasonToolUse},
+		{finishReasonContentFilter, anthropic.StopReasonRefusal},
+		{"", anthropic.StopReasonEndTurn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			if got := stopReasonFromOpenAI(tt.reason); got != tt.want {
+				t.Errorf("stopReasonFromOpenAI(%q) = %q, want %q", tt.reason, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishReasonFromGemini(t *testing.T) {
+	tests := []struct {
+		name      string
+		reason    genai.FinishReason
+		toolCalls bool
+		want      string
+	}{
+		{name: "stop", reason: genai.FinishReasonStop, want: finishReasonStop},
+		{name: "stop after tool calls", reason: genai.FinishReasonStop, toolCalls: true, want: finishReasonToolCalls},
+		{name: "max tokens", reason: genai.FinishReasonMaxTokens, toolCalls: true, want: finishReasonLength},
+		{name: "safety", reason: genai.FinishReasonSafety, want: finishReasonContentFilter},
+		{name: "prohibited content", reason: genai.FinishReasonProhibitedContent, want: finishReasonContentFilter},
+		{name: "unspecified", reason: genai.FinishReasonUnspecified, want: finishReasonStop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := finishReasonFromGemini(tt.reason, tt.toolCalls); got != tt.want {
+				t.Errorf("finishReasonFromGemini(%q, %v) = %q, want %q", tt.reason, tt.toolCalls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishReasonToGemini(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   genai.FinishReason
+	}{
+		{finishReasonStop, genai.FinishReasonStop},
+		{finishReasonToolCalls, genai.FinishReasonStop},
+		{finishReasonLength, genai.FinishReasonMaxTokens},
+		{finishReasonContentFilter, genai.FinishReasonSafety},
+		{"", genai.FinishReasonStop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			if got := finishReasonToGemini(tt.reason); got != tt.want {
+				t.Errorf("finishReasonToGemini(%q) = %q, want %q", tt.reason, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDataURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		wantMediaType string
+		wantData      string
+		wantOK        bool
+	}{
+		{name: "base64 image", url: "data:image/png;base64,iVBORw0KGgo=", wantMediaType: "image/png", wantData: "iVBORw0KGgo=", wantOK: true},
+		{name: "round trip", url: dataURL("application/pdf", "JVBERi0="), wantMediaType: "application/pdf", wantData: "JVBERi0=", wantOK: true},
+		{name: "not base64", url: "data:text/plain,hello"},
+		{name: "no data", url: "data:image/png;base64"},
+		{name: "remote URL", url: "https://example.com/cat.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaType, data, ok := parseDataURL(tt.url)
+			if ok != tt.wantOK || mediaType != tt.wantMediaType || data != tt.wantData {
+				t.Errorf("parseDataURL(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.url, mediaType, data, ok, tt.wantMediaType, tt.wantData, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestToolArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		want      string
+	}{
+		{name: "object", arguments: `{"city":"Paris"}`, want: `{"city":"Paris"}`},
+		{name: "empty", arguments: "", want: "{}"},
+		{name: "truncated", arguments: `{"city":"Par`, want: "{}"},
+		{name: "not an object", arguments: `["Paris"]`, want: "{}"},
+		{name: "null", arguments: "null", want: "{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(toolArguments(tt.arguments)); got != tt.want {
+				t.Errorf("toolArguments(%q) = %s, want %s", tt.arguments, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package format_adapter
+
+import (
+	"iter"
+
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+)
+
+// convertedStream serves a stream of one API from a stream of another. convert maps each
+// source event to zero or more target events; finish emits the closing events once the
+// source ends cleanly.
+type convertedStream[S, T any] struct {
+	source   contracts.EventStream[S]
+	convert  func(S) []T
+	finish   func() []T
+	queue    []T
+	current  T
+	finished bool
+}
+
+func newConvertedStream[S, T any](source contracts.EventStream[S], convert func(S) []T, finish func() []T) *convertedStream[S, T] {
+	return &convertedStream[S, T]{source: source, convert: convert, finish: finish}
+}
+
+// Next advances to the next converted event
+func (s *convertedStream[S, T]) Next() bool {
+	for len(s.queue) == 0 {
+		if s.source.Next() {
+			s.queue = s.convert(s.source.Current())
+			continue
+		}
+		if s.finished || s.source.Err() != nil {
+			return false
+		}
+		s.finished = true
+		if s.finish != nil {
+			s.queue = s.finish()
+		}
+		if len(s.queue) == 0 {
+			return false
+		}
+	}
+	s.current, s.queue = s.queue[0], s.queue[1:]
+	return true
+}
+
+// Current returns the event Next advanced to
+func (s *convertedStream[S, T]) Current() T {
+	return s.current
+}
+
+// Err returns the error of the source stream
+func (s *convertedStream[S, T]) Err() error {
+	return s.source.Err()
+}
+
+// Close closes the source stream
+func (s *convertedStream[S, T]) Close() error {
+	return s.source.Close()
+}
+
+// seqStream adapts an iterator such as the Gemini SDK's to an EventStream
+type seqStream[T any] struct {
+	next    func() (T, error, bool)
+	stop    func()
+	current T
+	err     error
+}
+
+func newSeqStream[T any](seq iter.Seq2[T, error]) *seqStream[T] {
+	next, stop := iter.Pull2(seq)
+	return &seqStream[T]{next: next, stop: stop}
+}
+
+// Next advances to the next item, stopping at the first error
+func (s *seqStream[T]) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	item, err, ok := s.next()
+	if !ok {
+		return false
+	}
+	if err != nil {
+		s.err = err
+		return false
+	}
+	s.current = item
+	return true
+}
+
+// Current returns the item Next advanced to
+func (s *seqStream[T]) Current() T {
+	return s.current
+}
+
+// Err returns the error the iterator yielded, if any
+func (s *seqStream[T]) Err() error {
+	return s.err
+}
+
+// Close stops the iterator
+func (s *seqStream[T]) Close() error {
+	s.stop()
+	return nil
+}
+
+// streamSeq adapts an EventStream to an iterator, closing the stream once iteration stops
+func streamSeq[T any](stream contracts.EventStream[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer func() { _ = stream.Close() }()
+		for stream.Next() {
+			if !yield(stream.Current(), nil) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package format_adapter
+
+import (
+	"errors"
+	"iter"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sliceStream is an EventStream over fixed events that fails with err once they run out
+type sliceStream[T any] struct {
+	events  []T
+	err     error
+	current T
+	closed  bool
+}
+
+func (s *sliceStream[T]) Next() bool {
+	if len(s.events) == 0 {
+		return false
+	}
+	s.current, s.events = s.events[0], s.events[1:]
+	return true
+}
+
+func (s *sliceStream[T]) Current() T {
+	return s.current
+}
+
+func (s *sliceStream[T]) Err() error {
+	if len(s.events) > 0 {
+		return nil
+	}
+	return s.err
+}
+
+func (s *sliceStream[T]) Close() error {
+	s.closed = true
+	return nil
+}
+
+// collect drains an EventStream
+func collect[T any](stream interface {
+	Next() bool
+	Current() T
+}) []T {
+	var events []T
+	for stream.Next() {
+		events = append(events, stream.Current())
+	}
+	return events
+}
+
+func TestConvertedStream(t *testing.T) {
+	errBroken := errors.New("connection reset")
+	// Each word becomes one event per letter; blank words produce none
+	letters := func(word string) []string {
+		return strings.Split(word, "")
+	}
+	finish := func() []string {
+		return []string{"done"}
+	}
+
+	tests := []struct {
+		name    string
+		events  []string
+		err     error
+		finish  func() []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "events are converted in order and finished once",
+			events: []string{"ab", "", "c"},
+			finish: finish,
+			want:   []string{"a", "b", "c", "done"},
+		},
+		{
+			name:   "empty source is still finished",
+			finish: finish,
+			want:   []string{"done"},
+		},
+		{
+			name:   "without a finish",
+			events: []string{"ab"},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:    "failed source is not finished",
+			events:  []string{"ab"},
+			err:     errBroken,
+			finish:  finish,
+			want:    []string{"a", "b"},
+			wantErr: errBroken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &sliceStream[string]{events: tt.events, err: tt.err}
+			stream := newConvertedStream(source, letters, tt.finish)
+
+			if got := collect[string](stream); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("converted events = %q, want %q", got, tt.want)
+			}
+			if stream.Next() {
+				t.Errorf("Next() after the end = true, want false")
+			}
+			if err := stream.Err(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Err() = %v, want %v", err, tt.wantErr)
+			}
+			if err := stream.Close(); err != nil || !source.closed {
+				t.Errorf("Close() = %v, source closed = %v; want the source closed", err, source.closed)
+			}
+		})
+	}
+}
+
+func TestSeqStream(t *testing.T) {
+	errBroken := errors.New("connection reset")
+	stopped := false
+	seq := func(yield func(int, error) bool) {
+		defer func() { stopped = true }()
+		for _, item := range []int{1, 2} {
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if !yield(0, errBroken) {
+			return
+		}
+		yield(3, nil)
+	}
+
+	stream := newSeqStream(iter.Seq2[int, error](seq))
+	if got := collect[int](stream); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("items = %v, want [1 2]", got)
+	}
+	if stream.Next() {
+		t.Errorf("Next() after an error = true, want false")
+	}
+	if err := stream.Err(); !errors.Is(err, errBroken) {
+		t.Errorf("Err() = %v, want %v", err, errBroken)
+	}
+	if err := stream.Close(); err != nil || !stopped {
+		t.Errorf("Close() = %v, iterator stopped = %v; want the iterator stopped", err, stopped)
+	}
+}
+
+func TestStreamSeq(t *testing.T) {
+	errBroken := errors.New("connection reset")
+
+	t.Run("yields events then the error", func(t *testing.T) {
+		source := &sliceStream[string]{events: []string{"a", "b"}, err: errBroken}
+		var events []string
+		var errs []error
+		for event, err := range streamSeq[string](source) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			events = append(events, event)
+		}
+		if !reflect.DeepEqual(events, []string{"a", "b"}) {
+			t.Errorf("events = %q, want [a b]", events)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], errBroken) {
+			t.Errorf("errors = %v, want [%v]", errs, errBroken)
+		}
+		if !source.closed {
+			t.Errorf("source not closed")
+		}
+	})
+
+	t.Run("closes the source when iteration stops early", func(t *testing.T) {
+		source := &sliceStream[string]{events: []string{"a", "b"}}
+		for range streamSeq[string](source) {
+			break
+		}
+		if !source.closed {
+			t.Errorf("source not closed")
+		}
+	})
+}
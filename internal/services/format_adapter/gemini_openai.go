@@ -0,0 +1,792 @@
+package format_adapter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+	"google.golang.org/genai"
+)
+
+// GeminiOpenAIConverter converts between the Gemini GenerateContent API and OpenAI chat
+// completions, so either API can be served by a provider speaking the other
+type GeminiOpenAIConverter struct{}
+
+// RequestToOpenAI converts a GenerateContent request to chat completion parameters
+func (c *GeminiOpenAIConverter) RequestToOpenAI(req *models.GeminiGenerateRequest) (*openai.ChatCompletionNewParams, error) {
+	if req == nil {
+		return nil, fmt.Errorf("gemini generate request cannot be nil")
+	}
+
+	params := &openai.ChatCompletionNewParams{Model: shared.ChatModel(req.Model)}
+	config := req.GenerationConfig
+	if config == nil {
+		config = &genai.GenerateContentConfig{}
+	}
+
+	system := req.SystemInstruction
+	if system == nil {
+		system = config.SystemInstruction
+	}
+	if text := geminiText(system); text != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(text))
+	}
+
+	// Gemini may omit function call IDs; generated IDs are matched to responses by name
+	pendingCalls := make(map[string][]string)
+	callCount := 0
+	for i, content := range req.Contents {
+		if content == nil {
+			continue
+		}
+		if content.Role == genai.RoleModel {
+			message, err := c.modelContentToOpenAI(content, pendingCalls, &callCount)
+			if err != nil {
+				return nil, fmt.Errorf("contents[%d]: %w", i, err)
+			}
+			params.Messages = append(params.Messages, message)
+			continue
+		}
+		messages, err := c.userContentToOpenAI(content, pendingCalls)
+		if err != nil {
+			return nil, fmt.Errorf("contents[%d]: %w", i, err)
+		}
+		params.Messages = append(params.Messages, messages...)
+	}
+
+	if config.Temperature != nil {
+		params.Temperature = openai.Float(float64(*config.Temperature))
+	}
+	if config.TopP != nil {
+		params.TopP = openai.Float(float64(*config.TopP))
+	}
+	if config.MaxOutputTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(config.MaxOutputTokens))
+	}
+	if len(config.StopSequences) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: config.StopSequences}
+	}
+	if config.Seed != nil {
+		params.Seed = openai.Int(int64(*config.Seed))
+	}
+	if config.PresencePenalty != nil {
+		params.PresencePenalty = openai.Float(float64(*config.PresencePenalty))
+	}
+	if config.FrequencyPenalty != nil {
+		params.FrequencyPenalty = openai.Float(float64(*config.FrequencyPenalty))
+	}
+	if config.ResponseMIMEType == "application/json" {
+		schema := config.ResponseJsonSchema
+		if schema == nil && config.ResponseSchema != nil {
+			schema = geminiSchemaToJSONSchema(config.ResponseSchema)
+		}
+		if schema != nil {
+			params.ResponseFormat.OfJSONSchema = &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{Name: "response", Schema: schema},
+			}
+		} else {
+			params.ResponseFormat.OfJSONObject = &shared.ResponseFormatJSONObjectParam{}
+		}
+	}
+
+	for _, tool := range slices.Concat(req.Tools, config.Tools) {
+		if tool == nil {
+			continue
+		}
+		for _, declaration := range tool.FunctionDeclarations {
+			parameters := shared.FunctionParameters{"type": "object", "properties": map[string]any{}}
+			switch {
+			case declaration.ParametersJsonSchema != nil:
+				if schema, ok := jsonObject(declaration.ParametersJsonSchema); ok {
+					parameters = schema
+				}
+			case declaration.Parameters != nil:
+				parameters = geminiSchemaToJSONSchema(declaration.Parameters)
+			}
+			function := shared.FunctionDefinitionParam{Name: declaration.Name, Parameters: parameters}
+			if declaration.Description != "" {
+				function.Description = openai.String(declaration.Description)
+			}
+			params.Tools = append(params.Tools, openai.ChatCompletionFunctionTool(function))
+		}
+	}
+
+	toolConfig := req.ToolConfig
+	if toolConfig == nil {
+		toolConfig = config.ToolConfig
+	}
+	if len(params.Tools) > 0 && toolConfig != nil && toolConfig.FunctionCallingConfig != nil {
+		calling := toolConfig.FunctionCallingConfig
+		switch calling.Mode {
+		case genai.FunctionCallingConfigModeAuto:
+			params.ToolChoice.OfAuto = openai.String("auto")
+		case genai.FunctionCallingConfigModeAny, genai.FunctionCallingConfigModeValidated:
+			if len(calling.AllowedFunctionNames) == 1 {
+				params.ToolChoice = openai.ToolChoiceOptionFunctionToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{Name: calling.AllowedFunctionNames[0]})
+			} else {
+				params.ToolChoice.OfAuto = openai.String("required")
+			}
+		case genai.FunctionCallingConfigModeNone:
+			params.ToolChoice.OfAuto = openai.String("none")
+		}
+	}
+
+	return params, nil
+}
+
+// modelContentToOpenAI converts a model turn to an assistant message
+func (c *GeminiOpenAIConverter) modelContentToOpenAI(content *genai.Content, pendingCalls map[string][]string, callCount *int) (openai.ChatCompletionMessageParamUnion, error) {
+	var text strings.Builder
+	var toolCalls []openai.ChatCompletionMessageToolCallUnionParam
+	for _, part := range content.Parts {
+		switch {
+		case part == nil || part.Thought:
+		case part.FunctionCall != nil:
+			arguments, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("invalid function call args: %w", err)
+			}
+			id := part.FunctionCall.ID
+			if id == "" {
+				*callCount++
+				id = fmt.Sprintf("call_%d", *callCount)
+			}
+			pendingCalls[part.FunctionCall.Name] = append(pendingCalls[part.FunctionCall.Name], id)
+			toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+				OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+					ID: id,
+					Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+						Name:      part.FunctionCall.Name,
+						Arguments: string(toolArguments(string(arguments))),
+					},
+				},
+			})
+		default:
+			text.WriteString(part.Text)
+		}
+	}
+
+	assistant := openai.ChatCompletionAssistantMessageParam{ToolCalls: toolCalls}
+	if text.Len() > 0 {
+		assistant.Content.OfString = openai.String(text.String())
+	}
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant}, nil
+}
+
+// userContentToOpenAI converts a user turn. Function responses become tool messages, which
+// precede the rest of the turn they were sent in.
+func (c *GeminiOpenAIConverter) userContentToOpenAI(content *genai.Content, pendingCalls map[string][]string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	var messages []openai.ChatCompletionMessageParamUnion
+	var parts []openai.ChatCompletionContentPartUnionParam
+	for _, part := range content.Parts {
+		switch {
+		case part == nil:
+		case part.FunctionResponse != nil:
+			response := part.FunctionResponse
+			id := response.ID
+			if ids := pendingCalls[response.Name]; len(ids) > 0 {
+				if id == "" {
+					id = ids[0]
+				}
+				pendingCalls[response.Name] = ids[1:]
+			}
+			messages = append(messages, openai.ToolMessage(functionResponseText(response.Response), id))
+		case part.InlineData != nil:
+			url := dataURL(part.InlineData.MIMEType, base64.StdEncoding.EncodeToString(part.InlineData.Data))
+			switch {
+			case strings.HasPrefix(part.InlineData.MIMEType, "image/"):
+				parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url}))
+			case part.InlineData.MIMEType == "application/pdf":
+				parts = append(parts, openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+					FileData: openai.String(url),
+					Filename: openai.String("document.pdf"),
+				}))
+			default:
+				return nil, fmt.Errorf("inline %s data cannot be served by an OpenAI provider", part.InlineData.MIMEType)
+			}
+		case part.FileData != nil:
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: part.FileData.FileURI}))
+		case part.Text != "":
+			parts = append(parts, openai.TextContentPart(part.Text))
+		}
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0].OfText != nil:
+		messages = append(messages, openai.UserMessage(parts[0].OfText.Text))
+	case len(parts) > 0:
+		messages = append(messages, openai.UserMessage(parts))
+	}
+	return messages, nil
+}
+
+// functionResponseText returns a function response as tool message content, unwrapping the
+// {"output": ...} envelope RequestFromOpenAI produces
+func functionResponseText(response map[string]any) string {
+	if output, ok := response["output"].(string); ok && len(response) == 1 {
+		return output
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// geminiSchemaToJSONSchema converts a Gemini schema to JSON schema, which differs mainly in
+// spelling types in upper case
+func geminiSchemaToJSONSchema(schema *genai.Schema) map[string]any {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return map[string]any{"type": "object"}
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return map[string]any{"type": "object"}
+	}
+	return lowercaseSchemaTypes(decoded).(map[string]any)
+}
+
+// lowercaseSchemaTypes lower-cases "type" values throughout a decoded schema and drops the
+// Gemini-only property ordering
+func lowercaseSchemaTypes(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			switch key {
+			case "type":
+				if name, ok := child.(string); ok {
+					value[key] = strings.ToLower(name)
+				}
+			case "propertyOrdering":
+				delete(value, key)
+			default:
+				value[key] = lowercaseSchemaTypes(child)
+			}
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = lowercaseSchemaTypes(child)
+		}
+	}
+	return value
+}
+
+// jsonObject returns a JSON schema value as a decoded object
+func jsonObject(value any) (map[string]any, bool) {
+	if object, ok := value.(map[string]any); ok {
+		return object, true
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	var object map[string]any
+	if err := json.Unmarshal(encoded, &object); err != nil || object == nil {
+		return nil, false
+	}
+	return object, true
+}
+
+// geminiText joins the non-thought text parts of a content
+func geminiText(content *genai.Content) string {
+	if content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range content.Parts {
+		if part != nil && !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// RequestFromOpenAI converts chat completion parameters to a GenerateContent request. The
+// system instruction, tools and tool config go in the generation config, which is what the
+// Gemini SDK sends.
+func (c *GeminiOpenAIConverter) RequestFromOpenAI(params *openai.ChatCompletionNewParams) (*models.GeminiGenerateRequest, error) {
+	if params == nil {
+		return nil, fmt.Errorf("openai chat completion params cannot be nil")
+	}
+
+	config := &genai.GenerateContentConfig{}
+	req := &models.GeminiGenerateRequest{Model: params.Model, GenerationConfig: config}
+
+	// Function responses carry the function name, which tool messages only reference by ID
+	toolNames := make(map[string]string)
+	var system []string
+	for i, message := range params.Messages {
+		var role string
+		var parts []*genai.Part
+		switch {
+		case message.OfSystem != nil:
+			content := message.OfSystem.Content
+			system = append(system, openAITextContent(content.OfString.Value, content.OfArrayOfContentParts))
+			continue
+		case message.OfDeveloper != nil:
+			content := message.OfDeveloper.Content
+			system = append(system, openAITextContent(content.OfString.Value, content.OfArrayOfContentParts))
+			continue
+		case message.OfUser != nil:
+			role = genai.RoleUser
+			content := message.OfUser.Content
+			if content.OfString.Valid() {
+				parts = append(parts, genai.NewPartFromText(content.OfString.Value))
+			}
+			for _, part := range content.OfArrayOfContentParts {
+				converted, err := contentPartToGemini(part)
+				if err != nil {
+					return nil, fmt.Errorf("messages[%d]: %w", i, err)
+				}
+				parts = append(parts, converted)
+			}
+		case message.OfAssistant != nil:
+			role = genai.RoleModel
+			content := message.OfAssistant.Content
+			if content.OfString.Valid() && content.OfString.Value != "" {
+				parts = append(parts, genai.NewPartFromText(content.OfString.Value))
+			}
+			for _, part := range content.OfArrayOfContentParts {
+				switch {
+				case part.OfText != nil && part.OfText.Text != "":
+					parts = append(parts, genai.NewPartFromText(part.OfText.Text))
+				case part.OfRefusal != nil:
+					parts = append(parts, genai.NewPartFromText(part.OfRefusal.Refusal))
+				}
+			}
+			for _, toolCall := range message.OfAssistant.ToolCalls {
+				if toolCall.OfFunction == nil {
+					return nil, fmt.Errorf("messages[%d]: custom tool calls cannot be served by a Gemini provider", i)
+				}
+				function := toolCall.OfFunction.Function
+				var args map[string]any
+				_ = json.Unmarshal(toolArguments(function.Arguments), &args)
+				toolNames[toolCall.OfFunction.ID] = function.Name
+				parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+					ID:   toolCall.OfFunction.ID,
+					Name: function.Name,
+					Args: args,
+				}})
+			}
+		case message.OfTool != nil:
+			role = genai.RoleUser
+			content := message.OfTool.Content
+			parts = append(parts, &genai.Part{FunctionResponse: &genai.FunctionResponse{
+				ID:       message.OfTool.ToolCallID,
+				Name:     toolNames[message.OfTool.ToolCallID],
+				Response: map[string]any{"output": openAITextContent(content.OfString.Value, content.OfArrayOfContentParts)},
+			}})
+		default:
+			return nil, fmt.Errorf("messages[%d]: function messages cannot be served by a Gemini provider", i)
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+		// Gemini requires roles to alternate, so consecutive turns of one role are merged
+		if last := len(req.Contents) - 1; last >= 0 && req.Contents[last].Role == role {
+			req.Contents[last].Parts = append(req.Contents[last].Parts, parts...)
+			continue
+		}
+		req.Contents = append(req.Contents, &genai.Content{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		config.SystemInstruction = genai.NewContentFromText(strings.Join(system, "\n\n"), genai.RoleUser)
+	}
+
+	if params.Temperature.Valid() {
+		config.Temperature = genai.Ptr(float32(params.Temperature.Value))
+	}
+	if params.TopP.Valid() {
+		config.TopP = genai.Ptr(float32(params.TopP.Value))
+	}
+	switch {
+	case params.MaxCompletionTokens.Valid():
+		config.MaxOutputTokens = int32(params.MaxCompletionTokens.Value)
+	case params.MaxTokens.Valid():
+		config.MaxOutputTokens = int32(params.MaxTokens.Value)
+	}
+	if params.Stop.OfString.Valid() {
+		config.StopSequences = []string{params.Stop.OfString.Value}
+	} else {
+		config.StopSequences = params.Stop.OfStringArray
+	}
+	if params.Seed.Valid() {
+		config.Seed = genai.Ptr(int32(params.Seed.Value))
+	}
+	if params.PresencePenalty.Valid() {
+		config.PresencePenalty = genai.Ptr(float32(params.PresencePenalty.Value))
+	}
+	if params.FrequencyPenalty.Valid() {
+		config.FrequencyPenalty = genai.Ptr(float32(params.FrequencyPenalty.Value))
+	}
+	switch {
+	case params.ResponseFormat.OfJSONSchema != nil:
+		config.ResponseMIMEType = "application/json"
+		config.ResponseJsonSchema = params.ResponseFormat.OfJSONSchema.JSONSchema.Schema
+	case params.ResponseFormat.OfJSONObject != nil:
+		config.ResponseMIMEType = "application/json"
+	}
+
+	var declarations []*genai.FunctionDeclaration
+	for _, tool := range params.Tools {
+		if tool.OfFunction == nil {
+			return nil, fmt.Errorf("custom tools cannot be served by a Gemini provider")
+		}
+		function := tool.OfFunction.Function
+		declaration := &genai.FunctionDeclaration{Name: function.Name, Description: function.Description.Value}
+		if function.Parameters != nil {
+			declaration.ParametersJsonSchema = map[string]any(function.Parameters)
+		}
+		declarations = append(declarations, declaration)
+	}
+	if len(declarations) > 0 {
+		config.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+		config.ToolConfig = toolConfigFromOpenAI(params.ToolChoice)
+	}
+
+	return req, nil
+}
+
+// contentPartToGemini converts a user content part
+func contentPartToGemini(part openai.ChatCompletionContentPartUnionParam) (*genai.Part, error) {
+	switch {
+	case part.OfText != nil:
+		return genai.NewPartFromText(part.OfText.Text), nil
+	case part.OfImageURL != nil:
+		return urlPartToGemini(part.OfImageURL.ImageURL.URL, "image/jpeg")
+	case part.OfFile != nil && part.OfFile.File.FileData.Valid():
+		return urlPartToGemini(part.OfFile.File.FileData.Value, "application/pdf")
+	case part.OfInputAudio != nil:
+		data, err := base64.StdEncoding.DecodeString(part.OfInputAudio.InputAudio.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 audio: %w", err)
+		}
+		return genai.NewPartFromBytes(data, "audio/"+part.OfInputAudio.InputAudio.Format), nil
+	default:
+		return nil, fmt.Errorf("uploaded files cannot be served by a Gemini provider")
+	}
+}
+
+// urlPartToGemini converts a data URL to inline data and any other URL to file data, guessing
+// its MIME type from the extension
+func urlPartToGemini(url, defaultMIMEType string) (*genai.Part, error) {
+	if mediaType, encoded, ok := parseDataURL(url); ok {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data URL: %w", err)
+		}
+		return genai.NewPartFromBytes(data, mediaType), nil
+	}
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(url)), ";")
+	if mimeType == "" {
+		mimeType = defaultMIMEType
+	}
+	return genai.NewPartFromURI(url, mimeType), nil
+}
+
+// toolConfigFromOpenAI converts an OpenAI tool choice to a Gemini function calling config
+func toolConfigFromOpenAI(choice openai.ChatCompletionToolChoiceOptionUnionParam) *genai.ToolConfig {
+	calling := &genai.FunctionCallingConfig{}
+	switch {
+	case choice.OfFunctionToolChoice != nil:
+		calling.Mode = genai.FunctionCallingConfigModeAny
+		calling.AllowedFunctionNames = []string{choice.OfFunctionToolChoice.Function.Name}
+	case choice.OfAuto.Value == "required":
+		calling.Mode = genai.FunctionCallingConfigModeAny
+	case choice.OfAuto.Value == "none":
+		calling.Mode = genai.FunctionCallingConfigModeNone
+	case choice.OfAuto.Valid():
+		calling.Mode = genai.FunctionCallingConfigModeAuto
+	default:
+		return nil
+	}
+	return &genai.ToolConfig{FunctionCallingConfig: calling}
+}
+
+// ResponseToOpenAI converts a GenerateContent response to a chat completion
+func (c *GeminiOpenAIConverter) ResponseToOpenAI(resp *genai.GenerateContentResponse) (*openai.ChatCompletion, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("genai generate content response cannot be nil")
+	}
+
+	completion := &openai.ChatCompletion{
+		ID:      resp.ResponseID,
+		Created: time.Now().Unix(),
+		Model:   resp.ModelVersion,
+		Object:  "chat.completion",
+		Usage:   usageFromGemini(resp.UsageMetadata),
+	}
+	if !resp.CreateTime.IsZero() {
+		completion.Created = resp.CreateTime.Unix()
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0] == nil {
+		finishReason := finishReasonStop
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			finishReason = finishReasonContentFilter
+		}
+		completion.Choices = []openai.ChatCompletionChoice{{
+			FinishReason: finishReason,
+			Message:      openai.ChatCompletionMessage{Role: "assistant"},
+		}}
+		return completion, nil
+	}
+
+	candidate := resp.Candidates[0]
+	text, toolCalls := c.candidateToOpenAI(candidate, 0)
+	var messageToolCalls []openai.ChatCompletionMessageToolCallUnion
+	for _, toolCall := range toolCalls {
+		messageToolCalls = append(messageToolCalls, openai.ChatCompletionMessageToolCallUnion{
+			ID:       toolCall.ID,
+			Type:     "function",
+			Function: openai.ChatCompletionMessageFunctionToolCallFunction{Name: toolCall.Function.Name, Arguments: toolCall.Function.Arguments},
+		})
+	}
+	completion.Choices = []openai.ChatCompletionChoice{{
+		FinishReason: finishReasonFromGemini(candidate.FinishReason, len(toolCalls) > 0),
+		Message: openai.ChatCompletionMessage{
+			Content:   text,
+			Role:      "assistant",
+			ToolCalls: messageToolCalls,
+		},
+	}}
+	return completion, nil
+}
+
+// candidateToOpenAI returns a candidate's text and its function calls as complete tool call
+// deltas, numbered from firstIndex
+func (c *GeminiOpenAIConverter) candidateToOpenAI(candidate *genai.Candidate, firstIndex int64) (string, []openai.ChatCompletionChunkChoiceDeltaToolCall) {
+	if candidate.Content == nil {
+		return "", nil
+	}
+
+	var text strings.Builder
+	var toolCalls []openai.ChatCompletionChunkChoiceDeltaToolCall
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part == nil || part.Thought:
+		case part.FunctionCall != nil:
+			index := firstIndex + int64(len(toolCalls))
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", index+1)
+			}
+			arguments, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				arguments = []byte("{}")
+			}
+			toolCalls = append(toolCalls, openai.ChatCompletionChunkChoiceDeltaToolCall{
+				Index: index,
+				ID:    id,
+				Type:  "function",
+				Function: openai.ChatCompletionChunkChoiceDeltaToolCallFunction{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(toolArguments(string(arguments))),
+				},
+			})
+		default:
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String(), toolCalls
+}
+
+// usageFromGemini builds OpenAI usage, counting thinking tokens as completion tokens
+func usageFromGemini(usage *genai.GenerateContentResponseUsageMetadata) openai.CompletionUsage {
+	if usage == nil {
+		return openai.CompletionUsage{}
+	}
+	prompt := int64(usage.PromptTokenCount)
+	completion := int64(usage.CandidatesTokenCount + usage.ThoughtsTokenCount)
+	return openai.CompletionUsage{
+		PromptTokens:            prompt,
+		CompletionTokens:        completion,
+		TotalTokens:             prompt + completion,
+		PromptTokensDetails:     openai.CompletionUsagePromptTokensDetails{CachedTokens: int64(usage.CachedContentTokenCount)},
+		CompletionTokensDetails: openai.CompletionUsageCompletionTokensDetails{ReasoningTokens: int64(usage.ThoughtsTokenCount)},
+	}
+}
+
+// usageToGemini builds Gemini usage metadata from OpenAI usage
+func usageToGemini(usage openai.CompletionUsage) *genai.GenerateContentResponseUsageMetadata {
+	return &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:        int32(usage.PromptTokens),
+		CandidatesTokenCount:    int32(usage.CompletionTokens - usage.CompletionTokensDetails.ReasoningTokens),
+		ThoughtsTokenCount:      int32(usage.CompletionTokensDetails.ReasoningTokens),
+		CachedContentTokenCount: int32(usage.PromptTokensDetails.CachedTokens),
+		TotalTokenCount:         int32(usage.PromptTokens + usage.CompletionTokens),
+	}
+}
+
+// ResponseFromOpenAI converts a chat completion to a GenerateContent response
+func (c *GeminiOpenAIConverter) ResponseFromOpenAI(resp *openai.ChatCompletion) (*genai.GenerateContentResponse, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("openai chat completion cannot be nil")
+	}
+
+	converted := &genai.GenerateContentResponse{
+		CreateTime:    time.Unix(resp.Created, 0),
+		ModelVersion:  resp.Model,
+		ResponseID:    resp.ID,
+		UsageMetadata: usageToGemini(resp.Usage),
+	}
+	if len(resp.Choices) == 0 {
+		return converted, nil
+	}
+
+	choice := resp.Choices[0]
+	var parts []*genai.Part
+	text := choice.Message.Content
+	if text == "" {
+		text = choice.Message.Refusal
+	}
+	if text != "" {
+		parts = append(parts, genai.NewPartFromText(text))
+	}
+	for _, toolCall := range choice.Message.ToolCalls {
+		var args map[string]any
+		_ = json.Unmarshal(toolArguments(toolCall.Function.Arguments), &args)
+		parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{ID: toolCall.ID, Name: toolCall.Function.Name, Args: args}})
+	}
+	converted.Candidates = []*genai.Candidate{{
+		Content:      &genai.Content{Role: genai.RoleModel, Parts: parts},
+		FinishReason: finishReasonToGemini(choice.FinishReason),
+	}}
+	return converted, nil
+}
+
+// StreamToOpenAI converts a GenerateContent stream to a chat completion chunk stream
+func (c *GeminiOpenAIConverter) StreamToOpenAI(stream iter.Seq2[*genai.GenerateContentResponse, error]) contracts.EventStream[openai.ChatCompletionChunk] {
+	var id, model string
+	created := time.Now().Unix()
+	started, finished := false, false
+	toolCallCount := int64(0)
+	// Gemini usage is cumulative, so only the latest is sent, with the finish reason
+	var usage *genai.GenerateContentResponseUsageMetadata
+
+	finish := func(finishReason string) openai.ChatCompletionChunk {
+		finished = true
+		chunk := openAIChunk(id, model, created, openai.ChatCompletionChunkChoiceDelta{}, finishReason)
+		chunk.Usage = usageFromGemini(usage)
+		return chunk
+	}
+
+	return newConvertedStream(contracts.EventStream[*genai.GenerateContentResponse](newSeqStream(stream)), func(resp *genai.GenerateContentResponse) []openai.ChatCompletionChunk {
+		if resp == nil || finished {
+			return nil
+		}
+		var chunks []openai.ChatCompletionChunk
+		if !started {
+			started = true
+			id, model = resp.ResponseID, resp.ModelVersion
+			chunks = append(chunks, openAIChunk(id, model, created, openai.ChatCompletionChunkChoiceDelta{Role: "assistant"}, ""))
+		}
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if len(resp.Candidates) == 0 || resp.Candidates[0] == nil {
+			if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+				chunks = append(chunks, finish(finishReasonContentFilter))
+			}
+			return chunks
+		}
+
+		candidate := resp.Candidates[0]
+		text, toolCalls := c.candidateToOpenAI(candidate, toolCallCount)
+		toolCallCount += int64(len(toolCalls))
+		if text != "" || len(toolCalls) > 0 {
+			chunks = append(chunks, openAIChunk(id, model, created, openai.ChatCompletionChunkChoiceDelta{Content: text, ToolCalls: toolCalls}, ""))
+		}
+		if candidate.FinishReason != "" {
+			chunks = append(chunks, finish(finishReasonFromGemini(candidate.FinishReason, toolCallCount > 0)))
+		}
+		return chunks
+	}, func() []openai.ChatCompletionChunk {
+		if finished || !started {
+			return nil
+		}
+		return []openai.ChatCompletionChunk{finish(finishReasonFromGemini(genai.FinishReasonStop, toolCallCount > 0))}
+	})
+}
+
+// StreamFromOpenAI converts a chat completion chunk stream to a GenerateContent stream. Text
+// is streamed as it arrives; tool calls are streamed in fragments, so they are sent whole
+// with the finish reason.
+func (c *GeminiOpenAIConverter) StreamFromOpenAI(stream contracts.EventStream[openai.ChatCompletionChunk]) iter.Seq2[*genai.GenerateContentResponse, error] {
+	type toolCall struct {
+		id, name  string
+		arguments strings.Builder
+	}
+	var id, model string
+	var toolCalls []*toolCall
+	toolCallIndexes := make(map[int64]int)
+	finishReason := ""
+	var usage openai.CompletionUsage
+
+	response := func(parts []*genai.Part) *genai.GenerateContentResponse {
+		return &genai.GenerateContentResponse{
+			ModelVersion: model,
+			ResponseID:   id,
+			Candidates:   []*genai.Candidate{{Content: &genai.Content{Role: genai.RoleModel, Parts: parts}}},
+		}
+	}
+
+	converted := newConvertedStream(stream, func(chunk openai.ChatCompletionChunk) []*genai.GenerateContentResponse {
+		id, model = chunk.ID, chunk.Model
+		if chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+
+		choice := chunk.Choices[0]
+		for _, delta := range choice.Delta.ToolCalls {
+			i, known := toolCallIndexes[delta.Index]
+			if !known {
+				i = len(toolCalls)
+				toolCallIndexes[delta.Index] = i
+				toolCalls = append(toolCalls, &toolCall{})
+			}
+			if delta.ID != "" {
+				toolCalls[i].id = delta.ID
+			}
+			if delta.Function.Name != "" {
+				toolCalls[i].name = delta.Function.Name
+			}
+			toolCalls[i].arguments.WriteString(delta.Function.Arguments)
+		}
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+		if text := choice.Delta.Content + choice.Delta.Refusal; text != "" {
+			return []*genai.GenerateContentResponse{response([]*genai.Part{genai.NewPartFromText(text)})}
+		}
+		return nil
+	}, func() []*genai.GenerateContentResponse {
+		var parts []*genai.Part
+		for _, call := range toolCalls {
+			var args map[string]any
+			_ = json.Unmarshal(toolArguments(call.arguments.String()), &args)
+			parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{ID: call.id, Name: call.name, Args: args}})
+		}
+		final := response(parts)
+		final.Candidates[0].FinishReason = finishReasonToGemini(finishReason)
+		final.UsageMetadata = usageToGemini(usage)
+		return []*genai.GenerateContentResponse{final}
+	})
+	return streamSeq[*genai.GenerateContentResponse](converted)
+}
@@ -0,0 +1,382 @@
+package format_adapter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+func TestGeminiRequestToOpenAI(t *testing.T) {
+	req := &models.GeminiGenerateRequest{
+		Model:             "gemini-2.5-flash",
+		SystemInstruction: genai.NewContentFromText("Be brief.", genai.RoleUser),
+		Contents: []*genai.Content{
+			{Role: genai.RoleUser, Parts: []*genai.Part{
+				genai.NewPartFromText("What is the weather?"),
+				genai.NewPartFromBytes([]byte("png"), "image/png"),
+			}},
+			// Gemini may omit function call IDs
+			{Role: genai.RoleModel, Parts: []*genai.Part{
+				{Text: "thinking", Thought: true},
+				genai.NewPartFromFunctionCall("weather", map[string]any{"city": "Paris"}),
+			}},
+			{Role: genai.RoleUser, Parts: []*genai.Part{
+				genai.NewPartFromFunctionResponse("weather", map[string]any{"output": "sunny"}),
+			}},
+		},
+		GenerationConfig: &genai.GenerateContentConfig{
+			MaxOutputTokens:  256,
+			Temperature:      genai.Ptr(float32(0.5)),
+			ResponseMIMEType: "application/json",
+			ToolConfig: &genai.ToolConfig{FunctionCallingConfig: &genai.FunctionCallingConfig{
+				Mode:                 genai.FunctionCallingConfigModeAny,
+				AllowedFunctionNames: []string{"weather"},
+			}},
+		},
+		Tools: []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{
+			Name: "weather",
+			Parameters: &genai.Schema{
+				Type:       genai.TypeObject,
+				Properties: map[string]*genai.Schema{"city": {Type: genai.TypeString}},
+			},
+		}}}},
+	}
+
+	params, err := GeminiOpenAI.RequestToOpenAI(req)
+	if err != nil {
+		t.Fatalf("RequestToOpenAI() error = %v", err)
+	}
+
+	if params.MaxCompletionTokens.Value != 256 || params.Temperature.Value != 0.5 {
+		t.Errorf("max tokens/temperature = %d/%v, want 256/0.5", params.MaxCompletionTokens.Value, params.Temperature.Value)
+	}
+	if params.ResponseFormat.OfJSONObject == nil {
+		t.Errorf("response_format = %+v, want json_object", params.ResponseFormat)
+	}
+	if len(params.Messages) != 4 {
+		t.Fatalf("got %d messages, want system, user, assistant and tool", len(params.Messages))
+	}
+	if system := params.Messages[0].OfSystem; system == nil || system.Content.OfString.Value != "Be brief." {
+		t.Errorf("system message = %+v", params.Messages[0])
+	}
+	user := params.Messages[1].OfUser
+	if user == nil || len(user.Content.OfArrayOfContentParts) != 2 {
+		t.Fatalf("user message = %+v, want text and image parts", params.Messages[1])
+	}
+	if image := user.Content.OfArrayOfContentParts[1].OfImageURL; image == nil || image.ImageURL.URL != "data:image/png;base64,cG5n" {
+		t.Errorf("image part = %+v, want a data URL", user.Content.OfArrayOfContentParts[1])
+	}
+	assistant := params.Messages[2].OfAssistant
+	if assistant == nil || assistant.Content.OfString.Valid() || len(assistant.ToolCalls) != 1 {
+		t.Fatalf("assistant message = %+v, want one tool call and no thought text", params.Messages[2])
+	}
+	call := assistant.ToolCalls[0].OfFunction
+	if call.ID != "call_1" || call.Function.Name != "weather" || call.Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	// The response is matched to the generated call ID by function name
+	if tool := params.Messages[3].OfTool; tool == nil || tool.ToolCallID != "call_1" || tool.Content.OfString.Value != "sunny" {
+		t.Errorf("tool message = %+v, want the unwrapped output for call_1", params.Messages[3])
+	}
+
+	if len(params.Tools) != 1 {
+		t.Fatalf("got %d tools, want 1", len(params.Tools))
+	}
+	wantParameters := openai.FunctionParameters{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+	}
+	if got := params.Tools[0].OfFunction.Function.Parameters; !reflect.DeepEqual(got, wantParameters) {
+		t.Errorf("tool parameters = %v, want %v", got, wantParameters)
+	}
+	if choice := params.ToolChoice.OfFunctionToolChoice; choice == nil || choice.Function.Name != "weather" {
+		t.Errorf("tool_choice = %+v, want the weather function", params.ToolChoice)
+	}
+}
+
+func TestGeminiRequestToOpenAIRejectsUnsupportedInlineData(t *testing.T) {
+	req := &models.GeminiGenerateRequest{
+		Model: "gemini-2.5-flash",
+		Contents: []*genai.Content{{Role: genai.RoleUser, Parts: []*genai.Part{
+			genai.NewPartFromBytes([]byte("RIFF"), "audio/wav"),
+		}}},
+	}
+	if _, err := GeminiOpenAI.RequestToOpenAI(req); err == nil {
+		t.Error("RequestToOpenAI() error = nil, want inline audio rejected")
+	}
+}
+
+func TestGeminiRequestFromOpenAI(t *testing.T) {
+	params := &openai.ChatCompletionNewParams{
+		Model:               "gpt-4o",
+		MaxCompletionTokens: openai.Int(128),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Be brief."),
+			openai.DeveloperMessage("Use metric units."),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart("Weather here?"),
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: "https://example.com/sky.png"}),
+			}),
+			{OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+				ToolCalls: []openai.ChatCompletionMessageToolCallUnionParam{{OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+					ID:       "call_1",
+					Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{Name: "weather", Arguments: `{"city":"Paris"}`},
+				}}},
+			}},
+			openai.ToolMessage("sunny", "call_1"),
+			openai.UserMessage("Thanks"),
+		},
+		Tools: []openai.ChatCompletionToolUnionParam{openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+			Name:       "weather",
+			Parameters: openai.FunctionParameters{"type": "object"},
+		})},
+		ToolChoice: openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("required")},
+	}
+
+	req, err := GeminiOpenAI.RequestFromOpenAI(params)
+	if err != nil {
+		t.Fatalf("RequestFromOpenAI() error = %v", err)
+	}
+
+	config := req.GenerationConfig
+	if config.MaxOutputTokens != 128 {
+		t.Errorf("max_output_tokens = %d, want 128", config.MaxOutputTokens)
+	}
+	if got := geminiText(config.SystemInstruction); got != "Be brief.\n\nUse metric units." {
+		t.Errorf("system instruction = %q, want the system and developer messages joined", got)
+	}
+	// The function response and the following user text are merged into one user turn
+	if len(req.Contents) != 3 {
+		t.Fatalf("got %d contents, want user, model and user", len(req.Contents))
+	}
+	if file := req.Contents[0].Parts[1].FileData; file == nil || file.FileURI != "https://example.com/sky.png" || file.MIMEType != "image/png" {
+		t.Errorf("image part = %+v, want file data with its MIME type guessed", req.Contents[0].Parts[1])
+	}
+	if call := req.Contents[1].Parts[0].FunctionCall; call == nil || call.Name != "weather" || call.Args["city"] != "Paris" {
+		t.Errorf("function call = %+v", req.Contents[1].Parts[0])
+	}
+	last := req.Contents[2]
+	if last.Role != genai.RoleUser || len(last.Parts) != 2 {
+		t.Fatalf("last content = %+v, want the function response and text", last)
+	}
+	response := last.Parts[0].FunctionResponse
+	if response == nil || response.ID != "call_1" || response.Name != "weather" || response.Response["output"] != "sunny" {
+		t.Errorf("function response = %+v, want the named weather response", last.Parts[0])
+	}
+
+	if len(config.Tools) != 1 || config.Tools[0].FunctionDeclarations[0].Name != "weather" {
+		t.Errorf("tools = %+v", config.Tools)
+	}
+	if calling := config.ToolConfig.FunctionCallingConfig; calling.Mode != genai.FunctionCallingConfigModeAny {
+		t.Errorf("function calling mode = %q, want ANY", calling.Mode)
+	}
+}
+
+func TestGeminiResponseToOpenAI(t *testing.T) {
+	tests := []struct {
+		name          string
+		resp          genai.GenerateContentResponse
+		wantFinish    string
+		wantContent   string
+		wantToolCalls int
+	}{
+		{
+			name: "text",
+			resp: genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+				Content:      genai.NewContentFromText("Hello", genai.RoleModel),
+				FinishReason: genai.FinishReasonStop,
+			}}},
+			wantFinish:  finishReasonStop,
+			wantContent: "Hello",
+		},
+		{
+			name: "function call finishes with STOP",
+			resp: genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+				Content:      &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{genai.NewPartFromFunctionCall("weather", map[string]any{"city": "Paris"})}},
+				FinishReason: genai.FinishReasonStop,
+			}}},
+			wantFinish:    finishReasonToolCalls,
+			wantToolCalls: 1,
+		},
+		{
+			name:       "blocked prompt",
+			resp:       genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonSafety}},
+			wantFinish: finishReasonContentFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completion, err := GeminiOpenAI.ResponseToOpenAI(&tt.resp)
+			if err != nil {
+				t.Fatalf("ResponseToOpenAI() error = %v", err)
+			}
+			choice := completion.Choices[0]
+			if choice.FinishReason != tt.wantFinish || choice.Message.Content != tt.wantContent || len(choice.Message.ToolCalls) != tt.wantToolCalls {
+				t.Errorf("choice = %+v, want finish %q, content %q and %d tool calls",
+					choice, tt.wantFinish, tt.wantContent, tt.wantToolCalls)
+			}
+		})
+	}
+}
+
+func TestGeminiUsageRoundTrip(t *testing.T) {
+	metadata := &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:        100,
+		CandidatesTokenCount:    20,
+		ThoughtsTokenCount:      30,
+		CachedContentTokenCount: 40,
+		TotalTokenCount:         150,
+	}
+
+	usage := usageFromGemini(metadata)
+	want := openai.CompletionUsage{
+		PromptTokens:            100,
+		CompletionTokens:        50,
+		TotalTokens:             150,
+		PromptTokensDetails:     openai.CompletionUsagePromptTokensDetails{CachedTokens: 40},
+		CompletionTokensDetails: openai.CompletionUsageCompletionTokensDetails{ReasoningTokens: 30},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("usageFromGemini() = %+v, want %+v", usage, want)
+	}
+	if got := usageToGemini(usage); !reflect.DeepEqual(got, metadata) {
+		t.Errorf("usageToGemini() = %+v, want %+v", got, metadata)
+	}
+}
+
+func TestGeminiStreamToOpenAI(t *testing.T) {
+	responses := []*genai.GenerateContentResponse{
+		{ResponseID: "resp_1", ModelVersion: "gemini-2.5-flash", Candidates: []*genai.Candidate{{Content: genai.NewContentFromText("Hi", genai.RoleModel)}}},
+		{Candidates: []*genai.Candidate{{Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+			genai.NewPartFromFunctionCall("weather", map[string]any{"city": "Paris"}),
+		}}}}},
+		{
+			Candidates: []*genai.Candidate{{Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+				genai.NewPartFromFunctionCall("weather", map[string]any{"city": "Oslo"}),
+			}}, FinishReason: genai.FinishReasonStop}},
+			UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5},
+		},
+	}
+	seq := func(yield func(*genai.GenerateContentResponse, error) bool) {
+		for _, resp := range responses {
+			if !yield(resp, nil) {
+				return
+			}
+		}
+	}
+
+	chunks := collect[openai.ChatCompletionChunk](GeminiOpenAI.StreamToOpenAI(seq))
+	if len(chunks) != 5 {
+		t.Fatalf("got %d chunks, want role, text, two tool calls and finish", len(chunks))
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" || chunks[1].Choices[0].Delta.Content != "Hi" {
+		t.Errorf("opening chunks = %+v, %+v", chunks[0].Choices[0].Delta, chunks[1].Choices[0].Delta)
+	}
+	// Tool calls are numbered across chunks
+	for i, want := range []struct {
+		index int64
+		id    string
+	}{{0, "call_1"}, {1, "call_2"}} {
+		call := chunks[2+i].Choices[0].Delta.ToolCalls[0]
+		if call.Index != want.index || call.ID != want.id {
+			t.Errorf("tool call %d = %+v, want index %d and ID %s", i, call, want.index, want.id)
+		}
+	}
+	finish := chunks[4]
+	if finish.ID != "resp_1" || finish.Choices[0].FinishReason != finishReasonToolCalls {
+		t.Errorf("finish chunk = %+v, want a tool_calls finish for resp_1", finish)
+	}
+	if finish.Usage.PromptTokens != 10 || finish.Usage.CompletionTokens != 5 {
+		t.Errorf("finish usage = %+v, want 10 prompt and 5 completion tokens", finish.Usage)
+	}
+}
+
+func TestGeminiStreamToOpenAIFinishesUnfinishedStream(t *testing.T) {
+	seq := func(yield func(*genai.GenerateContentResponse, error) bool) {
+		yield(&genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: genai.NewContentFromText("Hi", genai.RoleModel)}}}, nil)
+	}
+
+	chunks := collect[openai.ChatCompletionChunk](GeminiOpenAI.StreamToOpenAI(seq))
+	if len(chunks) != 3 || chunks[2].Choices[0].FinishReason != finishReasonStop {
+		t.Errorf("chunks = %+v, want role, text and a stop finish", chunks)
+	}
+}
+
+func TestGeminiStreamFromOpenAI(t *testing.T) {
+	chunk := func(delta openai.ChatCompletionChunkChoiceDelta, finishReason string) openai.ChatCompletionChunk {
+		return openAIChunk("chatcmpl-1", "gpt-4o", 0, delta, finishReason)
+	}
+	toolCall := func(id, name, arguments string) openai.ChatCompletionChunkChoiceDelta {
+		return openai.ChatCompletionChunkChoiceDelta{ToolCalls: []openai.ChatCompletionChunkChoiceDeltaToolCall{{
+			ID:       id,
+			Function: openai.ChatCompletionChunkChoiceDeltaToolCallFunction{Name: name, Arguments: arguments},
+		}}}
+	}
+	errBroken := errors.New("connection reset")
+
+	t.Run("text streams and tool calls arrive whole", func(t *testing.T) {
+		source := &sliceStream[openai.ChatCompletionChunk]{events: []openai.ChatCompletionChunk{
+			chunk(openai.ChatCompletionChunkChoiceDelta{Role: "assistant"}, ""),
+			chunk(openai.ChatCompletionChunkChoiceDelta{Content: "Checking"}, ""),
+			chunk(toolCall("call_1", "weather", `{"city":`), ""),
+			chunk(toolCall("", "", `"Paris"}`), ""),
+			chunk(openai.ChatCompletionChunkChoiceDelta{}, finishReasonToolCalls),
+			{ID: "chatcmpl-1", Usage: openai.CompletionUsage{PromptTokens: 9, CompletionTokens: 4, TotalTokens: 13}},
+		}}
+
+		var responses []*genai.GenerateContentResponse
+		for resp, err := range GeminiOpenAI.StreamFromOpenAI(source) {
+			if err != nil {
+				t.Fatalf("stream error = %v", err)
+			}
+			responses = append(responses, resp)
+		}
+
+		if len(responses) != 2 {
+			t.Fatalf("got %d responses, want the text and the final tool call", len(responses))
+		}
+		if text := responses[0].Text(); text != "Checking" {
+			t.Errorf("first response text = %q, want Checking", text)
+		}
+		final := responses[1]
+		calls := final.FunctionCalls()
+		if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Name != "weather" || calls[0].Args["city"] != "Paris" {
+			t.Errorf("function calls = %+v, want the assembled weather call", calls)
+		}
+		if final.Candidates[0].FinishReason != genai.FinishReasonStop {
+			t.Errorf("finish reason = %q, want STOP", final.Candidates[0].FinishReason)
+		}
+		if final.UsageMetadata.PromptTokenCount != 9 || final.UsageMetadata.CandidatesTokenCount != 4 {
+			t.Errorf("usage = %+v, want 9 prompt and 4 candidate tokens", final.UsageMetadata)
+		}
+		if !source.closed {
+			t.Error("source not closed")
+		}
+	})
+
+	t.Run("failed stream yields its error without finishing", func(t *testing.T) {
+		source := &sliceStream[openai.ChatCompletionChunk]{
+			events: []openai.ChatCompletionChunk{chunk(openai.ChatCompletionChunkChoiceDelta{Content: "Par"}, "")},
+			err:    errBroken,
+		}
+
+		var texts []string
+		var streamErr error
+		for resp, err := range GeminiOpenAI.StreamFromOpenAI(source) {
+			if err != nil {
+				streamErr = err
+				continue
+			}
+			texts = append(texts, resp.Text())
+		}
+		if !reflect.DeepEqual(texts, []string{"Par"}) || !errors.Is(streamErr, errBroken) {
+			t.Errorf("texts = %q, error = %v; want [Par] and %v", texts, streamErr, errBroken)
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils/clientcache"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,6 +29,36 @@ func NewGenerateService() *GenerateService {
 	}
 }
 
+// Client sends GenerateContent requests to a provider. Providers speaking the Gemini API are
+// called natively; others are served through format conversion.
+type Client interface {
+	SendRequest(ctx context.Context, req *models.GeminiGenerateRequest, requestID string) (*genai.GenerateContentResponse, error)
+	SendStreamingRequest(ctx context.Context, req *models.GeminiGenerateRequest, requestID string) (iter.Seq2[*genai.GenerateContentResponse, error], error)
+}
+
+// nativeClient sends requests with the Gemini SDK
+type nativeClient struct {
+	gs     *GenerateService
+	client *genai.Client
+}
+
+// Client returns a client calling the provider natively with the Gemini SDK
+func (gs *GenerateService) Client(ctx context.Context, providerConfig models.ProviderConfig) (Client, error) {
+	client, err := gs.CreateClient(ctx, providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &nativeClient{gs: gs, client: client}, nil
+}
+
+func (n *nativeClient) SendRequest(ctx context.Context, req *models.GeminiGenerateRequest, requestID string) (*genai.GenerateContentResponse, error) {
+	return n.gs.SendRequest(ctx, n.client, req, requestID)
+}
+
+func (n *nativeClient) SendStreamingRequest(ctx context.Context, req *models.GeminiGenerateRequest, requestID string) (iter.Seq2[*genai.GenerateContentResponse, error], error) {
+	return n.gs.SendStreamingRequest(ctx, n.client, req, requestID)
+}
+
 // generateConfigHash creates a hash of the provider config to detect changes
 func (gs *GenerateService) generateConfigHash(providerConfig models.ProviderConfig) (string, error) {
 	// Hash the entire provider config for consistent cache key generation
@@ -112,47 +143,63 @@ func (gs *GenerateService) SendStreamingRequest(
 	return streamIter, nil
 }
 
-// handleGeminiNonStreamingProvider handles non-streaming requests using native Gemini client
-func (gs *GenerateService) HandleGeminiNonStreamingProvider(
+// HandleNonStreamingProvider handles non-streaming requests using the provider's client
+func (gs *GenerateService) HandleNonStreamingProvider(
 	c *fiber.Ctx,
 	req *models.GeminiGenerateRequest,
-	providerConfig models.ProviderConfig,
+	client Client,
 	requestID string,
 ) (*genai.GenerateContentResponse, error) {
-	fiberlog.Debugf("[%s] Using native Gemini provider for non-streaming request", requestID)
+	fiberlog.Debugf("[%s] Sending non-streaming generate request", requestID)
 
-	client, err := gs.CreateClient(c.Context(), providerConfig)
-	if err != nil {
-		return nil, err
-	}
-
-	response, err := gs.SendRequest(c.Context(), client, req, requestID)
+	response, err := client.SendRequest(c.UserContext(), req, requestID)
 	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
-// handleGeminiStreamingProvider handles streaming requests using native Gemini client
-func (gs *GenerateService) HandleGeminiStreamingProvider(
+// HandleStreamingProvider handles streaming requests using the provider's client
+func (gs *GenerateService) HandleStreamingProvider(
 	c *fiber.Ctx,
 	req *models.GeminiGenerateRequest,
-	providerConfig models.ProviderConfig,
+	client Client,
 	requestID string,
 ) (iter.Seq2[*genai.GenerateContentResponse, error], error) {
-	fiberlog.Debugf("[%s] Using native Gemini provider for streaming request", requestID)
+	fiberlog.Debugf("[%s] Sending streaming generate request", requestID)
 
-	// Use context.Background() for client creation and streaming
-	// c.Context() gets canceled too early when headers are sent
-	client, err := gs.CreateClient(context.Background(), providerConfig)
+	// Use context.Background() for streaming - c.Context() gets canceled too early when headers
+	// are sent, and the stream handler monitors fasthttpCtx for client disconnects
+	streamIter, err := client.SendStreamingRequest(context.Background(), req, requestID)
 	if err != nil {
 		return nil, err
 	}
+	return streamIter, nil
+}
 
-	// Use context.Background() for streaming - the stream handler monitors fasthttpCtx for client disconnects
-	streamIter, err := gs.SendStreamingRequest(context.Background(), client, req, requestID)
+// SendShadowRequest replays a request against a shadow provider/model without streaming.
+// No usage is recorded: shadow calls are never billed.
+func (gs *GenerateService) SendShadowRequest(
+	ctx context.Context,
+	req models.GeminiGenerateRequest,
+	client Client,
+	provider, model string,
+	requestID string,
+) models.ShadowResult {
+	result := models.ShadowResult{Provider: provider, Model: model}
+	req.Model = model
+
+	resp, err := client.SendRequest(ctx, &req, requestID)
 	if err != nil {
-		return nil, err
+		result.Error = err.Error()
+		return result
 	}
-	return streamIter, nil
+
+	if resp.UsageMetadata != nil {
+		result.TokensInput = int(resp.UsageMetadata.PromptTokenCount)
+		result.TokensOutput = int(resp.UsageMetadata.CandidatesTokenCount)
+		result.Cost = usage.CalculateCost(provider, model, result.TokensInput, result.TokensOutput)
+	}
+	result.Output = resp.Text()
+	return result
 }
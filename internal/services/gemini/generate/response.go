@@ -8,6 +8,10 @@ import (
 	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
 	"github.com/Egham-7/adaptive-proxy/internal/services/format_adapter"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
@@ -75,15 +79,22 @@ func (rs *ResponseService) HandleNonStreamingResponse(
 				Cost:           usage.CalculateCost(provider, model, inputTokens, outputTokens),
 				StatusCode:     200,
 				RequestID:      requestID,
+				Metadata:       usage.UsageMetadata(c),
 			}
 
-			_, err := rs.usageService.RecordUsage(c.UserContext(), usageParams)
-			if err != nil {
-				fiberlog.Errorf("[%s] Failed to record usage: %v", requestID, err)
-			}
+			rs.usageService.Record(c, usageParams)
 		}
 	}
 
+	primary := models.ShadowResult{Provider: provider, Model: model, Output: response.Text()}
+	if response.UsageMetadata != nil {
+		primary.TokensInput = int(response.UsageMetadata.PromptTokenCount)
+		primary.TokensOutput = int(response.UsageMetadata.CandidatesTokenCount)
+		primary.Cost = usage.CalculateCost(provider, model, primary.TokensInput, primary.TokensOutput)
+	}
+	routing_trace.From(c).Select(provider, model)
+	shadow.SetPrimary(c, primary)
+
 	fiberlog.Infof("[%s] Non-streaming response processed successfully", requestID)
 	return c.JSON(adaptiveResp)
 }
@@ -97,6 +108,7 @@ func (rs *ResponseService) HandleStreamingResponse(
 	cacheSource string,
 	model string,
 	endpoint string,
+	observation *provider_stats.Observation,
 ) error {
 	fiberlog.Infof("[%s] Starting streaming response processing", requestID)
 
@@ -104,21 +116,48 @@ func (rs *ResponseService) HandleStreamingResponse(
 	apiKey, _ := auth.GetAPIKey(c)
 
 	// Use the proper Gemini streaming handler from the stream package
-	return handlers.HandleGemini(c, streamIter, requestID, provider, cacheSource, model, endpoint, rs.usageService, apiKey, rs.usageWorker)
+	if err := handlers.HandleGemini(c, streamIter, requestID, provider, cacheSource, model, endpoint, rs.usageService, apiKey, rs.usageWorker, observation); err != nil {
+		return err
+	}
+	routing_trace.From(c).Select(provider, model)
+	shadow.SetPrimary(c, models.ShadowResult{Provider: provider, Model: model})
+	return nil
 }
 
-// HandleError processes and returns error responses
+// PrepareStreamingResponse validates a Gemini stream without touching the response, for
+// streaming races; see handlers.PrepareGemini
+func (rs *ResponseService) PrepareStreamingResponse(
+	streamIter iter.Seq2[*genai.GenerateContentResponse, error],
+	requestID string,
+	provider string,
+	cacheSource string,
+	model string,
+	endpoint string,
+	apiKey *models.APIKey,
+	attempt usage.Attempt,
+	observation *provider_stats.Observation,
+) (*handlers.PendingStream, error) {
+	return handlers.PrepareGemini(streamIter, requestID, provider, cacheSource, model, endpoint, rs.usageService, apiKey, attempt, rs.usageWorker, observation)
+}
+
+// HandleError sends the failure as a Gemini error with the upstream status, see
+// response.ClassifyError
 func (rs *ResponseService) HandleError(c *fiber.Ctx, err error, requestID string) error {
-	fiberlog.Errorf("[%s] Handling error: %v", requestID, err)
+	apiErr := response.ClassifyError(err)
+	fiberlog.Errorf("[%s] Handling error %d: %v", requestID, apiErr.Status, err)
+	return response.SendError(c, response.FormatGemini, apiErr, requestID)
+}
 
-	errorResponse := fiber.Map{
-		"error": fiber.Map{
-			"message":    err.Error(),
-			"request_id": requestID,
-		},
-	}
+// HandleBadRequest returns a Gemini-style INVALID_ARGUMENT error
+func (rs *ResponseService) HandleBadRequest(c *fiber.Ctx, message, requestID string) error {
+	fiberlog.Warnf("[%s] Bad request: %s", requestID, message)
+	return response.SendError(c, response.FormatGemini, response.NewAPIError(fiber.StatusBadRequest, message), requestID)
+}
 
-	return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+// HandleForbidden returns a Gemini-style PERMISSION_DENIED error
+func (rs *ResponseService) HandleForbidden(c *fiber.Ctx, message, requestID string) error {
+	fiberlog.Warnf("[%s] Forbidden: %s", requestID, message)
+	return response.SendError(c, response.FormatGemini, response.NewAPIError(fiber.StatusForbidden, message), requestID)
 }
 
 // StoreSuccessfulSemanticCache stores the model response in semantic cache after successful completion
@@ -134,7 +173,7 @@ func (rs *ResponseService) StoreSuccessfulSemanticCache(
 	}
 
 	// Extract prompt for cache storage from Gemini contents
-	prompt, err := utils.ExtractPromptFromGeminiContents(req.Contents)
+	prompt, err := utils.ExtractGeminiPrompt(req.SystemInstruction, req.Contents, rs.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Errorf("[%s] Failed to extract prompt for semantic cache: %v", requestID, err)
 		return
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"slices"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminTokenHeader carries the static admin token as an alternative to an Authorization bearer
+const AdminTokenHeader = "X-Admin-Token"
+
+// RequireAdmin allows requests presenting adminToken as a bearer token or in the X-Admin-Token
+// header, or authenticated with an API key holding the "admin" (or "*") scope. identify, when
+// set, authenticates requests without the admin token (see AuthMiddleware.Identify); routes
+// behind it must be skipped by RequireAuth, which would reject the admin token. With no admin
+// token configured only admin-scoped API keys are accepted.
+func RequireAdmin(adminToken string, identify func(*fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get(AdminTokenHeader)
+		if token == "" {
+			token, _ = strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		}
+		if adminToken != "" && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			return c.Next()
+		}
+
+		if auth.GetAuthContext(c) == nil && identify != nil {
+			identify(c)
+		}
+		if authCtx := auth.GetAuthContext(c); authCtx != nil && authCtx.IsAPIKey() && authCtx.APIKey != nil {
+			if slices.Contains(authCtx.APIKey.Scopes, "admin") || slices.Contains(authCtx.APIKey.Scopes, "*") {
+				return c.Next()
+			}
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Insufficient permissions",
+			})
+		}
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Admin authentication required",
+		})
+	}
+}
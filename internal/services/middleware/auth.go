@@ -63,6 +63,23 @@ func (m *AuthMiddleware) RequireAuth() fiber.Handler {
 	return m.authenticate(true)
 }
 
+// Identify authenticates the request's token like RequireAuth, for handlers that accept other
+// credentials too. It stores the auth context and reports whether the token was valid, but
+// neither rejects the request nor passes it on.
+func (m *AuthMiddleware) Identify(c *fiber.Ctx) bool {
+	token := m.extractToken(c)
+	if token == "" {
+		return false
+	}
+	authenticated, authType, err := m.validateToken(c, token)
+	if err != nil || !authenticated {
+		return false
+	}
+	c.Locals("auth_type", authType)
+	c.Locals("auth_token", token)
+	return true
+}
+
 func (m *AuthMiddleware) RequireClerkAuth() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authCtx := auth.GetAuthContext(c)
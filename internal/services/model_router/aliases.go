@@ -0,0 +1,153 @@
+package model_router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// validateAliases checks that every alias has at least one "provider:model" target and a known fallback mode
+func validateAliases(aliases map[string]models.ModelAlias) error {
+	for name, alias := range aliases {
+		if name == "" {
+			return fmt.Errorf("alias name cannot be empty")
+		}
+		if len(alias.Models) == 0 {
+			return fmt.Errorf("alias %s: at least one model is required", name)
+		}
+		for _, target := range alias.Models {
+			if _, _, err := utils.ParseProviderModel(target); err != nil {
+				return fmt.Errorf("alias %s: model must be in provider:model format: %w", name, err)
+			}
+		}
+		switch alias.FallbackMode {
+		case "", models.FallbackModeSequential, models.FallbackModeRace, models.FallbackModeHedge:
+		default:
+			return fmt.Errorf("alias %s: unknown fallback_mode %q", name, alias.FallbackMode)
+		}
+	}
+	if len(aliases) > 0 {
+		fiberlog.Infof("ModelRouter: Loaded %d model aliases", len(aliases))
+	}
+	return nil
+}
+
+// ErrInvalidModelList is returned when an explicit model list has an entry that is not in
+// provider:model format
+var ErrInvalidModelList = errors.New("invalid model list")
+
+// ResolveAlias resolves a virtual model name into its ordered provider chain. It returns a nil
+// response when name is not an alias. Targets outside a routing rule's restricted candidate set
+// are dropped; ErrModelNotAllowed is returned when none remain.
+func (pm *ModelRouter) ResolveAlias(
+	name string,
+	routerConfig *models.ModelRouterConfig,
+	requestID string,
+) (*models.ModelSelectionResponse, *models.ModelAlias, error) {
+	alias, ok := pm.cfg.ModelAliases[name]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	chain, err := permittedChain(alias.Models, routerConfig, "alias "+name, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fiberlog.Infof("[%s] 🏷️  Model alias %s resolved to %s/%s (with %d alternatives)",
+		requestID, name, chain[0].Provider, chain[0].Model, len(chain)-1)
+
+	return &models.ModelSelectionResponse{
+		Provider:     chain[0].Provider,
+		Model:        chain[0].Model,
+		Alternatives: chain[1:],
+	}, &alias, nil
+}
+
+// ResolveModelList resolves a request's explicit fallback list of "provider:model" entries into
+// a chain tried in the listed order. Entries outside a routing rule's restricted candidate set
+// are dropped, and so are providers whose circuit breaker is open; ErrModelNotAllowed or
+// circuitbreaker.ErrOpen is returned when none remain.
+func (pm *ModelRouter) ResolveModelList(
+	ctx context.Context,
+	entries []string,
+	routerConfig *models.ModelRouterConfig,
+	cbs map[string]*circuitbreaker.CircuitBreaker,
+	requestID string,
+) (*models.ModelSelectionResponse, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: no models listed", ErrInvalidModelList)
+	}
+	chain, err := permittedChain(entries, routerConfig, "model list", requestID)
+	if err != nil {
+		if errors.Is(err, ErrModelNotAllowed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModelList, err)
+	}
+
+	trace := routing_trace.FromContext(ctx)
+	candidates := make([]models.ModelCapability, len(chain))
+	for i, candidate := range chain {
+		candidates[i] = models.ModelCapability{Provider: candidate.Provider, ModelName: candidate.Model}
+	}
+	trace.Consider(candidates)
+
+	available := make([]models.Alternative, 0, len(chain))
+	for _, candidate := range chain {
+		if !pm.isModelAvailable(candidate.Provider, cbs) {
+			fiberlog.Warnf("[%s] 🚫 Dropping %s/%s from model list (circuit breaker open)",
+				requestID, candidate.Provider, candidate.Model)
+			trace.Filter(candidate.Provider, candidate.Model, models.FilterReasonCircuitBreaker)
+			continue
+		}
+		available = append(available, candidate)
+	}
+	if len(available) == 0 {
+		return nil, fmt.Errorf("%w for every model in the list", circuitbreaker.ErrOpen)
+	}
+
+	fiberlog.Infof("[%s] 📋 Model list resolved to %s/%s (with %d alternatives)",
+		requestID, available[0].Provider, available[0].Model, len(available)-1)
+
+	return &models.ModelSelectionResponse{
+		Provider:     available[0].Provider,
+		Model:        available[0].Model,
+		Alternatives: available[1:],
+	}, nil
+}
+
+// permittedChain parses "provider:model" targets, dropping those outside a routing rule's
+// restricted candidate set. source names the targets' origin in errors and logs.
+func permittedChain(
+	targets []string,
+	routerConfig *models.ModelRouterConfig,
+	source string,
+	requestID string,
+) ([]models.Alternative, error) {
+	chain := make([]models.Alternative, 0, len(targets))
+	for _, target := range targets {
+		provider, model, err := utils.ParseProviderModel(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model %s in %s: %w", target, source, err)
+		}
+		candidate := models.Alternative{Provider: provider, Model: model}
+		if routerConfig != nil && routerConfig.RestrictedBy != "" && !isConfigured(routerConfig.Models, candidate) {
+			fiberlog.Warnf("[%s] 🚫 Dropping %s/%s from %s: not permitted by routing rule %q",
+				requestID, provider, model, source, routerConfig.RestrictedBy)
+			continue
+		}
+		chain = append(chain, candidate)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w %q: no model of %s is permitted", ErrModelNotAllowed, routerConfig.RestrictedBy, source)
+	}
+	return chain, nil
+}
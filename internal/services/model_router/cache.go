@@ -3,17 +3,21 @@ package model_router
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/semantic_cache"
 
-	"github.com/botirk38/semanticcache"
-	"github.com/botirk38/semanticcache/options"
 	fiberlog "github.com/gofiber/fiber/v2/log"
 )
 
 const (
 	defaultSemanticThreshold = 0.9
+	// redisKeyPrefix is the semanticcache library default
+	redisKeyPrefix = "semanticcache:"
+	// semanticCandidates is how many of the most similar entries are checked for a matching namespace
+	semanticCandidates = 10
 )
 
 // DefaultCacheConfig returns default cache configuration
@@ -26,8 +30,9 @@ func DefaultCacheConfig() models.CacheConfig {
 
 // ModelRouterCache wraps the semanticcache library for protocol manager specific operations
 type ModelRouterCache struct {
-	cache             *semanticcache.SemanticCache[string, models.ModelSelectionResponse]
+	cache             *semantic_cache.Cache[models.ModelSelectionResponse]
 	semanticThreshold float32
+	policy            *cachePolicy
 }
 
 // NewModelRouterCache creates a new protocol manager cache instance
@@ -51,156 +56,116 @@ func NewModelRouterCache(cfg *config.Config) (*ModelRouterCache, error) {
 	fiberlog.Debugf("ModelRouterCache: Configuration - enabled=%t, backend=%s, threshold=%.2f",
 		cacheConfig.Enabled, cacheConfig.Backend, threshold)
 
-	apiKey := cacheConfig.OpenAIAPIKey
-	if apiKey == "" {
-		fiberlog.Error("ModelRouterCache: OpenAI API key not set in cache configuration")
-		return nil, fmt.Errorf("OpenAI API key not set in cache configuration")
-	}
-	fiberlog.Debug("ModelRouterCache: OpenAI API key found")
-
-	// Determine embedding model
-	embedModel := cacheConfig.EmbeddingModel
-	if embedModel == "" {
-		embedModel = "text-embedding-3-large"
-	}
-
 	// Create semantic cache with backend based on configuration
 	fiberlog.Debug("ModelRouterCache: Creating semantic cache")
-	var cache *semanticcache.SemanticCache[string, models.ModelSelectionResponse]
-	var err error
-
-	backend := cacheConfig.Backend
-	if backend == "" {
-		backend = models.CacheBackendRedis // Default to Redis for backward compatibility
-		fiberlog.Warn("ModelRouterCache: Backend not specified, defaulting to redis")
-	}
-
-	switch backend {
-	case models.CacheBackendMemory:
-		capacity := cacheConfig.Capacity
-		if capacity <= 0 {
-			capacity = 1000 // Default capacity
-			fiberlog.Warnf("ModelRouterCache: Invalid or missing capacity, using default %d", capacity)
-		}
-		fiberlog.Debugf("ModelRouterCache: Using in-memory LRU backend with capacity=%d", capacity)
-		cache, err = semanticcache.New(
-			options.WithOpenAIProvider[string, models.ModelSelectionResponse](apiKey, embedModel),
-			options.WithLRUBackend[string, models.ModelSelectionResponse](capacity),
-		)
-
-	case models.CacheBackendRedis:
-		// Get Redis URL from cache config
-		redisURL := cacheConfig.RedisURL
-		if redisURL == "" {
-			fiberlog.Error("ModelRouterCache: redis URL not set - please configure redis_url in cache config")
-			return nil, fmt.Errorf("redis URL not set - please configure redis_url in cache config")
-		}
-		fiberlog.Debugf("ModelRouterCache: Using Redis backend with URL=%s", redisURL)
-		cache, err = semanticcache.New(
-			options.WithOpenAIProvider[string, models.ModelSelectionResponse](apiKey, embedModel),
-			options.WithRedisBackend[string, models.ModelSelectionResponse](redisURL, 0),
-		)
-
-	default:
-		return nil, fmt.Errorf("unsupported cache backend: %s (supported: redis, memory)", backend)
+	embedding, err := cfg.ResolveEmbedding(cacheConfig)
+	if err != nil {
+		fiberlog.Errorf("ModelRouterCache: Invalid embedding configuration: %v", err)
+		return nil, err
 	}
-
+	cache, err := semantic_cache.New[models.ModelSelectionResponse](cacheConfig, embedding, redisKeyPrefix)
 	if err != nil {
 		fiberlog.Errorf("ModelRouterCache: Failed to create semantic cache: %v", err)
-		return nil, fmt.Errorf("failed to create semantic cache: %w", err)
+		return nil, err
 	}
 	fiberlog.Info("ModelRouterCache: Semantic cache created successfully")
 
-	return &ModelRouterCache{
+	pmc := &ModelRouterCache{
 		cache:             cache,
 		semanticThreshold: float32(threshold),
-	}, nil
+		policy:            newCachePolicy(cfg),
+	}
+	fiberlog.Infof("ModelRouterCache: Cache generation %s", pmc.policy.generation)
+
+	// Entries persisted by Redis may predate a config change; remove them in the background
+	go pmc.sweep(context.Background())
+
+	return pmc, nil
 }
 
-// Lookup searches for a cached protocol response using exact match first, then semantic similarity with custom threshold
-func (pmc *ModelRouterCache) Lookup(ctx context.Context, prompt, requestID string, threshold float32) (*models.ModelSelectionResponse, string, bool) {
-	fiberlog.Debugf("[%s] ModelRouterCache: Starting cache lookup", requestID)
+// Lookup searches the namespace for a cached protocol response using exact match first, then semantic similarity with custom threshold.
+// It returns the cached response, the cache tier and the similarity score of the match (1.0 for exact matches).
+func (pmc *ModelRouterCache) Lookup(ctx context.Context, namespace, prompt, requestID string, threshold float32) (*models.ModelSelectionResponse, string, float32, bool) {
+	fiberlog.Debugf("[%s] ModelRouterCache: Starting cache lookup (namespace: %s)", requestID, namespace)
+	start := time.Now()
 
 	// 1) First try exact key matching
 	fiberlog.Debugf("[%s] ModelRouterCache: Trying exact key match", requestID)
-	if hit, found, err := pmc.cache.Get(ctx, prompt); found && err == nil {
-		fiberlog.Infof("[%s] ModelRouterCache: Exact cache hit", requestID)
-		return &hit, models.CacheTierSemanticExact, true
+	key := entryKey(namespace, prompt)
+	evicted := ""
+	if hit, found, err := pmc.cache.Get(ctx, key); found && err == nil {
+		if reason := pmc.policy.staleReason(hit, models.CacheTierSemanticExact, time.Now()); reason != "" {
+			pmc.evict(ctx, key, reason, requestID)
+			evicted = key
+		} else {
+			fiberlog.Infof("[%s] ModelRouterCache: Exact cache hit", requestID)
+			pmc.cache.RecordHit(false, 1.0, time.Since(start))
+			return &hit, models.CacheTierSemanticExact, 1.0, true
+		}
 	} else if err != nil {
 		fiberlog.Errorf("[%s] ModelRouterCache: Error during exact lookup: %v", requestID, err)
 	}
 	fiberlog.Debugf("[%s] ModelRouterCache: No exact match found", requestID)
 
-	// 2) If no exact match, try semantic similarity search with provided threshold
+	// 2) If no exact match, try semantic similarity search with provided threshold, among the
+	// entries of the namespace only
 	fiberlog.Debugf("[%s] ModelRouterCache: Trying semantic similarity search (threshold: %.2f)", requestID, threshold)
-	if match, err := pmc.cache.Lookup(ctx, prompt, threshold); err == nil && match != nil {
-		fiberlog.Infof("[%s] ModelRouterCache: Semantic cache hit", requestID)
-		return &match.Value, models.CacheTierSemanticSimilar, true
-	} else if err != nil {
+	matches, err := pmc.cache.TopMatchesWithPrefix(ctx, prompt, namespaceKeyPrefix(namespace), semanticCandidates)
+	if err != nil {
 		fiberlog.Errorf("[%s] ModelRouterCache: Error during semantic lookup: %v", requestID, err)
-	} else {
-		fiberlog.Debugf("[%s] ModelRouterCache: No semantic match found", requestID)
+		pmc.cache.RecordMiss(time.Since(start))
+		return nil, "", 0, false
+	}
+	for _, match := range matches {
+		if match.Score < threshold {
+			break
+		}
+		if match.Value.CacheKey == evicted {
+			continue
+		}
+		if reason := pmc.policy.staleReason(match.Value, models.CacheTierSemanticSimilar, time.Now()); reason != "" {
+			// An entry past the semantic TTL may still serve identical prompts until the exact TTL
+			if reason != staleExpired {
+				pmc.evict(ctx, match.Value.CacheKey, reason, requestID)
+			}
+			continue
+		}
+		fiberlog.Infof("[%s] ModelRouterCache: Semantic cache hit (score: %.2f)", requestID, match.Score)
+		pmc.cache.RecordHit(true, match.Score, time.Since(start))
+		return &match.Value, models.CacheTierSemanticSimilar, match.Score, true
 	}
 
 	fiberlog.Debugf("[%s] ModelRouterCache: Cache miss", requestID)
-	return nil, "", false
+	pmc.cache.RecordMiss(time.Since(start))
+	return nil, "", 0, false
 }
 
-// LookupAsync searches for a cached protocol response using exact match first, then semantic similarity with custom threshold
-// This method coordinates Get (exact) and Lookup (semantic) operations sequentially for proper cache tier determination
-func (pmc *ModelRouterCache) LookupAsync(ctx context.Context, prompt, requestID string, threshold float32) <-chan semanticcache.LookupResult[models.ModelSelectionResponse] {
-	resultCh := make(chan semanticcache.LookupResult[models.ModelSelectionResponse], 1)
-
-	// Spawn goroutine to coordinate GetAsync -> LookupAsync sequence
-	// This is necessary because we need to try GetAsync first (fast), then LookupAsync (slow) if GetAsync misses
-	go func() {
-		defer close(resultCh)
-
-		fiberlog.Debugf("[%s] ModelRouterCache: Starting cache lookup", requestID)
-
-		// Try exact key matching first (O(1) - fast)
-		fiberlog.Debugf("[%s] ModelRouterCache: Trying exact key match", requestID)
-		getCh := pmc.cache.GetAsync(ctx, prompt)
-		getResult := <-getCh
-
-		if getResult.Found && getResult.Error == nil {
-			fiberlog.Infof("[%s] ModelRouterCache: Exact cache hit", requestID)
-			resultCh <- semanticcache.LookupResult[models.ModelSelectionResponse]{
-				Match: &semanticcache.Match[models.ModelSelectionResponse]{
-					Value: getResult.Value,
-					Score: 1.0, // Exact match score
-				},
-				Error: nil,
-			}
-			return
-		} else if getResult.Error != nil {
-			fiberlog.Errorf("[%s] ModelRouterCache: Error during exact lookup: %v", requestID, getResult.Error)
-		}
-
-		// Try semantic similarity search (O(n) - slower, requires embedding computation)
-		fiberlog.Debugf("[%s] ModelRouterCache: Trying semantic similarity search (threshold: %.2f)", requestID, threshold)
-		lookupCh := pmc.cache.LookupAsync(ctx, prompt, threshold)
-		lookupResult := <-lookupCh
-
-		if lookupResult.Match != nil && lookupResult.Error == nil {
-			fiberlog.Infof("[%s] ModelRouterCache: Semantic cache hit (score: %.2f)", requestID, lookupResult.Match.Score)
-			resultCh <- lookupResult
-			return
-		} else if lookupResult.Error != nil {
-			fiberlog.Errorf("[%s] ModelRouterCache: Error during semantic lookup: %v", requestID, lookupResult.Error)
-		}
+// StoreAsync saves a protocol response to the namespace asynchronously (fire-and-forget) and
+// returns the key it is stored under
+func (pmc *ModelRouterCache) StoreAsync(ctx context.Context, namespace, prompt string, resp models.ModelSelectionResponse, requestID string) string {
+	fiberlog.Debugf("[%s] ModelRouterCache: Storing model response (fire-and-forget, model: %s/%s)", requestID, resp.Provider, resp.Model)
+	key := entryKey(namespace, prompt)
+	resp.CacheKey = key
+	resp.CacheNamespace = namespace
+	pmc.policy.stamp(&resp)
+	pmc.cache.SetAsync(ctx, key, prompt, resp)
+	return key
+}
 
-		fiberlog.Debugf("[%s] ModelRouterCache: Cache miss", requestID)
-		resultCh <- semanticcache.LookupResult[models.ModelSelectionResponse]{Match: nil, Error: nil}
-	}()
+// evict removes an entry that can no longer be served (fire-and-forget)
+func (pmc *ModelRouterCache) evict(ctx context.Context, key, reason, requestID string) {
+	fiberlog.Infof("[%s] ModelRouterCache: Skipping stale cache entry (%s)", requestID, reason)
+	pmc.cache.DeleteAsync(ctx, key)
+}
 
-	return resultCh
+// entryKey scopes the prompt to the namespace for exact lookups
+func entryKey(namespace, prompt string) string {
+	return namespaceKeyPrefix(namespace) + prompt
 }
 
-// StoreAsync saves a protocol response to the cache asynchronously (fire-and-forget)
-func (pmc *ModelRouterCache) StoreAsync(ctx context.Context, prompt string, resp models.ModelSelectionResponse, requestID string) {
-	fiberlog.Debugf("[%s] ModelRouterCache: Storing model response (fire-and-forget, model: %s/%s)", requestID, resp.Provider, resp.Model)
-	pmc.cache.SetAsync(ctx, prompt, prompt, resp)
+// namespaceKeyPrefix is the prefix of the keys of all entries in the namespace
+func namespaceKeyPrefix(namespace string) string {
+	return namespace + "|"
 }
 
 // DeleteAsync removes a cache entry asynchronously (fire-and-forget)
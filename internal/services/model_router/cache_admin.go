@@ -0,0 +1,237 @@
+package model_router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 100
+	// flushBatchSize bounds how many entries are loaded at once while flushing
+	flushBatchSize = 500
+)
+
+var (
+	// ErrCacheDisabled is returned by cache administration when the router cache is not enabled
+	ErrCacheDisabled = errors.New("router cache is not enabled")
+	// ErrInvalidCacheRequest is returned when a cache administration request is malformed
+	ErrInvalidCacheRequest = errors.New("invalid cache request")
+)
+
+// Cache returns the router cache for administration, or nil when caching is disabled
+func (pm *ModelRouter) Cache() *ModelRouterCache {
+	return pm.cache
+}
+
+// Stats reports the router cache's entries and hit rates
+func (pmc *ModelRouterCache) Stats(ctx context.Context) (models.CacheStats, error) {
+	if pmc == nil {
+		return models.CacheStats{}, ErrCacheDisabled
+	}
+	entries, err := pmc.cache.Len(ctx)
+	if err != nil {
+		return models.CacheStats{}, fmt.Errorf("failed to count cache entries: %w", err)
+	}
+	return pmc.cache.Stats("model_router", entries), nil
+}
+
+// Search returns the entries most similar to a prompt, optionally within one namespace
+func (pmc *ModelRouterCache) Search(ctx context.Context, req models.CacheSearchRequest) (*models.CacheSearchResponse, error) {
+	if pmc == nil {
+		return nil, ErrCacheDisabled
+	}
+	if req.Prompt == "" {
+		return nil, fmt.Errorf("%w: prompt is required", ErrInvalidCacheRequest)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	keyPrefix := ""
+	if req.Namespace != "" {
+		keyPrefix = filterKeyPrefix(req.Namespace)
+	}
+	matches, err := pmc.cache.TopMatchesWithPrefix(ctx, req.Prompt, keyPrefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search cache: %w", err)
+	}
+
+	entries := make([]models.CacheEntry, 0, len(matches))
+	for _, match := range matches {
+		entries = append(entries, cacheEntry(match.Value.CacheKey, match.Value, match.Score))
+	}
+	return &models.CacheSearchResponse{Entries: entries}, nil
+}
+
+// DryRun reports what a lookup of the prompt would return at the given threshold,
+// without checking circuit breakers, recording statistics or serving the entry
+func (pmc *ModelRouterCache) DryRun(ctx context.Context, req models.CacheLookupRequest) (*models.CacheLookupResponse, error) {
+	if pmc == nil {
+		return nil, ErrCacheDisabled
+	}
+	if req.Prompt == "" {
+		return nil, fmt.Errorf("%w: prompt is required", ErrInvalidCacheRequest)
+	}
+	if req.Threshold < 0 || req.Threshold > 1 {
+		return nil, fmt.Errorf("%w: threshold must be in (0.0, 1.0]", ErrInvalidCacheRequest)
+	}
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = CacheNamespace(ctx)
+	}
+	threshold := req.Threshold
+	if threshold == 0 {
+		threshold = pmc.semanticThreshold
+	}
+
+	now := time.Now()
+	result := &models.CacheLookupResponse{Threshold: threshold}
+	key := entryKey(namespace, req.Prompt)
+	if hit, found, err := pmc.cache.Get(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to look up cache: %w", err)
+	} else if found {
+		entry := cacheEntry(key, hit, 1.0)
+		entry.Stale = pmc.policy.staleReason(hit, models.CacheTierSemanticExact, now)
+		if entry.Stale == "" {
+			result.Hit = true
+			result.CacheTier = models.CacheTierSemanticExact
+			result.Entry = &entry
+		}
+	}
+
+	matches, err := pmc.cache.TopMatchesWithPrefix(ctx, req.Prompt, namespaceKeyPrefix(namespace), semanticCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search cache: %w", err)
+	}
+	result.Matches = make([]models.CacheEntry, 0, len(matches))
+	for _, match := range matches {
+		entry := cacheEntry(match.Value.CacheKey, match.Value, match.Score)
+		entry.Stale = pmc.policy.staleReason(match.Value, models.CacheTierSemanticSimilar, now)
+		result.Matches = append(result.Matches, entry)
+		if !result.Hit && entry.Stale == "" && match.Score >= threshold {
+			result.Hit = true
+			result.CacheTier = models.CacheTierSemanticSimilar
+			result.Entry = &entry
+		}
+	}
+	return result, nil
+}
+
+// Delete removes the entry stored under key and reports whether it existed
+func (pmc *ModelRouterCache) Delete(ctx context.Context, key string) (bool, error) {
+	if pmc == nil {
+		return false, ErrCacheDisabled
+	}
+	if key == "" {
+		return false, fmt.Errorf("%w: key is required", ErrInvalidCacheRequest)
+	}
+	exists, err := pmc.cache.Contains(ctx, key)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := pmc.cache.Delete(ctx, key); err != nil {
+		return false, fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	return true, nil
+}
+
+// Flush deletes the entries matching every filter of the request and returns how many
+// were deleted
+func (pmc *ModelRouterCache) Flush(ctx context.Context, req models.CacheFlushRequest) (int, error) {
+	if pmc == nil {
+		return 0, ErrCacheDisabled
+	}
+	if req.All {
+		count, err := pmc.cache.Len(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count cache entries: %w", err)
+		}
+		if err := pmc.cache.Flush(ctx); err != nil {
+			return 0, fmt.Errorf("failed to flush cache: %w", err)
+		}
+		return count, nil
+	}
+	if req.Namespace == "" && req.Provider == "" && req.Model == "" {
+		return 0, fmt.Errorf("%w: namespace, provider or model is required unless all is set", ErrInvalidCacheRequest)
+	}
+
+	keys, err := pmc.cache.Keys(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+	deleted := 0
+	for start := 0; start < len(keys); start += flushBatchSize {
+		batch := keys[start:min(start+flushBatchSize, len(keys))]
+		values, err := pmc.cache.GetBatch(ctx, batch)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to load cache entries: %w", err)
+		}
+		var matched []string
+		for key, value := range values {
+			if matchesFlush(value, req) {
+				matched = append(matched, key)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if err := pmc.cache.DeleteBatch(ctx, matched); err != nil {
+			return deleted, fmt.Errorf("failed to delete cache entries: %w", err)
+		}
+		deleted += len(matched)
+	}
+	return deleted, nil
+}
+
+// matchesFlush reports whether an entry matches all filters of a flush request. Provider and
+// model match the primary selection or any alternative.
+func matchesFlush(entry models.ModelSelectionResponse, req models.CacheFlushRequest) bool {
+	if req.Namespace != "" && !inNamespace(entry.CacheNamespace, req.Namespace) {
+		return false
+	}
+	if req.Provider == "" && req.Model == "" {
+		return true
+	}
+	candidates := append([]models.Alternative{{Provider: entry.Provider, Model: entry.Model}}, entry.Alternatives...)
+	for _, candidate := range candidates {
+		if (req.Provider == "" || candidate.Provider == req.Provider) && (req.Model == "" || candidate.Model == req.Model) {
+			return true
+		}
+	}
+	return false
+}
+
+// inNamespace matches a full namespace, or a tenant such as "org:acme" against all of its
+// candidate sets
+func inNamespace(namespace, filter string) bool {
+	return namespace == filter || strings.HasPrefix(namespace, filter+"/")
+}
+
+// filterKeyPrefix returns the key prefix of the entries inNamespace matches: a full namespace
+// ("tenant/candidates") has its own entries, a tenant those of all of its candidate sets
+func filterKeyPrefix(filter string) string {
+	if strings.Contains(filter, "/") {
+		return namespaceKeyPrefix(filter)
+	}
+	return filter + "/"
+}
+
+func cacheEntry(key string, value models.ModelSelectionResponse, score float32) models.CacheEntry {
+	return models.CacheEntry{
+		Key:          key,
+		Namespace:    value.CacheNamespace,
+		Provider:     value.Provider,
+		Model:        value.Model,
+		Alternatives: value.Alternatives,
+		CachedAt:     value.CachedAt,
+		Score:        score,
+	}
+}
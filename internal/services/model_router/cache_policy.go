@@ -0,0 +1,180 @@
+package model_router
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/config"
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// routerVersion is bumped whenever selection logic changes, so cached selections made by an
+// older router are no longer served
+const routerVersion = "1"
+
+// Reasons a cached selection is not served
+const (
+	staleGeneration   = "generation"
+	staleExpired      = "expired"
+	staleUnconfigured = "unconfigured"
+)
+
+// routedEndpoints are the endpoints whose providers cached selections may name
+var routedEndpoints = []string{"chat_completions", "messages", "generate", "select_model", "count_tokens"}
+
+// cachePolicy decides whether a cached selection may still be served: it must belong to the
+// current cache generation, be within the TTL of its tier and name at least one configured model
+type cachePolicy struct {
+	generation  string
+	exactTTL    time.Duration
+	semanticTTL time.Duration
+	// providers are the providers configured on any endpoint; catalog holds the provider/model
+	// pairs of providers that list their models
+	providers map[string]bool
+	catalog   map[string]bool
+}
+
+func newCachePolicy(cfg *config.Config) *cachePolicy {
+	policy := &cachePolicy{
+		generation: cacheGeneration(cfg),
+		providers:  configuredProviders(cfg),
+		catalog:    make(map[string]bool),
+	}
+	policy.exactTTL, policy.semanticTTL = cfg.ModelRouter.Cache.TierTTLs()
+	for _, model := range cfg.ModelCatalog() {
+		policy.catalog[candidateKey(model.Provider, model.ModelName)] = true
+		policy.catalog[candidateKey(model.Provider, "")] = true
+	}
+	return policy
+}
+
+// cacheGeneration hashes the router version, the configured cache version, the router mode and
+// the model catalog, including pricing and limits, so any change to them retires cached selections
+func cacheGeneration(cfg *config.Config) string {
+	catalog := cfg.ModelCatalog()
+	slices.SortFunc(catalog, func(a, b models.ModelCapability) int {
+		return cmp.Compare(candidateKey(a.Provider, a.ModelName), candidateKey(b.Provider, b.ModelName))
+	})
+
+	// Marshalling plain config structs cannot fail
+	encoded, _ := json.Marshal(struct {
+		Router    string                   `json:"router"`
+		Version   string                   `json:"version"`
+		Mode      models.ModelRouterMode   `json:"mode"`
+		Providers []string                 `json:"providers"`
+		Catalog   []models.ModelCapability `json:"catalog"`
+	}{
+		Router:    routerVersion,
+		Version:   cfg.ModelRouter.Cache.Version,
+		Mode:      cfg.ModelRouter.Client.Mode,
+		Providers: slices.Sorted(maps.Keys(configuredProviders(cfg))),
+		Catalog:   catalog,
+	})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+// configuredProviders returns the providers configured on any routed endpoint
+func configuredProviders(cfg *config.Config) map[string]bool {
+	providers := make(map[string]bool)
+	for _, endpoint := range routedEndpoints {
+		for provider := range cfg.GetProviders(endpoint) {
+			providers[provider] = true
+		}
+	}
+	return providers
+}
+
+// stamp marks a selection as cached now under the current generation
+func (p *cachePolicy) stamp(resp *models.ModelSelectionResponse) {
+	resp.CacheGeneration = p.generation
+	resp.CachedAt = time.Now()
+}
+
+// staleReason returns why a cached selection must not be served for a hit of the given tier,
+// or "" when it may be served
+func (p *cachePolicy) staleReason(entry models.ModelSelectionResponse, tier string, now time.Time) string {
+	if entry.CacheGeneration != p.generation {
+		return staleGeneration
+	}
+	ttl := p.exactTTL
+	if tier == models.CacheTierSemanticSimilar {
+		ttl = p.semanticTTL
+	}
+	if models.Expired(entry.CachedAt, ttl, now) {
+		return staleExpired
+	}
+	if !p.anyConfigured(entry) {
+		return staleUnconfigured
+	}
+	return ""
+}
+
+// anyConfigured reports whether the primary selection or an alternative is still configured
+func (p *cachePolicy) anyConfigured(entry models.ModelSelectionResponse) bool {
+	for _, candidate := range append([]models.Alternative{{Provider: entry.Provider, Model: entry.Model}}, entry.Alternatives...) {
+		if p.isConfigured(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfigured reports whether a provider is configured and, when it lists its models, whether
+// the model is among them
+func (p *cachePolicy) isConfigured(candidate models.Alternative) bool {
+	if !p.providers[candidate.Provider] {
+		return false
+	}
+	if !p.catalog[candidateKey(candidate.Provider, "")] {
+		return true
+	}
+	return p.catalog[candidateKey(candidate.Provider, candidate.Model)]
+}
+
+// sweep deletes the entries that can no longer be served: entries of other generations, written
+// before the model catalog or router changed, and entries naming only removed models. Expired
+// entries are left to be removed when they are next looked up.
+func (pmc *ModelRouterCache) sweep(ctx context.Context) {
+	keys, err := pmc.cache.Keys(ctx)
+	if err != nil {
+		fiberlog.Warnf("ModelRouterCache: Failed to list entries for invalidation: %v", err)
+		return
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += flushBatchSize {
+		batch := keys[start:min(start+flushBatchSize, len(keys))]
+		values, err := pmc.cache.GetBatch(ctx, batch)
+		if err != nil {
+			fiberlog.Warnf("ModelRouterCache: Failed to load entries for invalidation: %v", err)
+			return
+		}
+		var stale []string
+		for key, value := range values {
+			if value.CacheGeneration != pmc.policy.generation || !pmc.policy.anyConfigured(value) {
+				stale = append(stale, key)
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		if err := pmc.cache.DeleteBatch(ctx, stale); err != nil {
+			fiberlog.Warnf("ModelRouterCache: Failed to delete stale entries: %v", err)
+			return
+		}
+		deleted += len(stale)
+	}
+	if deleted > 0 {
+		fiberlog.Infof("🧹 ModelRouterCache: Invalidated %d entries from a previous model catalog or router version (generation %s)",
+			deleted, pmc.policy.generation)
+	}
+}
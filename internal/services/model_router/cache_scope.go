@@ -0,0 +1,92 @@
+package model_router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+)
+
+const (
+	sharedTenant = "shared"
+	// allCandidates marks requests without a candidate list, which may route to any configured model
+	allCandidates = "all"
+)
+
+// cacheNamespaceKey is the user context key of the request's router cache namespace
+type cacheNamespaceKey struct{}
+
+// validateCacheScope checks the configured router cache scope
+func validateCacheScope(scope models.CacheScope) error {
+	switch scope {
+	case "", models.CacheScopeShared, models.CacheScopeOrganization, models.CacheScopeProject, models.CacheScopeAPIKey:
+		return nil
+	}
+	return fmt.Errorf("unknown cache scope %q (supported: shared, organization, project, api_key)", scope)
+}
+
+// ScopeCache returns ctx with the request's router cache namespace attached. The namespace
+// combines the tenant, per the configured cache scope, with the candidate set left after routing
+// rules, so cached selections are never shared across tenants or served outside the models the
+// caller may use. It must run after ApplyRoutingRules and before circuit breakers trim the candidates.
+func (pm *ModelRouter) ScopeCache(ctx context.Context, rc models.RoutingContext, routerConfig *models.ModelRouterConfig) context.Context {
+	var scope models.CacheScope
+	if pm.cfg.ModelRouter != nil {
+		// Request overrides can replace the cache config, so the scope comes from YAML only
+		scope = pm.cfg.ModelRouter.Cache.Scope
+	}
+	namespace := cacheTenant(rc, scope) + "/" + candidateFingerprint(routerConfig)
+	return context.WithValue(ctx, cacheNamespaceKey{}, namespace)
+}
+
+// CacheNamespace returns the namespace attached by ScopeCache. Requests that were never scoped
+// share one namespace.
+func CacheNamespace(ctx context.Context) string {
+	if namespace, ok := ctx.Value(cacheNamespaceKey{}).(string); ok {
+		return namespace
+	}
+	return sharedTenant + "/" + allCandidates
+}
+
+// cacheTenant identifies the caller at the configured scope; unauthenticated callers are shared
+func cacheTenant(rc models.RoutingContext, scope models.CacheScope) string {
+	switch scope {
+	case models.CacheScopeShared:
+		return sharedTenant
+	case models.CacheScopeAPIKey:
+		if rc.APIKeyID != 0 {
+			return fmt.Sprintf("key:%d", rc.APIKeyID)
+		}
+		return sharedTenant
+	case models.CacheScopeProject:
+		if rc.ProjectID != 0 {
+			return fmt.Sprintf("project:%d", rc.ProjectID)
+		}
+	}
+	if rc.OrganizationID != "" {
+		return "org:" + rc.OrganizationID
+	}
+	if rc.APIKeyID != 0 {
+		return fmt.Sprintf("key:%d", rc.APIKeyID)
+	}
+	return sharedTenant
+}
+
+// candidateFingerprint hashes the sorted candidate set, so requests restricted to different
+// models never share entries
+func candidateFingerprint(routerConfig *models.ModelRouterConfig) string {
+	if routerConfig == nil || len(routerConfig.Models) == 0 {
+		return allCandidates
+	}
+	candidates := make([]string, 0, len(routerConfig.Models))
+	for _, model := range routerConfig.Models {
+		candidates = append(candidates, model.Provider+":"+model.ModelName)
+	}
+	slices.Sort(candidates)
+	sum := sha256.Sum256([]byte(strings.Join(candidates, ",")))
+	return hex.EncodeToString(sum[:8])
+}
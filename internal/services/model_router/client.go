@@ -18,6 +18,8 @@ import (
 )
 
 type ModelRouterClient struct {
+	mode              models.ModelRouterMode
+	heuristic         *HeuristicRouter
 	adaptiveRouterURL string
 	jwtSecret         string
 	timeout           time.Duration
@@ -26,6 +28,7 @@ type ModelRouterClient struct {
 
 func DefaultModelRouterClientConfig() ModelRouterClientConfig {
 	return ModelRouterClientConfig{
+		Mode:              models.ModelRouterModeRemote,
 		AdaptiveRouterURL: "",
 		JWTSecret:         "",
 		RequestTimeout:    5 * time.Second,
@@ -39,6 +42,7 @@ func DefaultModelRouterClientConfig() ModelRouterClientConfig {
 }
 
 type ModelRouterClientConfig struct {
+	Mode                 models.ModelRouterMode
 	AdaptiveRouterURL    string
 	JWTSecret            string
 	RequestTimeout       time.Duration
@@ -52,6 +56,10 @@ func NewModelRouterClient(cfg *config.Config, redisClient *redis.Client) *ModelR
 		return nil
 	}
 
+	if cfg.ModelRouter.Client.Mode != "" {
+		config.Mode = cfg.ModelRouter.Client.Mode
+	}
+
 	if cfg.ModelRouter.Client.AdaptiveRouterURL != "" {
 		config.AdaptiveRouterURL = cfg.ModelRouter.Client.AdaptiveRouterURL
 	}
@@ -84,7 +92,18 @@ func NewModelRouterClient(cfg *config.Config, redisClient *redis.Client) *ModelR
 }
 
 func NewModelRouterClientWithConfig(config ModelRouterClientConfig, redisClient *redis.Client) *ModelRouterClient {
+	mode := config.Mode
+	if mode == "" {
+		mode = models.ModelRouterModeRemote
+	}
+	if mode == models.ModelRouterModeRemote && config.AdaptiveRouterURL == "" {
+		fiberlog.Warn("ModelRouterClient: adaptive_router_url not set, using heuristic router")
+		mode = models.ModelRouterModeHeuristic
+	}
+
 	return &ModelRouterClient{
+		mode:              mode,
+		heuristic:         NewHeuristicRouter(),
 		adaptiveRouterURL: config.AdaptiveRouterURL,
 		jwtSecret:         config.JWTSecret,
 		timeout:           config.RequestTimeout,
@@ -111,7 +130,7 @@ func (c *ModelRouterClient) generateJWT() (string, error) {
 func (c *ModelRouterClient) SelectModel(
 	ctx context.Context,
 	req models.ModelSelectionRequest,
-) models.ModelSelectionResponse {
+) (models.ModelSelectionResponse, error) {
 	start := time.Now()
 
 	// Log the select model request details (non-PII at info level)
@@ -129,17 +148,22 @@ func (c *ModelRouterClient) SelectModel(
 			*req.CostBias, len(req.Models))
 	}
 
+	if c.mode == models.ModelRouterModeHeuristic {
+		fiberlog.Debugf("[SELECT_MODEL] Heuristic mode - selecting model in-process")
+		return c.getFallbackModelResponse(req)
+	}
+
 	if c.circuitBreaker != nil && !c.circuitBreaker.CanExecute() {
 		fiberlog.Warnf("[CIRCUIT_BREAKER] Adaptive Router service unavailable (Open state). Using fallback.")
 		circuitErr := fmt.Errorf("adaptive_router")
 		fiberlog.Debugf("[CIRCUIT_BREAKER] %v", circuitErr)
-		return c.getFallbackModelResponse(req.Models)
+		return c.getFallbackModelResponse(req)
 	}
 
 	jwtToken, err := c.generateJWT()
 	if err != nil {
 		fiberlog.Warnf("[JWT_ERROR] Failed to generate JWT token: %v. Using fallback.", err)
-		return c.getFallbackModelResponse(req.Models)
+		return c.getFallbackModelResponse(req)
 	}
 
 	var out models.ModelSelectionResponse
@@ -161,7 +185,7 @@ func (c *ModelRouterClient) SelectModel(
 		providerErr := fmt.Errorf("prediction request failed: %w", err)
 		fiberlog.Warnf("[PROVIDER_ERROR] %v", providerErr)
 		fiberlog.Warnf("[SELECT_MODEL] Request failed, using fallback model")
-		return c.getFallbackModelResponse(req.Models)
+		return c.getFallbackModelResponse(req)
 	}
 
 	if !out.IsValid() {
@@ -170,7 +194,7 @@ func (c *ModelRouterClient) SelectModel(
 		}
 		fiberlog.Warnf("[SELECT_MODEL] Adaptive router returned invalid response (provider: '%s', model: '%s'), using fallback",
 			out.Provider, out.Model)
-		return c.getFallbackModelResponse(req.Models)
+		return c.getFallbackModelResponse(req)
 	}
 
 	duration := time.Since(start)
@@ -179,46 +203,47 @@ func (c *ModelRouterClient) SelectModel(
 	}
 	fiberlog.Infof("[SELECT_MODEL] Request successful in %v - model: %s/%s",
 		duration, out.Provider, out.Model)
-	return out
+	return out, nil
 }
 
-func (c *ModelRouterClient) getFallbackModelResponse(availableModels []models.ModelCapability) models.ModelSelectionResponse {
-	// Filter valid models (both provider and model name required)
-	var validModels []models.ModelCapability
-	for _, model := range availableModels {
-		if model.Provider != "" && model.ModelName != "" {
-			validModels = append(validModels, model)
-		}
+// SendFeedback posts a batch of routing feedback to the adaptive_router service's /feedback endpoint.
+// It is a no-op in heuristic mode, where there is no service to learn from it.
+func (c *ModelRouterClient) SendFeedback(ctx context.Context, batch []models.RoutingFeedback) error {
+	if c == nil || c.mode == models.ModelRouterModeHeuristic || len(batch) == 0 {
+		return nil
 	}
 
-	// If we have valid models, choose the first one
-	if len(validModels) > 0 {
-		firstModel := validModels[0]
-		response := models.ModelSelectionResponse{
-			Provider: firstModel.Provider,
-			Model:    firstModel.ModelName,
-		}
-
-		// Add alternatives from remaining models (up to 3 alternatives)
-		for i := 1; i < len(validModels); i++ {
-			response.Alternatives = append(response.Alternatives, models.Alternative{
-				Provider: validModels[i].Provider,
-				Model:    validModels[i].ModelName,
-			})
-		}
-
-		return response
+	jwtToken, err := c.generateJWT()
+	if err != nil {
+		return fmt.Errorf("failed to generate JWT token: %w", err)
 	}
 
-	// Simple fallback: route to gemini-2.5-flash when no models provided, with gpt-4o as alternative
-	return models.ModelSelectionResponse{
-		Provider: "gemini",
-		Model:    "gemini-2.5-flash",
-		Alternatives: []models.Alternative{
-			{
-				Provider: "openai",
-				Model:    "gpt-4o",
-			},
+	opts := &services.RequestOptions{
+		Timeout: c.timeout,
+		Context: ctx,
+		Headers: map[string]string{
+			"Authorization": fmt.Sprintf("Bearer %s", jwtToken),
 		},
 	}
+	client := services.NewClient(c.adaptiveRouterURL)
+	if err := client.Post("/feedback", map[string]any{"feedback": batch}, nil, opts); err != nil {
+		return fmt.Errorf("feedback request failed: %w", err)
+	}
+	return nil
+}
+
+// getFallbackModelResponse selects a model without the adaptive_router service.
+// It ranks the candidates with the heuristic router; ErrNoCandidates is returned when no
+// candidate has both a provider and a model name.
+func (c *ModelRouterClient) getFallbackModelResponse(req models.ModelSelectionRequest) (models.ModelSelectionResponse, error) {
+	if c.heuristic != nil {
+		if response, ok := c.heuristic.SelectModel(req); ok {
+			fiberlog.Infof("[SELECT_MODEL] Heuristic router selected %s/%s (%d alternatives)",
+				response.Provider, response.Model, len(response.Alternatives))
+			return response, nil
+		}
+	}
+
+	return models.ModelSelectionResponse{}, fmt.Errorf("%w: %d models given, none with both a provider and a model name",
+		ErrNoCandidates, len(req.Models))
 }
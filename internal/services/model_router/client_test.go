@@ -0,0 +1,53 @@
+package model_router
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+)
+
+func TestModelRouterClientHeuristicSelection(t *testing.T) {
+	client := &ModelRouterClient{mode: models.ModelRouterModeHeuristic, heuristic: NewHeuristicRouter()}
+
+	tests := []struct {
+		name         string
+		models       []models.ModelCapability
+		wantProvider string
+		wantErr      error
+	}{
+		{
+			name:         "ranks the configured models",
+			models:       []models.ModelCapability{{Provider: "anthropic", ModelName: "claude-sonnet-4-5"}},
+			wantProvider: "anthropic",
+		},
+		{
+			name:    "no candidates",
+			wantErr: ErrNoCandidates,
+		},
+		{
+			name:    "candidates without a model name",
+			models:  []models.ModelCapability{{Provider: "anthropic"}, {ModelName: "gpt-4o"}},
+			wantErr: ErrNoCandidates,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.SelectModel(context.Background(), models.ModelSelectionRequest{Prompt: "hello", Models: tt.models})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SelectModel() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectModel() error = %v", err)
+			}
+			if got.Provider != tt.wantProvider {
+				t.Errorf("SelectModel() provider = %q, want %q", got.Provider, tt.wantProvider)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package model_router
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// validateExperiments checks experiment names, arms, weights and arm models
+func validateExperiments(experiments []models.Experiment, aliases map[string]models.ModelAlias) error {
+	seen := make(map[string]bool, len(experiments))
+	for _, experiment := range experiments {
+		if experiment.Name == "" {
+			return fmt.Errorf("experiment name cannot be empty")
+		}
+		if seen[experiment.Name] {
+			return fmt.Errorf("duplicate experiment %s", experiment.Name)
+		}
+		seen[experiment.Name] = true
+
+		switch experiment.StickyBy {
+		case "", models.ExperimentStickyUser, models.ExperimentStickyAPIKey:
+		default:
+			return fmt.Errorf("experiment %s: unknown sticky_by %q", experiment.Name, experiment.StickyBy)
+		}
+		if len(experiment.Arms) < 2 {
+			return fmt.Errorf("experiment %s: at least two arms are required", experiment.Name)
+		}
+		for _, arm := range experiment.Arms {
+			if arm.Name == "" {
+				return fmt.Errorf("experiment %s: arm name cannot be empty", experiment.Name)
+			}
+			if arm.Weight <= 0 {
+				return fmt.Errorf("experiment %s: arm %s weight must be positive", experiment.Name, arm.Name)
+			}
+			if _, ok := aliases[arm.Model]; ok {
+				continue
+			}
+			if _, _, err := utils.ParseProviderModel(arm.Model); err != nil {
+				return fmt.Errorf("experiment %s: arm %s model must be a model alias or in provider:model format", experiment.Name, arm.Name)
+			}
+		}
+	}
+	if len(experiments) > 0 {
+		fiberlog.Infof("ModelRouter: Loaded %d experiments", len(experiments))
+	}
+	return nil
+}
+
+// ApplyExperiment assigns the request to an experiment arm when one applies and tags the request's
+// usage records with the experiment and arm. It returns the model to use. Models pinned by a
+// routing rule are never replaced.
+func (pm *ModelRouter) ApplyExperiment(
+	c *fiber.Ctx,
+	rc models.RoutingContext,
+	model string,
+	rule *models.RoutingRule,
+	requestID string,
+) string {
+	if rule != nil && rule.Model != "" {
+		return model
+	}
+	assignment := pm.AssignExperiment(rc, model, rule, requestID)
+	if assignment == nil {
+		return model
+	}
+	usage.SetUsageMetadata(c, "experiment", assignment.Experiment)
+	usage.SetUsageMetadata(c, "experiment_arm", assignment.Arm)
+	routing_trace.From(c).SetExperiment(assignment)
+	return assignment.Model
+}
+
+// AssignExperiment assigns the request to an arm of the first experiment matching the routing context
+// and requested model. Arms whose model the matched routing rule does not allow are never assigned;
+// the experiment is skipped instead. It returns nil when no experiment applies.
+func (pm *ModelRouter) AssignExperiment(
+	rc models.RoutingContext,
+	requestedModel string,
+	rule *models.RoutingRule,
+	requestID string,
+) *models.ExperimentAssignment {
+	for i := range pm.cfg.Experiments {
+		experiment := &pm.cfg.Experiments[i]
+		if !experimentApplies(experiment, rc, requestedModel) {
+			continue
+		}
+
+		arm := pickArm(experiment, experimentSubject(experiment, rc))
+		if provider, model, err := utils.ParseProviderModel(arm.Model); err == nil && !rule.AllowsModel(provider, model) {
+			fiberlog.Warnf("[%s] 🧪 Skipping experiment %s: arm %s (%s) not permitted by routing rule %q",
+				requestID, experiment.Name, arm.Name, arm.Model, rule.Name)
+			return nil
+		}
+
+		fiberlog.Infof("[%s] 🧪 Experiment %s assigned arm %s (%s)", requestID, experiment.Name, arm.Name, arm.Model)
+		return &models.ExperimentAssignment{
+			Experiment: experiment.Name,
+			Arm:        arm.Name,
+			Model:      arm.Model,
+		}
+	}
+	return nil
+}
+
+// experimentApplies reports whether the experiment targets the request
+func experimentApplies(experiment *models.Experiment, rc models.RoutingContext, requestedModel string) bool {
+	if experiment.Model != requestedModel {
+		return false
+	}
+	if len(experiment.Endpoints) > 0 && !slices.Contains(experiment.Endpoints, rc.Endpoint) {
+		return false
+	}
+	if len(experiment.ProjectIDs) > 0 && !slices.Contains(experiment.ProjectIDs, rc.ProjectID) {
+		return false
+	}
+	return true
+}
+
+// experimentSubject returns the identity assignment is sticky to, or "" when the caller is anonymous
+func experimentSubject(experiment *models.Experiment, rc models.RoutingContext) string {
+	if experiment.StickyBy != models.ExperimentStickyAPIKey && rc.User != "" && rc.User != "anonymous" {
+		return "user:" + rc.User
+	}
+	if rc.APIKeyID != 0 {
+		return "api_key:" + strconv.FormatUint(uint64(rc.APIKeyID), 10)
+	}
+	return ""
+}
+
+// pickArm selects an arm by weight. A subject always hashes to the same arm; anonymous callers are assigned randomly.
+func pickArm(experiment *models.Experiment, subject string) models.ExperimentArm {
+	total := 0
+	for _, arm := range experiment.Arms {
+		total += arm.Weight
+	}
+
+	var bucket int
+	if subject == "" {
+		bucket = rand.IntN(total) // #nosec G404 - traffic splitting does not need a secure source
+	} else {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(experiment.Name + "|" + subject))
+		bucket = int(h.Sum64() % uint64(total))
+	}
+
+	for _, arm := range experiment.Arms {
+		if bucket < arm.Weight {
+			return arm
+		}
+		bucket -= arm.Weight
+	}
+	return experiment.Arms[len(experiment.Arms)-1]
+}
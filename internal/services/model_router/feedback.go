@@ -0,0 +1,162 @@
+package model_router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cacheEntryKeyPrefix = "router_cache_entry:"
+	// cacheEntryTTL bounds how long after a request feedback can still invalidate its cache entry
+	cacheEntryTTL = time.Hour
+	// maxCacheEntries caps the in-memory index
+	maxCacheEntries = 10000
+	redisTimeout    = 500 * time.Millisecond
+)
+
+// indexedEntry holds the cache keys a request was served from or stored under
+type indexedEntry struct {
+	keys      []string
+	expiresAt time.Time
+}
+
+// cacheEntryIndex remembers which semantic cache entries each request touched, so feedback can
+// invalidate them later. Entries are kept in memory and, when Redis is configured, shared so
+// feedback reaching another instance still finds them.
+type cacheEntryIndex struct {
+	mu          sync.Mutex
+	entries     map[string]*indexedEntry
+	redisClient *redis.Client
+}
+
+func newCacheEntryIndex(redisClient *redis.Client) *cacheEntryIndex {
+	return &cacheEntryIndex{
+		entries:     make(map[string]*indexedEntry),
+		redisClient: redisClient,
+	}
+}
+
+// remember records that the request used the cache entry stored under key
+func (i *cacheEntryIndex) remember(requestID, key string) {
+	if i == nil || requestID == "" || key == "" {
+		return
+	}
+
+	i.mu.Lock()
+	entry, ok := i.entries[requestID]
+	if !ok {
+		if len(i.entries) >= maxCacheEntries {
+			i.evictLocked()
+		}
+		entry = &indexedEntry{}
+		i.entries[requestID] = entry
+	}
+	for _, existing := range entry.keys {
+		if existing == key {
+			i.mu.Unlock()
+			return
+		}
+	}
+	entry.keys = append(entry.keys, key)
+	entry.expiresAt = time.Now().Add(cacheEntryTTL)
+	keys := append([]string(nil), entry.keys...)
+	i.mu.Unlock()
+
+	if i.redisClient != nil {
+		go i.pushShared(requestID, keys)
+	}
+}
+
+// take returns and forgets the cache keys recorded for the request
+func (i *cacheEntryIndex) take(ctx context.Context, requestID string) []string {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.Lock()
+	entry, ok := i.entries[requestID]
+	delete(i.entries, requestID)
+	i.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.keys
+	}
+
+	if i.redisClient == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	value, err := i.redisClient.GetDel(ctx, cacheEntryKeyPrefix+requestID).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			fiberlog.Debugf("[%s] ModelRouter: Failed to load cache entry keys: %v", requestID, err)
+		}
+		return nil
+	}
+	var keys []string
+	if err := json.Unmarshal([]byte(value), &keys); err != nil {
+		return nil
+	}
+	return keys
+}
+
+// evictLocked drops expired entries, or an arbitrary one when none has expired
+func (i *cacheEntryIndex) evictLocked() {
+	now := time.Now()
+	for requestID, entry := range i.entries {
+		if now.After(entry.expiresAt) {
+			delete(i.entries, requestID)
+		}
+	}
+	if len(i.entries) < maxCacheEntries {
+		return
+	}
+	for requestID := range i.entries {
+		delete(i.entries, requestID)
+		return
+	}
+}
+
+// pushShared stores the request's cache keys in Redis
+func (i *cacheEntryIndex) pushShared(requestID string, keys []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := json.Marshal(keys)
+	if err != nil {
+		return
+	}
+	if err := i.redisClient.Set(ctx, cacheEntryKeyPrefix+requestID, value, cacheEntryTTL).Err(); err != nil {
+		fiberlog.Debugf("[%s] ModelRouter: Failed to share cache entry keys: %v", requestID, err)
+	}
+}
+
+// InvalidateRequest deletes the semantic cache entries that served or were stored by the request,
+// so a routing decision that received negative feedback is not served again to similar prompts.
+// It reports whether any entry was invalidated.
+func (pm *ModelRouter) InvalidateRequest(ctx context.Context, requestID string) bool {
+	if pm.cache == nil {
+		return false
+	}
+	keys := pm.cacheEntry.take(ctx, requestID)
+	for _, key := range keys {
+		pm.cache.cache.DeleteAsync(ctx, key)
+	}
+	if len(keys) > 0 {
+		fiberlog.Infof("[%s] 🗑️  Invalidated %d semantic cache entries after negative feedback", requestID, len(keys))
+	}
+	return len(keys) > 0
+}
+
+// ForwardFeedback sends a batch of feedback to the adaptive_router service as training signal
+func (pm *ModelRouter) ForwardFeedback(ctx context.Context, batch []models.RoutingFeedback) error {
+	return pm.client.SendFeedback(ctx, batch)
+}
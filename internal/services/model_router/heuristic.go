@@ -0,0 +1,283 @@
+package model_router
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+const (
+	// defaultHeuristicCostBias is used when the request does not specify a cost bias
+	defaultHeuristicCostBias = 0.5
+	// maxHeuristicAlternatives caps the number of alternatives returned by the heuristic router
+	maxHeuristicAlternatives = 3
+	// latencyWeight is the share of the final score given to the latency tier
+	latencyWeight = 0.1
+	// toolPenalty is subtracted from models that cannot call tools when tools are present
+	toolPenalty = 0.5
+)
+
+// Prompt complexity levels estimated from the prompt text
+const (
+	complexityLow    = 0.33
+	complexityMedium = 0.66
+	complexityHigh   = 1.0
+)
+
+// complexityKeywords are phrases that typically indicate reasoning-heavy prompts
+var complexityKeywords = []string{
+	"analyze", "analyse", "prove", "derive", "step by step", "architecture",
+	"refactor", "debug", "optimize", "algorithm", "explain why", "trade-off",
+	"tradeoff", "design", "implement", "reason", "compare",
+}
+
+// HeuristicRouter ranks model capabilities locally without calling the adaptive_router service.
+// It is used as the primary router in "heuristic" mode and as a graceful fallback when
+// the remote service is unavailable.
+type HeuristicRouter struct{}
+
+// NewHeuristicRouter creates a new heuristic router
+func NewHeuristicRouter() *HeuristicRouter {
+	return &HeuristicRouter{}
+}
+
+// scoredModel pairs a candidate with its computed score
+type scoredModel struct {
+	model models.ModelCapability
+	score float64
+}
+
+// SelectModel ranks the candidate models and returns the best one with ranked alternatives.
+// The second return value is false when no candidate could be ranked.
+func (h *HeuristicRouter) SelectModel(req models.ModelSelectionRequest) (models.ModelSelectionResponse, bool) {
+	candidates := h.rankableModels(req.Models)
+	if len(candidates) == 0 {
+		return models.ModelSelectionResponse{}, false
+	}
+
+	costBias := float64(defaultHeuristicCostBias)
+	if req.CostBias != nil && *req.CostBias >= 0 && *req.CostBias <= 1 {
+		costBias = float64(*req.CostBias)
+	}
+
+	promptTokens := utils.EstimateTokens(req.Prompt)
+	// The extracted prompt may be a small part of a long conversation; size by the whole request when known
+	if req.EstimatedTokens > promptTokens {
+		promptTokens = req.EstimatedTokens
+	}
+	needsTools := req.ToolCount > 0 || hasTools(req.Tools) || hasTools(req.ToolCall)
+	complexity := estimatePromptComplexity(req.Prompt, promptTokens, needsTools)
+
+	minCost, maxCost := costRange(candidates)
+
+	scored := make([]scoredModel, 0, len(candidates))
+	for _, model := range candidates {
+		if model.MaxContextTokens > 0 && promptTokens > model.MaxContextTokens {
+			fiberlog.Debugf("[HEURISTIC_ROUTER] Skipping %s/%s: prompt (~%d tokens) exceeds context window (%d)",
+				model.Provider, model.ModelName, promptTokens, model.MaxContextTokens)
+			continue
+		}
+
+		capability := capabilityScore(model, complexity)
+		cost := costScore(model, minCost, maxCost)
+
+		// cost_bias: 0.0 = cheapest, 1.0 = best performance
+		score := capability*costBias + cost*(1-costBias)
+		score = score*(1-latencyWeight) + latencyScore(model.LatencyTier)*latencyWeight
+
+		if needsTools && !model.SupportsToolCalling {
+			score -= toolPenalty
+		}
+
+		scored = append(scored, scoredModel{model: model, score: score})
+	}
+
+	if len(scored) == 0 {
+		// Every model was too small for the prompt; rank by context size instead of failing
+		for _, model := range candidates {
+			scored = append(scored, scoredModel{model: model, score: float64(model.MaxContextTokens)})
+		}
+	}
+
+	// Stable sort keeps configuration order as the tie-breaker
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+
+	best := scored[0].model
+	response := models.ModelSelectionResponse{
+		Provider: best.Provider,
+		Model:    best.ModelName,
+	}
+	for _, s := range scored[1:] {
+		if len(response.Alternatives) >= maxHeuristicAlternatives {
+			break
+		}
+		response.Alternatives = append(response.Alternatives, models.Alternative{
+			Provider: s.model.Provider,
+			Model:    s.model.ModelName,
+		})
+	}
+
+	fiberlog.Debugf("[HEURISTIC_ROUTER] Selected %s/%s (score: %.3f, complexity: %.2f, cost_bias: %.2f, candidates: %d)",
+		best.Provider, best.ModelName, scored[0].score, complexity, costBias, len(scored))
+
+	return response, true
+}
+
+// rankableModels returns models with both provider and model name set, filling in pricing
+// from the global pricing table when the capability omits it
+func (h *HeuristicRouter) rankableModels(availableModels []models.ModelCapability) []models.ModelCapability {
+	candidates := make([]models.ModelCapability, 0, len(availableModels))
+	for _, model := range availableModels {
+		if model.Provider == "" || model.ModelName == "" {
+			continue
+		}
+		if model.CostPer1MInputTokens == 0 && model.CostPer1MOutputTokens == 0 {
+			if pricing, ok := usage.GlobalPricing[model.Provider][model.ModelName]; ok {
+				model.CostPer1MInputTokens = pricing.InputTokenCost
+				model.CostPer1MOutputTokens = pricing.OutputTokenCost
+			}
+		}
+		candidates = append(candidates, model)
+	}
+	return candidates
+}
+
+// estimatePromptComplexity returns a value in (0, 1] describing how demanding the prompt is
+func estimatePromptComplexity(prompt string, promptTokens int, hasToolDefinitions bool) float64 {
+	complexity := complexityLow
+	switch {
+	case promptTokens > 2000:
+		complexity = complexityHigh
+	case promptTokens > 300:
+		complexity = complexityMedium
+	}
+
+	lower := strings.ToLower(prompt)
+	matches := 0
+	for _, keyword := range complexityKeywords {
+		if strings.Contains(lower, keyword) {
+			matches++
+		}
+	}
+	if strings.Contains(prompt, "```") {
+		matches++
+	}
+	if hasToolDefinitions {
+		matches++
+	}
+
+	switch {
+	case matches >= 3:
+		complexity = complexityHigh
+	case matches >= 1 && complexity < complexityMedium:
+		complexity = complexityMedium
+	}
+
+	return complexity
+}
+
+// capabilityScore estimates how well a model fits a prompt of the given complexity
+func capabilityScore(model models.ModelCapability, complexity float64) float64 {
+	level := tierLevel(model.Complexity)
+	if level == 0 {
+		// Unknown complexity: approximate from price, as pricier models tend to be more capable
+		level = complexityMedium
+		if avg := averageCost(model); avg > 0 {
+			level = math.Min(complexityHigh, complexityLow+math.Log10(1+avg)/2)
+		}
+	}
+
+	// Models at or above the required complexity score highly; weaker models are penalized
+	if level >= complexity {
+		return 1.0 - (level-complexity)*0.25
+	}
+	return 1.0 - (complexity-level)*1.5
+}
+
+// costScore normalizes the model's price into [0, 1], where 1 is the cheapest candidate
+func costScore(model models.ModelCapability, minCost, maxCost float64) float64 {
+	avg := averageCost(model)
+	if avg == 0 || maxCost <= minCost {
+		return 1.0
+	}
+	return 1.0 - (avg-minCost)/(maxCost-minCost)
+}
+
+// latencyScore maps a latency tier to [0, 1], where 1 is the fastest
+func latencyScore(tier string) float64 {
+	switch strings.ToLower(tier) {
+	case "very_low", "ultra_low", "fast", "low":
+		return 1.0
+	case "medium", "moderate", "":
+		return 0.5
+	case "high", "slow":
+		return 0.2
+	case "very_high":
+		return 0.0
+	default:
+		return 0.5
+	}
+}
+
+// tierLevel maps a textual tier ("low", "medium", "high") to a complexity level; 0 when unknown
+func tierLevel(tier string) float64 {
+	switch strings.ToLower(tier) {
+	case "easy", "low", "simple":
+		return complexityLow
+	case "medium", "moderate":
+		return complexityMedium
+	case "hard", "high", "complex":
+		return complexityHigh
+	default:
+		return 0
+	}
+}
+
+// averageCost returns the blended per-1M token price, weighting output tokens like a typical request
+func averageCost(model models.ModelCapability) float64 {
+	return (model.CostPer1MInputTokens*3 + model.CostPer1MOutputTokens) / 4
+}
+
+// costRange returns the minimum and maximum priced average cost among the candidates
+func costRange(candidates []models.ModelCapability) (float64, float64) {
+	minCost, maxCost := math.MaxFloat64, 0.0
+	for _, model := range candidates {
+		avg := averageCost(model)
+		if avg == 0 {
+			continue
+		}
+		minCost = math.Min(minCost, avg)
+		maxCost = math.Max(maxCost, avg)
+	}
+	if maxCost == 0 {
+		return 0, 0
+	}
+	return minCost, maxCost
+}
+
+// hasTools reports whether the tool definitions value contains at least one tool.
+// Tools arrive as SDK-specific slices boxed in an interface, so reflection is used
+// to treat typed nil and empty slices as "no tools".
+func hasTools(tools any) bool {
+	if tools == nil {
+		return false
+	}
+	v := reflect.ValueOf(tools)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() > 0
+	case reflect.Pointer, reflect.Interface:
+		return !v.IsNil()
+	default:
+		return true
+	}
+}
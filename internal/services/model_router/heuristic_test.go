@@ -0,0 +1,201 @@
+package model_router
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+)
+
+func TestHeuristicRouterSelectModel(t *testing.T) {
+	cheapest := float32(0)
+	mostCapable := float32(1)
+
+	// Providers are not in the global pricing table, so only the prices given here apply
+	model := func(name string, inputCost, outputCost float64, complexity string) models.ModelCapability {
+		return models.ModelCapability{
+			Provider:              "acme",
+			ModelName:             name,
+			CostPer1MInputTokens:  inputCost,
+			CostPer1MOutputTokens: outputCost,
+			Complexity:            complexity,
+		}
+	}
+	withContext := func(m models.ModelCapability, maxContextTokens int) models.ModelCapability {
+		m.MaxContextTokens = maxContextTokens
+		return m
+	}
+	withTools := func(m models.ModelCapability) models.ModelCapability {
+		m.SupportsToolCalling = true
+		return m
+	}
+	alternatives := func(names ...string) []models.Alternative {
+		var alts []models.Alternative
+		for _, name := range names {
+			alts = append(alts, models.Alternative{Provider: "acme", Model: name})
+		}
+		return alts
+	}
+
+	tests := []struct {
+		name             string
+		req              models.ModelSelectionRequest
+		wantOK           bool
+		wantModel        string
+		wantAlternatives []models.Alternative
+	}{
+		{
+			name: "cost bias 0 orders by price",
+			req: models.ModelSelectionRequest{
+				Prompt:   "hi",
+				CostBias: &cheapest,
+				Models: []models.ModelCapability{
+					model("large", 10, 30, "high"),
+					model("small", 0.1, 0.4, "low"),
+					model("medium", 1, 4, "medium"),
+				},
+			},
+			wantOK:           true,
+			wantModel:        "small",
+			wantAlternatives: alternatives("medium", "large"),
+		},
+		{
+			name: "cost bias 1 orders complex prompts by capability",
+			req: models.ModelSelectionRequest{
+				Prompt:   "Analyze this algorithm step by step and compare the options",
+				CostBias: &mostCapable,
+				Models: []models.ModelCapability{
+					model("small", 0.1, 0.4, "low"),
+					model("medium", 1, 4, "medium"),
+					model("large", 10, 30, "high"),
+				},
+			},
+			wantOK:           true,
+			wantModel:        "large",
+			wantAlternatives: alternatives("medium", "small"),
+		},
+		{
+			name: "unpriced model scores as the cheapest",
+			req: models.ModelSelectionRequest{
+				Prompt:   "hi",
+				CostBias: &cheapest,
+				Models: []models.ModelCapability{
+					model("expensive", 10, 10, ""),
+					model("cheap", 1, 1, ""),
+					model("unpriced", 0, 0, ""),
+				},
+			},
+			wantOK:           true,
+			wantModel:        "cheap",
+			wantAlternatives: alternatives("unpriced", "expensive"),
+		},
+		{
+			name: "model too small for the prompt is skipped",
+			req: models.ModelSelectionRequest{
+				Prompt:          "hi",
+				EstimatedTokens: 10000,
+				CostBias:        &cheapest,
+				Models: []models.ModelCapability{
+					withContext(model("tiny", 0.1, 0.1, ""), 4000),
+					model("unbounded", 10, 10, ""),
+				},
+			},
+			wantOK:    true,
+			wantModel: "unbounded",
+		},
+		{
+			name: "every model too small ranks by context size",
+			req: models.ModelSelectionRequest{
+				Prompt:          "hi",
+				EstimatedTokens: 10000,
+				Models: []models.ModelCapability{
+					withContext(model("small", 0.1, 0.1, ""), 4000),
+					withContext(model("large", 10, 10, ""), 8000),
+				},
+			},
+			wantOK:           true,
+			wantModel:        "large",
+			wantAlternatives: alternatives("small"),
+		},
+		{
+			name: "tools penalize models without tool calling",
+			req: models.ModelSelectionRequest{
+				Prompt:    "hi",
+				ToolCount: 1,
+				Models: []models.ModelCapability{
+					model("plain", 1, 1, "medium"),
+					withTools(model("tools", 2, 2, "medium")),
+				},
+			},
+			wantOK:           true,
+			wantModel:        "tools",
+			wantAlternatives: alternatives("plain"),
+		},
+		{
+			name: "ties keep configuration order and alternatives are capped",
+			req: models.ModelSelectionRequest{
+				Prompt: "hi",
+				Models: []models.ModelCapability{
+					model("a", 0, 0, ""),
+					model("b", 0, 0, ""),
+					model("c", 0, 0, ""),
+					model("d", 0, 0, ""),
+					model("e", 0, 0, ""),
+				},
+			},
+			wantOK:           true,
+			wantModel:        "a",
+			wantAlternatives: alternatives("b", "c", "d"),
+		},
+		{
+			name: "models without a name cannot be ranked",
+			req: models.ModelSelectionRequest{
+				Prompt: "hi",
+				Models: []models.ModelCapability{{Provider: "acme"}},
+			},
+			wantOK: false,
+		},
+	}
+
+	router := NewHeuristicRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, ok := router.SelectModel(tt.req)
+			if ok != tt.wantOK {
+				t.Fatalf("SelectModel() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if resp.Provider != "acme" || resp.Model != tt.wantModel {
+				t.Errorf("SelectModel() selected %s/%s, want acme/%s", resp.Provider, resp.Model, tt.wantModel)
+			}
+			if !reflect.DeepEqual(resp.Alternatives, tt.wantAlternatives) {
+				t.Errorf("SelectModel() alternatives = %v, want %v", resp.Alternatives, tt.wantAlternatives)
+			}
+		})
+	}
+}
+
+func TestCostScore(t *testing.T) {
+	tests := []struct {
+		name             string
+		model            models.ModelCapability
+		minCost, maxCost float64
+		want             float64
+	}{
+		{name: "cheapest", model: models.ModelCapability{CostPer1MInputTokens: 1, CostPer1MOutputTokens: 1}, minCost: 1, maxCost: 5, want: 1},
+		{name: "most expensive", model: models.ModelCapability{CostPer1MInputTokens: 5, CostPer1MOutputTokens: 5}, minCost: 1, maxCost: 5, want: 0},
+		{name: "midway", model: models.ModelCapability{CostPer1MInputTokens: 3, CostPer1MOutputTokens: 3}, minCost: 1, maxCost: 5, want: 0.5},
+		{name: "unpriced", model: models.ModelCapability{}, minCost: 1, maxCost: 5, want: 1},
+		{name: "single price", model: models.ModelCapability{CostPer1MInputTokens: 2, CostPer1MOutputTokens: 2}, minCost: 2, maxCost: 2, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := costScore(tt.model, tt.minCost, tt.maxCost); got != tt.want {
+				t.Errorf("costScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,25 +2,44 @@ package model_router
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	fiberlog "github.com/gofiber/fiber/v2/log"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	// ErrContextTooLong is returned when no candidate model can hold the request's input and requested output
+	ErrContextTooLong = errors.New("context too long")
+	// ErrUnsupportedFeature is returned when no candidate model supports a feature the request needs
+	ErrUnsupportedFeature = errors.New("unsupported feature")
+	// ErrNoCandidates is returned when there is no candidate model left to select from
+	ErrNoCandidates = errors.New("no candidate models")
+)
+
 // ModelRouter coordinates protocol selection and caching for model selection.
 type ModelRouter struct {
-	cache  *ModelRouterCache
-	client *ModelRouterClient
-	cfg    *config.Config
+	cache      *ModelRouterCache
+	client     *ModelRouterClient
+	cfg        *config.Config
+	stats      *provider_stats.Tracker
+	rules      *RuleEngine
+	cacheEntry *cacheEntryIndex
 }
 
 // NewModelRouter creates a new ModelRouter with cache configuration.
-func NewModelRouter(cfg *config.Config, redisClient *redis.Client) (*ModelRouter, error) {
+// stats is optional; when set, degraded models are demoted in the returned selection.
+func NewModelRouter(cfg *config.Config, redisClient *redis.Client, stats *provider_stats.Tracker) (*ModelRouter, error) {
 	// ModelRouter is optional
 	if cfg.ModelRouter == nil {
 		return nil, fmt.Errorf("ModelRouter not configured")
@@ -37,13 +56,37 @@ func NewModelRouter(cfg *config.Config, redisClient *redis.Client) (*ModelRouter
 		return nil, err
 	}
 
+	rules, err := NewRuleEngine(cfg.RoutingRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing_rules: %w", err)
+	}
+
+	if err := validateAliases(cfg.ModelAliases); err != nil {
+		return nil, fmt.Errorf("invalid model_aliases: %w", err)
+	}
+
+	if err := validateExperiments(cfg.Experiments, cfg.ModelAliases); err != nil {
+		return nil, fmt.Errorf("invalid experiments: %w", err)
+	}
+
+	if err := validateCacheScope(cfg.ModelRouter.Cache.Scope); err != nil {
+		return nil, fmt.Errorf("invalid model_router cache: %w", err)
+	}
+
+	if err := utils.ValidatePromptExtraction(cfg.ModelRouter.PromptExtraction); err != nil {
+		return nil, fmt.Errorf("invalid prompt_extraction: %w", err)
+	}
+
 	client := NewModelRouterClient(cfg, redisClient)
 	fiberlog.Info("ModelRouter: Client initialized successfully")
 
 	return &ModelRouter{
-		cache:  cache,
-		client: client,
-		cfg:    cfg,
+		cache:      cache,
+		client:     client,
+		cfg:        cfg,
+		stats:      stats,
+		rules:      rules,
+		cacheEntry: newCacheEntryIndex(redisClient),
 	}, nil
 }
 
@@ -58,11 +101,23 @@ func (pm *ModelRouter) SelectModelWithCache(
 	cbs map[string]*circuitbreaker.CircuitBreaker,
 	tools any,
 	toolCall any,
+	profile *models.RequestProfile,
 ) (*models.ModelSelectionResponse, string, error) {
 	fiberlog.Infof("[%s] ═══ Model Selection Started ═══", requestID)
 	fiberlog.Infof("[%s] User: %s | Prompt length: %d chars | Cost bias: %.2f",
 		requestID, userID, len(prompt), modelRouterConfig.CostBias)
 
+	trace := routing_trace.FromContext(ctx)
+	start := time.Now()
+	defer func() { trace.SetRouterLatency(time.Since(start)) }()
+	trace.Consider(modelRouterConfig.Models)
+
+	// Drop models that cannot hold the request or lack a feature it needs before consulting cache or router
+	excluded, err := pm.filterIncompatible(modelRouterConfig, profile, trace, requestID)
+	if err != nil {
+		return nil, "", err
+	}
+
 	cacheConfigOverride := modelRouterConfig.Cache
 
 	// 1) Check if cache should be used (either default cache or override config)
@@ -71,12 +126,16 @@ func (pm *ModelRouter) SelectModelWithCache(
 		fiberlog.Infof("[%s] 🔍 Cache enabled - checking semantic cache (threshold: %.2f)",
 			requestID, cacheConfigOverride.SemanticThreshold)
 
-		cacheResult := pm.lookupCache(ctx, prompt, requestID, cacheConfigOverride, cbs)
+		cacheResult := pm.lookupCache(ctx, CacheNamespace(ctx), prompt, requestID, cacheConfigOverride, cbs, candidateFilter(modelRouterConfig, excluded), trace)
 		if cacheResult.Hit {
 			fiberlog.Infof("[%s] ✅ CACHE HIT (%s) - serving from cache: %s/%s",
 				requestID, cacheResult.Source, cacheResult.Response.Provider, cacheResult.Response.Model)
 			fiberlog.Infof("[%s] ═══ Model Selection Complete (Cache) ═══", requestID)
-			return cacheResult.Response, cacheResult.Source, nil
+			trace.SetCache(cacheResult.Source, cacheResult.Similarity)
+			pm.cacheEntry.remember(requestID, cacheResult.Key)
+			resp := pm.rankByPerformance(cacheResult.Response, requestID)
+			trace.Select(resp.Provider, resp.Model)
+			return resp, cacheResult.Source, nil
 		}
 		fiberlog.Infof("[%s] ❌ Cache miss - proceeding to AI service", requestID)
 	} else {
@@ -92,7 +151,7 @@ func (pm *ModelRouter) SelectModelWithCache(
 
 	// Filter out providers with open circuit breakers if circuit breakers are available
 	if cbs != nil && modelRouterConfig != nil {
-		pm.filterUnavailableProviders(modelRouterConfig, cbs, requestID)
+		pm.filterUnavailableProviders(modelRouterConfig, cbs, trace, requestID)
 	}
 
 	req := models.ModelSelectionRequest{
@@ -103,7 +162,23 @@ func (pm *ModelRouter) SelectModelWithCache(
 		Models:   modelRouterConfig.Models,
 		CostBias: &modelRouterConfig.CostBias,
 	}
-	resp := pm.client.SelectModel(ctx, req)
+	if profile != nil {
+		req.TurnCount = profile.TurnCount
+		req.EstimatedTokens = profile.EstimatedInputTokens
+		req.Modalities = profile.Modalities
+		req.ToolCount = profile.ToolCount
+	}
+	selected, err := pm.client.SelectModel(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := enforceRestriction(&selected, modelRouterConfig, trace, requestID)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp = dropExcluded(resp, excluded, requestID); resp == nil {
+		return nil, "", fmt.Errorf("%w: the router only selected incompatible models", ErrUnsupportedFeature)
+	}
 
 	// Log detailed model selection response
 	fiberlog.Infof("[%s] ✅ AI service selected PRIMARY: %s/%s",
@@ -121,7 +196,40 @@ func (pm *ModelRouter) SelectModelWithCache(
 
 	fiberlog.Infof("[%s] ═══ Model Selection Complete (AI Service) ═══", requestID)
 
-	return &resp, "", nil
+	resp = pm.rankByPerformance(resp, requestID)
+	trace.Select(resp.Provider, resp.Model)
+	return resp, "", nil
+}
+
+// PromptExtraction returns the configured strategy for reducing conversations to the routing prompt.
+// Routing and cache storage must use the same strategy so cache entries match later lookups.
+func (pm *ModelRouter) PromptExtraction() models.PromptExtractionConfig {
+	if pm == nil || pm.cfg.ModelRouter == nil {
+		return models.PromptExtractionConfig{}
+	}
+	return pm.cfg.ModelRouter.PromptExtraction
+}
+
+// rankByPerformance reorders the primary and alternatives using observed latency and error rates,
+// so degraded models are tried last instead of waiting for their circuit breaker to open
+func (pm *ModelRouter) rankByPerformance(resp *models.ModelSelectionResponse, requestID string) *models.ModelSelectionResponse {
+	if pm.stats == nil || resp == nil || len(resp.Alternatives) == 0 {
+		return resp
+	}
+
+	candidates := append([]models.Alternative{{Provider: resp.Provider, Model: resp.Model}}, resp.Alternatives...)
+	ranked := pm.stats.Rank(candidates, requestID)
+
+	if ranked[0].Provider != resp.Provider || ranked[0].Model != resp.Model {
+		fiberlog.Infof("[%s] 🔀 Primary %s/%s degraded, promoting %s/%s",
+			requestID, resp.Provider, resp.Model, ranked[0].Provider, ranked[0].Model)
+	}
+
+	return &models.ModelSelectionResponse{
+		Provider:     ranked[0].Provider,
+		Model:        ranked[0].Model,
+		Alternatives: ranked[1:],
+	}
 }
 
 // StoreSuccessfulModel stores a model response in the semantic cache (fire-and-forget)
@@ -135,7 +243,8 @@ func (pm *ModelRouter) StoreSuccessfulModel(
 	if pm.cache != nil && (modelRouterConfig == nil || modelRouterConfig.Cache.Enabled) {
 		fiberlog.Infof("[%s] 💾 Storing successful response in cache: %s/%s",
 			requestID, resp.Provider, resp.Model)
-		pm.cache.StoreAsync(ctx, prompt, resp, requestID)
+		key := pm.cache.StoreAsync(ctx, CacheNamespace(ctx), prompt, resp, requestID)
+		pm.cacheEntry.remember(requestID, key)
 	} else {
 		fiberlog.Debugf("[%s] ⏭️  Skipping cache storage (cache disabled or unavailable)", requestID)
 	}
@@ -161,10 +270,122 @@ func (pm *ModelRouter) ValidateContext() error {
 	return nil
 }
 
+// filterIncompatible removes models that cannot hold the request's estimated input plus requested output,
+// or that lack a feature the request needs (vision, audio, structured outputs, ...).
+// It returns the set of excluded candidate keys so cached and router-selected alternatives can be filtered
+// the same way, or ErrContextTooLong / ErrUnsupportedFeature when every configured model was excluded.
+func (pm *ModelRouter) filterIncompatible(
+	config *models.ModelRouterConfig,
+	profile *models.RequestProfile,
+	trace *routing_trace.Recorder,
+	requestID string,
+) (map[string]bool, error) {
+	if config == nil || profile == nil || len(config.Models) == 0 {
+		return nil, nil
+	}
+
+	excluded := make(map[string]bool)
+	compatible := make([]models.ModelCapability, 0, len(config.Models))
+	var missingFeature string
+	for _, model := range config.Models {
+		if !profile.Fits(model) {
+			fiberlog.Warnf("[%s] 🚫 Filtering out %s/%s (context: %d, max output: %d; request needs ~%d input + %d output tokens)",
+				requestID, model.Provider, model.ModelName, model.MaxContextTokens, model.MaxOutputTokens,
+				profile.EstimatedInputTokens, profile.MaxOutputTokens)
+			excluded[candidateKey(model.Provider, model.ModelName)] = true
+			trace.Filter(model.Provider, model.ModelName, models.FilterReasonContextWindow)
+			continue
+		}
+		if feature := profile.UnsupportedFeature(model); feature != "" {
+			fiberlog.Warnf("[%s] 🚫 Filtering out %s/%s: request needs %s", requestID, model.Provider, model.ModelName, feature)
+			excluded[candidateKey(model.Provider, model.ModelName)] = true
+			trace.Filter(model.Provider, model.ModelName, models.FilterReasonUnsupported+":"+feature)
+			missingFeature = feature
+			continue
+		}
+		compatible = append(compatible, model)
+	}
+
+	if len(compatible) == 0 {
+		if missingFeature != "" {
+			return excluded, fmt.Errorf("%w: no available model supports %s", ErrUnsupportedFeature, strings.Join(profile.Features, ", "))
+		}
+		return excluded, fmt.Errorf("%w: request needs ~%d input tokens and %d output tokens, which exceeds the context window of every available model",
+			ErrContextTooLong, profile.EstimatedInputTokens, profile.MaxOutputTokens)
+	}
+
+	config.Models = compatible
+	return excluded, nil
+}
+
+// dropExcluded removes excluded candidates from a router selection, promoting the first remaining
+// alternative when the primary was excluded. It returns nil when nothing remains.
+func dropExcluded(resp *models.ModelSelectionResponse, excluded map[string]bool, requestID string) *models.ModelSelectionResponse {
+	if len(excluded) == 0 {
+		return resp
+	}
+	var kept []models.Alternative
+	for _, candidate := range append([]models.Alternative{{Provider: resp.Provider, Model: resp.Model}}, resp.Alternatives...) {
+		if isExcluded(excluded, candidate) {
+			fiberlog.Warnf("[%s] 🚫 Dropping %s/%s from router selection: incompatible with the request",
+				requestID, candidate.Provider, candidate.Model)
+			continue
+		}
+		kept = append(kept, candidate)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return &models.ModelSelectionResponse{
+		Provider:     kept[0].Provider,
+		Model:        kept[0].Model,
+		Alternatives: kept[1:],
+	}
+}
+
+// candidateKey builds the lookup key for a provider/model pair
+func candidateKey(provider, model string) string {
+	return provider + "/" + model
+}
+
+// isExcluded reports whether a candidate was excluded, either by exact model or by a provider-wide entry
+func isExcluded(excluded map[string]bool, candidate models.Alternative) bool {
+	if len(excluded) == 0 {
+		return false
+	}
+	return excluded[candidateKey(candidate.Provider, candidate.Model)] || excluded[candidateKey(candidate.Provider, "")]
+}
+
+// isConfigured reports whether a candidate is part of the configured models,
+// either by exact model or through a provider-only entry
+func isConfigured(configured []models.ModelCapability, candidate models.Alternative) bool {
+	for _, model := range configured {
+		if model.Provider == candidate.Provider && (model.ModelName == "" || model.ModelName == candidate.Model) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateFilter returns a predicate accepting cached candidates that were not excluded and
+// remain in the request's candidate set (after routing rules and context-window filtering)
+func candidateFilter(config *models.ModelRouterConfig, excluded map[string]bool) func(models.Alternative) bool {
+	return func(candidate models.Alternative) bool {
+		if isExcluded(excluded, candidate) {
+			return false
+		}
+		if config != nil && len(config.Models) > 0 && !isConfigured(config.Models, candidate) {
+			return false
+		}
+		return true
+	}
+}
+
 // filterUnavailableProviders removes providers with open circuit breakers from the model list
 func (pm *ModelRouter) filterUnavailableProviders(
 	config *models.ModelRouterConfig,
 	cbs map[string]*circuitbreaker.CircuitBreaker,
+	trace *routing_trace.Recorder,
 	requestID string,
 ) {
 	if config == nil || config.Models == nil {
@@ -181,6 +402,7 @@ func (pm *ModelRouter) filterUnavailableProviders(
 			fiberlog.Warnf("[%s] 🚫 Filtering out provider %s/%s (circuit breaker open)",
 				requestID, providerName, model.ModelName)
 			filteredProviders = append(filteredProviders, providerName)
+			trace.Filter(providerName, model.ModelName, models.FilterReasonCircuitBreaker)
 			continue
 		}
 		availableModels = append(availableModels, model)
@@ -232,7 +454,7 @@ func createCacheIfEnabled(cfg *config.Config, semanticCacheConfig models.CacheCo
 }
 
 // lookupCache performs cache lookup with circuit breaker validation (synchronous reads)
-func (pm *ModelRouter) lookupCache(ctx context.Context, prompt, requestID string, cacheConfig models.CacheConfig, cbs map[string]*circuitbreaker.CircuitBreaker) models.CacheResult {
+func (pm *ModelRouter) lookupCache(ctx context.Context, namespace, prompt, requestID string, cacheConfig models.CacheConfig, cbs map[string]*circuitbreaker.CircuitBreaker, allowed func(models.Alternative) bool, trace *routing_trace.Recorder) models.CacheResult {
 	threshold := pm.cache.semanticThreshold
 	if cacheConfig.SemanticThreshold > 0 {
 		threshold = float32(cacheConfig.SemanticThreshold)
@@ -241,7 +463,7 @@ func (pm *ModelRouter) lookupCache(ctx context.Context, prompt, requestID string
 	}
 
 	fiberlog.Debugf("[%s] Performing cache lookup with threshold: %.2f", requestID, threshold)
-	cachedResponse, source, found := pm.cache.Lookup(ctx, prompt, requestID, threshold)
+	cachedResponse, source, similarity, found := pm.cache.Lookup(ctx, namespace, prompt, requestID, threshold)
 	if !found {
 		fiberlog.Debugf("[%s] No matching entry found in cache", requestID)
 		return models.CacheResult{Hit: false}
@@ -250,11 +472,23 @@ func (pm *ModelRouter) lookupCache(ctx context.Context, prompt, requestID string
 	fiberlog.Infof("[%s] Found cache entry from %s: %s/%s",
 		requestID, source, cachedResponse.Provider, cachedResponse.Model)
 
-	validResponse := pm.selectAvailableModel(cachedResponse, cbs, requestID)
+	// Semantic hits are stored under another prompt
+	key := cachedResponse.CacheKey
+	if key == "" {
+		key = entryKey(namespace, prompt)
+	}
+
+	validResponse, filtered := pm.selectAvailableModel(cachedResponse, cbs, allowed, trace, requestID)
 	if validResponse == nil {
+		if filtered {
+			// The entry may still be valid for other requests, so keep it and treat this lookup as a miss
+			fiberlog.Warnf("[%s] ⚠️  No cached model permitted for this request (context window, routing rules or circuit breakers)",
+				requestID)
+			return models.CacheResult{Hit: false}
+		}
 		fiberlog.Warnf("[%s] ⚠️  All cached models unavailable (circuit breakers open) - invalidating cache entry",
 			requestID)
-		pm.cache.DeleteAsync(ctx, prompt, cachedResponse.Provider, requestID)
+		pm.cache.DeleteAsync(ctx, key, cachedResponse.Provider, requestID)
 		return models.CacheResult{Hit: false}
 	}
 
@@ -264,28 +498,40 @@ func (pm *ModelRouter) lookupCache(ctx context.Context, prompt, requestID string
 	}
 
 	return models.CacheResult{
-		Response: validResponse,
-		Source:   source,
-		Hit:      true,
+		Response:   validResponse,
+		Source:     source,
+		Similarity: similarity,
+		Key:        key,
+		Hit:        true,
 	}
 }
 
-// selectAvailableModel finds the first available model from cached response considering circuit breakers
-func (pm *ModelRouter) selectAvailableModel(cachedResponse *models.ModelSelectionResponse, cbs map[string]*circuitbreaker.CircuitBreaker, requestID string) *models.ModelSelectionResponse {
+// selectAvailableModel finds the first available model from cached response considering circuit breakers.
+// The second return value reports whether any cached candidate was rejected by the allowed predicate.
+func (pm *ModelRouter) selectAvailableModel(cachedResponse *models.ModelSelectionResponse, cbs map[string]*circuitbreaker.CircuitBreaker, allowed func(models.Alternative) bool, trace *routing_trace.Recorder, requestID string) (*models.ModelSelectionResponse, bool) {
 	if cachedResponse == nil {
-		return nil
+		return nil, false
 	}
 
-	// Build a list of all potential models (primary + alternatives)
-	candidates := []models.Alternative{
+	// Build a list of all potential models (primary + alternatives), skipping ones not permitted for this request
+	filtered := false
+	candidates := make([]models.Alternative, 0, len(cachedResponse.Alternatives)+1)
+	for _, candidate := range append([]models.Alternative{
 		{Provider: cachedResponse.Provider, Model: cachedResponse.Model},
+	}, cachedResponse.Alternatives...) {
+		if allowed != nil && !allowed(candidate) {
+			fiberlog.Debugf("[%s] 🚫 Cached model %s/%s not permitted for this request",
+				requestID, candidate.Provider, candidate.Model)
+			filtered = true
+			continue
+		}
+		candidates = append(candidates, candidate)
 	}
-	candidates = append(candidates, cachedResponse.Alternatives...)
 
 	// Find first available model
-	availableIdx := pm.findFirstAvailableModel(candidates, cbs, requestID)
+	availableIdx := pm.findFirstAvailableModel(candidates, cbs, trace, requestID)
 	if availableIdx == -1 {
-		return nil
+		return nil, filtered
 	}
 
 	selected := candidates[availableIdx]
@@ -295,11 +541,11 @@ func (pm *ModelRouter) selectAvailableModel(cachedResponse *models.ModelSelectio
 		Provider:     selected.Provider,
 		Model:        selected.Model,
 		Alternatives: alternatives,
-	}
+	}, filtered
 }
 
 // findFirstAvailableModel returns the index of the first available model, or -1 if none are available
-func (pm *ModelRouter) findFirstAvailableModel(candidates []models.Alternative, cbs map[string]*circuitbreaker.CircuitBreaker, requestID string) int {
+func (pm *ModelRouter) findFirstAvailableModel(candidates []models.Alternative, cbs map[string]*circuitbreaker.CircuitBreaker, trace *routing_trace.Recorder, requestID string) int {
 	for i, candidate := range candidates {
 		if pm.isModelAvailable(candidate.Provider, cbs) {
 			if i > 0 {
@@ -313,6 +559,7 @@ func (pm *ModelRouter) findFirstAvailableModel(candidates []models.Alternative,
 		}
 		fiberlog.Debugf("[%s] 🚫 Model %s/%s unavailable (circuit breaker)",
 			requestID, candidate.Provider, candidate.Model)
+		trace.Filter(candidate.Provider, candidate.Model, models.FilterReasonCircuitBreaker)
 	}
 	return -1
 }
@@ -0,0 +1,285 @@
+package model_router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// ErrModelNotAllowed is returned when a routing rule forbids the requested model
+var ErrModelNotAllowed = errors.New("model not allowed by routing rule")
+
+// compiledRule pairs a routing rule with its compiled prompt regex
+type compiledRule struct {
+	rule        models.RoutingRule
+	promptRegex *regexp.Regexp
+}
+
+// RuleEngine evaluates routing_rules in order; the first matching rule wins
+type RuleEngine struct {
+	rules []compiledRule
+}
+
+// NewRuleEngine validates and compiles routing rules
+func NewRuleEngine(rules []models.RoutingRule) (*RuleEngine, error) {
+	engine := &RuleEngine{rules: make([]compiledRule, 0, len(rules))}
+	for i, rule := range rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			rule.Name = name
+		}
+
+		compiled := compiledRule{rule: rule}
+		if rule.Match.PromptRegex != "" {
+			re, err := regexp.Compile(rule.Match.PromptRegex)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %s: invalid prompt_regex: %w", name, err)
+			}
+			compiled.promptRegex = re
+		}
+		if rule.Model != "" {
+			if _, _, err := utils.ParseProviderModel(rule.Model); err != nil {
+				return nil, fmt.Errorf("routing rule %s: model must be in provider:model format: %w", name, err)
+			}
+		}
+		for _, allowed := range rule.AllowedModels {
+			if !strings.Contains(allowed, ":") {
+				return nil, fmt.Errorf("routing rule %s: allowed_models entry %q must be in provider:model format", name, allowed)
+			}
+		}
+		if rule.CostBias != nil && (*rule.CostBias < 0 || *rule.CostBias > 1) {
+			return nil, fmt.Errorf("routing rule %s: cost_bias must be between 0.0 and 1.0", name)
+		}
+		switch rule.FallbackMode {
+		case "", models.FallbackModeSequential, models.FallbackModeRace, models.FallbackModeHedge:
+		default:
+			return nil, fmt.Errorf("routing rule %s: unknown fallback_mode %q", name, rule.FallbackMode)
+		}
+
+		engine.rules = append(engine.rules, compiled)
+	}
+
+	if len(engine.rules) > 0 {
+		fiberlog.Infof("ModelRouter: Loaded %d routing rules", len(engine.rules))
+	}
+	return engine, nil
+}
+
+// Match returns the first rule matching the routing context, or nil
+func (e *RuleEngine) Match(rc models.RoutingContext) *models.RoutingRule {
+	if e == nil {
+		return nil
+	}
+	for i := range e.rules {
+		if e.rules[i].matches(rc) {
+			return &e.rules[i].rule
+		}
+	}
+	return nil
+}
+
+// matches reports whether every condition set on the rule holds for the context
+func (r *compiledRule) matches(rc models.RoutingContext) bool {
+	m := r.rule.Match
+
+	if len(m.Endpoints) > 0 && !slices.Contains(m.Endpoints, rc.Endpoint) {
+		return false
+	}
+	if len(m.APIKeyIDs) > 0 && !slices.Contains(m.APIKeyIDs, rc.APIKeyID) {
+		return false
+	}
+	if len(m.APIKeyPrefixes) > 0 && !slices.ContainsFunc(m.APIKeyPrefixes, func(prefix string) bool {
+		return rc.APIKeyPrefix != "" && strings.HasPrefix(rc.APIKeyPrefix, prefix)
+	}) {
+		return false
+	}
+	if len(m.ProjectIDs) > 0 && !slices.Contains(m.ProjectIDs, rc.ProjectID) {
+		return false
+	}
+	if len(m.OrganizationIDs) > 0 && !slices.Contains(m.OrganizationIDs, rc.OrganizationID) {
+		return false
+	}
+	for key, value := range m.Metadata {
+		if actual, ok := rc.Metadata[key]; !ok || (value != "*" && actual != value) {
+			return false
+		}
+	}
+	if m.HasTools != nil && *m.HasTools != rc.HasTools {
+		return false
+	}
+	if m.MinTokens > 0 && rc.EstimatedTokens < m.MinTokens {
+		return false
+	}
+	if m.MaxTokens > 0 && rc.EstimatedTokens > m.MaxTokens {
+		return false
+	}
+	if r.promptRegex != nil && !r.promptRegex.MatchString(rc.Prompt) {
+		return false
+	}
+	return true
+}
+
+// NewRoutingContext builds a routing context for the endpoint with the caller's API key,
+// project and organization taken from the auth context
+func NewRoutingContext(c *fiber.Ctx, endpoint string) models.RoutingContext {
+	rc := models.RoutingContext{Endpoint: endpoint}
+	if apiKey, ok := auth.GetAPIKey(c); ok && apiKey != nil {
+		rc.APIKeyID = apiKey.ID
+		rc.APIKeyPrefix = apiKey.KeyPrefix
+		rc.ProjectID = apiKey.ProjectID
+		rc.OrganizationID = apiKey.OrganizationID
+	}
+	if projectID, ok := auth.GetProjectID(c); ok {
+		rc.ProjectID = projectID
+	}
+	if organizationID, ok := auth.GetOrganizationID(c); ok {
+		rc.OrganizationID = organizationID
+	}
+	return rc
+}
+
+// matchedRuleKey is the context key of the routing rule matched for the request
+type matchedRuleKey struct{}
+
+// WithRule returns ctx with the request's matched routing rule attached, so calls made outside
+// routing, such as shadow mirroring, can honour its candidate restriction
+func WithRule(ctx context.Context, rule *models.RoutingRule) context.Context {
+	return context.WithValue(ctx, matchedRuleKey{}, rule)
+}
+
+// RuleFromContext returns the routing rule attached by WithRule, or nil when none matched
+func RuleFromContext(ctx context.Context) *models.RoutingRule {
+	rule, _ := ctx.Value(matchedRuleKey{}).(*models.RoutingRule)
+	return rule
+}
+
+// ApplyRoutingRules evaluates routing rules for the request and applies the matching rule's
+// candidate restrictions and cost bias to routerConfig. It returns the matched rule (nil when
+// none matched) and the model to use: the rule's pinned model, or requestedModel.
+// ErrModelNotAllowed is returned when an explicitly requested model violates the rule.
+// The matched rule and the candidates it removes are recorded in the routing trace of ctx.
+func (pm *ModelRouter) ApplyRoutingRules(
+	ctx context.Context,
+	rc models.RoutingContext,
+	requestedModel string,
+	routerConfig *models.ModelRouterConfig,
+	requestID string,
+) (*models.RoutingRule, string, error) {
+	rule := pm.rules.Match(rc)
+	if rule == nil {
+		return nil, requestedModel, nil
+	}
+	fiberlog.Infof("[%s] 📜 Routing rule %q matched (endpoint: %s)", requestID, rule.Name, rc.Endpoint)
+	trace := routing_trace.FromContext(ctx)
+	trace.SetRule(rule.Name)
+
+	if rule.Model != "" {
+		fiberlog.Infof("[%s] 📌 Routing rule %q pins model %s", requestID, rule.Name, rule.Model)
+		return rule, rule.Model, nil
+	}
+
+	if requestedModel != "" && rule.RestrictsCandidates() {
+		// Only provider:model specs are explicit; anything else goes through the (restricted) router
+		if provider, model, err := utils.ParseProviderModel(requestedModel); err == nil && !rule.AllowsModel(provider, model) {
+			return rule, "", fmt.Errorf("%w %q: %s:%s is not permitted", ErrModelNotAllowed, rule.Name, provider, model)
+		}
+	}
+
+	if routerConfig != nil {
+		if rule.CostBias != nil {
+			fiberlog.Debugf("[%s] Routing rule %q overrides cost_bias %.2f -> %.2f",
+				requestID, rule.Name, routerConfig.CostBias, *rule.CostBias)
+			routerConfig.CostBias = *rule.CostBias
+		}
+		if rule.RestrictsCandidates() {
+			trace.Consider(routerConfig.Models)
+			routerConfig.Models = restrictCandidates(rule, routerConfig.Models, trace)
+			routerConfig.RestrictedBy = rule.Name
+			if len(routerConfig.Models) == 0 {
+				return rule, "", fmt.Errorf("%w %q: no configured model is permitted", ErrModelNotAllowed, rule.Name)
+			}
+			fiberlog.Infof("[%s] Routing rule %q restricts candidates to %d models", requestID, rule.Name, len(routerConfig.Models))
+		}
+	}
+
+	return rule, requestedModel, nil
+}
+
+// restrictCandidates keeps only the models the rule allows. Provider-only entries are kept when the
+// whole provider is allowed, or expanded into the rule's explicitly allowed models for that provider.
+func restrictCandidates(rule *models.RoutingRule, candidates []models.ModelCapability, trace *routing_trace.Recorder) []models.ModelCapability {
+	restricted := make([]models.ModelCapability, 0, len(candidates))
+	for _, model := range candidates {
+		if model.ModelName != "" {
+			if rule.AllowsModel(model.Provider, model.ModelName) {
+				restricted = append(restricted, model)
+			} else {
+				trace.Filter(model.Provider, model.ModelName, models.FilterReasonRoutingRule)
+			}
+			continue
+		}
+		if rule.AllowsProvider(model.Provider) {
+			restricted = append(restricted, model)
+			continue
+		}
+		allowed := rule.AllowedModelsFor(model.Provider)
+		if len(allowed) == 0 {
+			trace.Filter(model.Provider, "", models.FilterReasonRoutingRule)
+		}
+		for _, name := range allowed {
+			expanded := model
+			expanded.ModelName = name
+			restricted = append(restricted, expanded)
+		}
+	}
+	return restricted
+}
+
+// enforceRestriction drops router selections outside the restricted candidate set. When the router
+// picked nothing permitted, the first explicitly configured model is used instead.
+func enforceRestriction(resp *models.ModelSelectionResponse, config *models.ModelRouterConfig, trace *routing_trace.Recorder, requestID string) (*models.ModelSelectionResponse, error) {
+	if config == nil || config.RestrictedBy == "" {
+		return resp, nil
+	}
+
+	permitted := make([]models.Alternative, 0, len(resp.Alternatives)+1)
+	for _, candidate := range append([]models.Alternative{{Provider: resp.Provider, Model: resp.Model}}, resp.Alternatives...) {
+		if isConfigured(config.Models, candidate) {
+			permitted = append(permitted, candidate)
+			continue
+		}
+		fiberlog.Warnf("[%s] 🚫 Dropping %s/%s: not permitted by routing rule %q",
+			requestID, candidate.Provider, candidate.Model, config.RestrictedBy)
+		trace.Filter(candidate.Provider, candidate.Model, models.FilterReasonRoutingRule)
+	}
+
+	if len(permitted) == 0 {
+		for _, model := range config.Models {
+			if model.ModelName != "" {
+				permitted = append(permitted, models.Alternative{Provider: model.Provider, Model: model.ModelName})
+				break
+			}
+		}
+	}
+	if len(permitted) == 0 {
+		return nil, fmt.Errorf("%w %q: the router did not select a permitted model", ErrModelNotAllowed, config.RestrictedBy)
+	}
+
+	return &models.ModelSelectionResponse{
+		Provider:     permitted[0].Provider,
+		Model:        permitted[0].Model,
+		Alternatives: permitted[1:],
+	}, nil
+}
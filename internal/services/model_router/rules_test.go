@@ -0,0 +1,387 @@
+package model_router
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+)
+
+func TestNewRuleEngine(t *testing.T) {
+	invalidBias := float32(1.5)
+
+	tests := []struct {
+		name    string
+		rule    models.RoutingRule
+		wantErr bool
+	}{
+		{name: "valid", rule: models.RoutingRule{Model: "openai:gpt-4o", Match: models.RoutingRuleMatch{PromptRegex: "^sql"}}},
+		{name: "invalid prompt regex", rule: models.RoutingRule{Match: models.RoutingRuleMatch{PromptRegex: "("}}, wantErr: true},
+		{name: "model without provider", rule: models.RoutingRule{Model: "gpt-4o"}, wantErr: true},
+		{name: "allowed model without provider", rule: models.RoutingRule{AllowedModels: []string{"gpt-4o"}}, wantErr: true},
+		{name: "cost bias out of range", rule: models.RoutingRule{CostBias: &invalidBias}, wantErr: true},
+		{name: "unknown fallback mode", rule: models.RoutingRule{FallbackMode: "parallel"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRuleEngine([]models.RoutingRule{tt.rule})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRuleEngine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleEngineMatch(t *testing.T) {
+	hasTools := true
+	engine, err := NewRuleEngine([]models.RoutingRule{
+		{Name: "messages-only", Match: models.RoutingRuleMatch{Endpoints: []string{"messages"}, MinTokens: 1000}},
+		{Name: "team-key", Match: models.RoutingRuleMatch{APIKeyPrefixes: []string{"sk-team"}}},
+		{Name: "tagged", Match: models.RoutingRuleMatch{Metadata: map[string]string{"tier": "free", "team": "*"}}},
+		{Name: "tools", Match: models.RoutingRuleMatch{HasTools: &hasTools, ProjectIDs: []uint{7}}},
+		{Name: "sql", Match: models.RoutingRuleMatch{PromptRegex: `(?i)\bselect\b`, MaxTokens: 500}},
+		{}, // catch-all, named after its position
+	})
+	if err != nil {
+		t.Fatalf("NewRuleEngine() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		rc   models.RoutingContext
+		want string
+	}{
+		{
+			name: "endpoint and token bounds",
+			rc:   models.RoutingContext{Endpoint: "messages", EstimatedTokens: 1500},
+			want: "messages-only",
+		},
+		{
+			name: "token bound not met falls through",
+			rc:   models.RoutingContext{Endpoint: "messages", EstimatedTokens: 999},
+			want: "#6",
+		},
+		{
+			name: "api key prefix",
+			rc:   models.RoutingContext{Endpoint: "chat_completions", APIKeyPrefix: "sk-team-1234"},
+			want: "team-key",
+		},
+		{
+			name: "metadata with wildcard",
+			rc:   models.RoutingContext{Metadata: map[string]string{"tier": "free", "team": "search"}},
+			want: "tagged",
+		},
+		{
+			name: "metadata missing a key",
+			rc:   models.RoutingContext{Metadata: map[string]string{"tier": "free"}},
+			want: "#6",
+		},
+		{
+			name: "every condition must hold",
+			rc:   models.RoutingContext{HasTools: true, ProjectID: 8},
+			want: "#6",
+		},
+		{
+			name: "tools and project",
+			rc:   models.RoutingContext{HasTools: true, ProjectID: 7},
+			want: "tools",
+		},
+		{
+			name: "prompt regex",
+			rc:   models.RoutingContext{Prompt: "SELECT * FROM users", EstimatedTokens: 10},
+			want: "sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := engine.Match(tt.rc)
+			if rule == nil {
+				t.Fatalf("Match() = nil, want %q", tt.want)
+			}
+			if rule.Name != tt.want {
+				t.Errorf("Match() = %q, want %q", rule.Name, tt.want)
+			}
+		})
+	}
+
+	t.Run("no rule matches", func(t *testing.T) {
+		engine, err := NewRuleEngine([]models.RoutingRule{{Match: models.RoutingRuleMatch{Endpoints: []string{"generate"}}}})
+		if err != nil {
+			t.Fatalf("NewRuleEngine() error = %v", err)
+		}
+		if rule := engine.Match(models.RoutingContext{Endpoint: "messages"}); rule != nil {
+			t.Errorf("Match() = %q, want nil", rule.Name)
+		}
+	})
+}
+
+func TestRestrictCandidates(t *testing.T) {
+	candidates := []models.ModelCapability{
+		{Provider: "openai", ModelName: "gpt-4o"},
+		{Provider: "openai", ModelName: "gpt-4o-mini"},
+		{Provider: "anthropic"},
+		{Provider: "gemini"},
+	}
+
+	tests := []struct {
+		name string
+		rule models.RoutingRule
+		want []models.ModelCapability
+	}{
+		{
+			name: "allowed providers",
+			rule: models.RoutingRule{AllowedProviders: []string{"OpenAI"}},
+			want: []models.ModelCapability{
+				{Provider: "openai", ModelName: "gpt-4o"},
+				{Provider: "openai", ModelName: "gpt-4o-mini"},
+			},
+		},
+		{
+			name: "allowed models",
+			rule: models.RoutingRule{AllowedModels: []string{"openai:gpt-4o-mini", "gemini:*"}},
+			want: []models.ModelCapability{
+				{Provider: "openai", ModelName: "gpt-4o-mini"},
+				{Provider: "gemini"},
+			},
+		},
+		{
+			name: "provider-only entries expand into allowed models",
+			rule: models.RoutingRule{AllowedModels: []string{"anthropic:claude-sonnet-4-5", "anthropic:claude-haiku-4-5"}},
+			want: []models.ModelCapability{
+				{Provider: "anthropic", ModelName: "claude-sonnet-4-5"},
+				{Provider: "anthropic", ModelName: "claude-haiku-4-5"},
+			},
+		},
+		{
+			name: "nothing permitted",
+			rule: models.RoutingRule{AllowedProviders: []string{"mistral"}},
+			want: []models.ModelCapability{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := restrictCandidates(&tt.rule, candidates, nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restrictCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceRestriction(t *testing.T) {
+	restricted := &models.ModelRouterConfig{
+		RestrictedBy: "internal",
+		Models: []models.ModelCapability{
+			{Provider: "anthropic"},
+			{Provider: "openai", ModelName: "gpt-4o-mini"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		config  *models.ModelRouterConfig
+		resp    models.ModelSelectionResponse
+		want    *models.ModelSelectionResponse
+		wantErr bool
+	}{
+		{
+			name:   "unrestricted selection is kept",
+			config: &models.ModelRouterConfig{},
+			resp:   models.ModelSelectionResponse{Provider: "mistral", Model: "mistral-large"},
+			want:   &models.ModelSelectionResponse{Provider: "mistral", Model: "mistral-large"},
+		},
+		{
+			name:   "disallowed selection is dropped",
+			config: restricted,
+			resp: models.ModelSelectionResponse{
+				Provider: "openai",
+				Model:    "gpt-4o",
+				Alternatives: []models.Alternative{
+					{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+					{Provider: "openai", Model: "gpt-4o-mini"},
+				},
+			},
+			want: &models.ModelSelectionResponse{
+				Provider:     "anthropic",
+				Model:        "claude-sonnet-4-5",
+				Alternatives: []models.Alternative{{Provider: "openai", Model: "gpt-4o-mini"}},
+			},
+		},
+		{
+			name:   "first configured model when nothing selected is permitted",
+			config: restricted,
+			resp:   models.ModelSelectionResponse{Provider: "gemini", Model: "gemini-2.5-pro"},
+			want:   &models.ModelSelectionResponse{Provider: "openai", Model: "gpt-4o-mini", Alternatives: []models.Alternative{}},
+		},
+		{
+			name: "error without a configured model to fall back to",
+			config: &models.ModelRouterConfig{
+				RestrictedBy: "internal",
+				Models:       []models.ModelCapability{{Provider: "anthropic"}},
+			},
+			resp:    models.ModelSelectionResponse{Provider: "gemini", Model: "gemini-2.5-pro"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := enforceRestriction(&tt.resp, tt.config, nil, "test")
+			if tt.wantErr {
+				if !errors.Is(err, ErrModelNotAllowed) {
+					t.Fatalf("enforceRestriction() error = %v, want ErrModelNotAllowed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("enforceRestriction() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enforceRestriction() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermittedChain(t *testing.T) {
+	restricted := &models.ModelRouterConfig{
+		RestrictedBy: "internal",
+		Models: []models.ModelCapability{
+			{Provider: "anthropic"},
+			{Provider: "openai", ModelName: "gpt-4o-mini"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		targets      []string
+		routerConfig *models.ModelRouterConfig
+		want         []models.Alternative
+		wantErr      error
+	}{
+		{
+			name:    "unrestricted",
+			targets: []string{"openai:gpt-4o", "gemini:gemini-2.5-pro"},
+			want: []models.Alternative{
+				{Provider: "openai", Model: "gpt-4o"},
+				{Provider: "gemini", Model: "gemini-2.5-pro"},
+			},
+		},
+		{
+			name:         "drops targets outside the restriction",
+			targets:      []string{"openai:gpt-4o", "anthropic:claude-sonnet-4-5", "openai:gpt-4o-mini"},
+			routerConfig: restricted,
+			want: []models.Alternative{
+				{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+				{Provider: "openai", Model: "gpt-4o-mini"},
+			},
+		},
+		{
+			name:         "no target permitted",
+			targets:      []string{"openai:gpt-4o"},
+			routerConfig: restricted,
+			wantErr:      ErrModelNotAllowed,
+		},
+		{
+			name:    "invalid target",
+			targets: []string{"gpt-4o"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := permittedChain(tt.targets, tt.routerConfig, "alias test", "test")
+			if tt.want == nil {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("permittedChain() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("permittedChain() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("permittedChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRoutingRules(t *testing.T) {
+	engine, err := NewRuleEngine([]models.RoutingRule{
+		{Name: "pinned", Match: models.RoutingRuleMatch{Endpoints: []string{"generate"}}, Model: "gemini:gemini-2.5-flash"},
+		{Name: "internal", AllowedProviders: []string{"anthropic"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRuleEngine() error = %v", err)
+	}
+	router := &ModelRouter{rules: engine}
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		model      string
+		wantRule   string
+		wantModel  string
+		wantModels []models.ModelCapability
+		wantErr    bool
+	}{
+		{
+			name:      "pinned model replaces the requested one",
+			endpoint:  "generate",
+			model:     "openai:gpt-4o",
+			wantRule:  "pinned",
+			wantModel: "gemini:gemini-2.5-flash",
+			wantModels: []models.ModelCapability{
+				{Provider: "anthropic"},
+				{Provider: "openai"},
+			},
+		},
+		{
+			name:     "requested model outside the restriction",
+			endpoint: "messages",
+			model:    "openai:gpt-4o",
+			wantRule: "internal",
+			wantErr:  true,
+		},
+		{
+			name:       "candidates are restricted",
+			endpoint:   "messages",
+			wantRule:   "internal",
+			wantModels: []models.ModelCapability{{Provider: "anthropic"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routerConfig := &models.ModelRouterConfig{
+				Models: []models.ModelCapability{{Provider: "anthropic"}, {Provider: "openai"}},
+			}
+			rule, model, err := router.ApplyRoutingRules(context.Background(),
+				models.RoutingContext{Endpoint: tt.endpoint}, tt.model, routerConfig, "test")
+			if rule == nil || rule.Name != tt.wantRule {
+				t.Fatalf("ApplyRoutingRules() rule = %v, want %q", rule, tt.wantRule)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrModelNotAllowed) {
+					t.Fatalf("ApplyRoutingRules() error = %v, want ErrModelNotAllowed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyRoutingRules() error = %v", err)
+			}
+			if model != tt.wantModel {
+				t.Errorf("ApplyRoutingRules() model = %q, want %q", model, tt.wantModel)
+			}
+			if !reflect.DeepEqual(routerConfig.Models, tt.wantModels) {
+				t.Errorf("ApplyRoutingRules() candidates = %v, want %v", routerConfig.Models, tt.wantModels)
+			}
+		})
+	}
+}
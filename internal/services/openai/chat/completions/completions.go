@@ -4,16 +4,24 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/config"
 	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/anthropic/messages"
 	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
 	"github.com/Egham-7/adaptive-proxy/internal/services/fallback"
 	"github.com/Egham-7/adaptive-proxy/internal/services/format_adapter"
+	"github.com/Egham-7/adaptive-proxy/internal/services/gemini/generate"
+	"github.com/Egham-7/adaptive-proxy/internal/services/protocols"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/internal/utils/clientcache"
@@ -35,12 +43,16 @@ type CompletionService struct {
 	fallbackService *fallback.FallbackService
 	responseService *ResponseService
 	clientCache     *clientcache.Cache[*openai.Client]
+	gateway         *protocols.Gateway
 	circuitBreakers map[string]*circuitbreaker.CircuitBreaker
 	usageService    *usage.Service
 	usageWorker     *usage.Worker
+	statsTracker    *provider_stats.Tracker
+	shadowSvc       *shadow.Service
+	shadowConfig    *models.ShadowConfig
 }
 
-func NewCompletionService(cfg *config.Config, responseService *ResponseService, circuitBreakers map[string]*circuitbreaker.CircuitBreaker, usageService *usage.Service, usageWorker *usage.Worker) *CompletionService {
+func NewCompletionService(cfg *config.Config, responseService *ResponseService, circuitBreakers map[string]*circuitbreaker.CircuitBreaker, usageService *usage.Service, usageWorker *usage.Worker, statsTracker *provider_stats.Tracker, shadowSvc *shadow.Service) *CompletionService {
 	if responseService == nil {
 		panic("NewCompletionService: responseService cannot be nil")
 	}
@@ -49,12 +61,16 @@ func NewCompletionService(cfg *config.Config, responseService *ResponseService,
 	}
 
 	return &CompletionService{
-		fallbackService: fallback.NewFallbackService(cfg),
+		fallbackService: fallback.NewFallbackService(cfg, "chat_completions", statsTracker),
 		responseService: responseService,
 		clientCache:     clientcache.NewCache[*openai.Client](),
+		gateway:         protocols.NewGateway(messages.NewMessagesService(), generate.NewGenerateService()),
 		circuitBreakers: circuitBreakers,
 		usageService:    usageService,
 		usageWorker:     usageWorker,
+		statsTracker:    statsTracker,
+		shadowSvc:       shadowSvc,
+		shadowConfig:    cfg.GetShadowConfig(serviceTypeChatCompletions),
 	}
 }
 
@@ -71,8 +87,9 @@ func (cs *CompletionService) generateConfigHash(providerConfig models.ProviderCo
 	return fmt.Sprintf("%x", hash[:16]), nil // Use first 16 bytes for cache key
 }
 
-// createClient creates or retrieves a cached OpenAI client for the given provider
-func (cs *CompletionService) createClient(providerName string, resolvedConfig *config.Config, isStream bool) (*openai.Client, error) {
+// createClient creates or retrieves a cached client for the given provider. Providers of other
+// protocols are served through the protocol gateway.
+func (cs *CompletionService) createClient(providerName string, resolvedConfig *config.Config, isStream bool) (protocols.ChatClient, error) {
 	if resolvedConfig == nil {
 		return nil, fmt.Errorf("resolved config is nil")
 	}
@@ -82,12 +99,19 @@ func (cs *CompletionService) createClient(providerName string, resolvedConfig *c
 	if !exists {
 		return nil, fmt.Errorf("provider is not configured")
 	}
+	if providerConfig.Protocol != "" && providerConfig.Protocol != models.ProtocolOpenAI {
+		return cs.gateway.Chat(providerConfig)
+	}
 
 	// Generate cache key based on provider config hash
 	configHash, err := cs.generateConfigHash(providerConfig, isStream)
 	if err != nil {
 		fiberlog.Warnf("Failed to generate config hash for %s: %v, creating new client without caching", providerName, err)
-		return cs.buildClient(providerConfig, providerName, isStream)
+		client, err := cs.buildClient(providerConfig, providerName, isStream)
+		if err != nil {
+			return nil, err
+		}
+		return protocols.OpenAIChat(client), nil
 	}
 
 	cacheKey := fmt.Sprintf("%s:%s", providerName, configHash)
@@ -102,7 +126,7 @@ func (cs *CompletionService) createClient(providerName string, resolvedConfig *c
 	}
 
 	fiberlog.Debugf("Using OpenAI client for %s (config hash: %s)", providerName, configHash[:8])
-	return client, nil
+	return protocols.OpenAIChat(client), nil
 }
 
 func (cs *CompletionService) buildClient(providerConfig models.ProviderConfig, providerName string, isStream bool) (*openai.Client, error) {
@@ -115,6 +139,8 @@ func (cs *CompletionService) buildClient(providerConfig models.ProviderConfig, p
 
 	opts := []openaiOption.RequestOption{
 		openaiOption.WithAPIKey(providerConfig.APIKey),
+		// Retries are applied by the fallback service, per provider retry_config
+		openaiOption.WithMaxRetries(0),
 	}
 
 	if providerConfig.BaseURL != "" {
@@ -153,12 +179,27 @@ func (cs *CompletionService) HandleCompletion(
 		return fmt.Errorf("invalid input parameters")
 	}
 
-	executeFunc := cs.createExecuteFunc(req, isStream, cacheSource, resolvedConfig)
+	fallbackConfig := cs.fallbackService.GetFallbackConfig(req.Fallback)
+	executeFunc := cs.fallbackService.WithRetries(cs.createExecuteFunc(req, isStream, cacheSource, resolvedConfig), fallbackConfig)
 	primary := models.Alternative{
 		Provider: resp.Provider,
 		Model:    resp.Model,
 	}
 
+	// Streaming races and hedges, and hedged requests, run the primary alongside its alternatives
+	if len(resp.Alternatives) > 0 {
+		candidates := append([]models.Alternative{primary}, resp.Alternatives...)
+		if isStream && fallbackConfig.MidStreamRecovery {
+			cs.fallbackService.EnableStreamResume(c, candidates, fallbackConfig, cs.createStreamContinueFunc(c, req, cacheSource, resolvedConfig), requestID)
+		}
+		switch {
+		case isStream && (fallbackConfig.Mode == models.FallbackModeRace || fallbackConfig.Mode == models.FallbackModeHedge):
+			return cs.fallbackService.RaceStreams(c, candidates, fallbackConfig, cs.createStreamOpenFunc(c, req, cacheSource, resolvedConfig), requestID)
+		case fallbackConfig.Mode == models.FallbackModeHedge:
+			return cs.fallbackService.Execute(c, candidates, fallbackConfig, executeFunc, requestID, isStream)
+		}
+	}
+
 	// Try primary provider first
 	fiberlog.Infof("[%s] Trying primary provider: %s/%s", requestID, resp.Provider, resp.Model)
 	err := executeFunc(c, primary, requestID)
@@ -174,12 +215,16 @@ func (cs *CompletionService) HandleCompletion(
 		fiberlog.Errorf("[%s] ❌ Primary provider failed and no alternatives available: %v", requestID, err)
 		return err
 	}
+	if !fallback.ShouldFallback(c.UserContext(), err) {
+		fiberlog.Errorf("[%s] ❌ Primary provider failed with a %s error, not trying alternatives: %v",
+			requestID, fallback.ClassifyAttempt(c.UserContext(), err), err)
+		return err
+	}
 
 	// Use fallback service with alternatives only
 	fiberlog.Warnf("[%s] ⚠️  Primary provider failed: %v", requestID, err)
 	fiberlog.Infof("[%s] Using fallback with %d alternatives", requestID, len(resp.Alternatives))
 
-	fallbackConfig := cs.fallbackService.GetFallbackConfig(req.Fallback)
 	return cs.fallbackService.Execute(c, resp.Alternatives, fallbackConfig, executeFunc, requestID, isStream)
 }
 
@@ -195,41 +240,157 @@ func (cs *CompletionService) createExecuteFunc(
 		if cb := cs.circuitBreakers[provider.Provider]; cb != nil {
 			if !cb.CanExecute() {
 				fiberlog.Warnf("[%s] Circuit breaker is OPEN for provider %s, skipping", reqID, provider.Provider)
-				return fmt.Errorf("circuit breaker is OPEN for provider %s", provider.Provider)
+				routing_trace.From(c).Filter(provider.Provider, provider.Model, models.FilterReasonCircuitBreaker)
+				return fmt.Errorf("%w for provider %s", circuitbreaker.ErrOpen, provider.Provider)
 			}
 			fiberlog.Debugf("[%s] Circuit breaker check passed for provider %s", reqID, provider.Provider)
 		}
 
 		client, err := cs.createClient(provider.Provider, resolvedConfig, isStream)
 		if err != nil {
-			return fmt.Errorf("client creation failed for provider %s: %w", provider.Provider, err)
+			err = fmt.Errorf("client creation failed for provider %s: %w", provider.Provider, err)
+			routing_trace.From(c).Failed(provider.Provider, provider.Model, err, 0)
+			return err
 		}
 
 		// Create a copy to avoid race conditions when mutating req.Model
 		reqCopy := *req
 		reqCopy.Model = shared.ChatModel(provider.Model)
 
-		err = cs.executeOpenAICompletion(c, client, provider.Provider, &reqCopy, reqID, isStream, cacheSource, resolvedConfig)
+		// Streams are finished by the stream orchestrator once they end
+		start := time.Now()
+		observation := cs.statsTracker.Start(provider.Provider, provider.Model)
+		err = cs.executeOpenAICompletion(c, client, provider.Provider, &reqCopy, reqID, isStream, cacheSource, resolvedConfig, observation)
+		if err != nil || !isStream {
+			observation.Finish(err)
+		}
 		if err != nil {
-			// Check if the error is a retryable provider error that should trigger fallback
-			// For non-retryable errors, wrap them to prevent fallback
-			return fmt.Errorf("non-retryable error from provider %s: %w", provider.Provider, err)
+			if !errors.Is(err, context.Canceled) {
+				routing_trace.From(c).Failed(provider.Provider, provider.Model, err, time.Since(start))
+				if apiKey, ok := auth.GetAPIKey(c); ok && apiKey != nil && cs.usageService != nil {
+					cs.usageService.Record(c, usage.FailedAttempt(apiKey, usage.AttemptOf(c), "/v1/chat/completions", provider, fallback.StatusCode(err), err, reqID))
+				}
+			}
+			// Retries and fallback are decided by the fallback service from the error's class
+			return fmt.Errorf("provider %s failed: %w", provider.Provider, err)
 		}
 
 		return nil
 	}
 }
 
+// createStreamOpenFunc creates the stream opener for streaming races
+func (cs *CompletionService) createStreamOpenFunc(
+	c *fiber.Ctx,
+	req *models.ChatCompletionRequest,
+	cacheSource string,
+	resolvedConfig *config.Config,
+) models.StreamOpenFunc {
+	open := cs.streamOpener(c, cacheSource, resolvedConfig)
+	return func(ctx context.Context, provider models.Alternative, reqID string) (models.PendingStream, error) {
+		return open(ctx, req, provider, reqID)
+	}
+}
+
+// createStreamContinueFunc creates the opener of continuations for mid-stream recovery. OpenAI
+// has no prefill, so the partial reply is followed by a request to continue it.
+func (cs *CompletionService) createStreamContinueFunc(
+	c *fiber.Ctx,
+	req *models.ChatCompletionRequest,
+	cacheSource string,
+	resolvedConfig *config.Config,
+) models.StreamContinueFunc {
+	open := cs.streamOpener(c, cacheSource, resolvedConfig)
+	return func(ctx context.Context, provider models.Alternative, partial, reqID string) (models.PendingStream, error) {
+		continuation := *req
+		continuation.Messages = append(slices.Clip(req.Messages),
+			openai.AssistantMessage(partial),
+			openai.UserMessage(models.MidStreamContinuePrompt),
+		)
+		return open(ctx, &continuation, provider, reqID)
+	}
+}
+
+// streamOpener returns a function opening a provider's stream for req without touching the
+// response. Everything it needs from c is read here, since streams are opened concurrently, or
+// after the handler returned, and must not touch c.
+func (cs *CompletionService) streamOpener(
+	c *fiber.Ctx,
+	cacheSource string,
+	resolvedConfig *config.Config,
+) func(ctx context.Context, req *models.ChatCompletionRequest, provider models.Alternative, reqID string) (models.PendingStream, error) {
+	apiKey, _ := auth.GetAPIKey(c)
+	usageMetadata := usage.UsageMetadata(c)
+	trace := routing_trace.From(c)
+
+	return func(ctx context.Context, req *models.ChatCompletionRequest, provider models.Alternative, reqID string) (models.PendingStream, error) {
+		cb := cs.circuitBreakers[provider.Provider]
+		if cb != nil && !cb.CanExecute() {
+			fiberlog.Warnf("[%s] Circuit breaker is OPEN for provider %s, skipping", reqID, provider.Provider)
+			trace.Filter(provider.Provider, provider.Model, models.FilterReasonCircuitBreaker)
+			return nil, fmt.Errorf("%w for provider %s", circuitbreaker.ErrOpen, provider.Provider)
+		}
+
+		client, err := cs.createClient(provider.Provider, resolvedConfig, true)
+		if err != nil {
+			err = fmt.Errorf("client creation failed for provider %s: %w", provider.Provider, err)
+			trace.Failed(provider.Provider, provider.Model, err, 0)
+			return nil, err
+		}
+
+		reqCopy := *req
+		reqCopy.Model = shared.ChatModel(provider.Model)
+		openAIParams, err := format_adapter.AdaptiveToOpenAI.ConvertRequest(&reqCopy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert request to OpenAI parameters: %w", err)
+		}
+
+		// ctx is cancelled when the contender loses, which aborts the upstream request
+		start := time.Now()
+		attempt := usage.Attempt{Metadata: usageMetadata, Start: start}
+		observation := cs.statsTracker.Start(provider.Provider, provider.Model)
+		model := string(openAIParams.Model)
+		streamResp, err := client.NewStreaming(ctx, openAIParams, reqID)
+		var pending *handlers.PendingStream
+		if err == nil {
+			pending, err = handlers.PrepareOpenAI(streamResp, reqID, provider.Provider, cacheSource, model, "/v1/chat/completions",
+				cs.usageService, apiKey, attempt, cs.usageWorker, observation)
+		}
+		if err != nil {
+			observation.Finish(err)
+			if !errors.Is(err, context.Canceled) {
+				trace.Failed(provider.Provider, provider.Model, err, time.Since(start))
+				if cs.usageWorker != nil && apiKey != nil {
+					cs.usageWorker.Submit(usage.FailedAttempt(apiKey, attempt, "/v1/chat/completions", provider, fallback.StatusCode(err), err, reqID), reqID)
+				}
+				if cb != nil {
+					cb.RecordFailure()
+				}
+			}
+			return nil, fmt.Errorf("provider %s failed: %w", provider.Provider, err)
+		}
+		if cb != nil {
+			cb.RecordSuccess()
+		}
+
+		return fallback.NewPendingStream(pending, observation, func(c *fiber.Ctx) {
+			routing_trace.From(c).Select(provider.Provider, model)
+			shadow.SetPrimary(c, models.ShadowResult{Provider: provider.Provider, Model: model})
+		}), nil
+	}
+}
+
 // executeOpenAICompletion handles providers with OpenAI-compatible format
 func (cs *CompletionService) executeOpenAICompletion(
 	c *fiber.Ctx,
-	client *openai.Client,
+	client protocols.ChatClient,
 	providerName string,
 	req *models.ChatCompletionRequest,
 	requestID string,
 	isStream bool,
 	cacheSource string,
 	resolvedConfig *config.Config,
+	observation *provider_stats.Observation,
 ) error {
 	// Convert request using format adapter
 	openAIParams, err := format_adapter.AdaptiveToOpenAI.ConvertRequest(req)
@@ -242,7 +403,7 @@ func (cs *CompletionService) executeOpenAICompletion(
 	}
 
 	if isStream {
-		return cs.handleStreamingCompletion(c, client, providerName, openAIParams, requestID, cacheSource)
+		return cs.handleStreamingCompletion(c, client, providerName, openAIParams, requestID, cacheSource, observation)
 	}
 
 	return cs.handleNonStreamingCompletion(c, client, providerName, openAIParams, requestID, cacheSource, resolvedConfig)
@@ -251,17 +412,25 @@ func (cs *CompletionService) executeOpenAICompletion(
 // handleStreamingCompletion handles streaming completions
 func (cs *CompletionService) handleStreamingCompletion(
 	c *fiber.Ctx,
-	client *openai.Client,
+	client protocols.ChatClient,
 	providerName string,
 	openAIParams *openai.ChatCompletionNewParams,
 	requestID string,
 	cacheSource string,
+	observation *provider_stats.Observation,
 ) error {
 	fiberlog.Infof("[%s] streaming response from %s", requestID, providerName)
 
 	// Use context.Background() for streaming - c.UserContext() gets canceled too early
 	// The stream handler will monitor fasthttpCtx for actual client disconnects
-	streamResp := client.Chat.Completions.NewStreaming(context.Background(), *openAIParams)
+	streamResp, err := client.NewStreaming(context.Background(), openAIParams, requestID)
+	if err != nil {
+		if cb := cs.circuitBreakers[providerName]; cb != nil && !errors.Is(err, context.Canceled) {
+			cb.RecordFailure()
+			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (streaming)", requestID, providerName)
+		}
+		return err
+	}
 
 	// Extract model and API key for usage tracking
 	model := string(openAIParams.Model)
@@ -270,10 +439,10 @@ func (cs *CompletionService) handleStreamingCompletion(
 	// Get API key from auth context
 	apiKey, _ := auth.GetAPIKey(c)
 
-	err := handlers.HandleOpenAI(c, streamResp, requestID, providerName, cacheSource, model, endpoint, cs.usageService, apiKey, cs.usageWorker)
+	err = handlers.HandleOpenAI(c, streamResp, requestID, providerName, cacheSource, model, endpoint, cs.usageService, apiKey, cs.usageWorker, observation)
 	if err != nil {
 		// Record failure in circuit breaker
-		if cb := cs.circuitBreakers[providerName]; cb != nil {
+		if cb := cs.circuitBreakers[providerName]; cb != nil && !errors.Is(err, context.Canceled) {
 			cb.RecordFailure()
 			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (streaming)", requestID, providerName)
 		}
@@ -286,13 +455,15 @@ func (cs *CompletionService) handleStreamingCompletion(
 		fiberlog.Infof("[%s] 🟢 Circuit breaker recorded SUCCESS for provider %s (streaming)", requestID, providerName)
 	}
 
+	routing_trace.From(c).Select(providerName, model)
+	shadow.SetPrimary(c, models.ShadowResult{Provider: providerName, Model: model})
 	return nil
 }
 
 // handleNonStreamingCompletion handles non-streaming completions
 func (cs *CompletionService) handleNonStreamingCompletion(
 	c *fiber.Ctx,
-	client *openai.Client,
+	client protocols.ChatClient,
 	providerName string,
 	openAIParams *openai.ChatCompletionNewParams,
 	requestID string,
@@ -309,10 +480,10 @@ func (cs *CompletionService) handleNonStreamingCompletion(
 		defer cancel()
 	}
 
-	resp, err := client.Chat.Completions.New(ctx, *openAIParams)
+	resp, err := client.New(ctx, openAIParams, requestID)
 	if err != nil {
-		// Record failure in circuit breaker
-		if cb := cs.circuitBreakers[providerName]; cb != nil {
+		// Record failure in circuit breaker, unless the attempt was cancelled as a hedge loser
+		if cb := cs.circuitBreakers[providerName]; cb != nil && !errors.Is(err, context.Canceled) {
 			cb.RecordFailure()
 			fiberlog.Warnf("[%s] 🔴 Circuit breaker recorded FAILURE for provider %s (non-streaming)", requestID, providerName)
 		}
@@ -356,15 +527,28 @@ func (cs *CompletionService) handleNonStreamingCompletion(
 				Cost:           usage.CalculateCost(providerName, string(resp.Model), inputTokens, outputTokens),
 				StatusCode:     200,
 				RequestID:      requestID,
+				Metadata:       usage.UsageMetadata(c),
 			}
 
-			_, err := cs.usageService.RecordUsage(c.UserContext(), usageParams)
-			if err != nil {
-				fiberlog.Errorf("[%s] Failed to record usage: %v", requestID, err)
-			}
+			cs.usageService.Record(c, usageParams)
 		}
 	}
 
+	inputTokens := int(resp.Usage.PromptTokens)
+	outputTokens := int(resp.Usage.CompletionTokens)
+	shadow.SetPrimary(c, models.ShadowResult{
+		Provider:     providerName,
+		Model:        resp.Model,
+		TokensInput:  inputTokens,
+		TokensOutput: outputTokens,
+		Cost:         usage.CalculateCost(providerName, resp.Model, inputTokens, outputTokens),
+		Output:       completionText(resp),
+	})
+
+	trace := routing_trace.From(c)
+	trace.Select(providerName, string(openAIParams.Model))
+	adaptiveResp.Routing = trace.ForResponse()
+
 	return c.JSON(adaptiveResp)
 }
 
@@ -382,11 +566,67 @@ func (cs *CompletionService) HandleModel(
 		cs.responseService.SetStreamHeaders(c)
 	}
 
+	start := time.Now()
 	if err := cs.HandleCompletion(c, req, resp, requestID, isStream, cacheSource, resolvedConfig); err != nil {
-		return cs.responseService.HandleError(c, fiber.StatusInternalServerError, err.Error(), requestID)
+		return cs.responseService.HandleProviderError(c, err, requestID)
 	}
 
+	// Mirror the request to the endpoint's shadow model now that the response has been served
+	reqCopy := *req
+	cs.shadowSvc.Mirror(c, cs.shadowConfig, serviceTypeChatCompletions, requestID, start, isStream,
+		func(ctx context.Context, provider, model string) models.ShadowResult {
+			return cs.sendShadowCompletion(ctx, &reqCopy, provider, model, resolvedConfig, requestID)
+		})
+
 	// Store successful response in semantic cache
 	cs.responseService.StoreSuccessfulSemanticCache(c.UserContext(), req, resp, requestID)
 	return nil
 }
+
+// sendShadowCompletion replays a request against a shadow provider/model without streaming.
+// No usage is recorded: shadow calls are never billed.
+func (cs *CompletionService) sendShadowCompletion(
+	ctx context.Context,
+	req *models.ChatCompletionRequest,
+	provider, model string,
+	resolvedConfig *config.Config,
+	requestID string,
+) models.ShadowResult {
+	result := models.ShadowResult{Provider: provider, Model: model}
+
+	client, err := cs.createClient(provider, resolvedConfig, false)
+	if err != nil {
+		result.Error = fmt.Sprintf("client creation failed: %v", err)
+		return result
+	}
+
+	req.Model = shared.ChatModel(model)
+	openAIParams, err := format_adapter.AdaptiveToOpenAI.ConvertRequest(req)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to convert request: %v", err)
+		return result
+	}
+	// Stream options are only valid on streaming requests
+	openAIParams.StreamOptions = openai.ChatCompletionStreamOptionsParam{}
+
+	fiberlog.Debugf("[%s] Sending shadow completion to %s/%s", requestID, provider, model)
+	resp, err := client.New(ctx, openAIParams, requestID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.TokensInput = int(resp.Usage.PromptTokens)
+	result.TokensOutput = int(resp.Usage.CompletionTokens)
+	result.Cost = usage.CalculateCost(provider, model, result.TokensInput, result.TokensOutput)
+	result.Output = completionText(resp)
+	return result
+}
+
+// completionText returns the content of the first choice of a completion
+func completionText(resp *openai.ChatCompletion) string {
+	if len(resp.Choices) == 0 {
+		return ""
+	}
+	return resp.Choices[0].Message.Content
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/openai/openai-go/v2/shared"
 )
 
 // RequestService handles request parsing and validation for chat completions
@@ -34,6 +35,10 @@ func (rs *RequestService) ParseChatCompletionRequest(c *fiber.Ctx) (*models.Chat
 		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 	}
 
+	// An OpenRouter-style models array is handled as a comma-separated model list
+	req.Model = shared.ChatModel(utils.JoinModelList(string(req.Model), req.Models))
+	req.Models = nil
+
 	return &req, nil
 }
 
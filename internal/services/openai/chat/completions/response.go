@@ -66,6 +66,8 @@ func (rs *ResponseService) HandleError(
 		code, subcode = "invalid_request_error", "bad_request"
 	case fiber.StatusUnauthorized:
 		code, subcode = "authentication_error", "unauthorized"
+	case fiber.StatusForbidden:
+		code, subcode = "permission_error", "forbidden"
 	case fiber.StatusTooManyRequests:
 		code, subcode = "rate_limit_error", "rate_limit_exceeded"
 	default:
@@ -74,6 +76,14 @@ func (rs *ResponseService) HandleError(
 	return rs.Error(c, statusCode, message, code, subcode)
 }
 
+// HandleProviderError sends a failed completion with the status and OpenAI error body of the
+// upstream failure, see response.ClassifyError
+func (rs *ResponseService) HandleProviderError(c *fiber.Ctx, err error, requestID string) error {
+	apiErr := response.ClassifyError(err)
+	fiberlog.Errorf("[%s] Error %d: %v", requestID, apiErr.Status, err)
+	return response.SendError(c, response.FormatOpenAI, apiErr, requestID)
+}
+
 // HandleBadRequest handles 400 errors
 func (rs *ResponseService) HandleBadRequest(
 	c *fiber.Ctx,
@@ -82,6 +92,14 @@ func (rs *ResponseService) HandleBadRequest(
 	return rs.HandleError(c, fiber.StatusBadRequest, message, requestID)
 }
 
+// HandleForbidden handles 403 errors
+func (rs *ResponseService) HandleForbidden(
+	c *fiber.Ctx,
+	message, requestID string,
+) error {
+	return rs.HandleError(c, fiber.StatusForbidden, message, requestID)
+}
+
 // HandleInternalError handles 500 errors
 func (rs *ResponseService) HandleInternalError(
 	c *fiber.Ctx,
@@ -120,7 +138,7 @@ func (rs *ResponseService) StoreSuccessfulSemanticCache(
 	}
 
 	// Extract prompt from messages
-	prompt, err := utils.ExtractLastMessage(openAIParams.Messages)
+	prompt, err := utils.ExtractOpenAIPrompt(openAIParams.Messages, rs.modelRouter.PromptExtraction())
 	if err != nil {
 		fiberlog.Errorf("[%s] Failed to extract prompt for semantic cache: %v", requestID, err)
 		return
@@ -0,0 +1,196 @@
+package protocols
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/anthropic/messages"
+	"github.com/Egham-7/adaptive-proxy/internal/services/format_adapter"
+	"github.com/Egham-7/adaptive-proxy/internal/services/gemini/generate"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+// ChatClient sends chat completion requests to a provider
+type ChatClient interface {
+	New(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (*openai.ChatCompletion, error)
+	NewStreaming(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (contracts.EventStream[openai.ChatCompletionChunk], error)
+}
+
+// openAIChat calls an OpenAI-compatible provider natively
+type openAIChat struct {
+	client *openai.Client
+}
+
+// OpenAIChat returns a ChatClient calling an OpenAI-compatible provider with the given client
+func OpenAIChat(client *openai.Client) ChatClient {
+	return &openAIChat{client: client}
+}
+
+func (o *openAIChat) New(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (*openai.ChatCompletion, error) {
+	return o.client.Chat.Completions.New(ctx, *params)
+}
+
+func (o *openAIChat) NewStreaming(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (contracts.EventStream[openai.ChatCompletionChunk], error) {
+	return o.client.Chat.Completions.NewStreaming(ctx, *params), nil
+}
+
+// timeoutChat bounds non-streaming calls by the provider's timeout_ms, as the native OpenAI client
+// does. Streams stay open for as long as the provider sends.
+type timeoutChat struct {
+	ChatClient
+	timeout time.Duration
+}
+
+func (t *timeoutChat) New(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (*openai.ChatCompletion, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.ChatClient.New(ctx, params, requestID)
+}
+
+// anthropicChat serves chat completions from an Anthropic provider
+type anthropicChat struct {
+	client messages.Client
+}
+
+func (a *anthropicChat) New(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (*openai.ChatCompletion, error) {
+	req, err := format_adapter.AnthropicOpenAI.RequestFromOpenAI(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to Anthropic format: %w", err)
+	}
+	fiberlog.Debugf("[%s] Serving chat completion from Anthropic provider", requestID)
+	message, err := a.client.SendMessage(ctx, req, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return format_adapter.AnthropicOpenAI.ResponseToOpenAI(message)
+}
+
+func (a *anthropicChat) NewStreaming(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (contracts.EventStream[openai.ChatCompletionChunk], error) {
+	req, err := format_adapter.AnthropicOpenAI.RequestFromOpenAI(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to Anthropic format: %w", err)
+	}
+	fiberlog.Debugf("[%s] Serving chat completion stream from Anthropic provider", requestID)
+	stream, err := a.client.SendStreamingMessage(ctx, req, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return format_adapter.AnthropicOpenAI.StreamToOpenAI(stream), nil
+}
+
+// geminiChat serves chat completions from a Gemini provider
+type geminiChat struct {
+	client generate.Client
+}
+
+func (g *geminiChat) New(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (*openai.ChatCompletion, error) {
+	req, err := format_adapter.GeminiOpenAI.RequestFromOpenAI(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to Gemini format: %w", err)
+	}
+	fiberlog.Debugf("[%s] Serving chat completion from Gemini provider", requestID)
+	resp, err := g.client.SendRequest(ctx, req, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return format_adapter.GeminiOpenAI.ResponseToOpenAI(resp)
+}
+
+func (g *geminiChat) NewStreaming(ctx context.Context, params *openai.ChatCompletionNewParams, requestID string) (contracts.EventStream[openai.ChatCompletionChunk], error) {
+	req, err := format_adapter.GeminiOpenAI.RequestFromOpenAI(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to Gemini format: %w", err)
+	}
+	fiberlog.Debugf("[%s] Serving chat completion stream from Gemini provider", requestID)
+	stream, err := g.client.SendStreamingRequest(ctx, req, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return format_adapter.GeminiOpenAI.StreamToOpenAI(stream), nil
+}
+
+// streamingParams returns params requesting usage on the stream, which converted streams
+// need to report the usage of the API they serve
+func streamingParams(params *openai.ChatCompletionNewParams) *openai.ChatCompletionNewParams {
+	streaming := *params
+	streaming.StreamOptions.IncludeUsage = openai.Bool(true)
+	return &streaming
+}
+
+// messagesViaChat serves the Messages API from a provider of another protocol
+type messagesViaChat struct {
+	chat ChatClient
+}
+
+func (m *messagesViaChat) SendMessage(ctx context.Context, req *models.AnthropicMessageRequest, requestID string) (*anthropic.Message, error) {
+	params, err := format_adapter.AnthropicOpenAI.RequestToOpenAI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to OpenAI format: %w", err)
+	}
+
+	fiberlog.Infof("[%s] Serving message request through chat completions - model: %s", requestID, req.Model)
+	startTime := time.Now()
+	resp, err := m.chat.New(ctx, params, requestID)
+	if err != nil {
+		fiberlog.Errorf("[%s] Chat completion request failed after %v: %v", requestID, time.Since(startTime), err)
+		return nil, fmt.Errorf("message request failed: %w", err)
+	}
+	return format_adapter.AnthropicOpenAI.ResponseFromOpenAI(resp)
+}
+
+func (m *messagesViaChat) SendStreamingMessage(ctx context.Context, req *models.AnthropicMessageRequest, requestID string) (contracts.EventStream[anthropic.MessageStreamEventUnion], error) {
+	params, err := format_adapter.AnthropicOpenAI.RequestToOpenAI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to OpenAI format: %w", err)
+	}
+
+	fiberlog.Infof("[%s] Serving streaming message request through chat completions - model: %s", requestID, req.Model)
+	stream, err := m.chat.NewStreaming(ctx, streamingParams(params), requestID)
+	if err != nil {
+		return nil, err
+	}
+	return format_adapter.AnthropicOpenAI.StreamFromOpenAI(stream), nil
+}
+
+// generateViaChat serves the GenerateContent API from a provider of another protocol
+type generateViaChat struct {
+	chat ChatClient
+}
+
+func (g *generateViaChat) SendRequest(ctx context.Context, req *models.GeminiGenerateRequest, requestID string) (*genai.GenerateContentResponse, error) {
+	params, err := format_adapter.GeminiOpenAI.RequestToOpenAI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to OpenAI format: %w", err)
+	}
+
+	fiberlog.Infof("[%s] Serving generate request through chat completions - model: %s", requestID, req.Model)
+	startTime := time.Now()
+	resp, err := g.chat.New(ctx, params, requestID)
+	if err != nil {
+		fiberlog.Errorf("[%s] Chat completion request failed after %v: %v", requestID, time.Since(startTime), err)
+		return nil, fmt.Errorf("generate request failed %w", err)
+	}
+	return format_adapter.GeminiOpenAI.ResponseFromOpenAI(resp)
+}
+
+func (g *generateViaChat) SendStreamingRequest(ctx context.Context, req *models.GeminiGenerateRequest, requestID string) (iter.Seq2[*genai.GenerateContentResponse, error], error) {
+	params, err := format_adapter.GeminiOpenAI.RequestToOpenAI(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request to OpenAI format: %w", err)
+	}
+
+	fiberlog.Infof("[%s] Serving streaming generate request through chat completions - model: %s", requestID, req.Model)
+	stream, err := g.chat.NewStreaming(ctx, streamingParams(params), requestID)
+	if err != nil {
+		return nil, err
+	}
+	return format_adapter.GeminiOpenAI.StreamFromOpenAI(stream), nil
+}
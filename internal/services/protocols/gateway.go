@@ -0,0 +1,131 @@
+// Package protocols serves each inbound API from providers of any protocol. Providers speaking
+// the endpoint's own API are called natively; the others are reached through the format
+// adapter's cross-protocol converters, with OpenAI chat completions as the hub.
+package protocols
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/anthropic/messages"
+	"github.com/Egham-7/adaptive-proxy/internal/services/gemini/generate"
+	"github.com/Egham-7/adaptive-proxy/internal/utils/clientcache"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/openai/openai-go/v2"
+	openaiOption "github.com/openai/openai-go/v2/option"
+)
+
+// Gateway creates clients for a provider from the API it is called through and the protocol
+// it speaks
+type Gateway struct {
+	messagesSvc *messages.MessagesService
+	generateSvc *generate.GenerateService
+	clientCache *clientcache.Cache[*openai.Client]
+}
+
+// NewGateway creates a Gateway calling native Anthropic and Gemini providers through the given
+// services
+func NewGateway(messagesSvc *messages.MessagesService, generateSvc *generate.GenerateService) *Gateway {
+	return &Gateway{
+		messagesSvc: messagesSvc,
+		generateSvc: generateSvc,
+		clientCache: clientcache.NewCache[*openai.Client](),
+	}
+}
+
+// Chat returns a chat completions client for the provider. Non-streaming calls are bounded by
+// the provider's timeout_ms.
+func (g *Gateway) Chat(providerConfig models.ProviderConfig) (ChatClient, error) {
+	chat, err := g.chat(providerConfig)
+	if err != nil || providerConfig.TimeoutMs <= 0 {
+		return chat, err
+	}
+	return &timeoutChat{ChatClient: chat, timeout: time.Duration(providerConfig.TimeoutMs) * time.Millisecond}, nil
+}
+
+// chat returns the client calling the provider in its own protocol
+func (g *Gateway) chat(providerConfig models.ProviderConfig) (ChatClient, error) {
+	switch providerConfig.Protocol {
+	case "", models.ProtocolOpenAI:
+		client, err := g.openAIClient(providerConfig)
+		if err != nil {
+			return nil, err
+		}
+		return OpenAIChat(client), nil
+	case models.ProtocolAnthropic:
+		return &anthropicChat{client: g.messagesSvc.Client(providerConfig)}, nil
+	case models.ProtocolGemini:
+		// Streams outlive the request context, so the client must not be bound to it
+		client, err := g.generateSvc.Client(context.Background(), providerConfig)
+		if err != nil {
+			return nil, err
+		}
+		return &geminiChat{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider protocol %q", providerConfig.Protocol)
+	}
+}
+
+// Messages returns a Messages API client for the provider
+func (g *Gateway) Messages(providerConfig models.ProviderConfig) (messages.Client, error) {
+	if providerConfig.Protocol == "" || providerConfig.Protocol == models.ProtocolAnthropic {
+		return g.messagesSvc.Client(providerConfig), nil
+	}
+	chat, err := g.Chat(providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &messagesViaChat{chat: chat}, nil
+}
+
+// Generate returns a GenerateContent client for the provider
+func (g *Gateway) Generate(ctx context.Context, providerConfig models.ProviderConfig) (generate.Client, error) {
+	if providerConfig.Protocol == "" || providerConfig.Protocol == models.ProtocolGemini {
+		return g.generateSvc.Client(ctx, providerConfig)
+	}
+	chat, err := g.Chat(providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &generateViaChat{chat: chat}, nil
+}
+
+// openAIClient creates or retrieves a cached OpenAI client
+func (g *Gateway) openAIClient(providerConfig models.ProviderConfig) (*openai.Client, error) {
+	configJSON, err := json.Marshal(providerConfig)
+	if err != nil {
+		fiberlog.Warnf("Failed to generate config hash: %v, creating new client without caching", err)
+		return g.buildOpenAIClient(providerConfig)
+	}
+	hash := sha256.Sum256(configJSON)
+	return g.clientCache.GetOrCreate(fmt.Sprintf("%x", hash[:16]), func() (*openai.Client, error) {
+		return g.buildOpenAIClient(providerConfig)
+	})
+}
+
+// buildOpenAIClient creates a new OpenAI client with the given configuration
+func (g *Gateway) buildOpenAIClient(providerConfig models.ProviderConfig) (*openai.Client, error) {
+	if providerConfig.APIKey == "" {
+		return nil, fmt.Errorf("API key not configured")
+	}
+
+	opts := []openaiOption.RequestOption{
+		openaiOption.WithAPIKey(providerConfig.APIKey),
+		// Retries are applied by the fallback service, per provider retry_config
+		openaiOption.WithMaxRetries(0),
+	}
+	if providerConfig.BaseURL != "" {
+		opts = append(opts, openaiOption.WithBaseURL(providerConfig.BaseURL))
+	}
+	for key, value := range providerConfig.Headers {
+		opts = append(opts, openaiOption.WithHeader(key, value))
+	}
+
+	client := openai.NewClient(opts...)
+	return &client, nil
+}
@@ -0,0 +1,97 @@
+package provider_stats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Observation measures a single provider call from start to completion.
+// For streams, MarkFirstToken is called once the first chunk arrives and
+// Finish when the stream ends. All methods are safe on a nil Observation.
+type Observation struct {
+	tracker    *Tracker
+	provider   string
+	model      string
+	start      time.Time
+	firstToken time.Time
+	once       sync.Once
+	mu         sync.Mutex
+}
+
+// Start begins observing a call to the provider/model
+func (t *Tracker) Start(provider, model string) *Observation {
+	if t == nil {
+		return nil
+	}
+	return &Observation{
+		tracker:  t,
+		provider: provider,
+		model:    model,
+		start:    time.Now(),
+	}
+}
+
+// MarkFirstToken records the time to first token
+func (o *Observation) MarkFirstToken() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.firstToken.IsZero() {
+		o.firstToken = time.Now()
+	}
+}
+
+// Finish records the sample. Only the first call has an effect, so callers may
+// call it defensively on every exit path. Client cancellations are not recorded
+// because they say nothing about the provider's health.
+func (o *Observation) Finish(err error) {
+	if o == nil {
+		return
+	}
+	o.once.Do(func() {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
+		o.mu.Lock()
+		firstToken := o.firstToken
+		o.mu.Unlock()
+
+		now := time.Now()
+		sample := Sample{
+			Timestamp: now,
+			Latency:   now.Sub(o.start),
+			Failed:    err != nil,
+		}
+		if !firstToken.IsZero() {
+			sample.TimeToFirstToken = firstToken.Sub(o.start)
+		}
+		o.tracker.Record(o.provider, o.model, sample)
+	})
+}
+
+// Abandon records only the time to first token of a stream dropped after its first chunk, such
+// as a streaming race loser; its latency says nothing about a full response. Streams abandoned
+// before their first token are not recorded. Like Finish, only the first call has an effect.
+func (o *Observation) Abandon() {
+	if o == nil {
+		return
+	}
+	o.once.Do(func() {
+		o.mu.Lock()
+		firstToken := o.firstToken
+		o.mu.Unlock()
+
+		if firstToken.IsZero() {
+			return
+		}
+		o.tracker.Record(o.provider, o.model, Sample{
+			Timestamp:        time.Now(),
+			TimeToFirstToken: firstToken.Sub(o.start),
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package provider_stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// Rank reorders candidates so healthy models keep their original order and degraded
+// models (high error rate or p95 latency well above their peers) move to the end.
+// Degraded models are ordered by error rate, then by p95 latency.
+func (t *Tracker) Rank(candidates []models.Alternative, requestID string) []models.Alternative {
+	if t == nil || len(candidates) < 2 {
+		return candidates
+	}
+
+	stats := make([]Stats, len(candidates))
+	for i, candidate := range candidates {
+		stats[i] = t.Snapshot(candidate.Provider, candidate.Model)
+	}
+
+	medianLatency := t.medianP95(stats, func(s Stats) time.Duration { return s.P95Latency })
+	medianTTFT := t.medianP95(stats, func(s Stats) time.Duration { return s.P95TimeToFirstToken })
+
+	healthy := make([]models.Alternative, 0, len(candidates))
+	type degradedCandidate struct {
+		candidate models.Alternative
+		stats     Stats
+	}
+	var degraded []degradedCandidate
+
+	for i, candidate := range candidates {
+		s := stats[i]
+		reason := t.degradationReason(s, medianLatency, medianTTFT)
+		if reason == "" {
+			healthy = append(healthy, candidate)
+			continue
+		}
+		fiberlog.Infof("[%s] 🐢 Demoting %s/%s: %s (samples: %d, error rate: %.0f%%, p95: %v, p95 ttft: %v)",
+			requestID, candidate.Provider, candidate.Model, reason, s.Samples, s.ErrorRate*100, s.P95Latency, s.P95TimeToFirstToken)
+		degraded = append(degraded, degradedCandidate{candidate: candidate, stats: s})
+	}
+
+	if len(degraded) == 0 {
+		return candidates
+	}
+
+	sort.SliceStable(degraded, func(i, j int) bool {
+		if degraded[i].stats.ErrorRate != degraded[j].stats.ErrorRate {
+			return degraded[i].stats.ErrorRate < degraded[j].stats.ErrorRate
+		}
+		return degraded[i].stats.P95Latency < degraded[j].stats.P95Latency
+	})
+
+	ranked := healthy
+	for _, d := range degraded {
+		ranked = append(ranked, d.candidate)
+	}
+	return ranked
+}
+
+// degradationReason returns why a model is considered degraded, or "" when it is healthy
+func (t *Tracker) degradationReason(s Stats, medianLatency, medianTTFT time.Duration) string {
+	if s.Samples < t.config.MinSamples {
+		return ""
+	}
+	switch {
+	case s.ErrorRate >= t.config.ErrorRateThreshold:
+		return "error rate above threshold"
+	case medianTTFT > 0 && float64(s.P95TimeToFirstToken) > float64(medianTTFT)*t.config.LatencyFactor:
+		return "time to first token well above peers"
+	case medianLatency > 0 && float64(s.P95Latency) > float64(medianLatency)*t.config.LatencyFactor:
+		return "latency well above peers"
+	}
+	return ""
+}
+
+// medianP95 returns the median of a p95 metric across candidates with enough samples
+func (t *Tracker) medianP95(stats []Stats, metric func(Stats) time.Duration) time.Duration {
+	values := make([]time.Duration, 0, len(stats))
+	for _, s := range stats {
+		if s.Samples >= t.config.MinSamples && metric(s) > 0 {
+			values = append(values, metric(s))
+		}
+	}
+	// A median needs peers to compare against
+	if len(values) < 2 {
+		return 0
+	}
+	return percentile(values, 0.5)
+}
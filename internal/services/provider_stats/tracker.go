@@ -0,0 +1,290 @@
+package provider_stats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	providerStatsKeyPrefix = "provider_stats:"
+	redisTimeout           = 500 * time.Millisecond
+)
+
+// Config controls the rolling window and the thresholds used to flag degraded models
+type Config struct {
+	// WindowSize is the maximum number of samples kept per provider/model
+	WindowSize int
+	// MaxAge discards samples older than this
+	MaxAge time.Duration
+	// MinSamples is the number of samples required before a model can be judged degraded
+	MinSamples int
+	// ErrorRateThreshold flags a model as degraded once its error rate reaches this value
+	ErrorRateThreshold float64
+	// LatencyFactor flags a model as degraded when its p95 exceeds the candidates' median p95 by this factor
+	LatencyFactor float64
+	// RefreshInterval controls how often shared stats are re-read from Redis
+	RefreshInterval time.Duration
+}
+
+// DefaultConfig returns the default tracker configuration
+func DefaultConfig() Config {
+	return Config{
+		WindowSize:         100,
+		MaxAge:             5 * time.Minute,
+		MinSamples:         10,
+		ErrorRateThreshold: 0.25,
+		LatencyFactor:      2.0,
+		RefreshInterval:    5 * time.Second,
+	}
+}
+
+// Sample is a single observed provider call
+type Sample struct {
+	Timestamp        time.Time
+	Latency          time.Duration
+	TimeToFirstToken time.Duration
+	Failed           bool
+}
+
+// Stats summarizes the recent samples of a provider/model
+type Stats struct {
+	Samples             int           `json:"samples"`
+	ErrorRate           float64       `json:"error_rate"`
+	P95Latency          time.Duration `json:"p95_latency"`
+	P95TimeToFirstToken time.Duration `json:"p95_time_to_first_token"`
+}
+
+// sharedSnapshot caches stats read from Redis
+type sharedSnapshot struct {
+	stats      Stats
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// Tracker keeps rolling latency and error statistics per provider/model.
+// Samples are always kept in memory; when Redis is configured they are also
+// pushed to a shared list so all instances route on the same picture.
+type Tracker struct {
+	mu          sync.Mutex
+	local       map[string][]Sample
+	shared      map[string]*sharedSnapshot
+	redisClient *redis.Client
+	config      Config
+}
+
+// NewTracker creates a tracker with the default configuration. redisClient may be nil.
+func NewTracker(redisClient *redis.Client) *Tracker {
+	return NewTrackerWithConfig(redisClient, DefaultConfig())
+}
+
+// NewTrackerWithConfig creates a tracker with a custom configuration. redisClient may be nil.
+func NewTrackerWithConfig(redisClient *redis.Client, config Config) *Tracker {
+	if redisClient == nil {
+		fiberlog.Info("ProviderStats: Redis not configured, using per-instance stats")
+	}
+	return &Tracker{
+		local:       make(map[string][]Sample),
+		shared:      make(map[string]*sharedSnapshot),
+		redisClient: redisClient,
+		config:      config,
+	}
+}
+
+// statsKey builds the key for a provider/model pair
+func statsKey(provider, model string) string {
+	return provider + ":" + model
+}
+
+// Record adds a sample for the provider/model
+func (t *Tracker) Record(provider, model string, sample Sample) {
+	if t == nil || provider == "" {
+		return
+	}
+	if sample.Timestamp.IsZero() {
+		sample.Timestamp = time.Now()
+	}
+	if sample.TimeToFirstToken == 0 {
+		sample.TimeToFirstToken = sample.Latency
+	}
+
+	key := statsKey(provider, model)
+
+	t.mu.Lock()
+	samples := append(t.local[key], sample)
+	if len(samples) > t.config.WindowSize {
+		samples = samples[len(samples)-t.config.WindowSize:]
+	}
+	t.local[key] = samples
+	t.mu.Unlock()
+
+	if t.redisClient != nil {
+		go t.pushShared(key, sample)
+	}
+}
+
+// Snapshot returns the current stats for a provider/model.
+// Shared stats are preferred when Redis is configured and have been loaded.
+func (t *Tracker) Snapshot(provider, model string) Stats {
+	if t == nil {
+		return Stats{}
+	}
+	key := statsKey(provider, model)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.redisClient != nil {
+		snapshot, ok := t.shared[key]
+		if !ok {
+			snapshot = &sharedSnapshot{}
+			t.shared[key] = snapshot
+		}
+		if !snapshot.refreshing && time.Since(snapshot.fetchedAt) > t.config.RefreshInterval {
+			snapshot.refreshing = true
+			go t.refreshShared(key)
+		}
+		if !snapshot.fetchedAt.IsZero() {
+			return snapshot.stats
+		}
+	}
+
+	return t.summarize(t.local[key])
+}
+
+// summarize computes stats from samples, ignoring those older than MaxAge
+func (t *Tracker) summarize(samples []Sample) Stats {
+	cutoff := time.Now().Add(-t.config.MaxAge)
+
+	var failures int
+	latencies := make([]time.Duration, 0, len(samples))
+	ttfts := make([]time.Duration, 0, len(samples))
+	total := 0
+	for _, sample := range samples {
+		if sample.Timestamp.Before(cutoff) {
+			continue
+		}
+		total++
+		if sample.Failed {
+			failures++
+			continue
+		}
+		// Abandoned streams only contribute their time to first token
+		if sample.Latency > 0 {
+			latencies = append(latencies, sample.Latency)
+		}
+		ttfts = append(ttfts, sample.TimeToFirstToken)
+	}
+
+	if total == 0 {
+		return Stats{}
+	}
+	return Stats{
+		Samples:             total,
+		ErrorRate:           float64(failures) / float64(total),
+		P95Latency:          percentile(latencies, 0.95),
+		P95TimeToFirstToken: percentile(ttfts, 0.95),
+	}
+}
+
+// pushShared appends a sample to the shared Redis window
+func (t *Tracker) pushShared(key string, sample Sample) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	redisKey := providerStatsKeyPrefix + key
+	pipe := t.redisClient.Pipeline()
+	pipe.LPush(ctx, redisKey, encodeSample(sample))
+	pipe.LTrim(ctx, redisKey, 0, int64(t.config.WindowSize-1))
+	pipe.Expire(ctx, redisKey, t.config.MaxAge)
+	if _, err := pipe.Exec(ctx); err != nil {
+		fiberlog.Debugf("ProviderStats: Failed to push sample for %s: %v", key, err)
+	}
+}
+
+// refreshShared reloads the shared window for a key from Redis
+func (t *Tracker) refreshShared(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	values, err := t.redisClient.LRange(ctx, providerStatsKeyPrefix+key, 0, int64(t.config.WindowSize-1)).Result()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := t.shared[key]
+	snapshot.refreshing = false
+	if err != nil {
+		fiberlog.Debugf("ProviderStats: Failed to load shared stats for %s: %v", key, err)
+		return
+	}
+
+	samples := make([]Sample, 0, len(values))
+	for _, value := range values {
+		sample, err := decodeSample(value)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	snapshot.stats = t.summarize(samples)
+	snapshot.fetchedAt = time.Now()
+}
+
+// encodeSample serializes a sample as "unix_ms|latency_ms|ttft_ms|failed"
+func encodeSample(sample Sample) string {
+	failed := 0
+	if sample.Failed {
+		failed = 1
+	}
+	return fmt.Sprintf("%d|%d|%d|%d",
+		sample.Timestamp.UnixMilli(), sample.Latency.Milliseconds(), sample.TimeToFirstToken.Milliseconds(), failed)
+}
+
+// decodeSample parses a sample produced by encodeSample
+func decodeSample(value string) (Sample, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 4 {
+		return Sample{}, fmt.Errorf("invalid sample %q", value)
+	}
+	fields := make([]int64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return Sample{}, fmt.Errorf("invalid sample %q: %w", value, err)
+		}
+		fields[i] = n
+	}
+	return Sample{
+		Timestamp:        time.UnixMilli(fields[0]),
+		Latency:          time.Duration(fields[1]) * time.Millisecond,
+		TimeToFirstToken: time.Duration(fields[2]) * time.Millisecond,
+		Failed:           fields[3] == 1,
+	}, nil
+}
+
+// percentile returns the p-th percentile (0-1) of the durations using nearest rank
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(float64(len(sorted))*p+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
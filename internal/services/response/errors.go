@@ -0,0 +1,309 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
+	"github.com/Egham-7/adaptive-proxy/internal/services/fallback"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+// Format is the error body shape expected by the clients of an inbound API
+type Format int
+
+const (
+	// FormatOpenAI is {"error": {"message", "type", "param", "code"}}
+	FormatOpenAI Format = iota
+	// FormatAnthropic is {"type": "error", "error": {"type", "message"}}
+	FormatAnthropic
+	// FormatGemini is {"error": {"code", "message", "status"}}
+	FormatGemini
+)
+
+// ErrorKind is the proxy's error taxonomy, which every API maps to its own error types
+type ErrorKind string
+
+const (
+	KindInvalidRequest  ErrorKind = "invalid_request"
+	KindAuthentication  ErrorKind = "authentication"
+	KindPermission      ErrorKind = "permission"
+	KindNotFound        ErrorKind = "not_found"
+	KindRequestTooLarge ErrorKind = "request_too_large"
+	KindRateLimit       ErrorKind = "rate_limit"
+	KindCancelled       ErrorKind = "cancelled"
+	KindTimeout         ErrorKind = "timeout"
+	KindOverloaded      ErrorKind = "overloaded"
+	KindAPI             ErrorKind = "api_error"
+)
+
+const (
+	// StatusClientClosedRequest is returned when the client went away before the response
+	StatusClientClosedRequest = 499
+	// statusOverloaded is Anthropic's status for an overloaded API; other APIs get 503
+	statusOverloaded = 529
+)
+
+// APIError is a failure classified for the client: the status to respond with, its kind, and
+// whatever native details the upstream provider sent along
+type APIError struct {
+	Status  int
+	Kind    ErrorKind
+	Message string
+	// RetryAfter is sent as the Retry-After header when set
+	RetryAfter time.Duration
+
+	// Native error fields of the upstream provider, passed through to clients of its API
+	openAIType  string
+	openAICode  string
+	openAIParam string
+	anthropic   string
+	geminiState string
+	gemini      []map[string]any
+}
+
+// NewAPIError creates an error with the given status and message
+func NewAPIError(status int, message string) *APIError {
+	return &APIError{Status: status, Kind: kindOf(status), Message: message}
+}
+
+// ClassifyError maps a failure to the status and kind returned to the client. Provider SDK
+// errors keep their upstream status, message and Retry-After, including when they are wrapped
+// in a contracts.StreamError or were the last failure of a fallback. Errors without a status are
+// classified as cancelled requests (499), timeouts (504), open circuit breakers (503), network
+// or stream failures of the provider (502), or internal errors (500).
+func ClassifyError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	// SDK errors print the upstream URL and raw body, so only their upstream message is kept
+	status, message := fallback.StatusCode(err), ""
+	if status != 0 {
+		message = fmt.Sprintf("provider responded with status %d", status)
+	} else {
+		status, message = statusWithoutResponse(err), err.Error()
+	}
+	e := NewAPIError(status, message)
+	e.RetryAfter = fallback.RetryAfter(err, time.Now())
+	e.upstream(err)
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Kind, e.Message)
+}
+
+// statusWithoutResponse picks the status of a failure that never got a provider response
+func statusWithoutResponse(err error) int {
+	var netErr net.Error
+	switch {
+	case contracts.IsClientDisconnect(err), errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout
+	case errors.Is(err, circuitbreaker.ErrOpen):
+		return http.StatusServiceUnavailable
+	case contracts.IsProviderError(err), fallback.ClassifyError(err) == fallback.ErrorRetryable:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// upstream copies the message and native fields of a provider SDK error
+func (e *APIError) upstream(err error) {
+	var openaiErr *openai.Error
+	var anthropicErr *anthropic.Error
+	var geminiErr genai.APIError
+	var geminiErrPtr *genai.APIError
+	switch {
+	case errors.As(err, &openaiErr):
+		e.setMessage(openaiErr.Message)
+		e.openAIType, e.openAICode, e.openAIParam = openaiErr.Type, openaiErr.Code, openaiErr.Param
+	case errors.As(err, &anthropicErr):
+		var body struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal([]byte(anthropicErr.RawJSON()), &body) == nil {
+			e.setMessage(body.Error.Message)
+			e.anthropic = body.Error.Type
+		}
+	case errors.As(err, &geminiErr):
+		e.fromGemini(geminiErr)
+	case errors.As(err, &geminiErrPtr) && geminiErrPtr != nil:
+		e.fromGemini(*geminiErrPtr)
+	}
+}
+
+func (e *APIError) fromGemini(err genai.APIError) {
+	e.setMessage(err.Message)
+	e.geminiState = err.Status
+	e.gemini = err.Details
+}
+
+func (e *APIError) setMessage(message string) {
+	if message != "" {
+		e.Message = message
+	}
+}
+
+func kindOf(status int) ErrorKind {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return KindInvalidRequest
+	case http.StatusUnauthorized:
+		return KindAuthentication
+	case http.StatusForbidden:
+		return KindPermission
+	case http.StatusNotFound:
+		return KindNotFound
+	case http.StatusRequestEntityTooLarge:
+		return KindRequestTooLarge
+	case http.StatusTooManyRequests:
+		return KindRateLimit
+	case StatusClientClosedRequest:
+		return KindCancelled
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return KindTimeout
+	case http.StatusServiceUnavailable, statusOverloaded:
+		return KindOverloaded
+	}
+	if status < http.StatusInternalServerError {
+		return KindInvalidRequest
+	}
+	return KindAPI
+}
+
+// SendError writes the error in the native shape of the API, with a Retry-After header when
+// the provider asked clients to wait
+func SendError(c *fiber.Ctx, format Format, e *APIError, requestID string) error {
+	if e.RetryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	}
+	switch format {
+	case FormatAnthropic:
+		return c.Status(e.Status).JSON(e.anthropicBody(requestID))
+	case FormatGemini:
+		return c.Status(e.statusFor(format)).JSON(e.geminiBody())
+	default:
+		return c.Status(e.statusFor(format)).JSON(e.openAIBody())
+	}
+}
+
+// statusFor returns the status sent to clients of the API; only Anthropic has 529
+func (e *APIError) statusFor(format Format) int {
+	if e.Status == statusOverloaded && format != FormatAnthropic {
+		return http.StatusServiceUnavailable
+	}
+	return e.Status
+}
+
+func (e *APIError) openAIBody() ErrorResponse {
+	errorType, code := e.openAIType, e.openAICode
+	if errorType == "" {
+		errorType, code = openAITypes[e.Kind][0], openAITypes[e.Kind][1]
+	}
+	detail := ErrorDetail{Message: e.Message, Type: errorType, Code: code}
+	if e.openAIParam != "" {
+		detail.Param = &e.openAIParam
+	}
+	return ErrorResponse{Error: detail}
+}
+
+// openAITypes holds the OpenAI error type and code of each kind
+var openAITypes = map[ErrorKind][2]string{
+	KindInvalidRequest:  {"invalid_request_error", "bad_request"},
+	KindAuthentication:  {"authentication_error", "unauthorized"},
+	KindPermission:      {"permission_error", "forbidden"},
+	KindNotFound:        {"invalid_request_error", "not_found"},
+	KindRequestTooLarge: {"invalid_request_error", "request_too_large"},
+	KindRateLimit:       {"rate_limit_error", "rate_limit_exceeded"},
+	KindCancelled:       {"invalid_request_error", "request_cancelled"},
+	KindTimeout:         {"timeout_error", "timeout"},
+	KindOverloaded:      {"server_error", "overloaded"},
+	KindAPI:             {"internal_error", "completion_failed"},
+}
+
+func (e *APIError) anthropicBody(requestID string) fiber.Map {
+	errorType := e.anthropic
+	if errorType == "" {
+		errorType = anthropicTypes[e.Kind]
+	}
+	return fiber.Map{
+		"type": "error",
+		"error": fiber.Map{
+			"type":    errorType,
+			"message": e.Message,
+		},
+		"request_id": requestID,
+	}
+}
+
+// anthropicTypes holds the Anthropic error type of each kind
+var anthropicTypes = map[ErrorKind]string{
+	KindInvalidRequest:  "invalid_request_error",
+	KindAuthentication:  "authentication_error",
+	KindPermission:      "permission_error",
+	KindNotFound:        "not_found_error",
+	KindRequestTooLarge: "request_too_large",
+	KindRateLimit:       "rate_limit_error",
+	KindCancelled:       "invalid_request_error",
+	KindTimeout:         "timeout_error",
+	KindOverloaded:      "overloaded_error",
+	KindAPI:             "api_error",
+}
+
+func (e *APIError) geminiBody() fiber.Map {
+	status := e.geminiState
+	if status == "" {
+		status = geminiStatuses[e.Kind]
+	}
+	body := fiber.Map{
+		"code":    e.statusFor(FormatGemini),
+		"message": e.Message,
+		"status":  status,
+	}
+	details := e.gemini
+	if details == nil && e.RetryAfter > 0 {
+		// Google clients read the retry delay from RetryInfo rather than the header
+		details = []map[string]any{{
+			"@type":      "type.googleapis.com/google.rpc.RetryInfo",
+			"retryDelay": fmt.Sprintf("%ds", int(math.Ceil(e.RetryAfter.Seconds()))),
+		}}
+	}
+	if details != nil {
+		body["details"] = details
+	}
+	return fiber.Map{"error": body}
+}
+
+// geminiStatuses holds the google.rpc.Code name of each kind
+var geminiStatuses = map[ErrorKind]string{
+	KindInvalidRequest:  "INVALID_ARGUMENT",
+	KindAuthentication:  "UNAUTHENTICATED",
+	KindPermission:      "PERMISSION_DENIED",
+	KindNotFound:        "NOT_FOUND",
+	KindRequestTooLarge: "INVALID_ARGUMENT",
+	KindRateLimit:       "RESOURCE_EXHAUSTED",
+	KindCancelled:       "CANCELLED",
+	KindTimeout:         "DEADLINE_EXCEEDED",
+	KindOverloaded:      "UNAVAILABLE",
+	KindAPI:             "INTERNAL",
+}
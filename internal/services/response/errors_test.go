@@ -0,0 +1,250 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+// openaiError is the error the OpenAI SDK returns for a response with status, header and body
+func openaiError(t *testing.T, status int, header http.Header, body string) error {
+	t.Helper()
+	err := &openai.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil),
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+	if decodeErr := err.UnmarshalJSON([]byte(body)); decodeErr != nil {
+		t.Fatalf("decode OpenAI error: %v", decodeErr)
+	}
+	return err
+}
+
+// anthropicError is the error the Anthropic SDK returns for a response with status and body
+func anthropicError(t *testing.T, status int, body string) error {
+	t.Helper()
+	err := &anthropic.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", nil),
+		Response:   &http.Response{StatusCode: status, Header: http.Header{}},
+	}
+	if decodeErr := err.UnmarshalJSON([]byte(body)); decodeErr != nil {
+		t.Fatalf("decode Anthropic error: %v", decodeErr)
+	}
+	return err
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantKind       ErrorKind
+		wantMessage    string
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:           "openai rate limit",
+			err:            openaiError(t, 429, http.Header{"Retry-After": []string{"7"}}, `{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}`),
+			wantStatus:     429,
+			wantKind:       KindRateLimit,
+			wantMessage:    "Rate limit reached",
+			wantRetryAfter: 7 * time.Second,
+		},
+		{
+			name:        "anthropic overloaded",
+			err:         anthropicError(t, 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`),
+			wantStatus:  529,
+			wantKind:    KindOverloaded,
+			wantMessage: "Overloaded",
+		},
+		{
+			name:        "gemini not found",
+			err:         genai.APIError{Code: 404, Message: "models/nope is not found", Status: "NOT_FOUND"},
+			wantStatus:  404,
+			wantKind:    KindNotFound,
+			wantMessage: "models/nope is not found",
+		},
+		{
+			name:        "provider error without message",
+			err:         openaiError(t, 401, nil, `{}`),
+			wantStatus:  401,
+			wantKind:    KindAuthentication,
+			wantMessage: "provider responded with status 401",
+		},
+		{
+			name:        "wrapped in a stream error",
+			err:         contracts.NewProviderError("req", "openai", openaiError(t, 400, nil, `{"message":"Bad tool schema"}`)),
+			wantStatus:  400,
+			wantKind:    KindInvalidRequest,
+			wantMessage: "Bad tool schema",
+		},
+		{
+			name:        "last failure of a fallback",
+			err:         fmt.Errorf("fallback: %w", &genai.APIError{Code: 503, Message: "Unavailable"}),
+			wantStatus:  503,
+			wantKind:    KindOverloaded,
+			wantMessage: "Unavailable",
+		},
+		{
+			name:       "client disconnected",
+			err:        contracts.NewClientDisconnectError("req"),
+			wantStatus: StatusClientClosedRequest,
+			wantKind:   KindCancelled,
+		},
+		{name: "cancelled", err: context.Canceled, wantStatus: StatusClientClosedRequest, wantKind: KindCancelled},
+		{name: "deadline", err: fmt.Errorf("race timeout: %w", context.DeadlineExceeded), wantStatus: 504, wantKind: KindTimeout},
+		{name: "network timeout", err: timeoutError{}, wantStatus: 504, wantKind: KindTimeout},
+		{name: "circuit breaker open", err: circuitbreaker.ErrOpen, wantStatus: 503, wantKind: KindOverloaded},
+		{name: "stream failed", err: contracts.NewProviderError("req", "openai", errors.New("bad chunk")), wantStatus: 502, wantKind: KindAPI},
+		{name: "connection dropped", err: io.ErrUnexpectedEOF, wantStatus: 502, wantKind: KindAPI},
+		{name: "internal", err: errors.New("no providers available"), wantStatus: 500, wantKind: KindAPI},
+		{name: "already classified", err: fmt.Errorf("wrapped: %w", NewAPIError(403, "Forbidden")), wantStatus: 403, wantKind: KindPermission, wantMessage: "Forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyError(tt.err)
+			if got.Status != tt.wantStatus || got.Kind != tt.wantKind || got.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("ClassifyError() = %d %s (retry after %v), want %d %s (retry after %v)",
+					got.Status, got.Kind, got.RetryAfter, tt.wantStatus, tt.wantKind, tt.wantRetryAfter)
+			}
+			if tt.wantMessage != "" && got.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSendError(t *testing.T) {
+	rateLimited := ClassifyError(openaiError(t, 429, http.Header{"Retry-After": []string{"1.2"}},
+		`{"message":"Slow down","type":"tokens","code":"rate_limit_exceeded","param":"messages"}`))
+	overloaded := ClassifyError(anthropicError(t, 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+	quota := ClassifyError(genai.APIError{Code: 429, Message: "Quota exceeded", Status: "RESOURCE_EXHAUSTED", Details: []map[string]any{
+		{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "30s"},
+	}})
+	internal := ClassifyError(errors.New("boom"))
+
+	tests := []struct {
+		name           string
+		err            *APIError
+		format         Format
+		wantStatus     int
+		wantRetryAfter string
+		wantBody       string
+	}{
+		{
+			name:           "openai native fields",
+			err:            rateLimited,
+			format:         FormatOpenAI,
+			wantStatus:     429,
+			wantRetryAfter: "2",
+			wantBody:       `{"error":{"message":"Slow down","type":"tokens","code":"rate_limit_exceeded","param":"messages"}}`,
+		},
+		{
+			name:           "openai error in anthropic shape",
+			err:            rateLimited,
+			format:         FormatAnthropic,
+			wantStatus:     429,
+			wantRetryAfter: "2",
+			wantBody:       `{"type":"error","error":{"type":"rate_limit_error","message":"Slow down"},"request_id":"req-1"}`,
+		},
+		{
+			name:       "anthropic native type",
+			err:        overloaded,
+			format:     FormatAnthropic,
+			wantStatus: 529,
+			wantBody:   `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"req-1"}`,
+		},
+		{
+			name:       "529 is 503 for openai clients",
+			err:        overloaded,
+			format:     FormatOpenAI,
+			wantStatus: 503,
+			wantBody:   `{"error":{"message":"Overloaded","type":"server_error","code":"overloaded","param":null}}`,
+		},
+		{
+			name:       "529 is 503 for gemini clients",
+			err:        overloaded,
+			format:     FormatGemini,
+			wantStatus: 503,
+			wantBody:   `{"error":{"code":503,"message":"Overloaded","status":"UNAVAILABLE"}}`,
+		},
+		{
+			name:           "gemini native details",
+			err:            quota,
+			format:         FormatGemini,
+			wantStatus:     429,
+			wantRetryAfter: "30",
+			wantBody:       `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"30s"}]}}`,
+		},
+		{
+			name:           "retry info added for gemini clients",
+			err:            rateLimited,
+			format:         FormatGemini,
+			wantStatus:     429,
+			wantRetryAfter: "2",
+			wantBody:       `{"error":{"code":429,"message":"Slow down","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"2s"}]}}`,
+		},
+		{
+			name:       "internal error",
+			err:        internal,
+			format:     FormatOpenAI,
+			wantStatus: 500,
+			wantBody:   `{"error":{"message":"boom","type":"internal_error","code":"completion_failed","param":null}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return SendError(c, tt.format, tt.err, "req-1")
+			})
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get(fiber.HeaderRetryAfter); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			var got, want any
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("body %s is not JSON: %v", body, err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("wantBody is not JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+}
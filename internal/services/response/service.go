@@ -22,6 +22,8 @@ type ErrorDetail struct {
 	Message string `json:"message"`
 	Type    string `json:"type"`
 	Code    string `json:"code"`
+	// Param names the offending request parameter, when known
+	Param *string `json:"param"`
 }
 
 // Error sends an error response with specified status, type, and code
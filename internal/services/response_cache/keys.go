@@ -0,0 +1,168 @@
+package response_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ignoredFields do not change the response and are left out of cache keys
+var ignoredFields = []string{
+	"stream", "stream_options", "model_router", "fallback", "provider_configs",
+	"include_routing", "cache_response", "user", "metadata",
+}
+
+// Key identifies a request in the response cache
+type Key struct {
+	// Exact hashes everything in the request that affects the response
+	Exact string
+	// Context hashes the request without its final message, so semantic hits only match
+	// requests that differ in the wording of the final message
+	Context string
+	// Prompt is the text of the final message, embedded for semantic lookups
+	Prompt string
+}
+
+// OpenAIKey returns the cache key of a chat completion request. It returns false when the
+// request is not deterministic (no zero temperature or seed, or tools are set) unless
+// cache_response forces caching, and always when the cache is disabled.
+func (s *Service) OpenAIKey(c *fiber.Ctx, req *models.ChatCompletionRequest) (Key, bool) {
+	if s == nil {
+		return Key{}, false
+	}
+	deterministic := (req.Temperature.Valid() && req.Temperature.Value == 0) || req.Seed.Valid()
+	if !cacheable(req.CacheResponse, deterministic, len(req.Tools) > 0) {
+		return Key{}, false
+	}
+	prompt, err := utils.ExtractLastMessage(req.Messages)
+	if err != nil {
+		return Key{}, false
+	}
+	return s.newKey(c, "chat_completions", string(req.Model), req, "messages", prompt)
+}
+
+// AnthropicKey returns the cache key of a Messages request, following the rules of OpenAIKey.
+// Anthropic has no seed, so only a zero temperature makes a request deterministic.
+func (s *Service) AnthropicKey(c *fiber.Ctx, req *models.AnthropicMessageRequest) (Key, bool) {
+	if s == nil {
+		return Key{}, false
+	}
+	deterministic := req.Temperature.Valid() && req.Temperature.Value == 0
+	if !cacheable(req.CacheResponse, deterministic, len(req.Tools) > 0) {
+		return Key{}, false
+	}
+	prompt, err := utils.ExtractPromptFromAnthropicMessages(req.Messages)
+	if err != nil {
+		return Key{}, false
+	}
+	return s.newKey(c, "messages", string(req.Model), req, "messages", prompt)
+}
+
+// GeminiKey returns the cache key of a GenerateContent request, following the rules of OpenAIKey
+func (s *Service) GeminiKey(c *fiber.Ctx, req *models.GeminiGenerateRequest) (Key, bool) {
+	if s == nil {
+		return Key{}, false
+	}
+	deterministic := false
+	if cfg := req.GenerationConfig; cfg != nil {
+		deterministic = (cfg.Temperature != nil && *cfg.Temperature == 0) || cfg.Seed != nil
+	}
+	if !cacheable(req.CacheResponse, deterministic, len(req.Tools) > 0) {
+		return Key{}, false
+	}
+	prompt, err := utils.ExtractPromptFromGeminiContents(req.Contents)
+	if err != nil {
+		return Key{}, false
+	}
+	return s.newKey(c, "generate", req.Model, req, "contents", prompt)
+}
+
+// cacheable applies the explicit cache_response flag, or otherwise caches deterministic
+// requests without tools
+func cacheable(explicit *bool, deterministic, hasTools bool) bool {
+	if explicit != nil {
+		return *explicit
+	}
+	return deterministic && !hasTools
+}
+
+// newKey hashes the request without the ignored fields. Entries are scoped to the caller's
+// organization (or API key) so responses are never shared across tenants, and to the request's
+// route so they are never served to requests that would be routed to other models.
+func (s *Service) newKey(c *fiber.Ctx, endpoint, model string, req any, messagesField, prompt string) (Key, bool) {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return Key{}, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return Key{}, false
+	}
+	for _, field := range ignoredFields {
+		delete(fields, field)
+	}
+
+	scope := endpoint
+	if apiKey, ok := auth.GetAPIKey(c); ok && apiKey != nil {
+		if apiKey.OrganizationID != "" {
+			scope += ":org:" + apiKey.OrganizationID
+		} else {
+			scope += ":key:" + strconv.FormatUint(uint64(apiKey.ID), 10)
+		}
+	}
+	scope += "\n" + s.route(c, model)
+
+	exact, err := hashFields(scope, fields)
+	if err != nil {
+		return Key{}, false
+	}
+	if messages, ok := fields[messagesField].([]any); ok && len(messages) > 0 {
+		fields[messagesField] = messages[:len(messages)-1]
+	}
+	context, err := hashFields(scope, fields)
+	if err != nil {
+		return Key{}, false
+	}
+
+	return Key{Exact: exact, Context: context, Prompt: prompt}, true
+}
+
+// route identifies how the request is routed: the matched routing rule, the router cache
+// namespace (which holds the candidates left after the rule), the experiment arm and the chain
+// of a requested alias. It must be taken after routing rules and experiments were applied.
+func (s *Service) route(c *fiber.Ctx, model string) string {
+	ctx := c.UserContext()
+	parts := []string{model_router.CacheNamespace(ctx)}
+	if rule := model_router.RuleFromContext(ctx); rule != nil {
+		parts = append(parts, "rule:"+rule.Name)
+	}
+	if trace := routing_trace.From(c).Snapshot(); trace != nil && trace.Experiment != nil {
+		parts = append(parts, "experiment:"+trace.Experiment.Experiment+"/"+trace.Experiment.Arm)
+	}
+	if alias, ok := s.aliases[model]; ok {
+		parts = append(parts, "alias:"+strings.Join(alias.Models, ","))
+	}
+	return strings.Join(parts, "|")
+}
+
+// hashFields hashes the scope and fields; encoding/json sorts map keys, so equal requests
+// always produce the same hash
+func hashFields(scope string, fields map[string]any) (string, error) {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	hash := sha256.Sum256(append([]byte(scope+"\n"), encoded...))
+	return "response:" + hex.EncodeToString(hash[:]), nil
+}
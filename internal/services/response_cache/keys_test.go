@@ -0,0 +1,70 @@
+package response_cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/genai"
+)
+
+func TestKeyScopedToRoute(t *testing.T) {
+	s := &Service{aliases: map[string]models.ModelAlias{
+		"fast": {Models: []string{"openai:gpt-4o-mini", "anthropic:claude-haiku-4-5"}},
+	}}
+	cacheResponse := true
+
+	// key returns the exact key of the same request routed as route describes
+	key := func(model string, route func(c *fiber.Ctx)) string {
+		app := fiber.New()
+		c := app.AcquireCtx(&fasthttp.RequestCtx{})
+		defer app.ReleaseCtx(c)
+		c.SetUserContext(context.Background())
+		routing_trace.Start(c, false)
+		route(c)
+
+		req := &models.GeminiGenerateRequest{
+			Model:         model,
+			Contents:      []*genai.Content{genai.NewContentFromText("hello", genai.RoleUser)},
+			CacheResponse: &cacheResponse,
+		}
+		got, ok := s.GeminiKey(c, req)
+		if !ok {
+			t.Fatal("GeminiKey() found the request not cacheable")
+		}
+		return got.Exact
+	}
+	withRule := func(name string) func(c *fiber.Ctx) {
+		return func(c *fiber.Ctx) {
+			c.SetUserContext(model_router.WithRule(c.UserContext(), &models.RoutingRule{Name: name}))
+		}
+	}
+	withArm := func(arm string) func(c *fiber.Ctx) {
+		return func(c *fiber.Ctx) {
+			routing_trace.From(c).SetExperiment(&models.ExperimentAssignment{Experiment: "exp", Arm: arm})
+		}
+	}
+	unrouted := func(c *fiber.Ctx) {}
+
+	if key("gemini-2.5-flash", unrouted) != key("gemini-2.5-flash", unrouted) {
+		t.Error("equal requests have different keys")
+	}
+	if key("gemini-2.5-flash", withRule("eu")) == key("gemini-2.5-flash", withRule("us")) {
+		t.Error("requests matching different routing rules share a key")
+	}
+	if key("gemini-2.5-flash", withArm("control")) == key("gemini-2.5-flash", withArm("treatment")) {
+		t.Error("requests assigned to different experiment arms share a key")
+	}
+
+	// An alias is keyed by its chain, not only by its name
+	fast := key("fast", unrouted)
+	s.aliases["fast"] = models.ModelAlias{Models: []string{"anthropic:claude-haiku-4-5"}}
+	if key("fast", unrouted) == fast {
+		t.Error("requests for an alias with different chains share a key")
+	}
+}
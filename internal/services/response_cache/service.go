@@ -0,0 +1,258 @@
+package response_cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/config"
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/services/semantic_cache"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/stream_simulator"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+const (
+	// defaultSemanticThreshold is stricter than the router cache's since a hit replaces the
+	// whole response, not just the model choice
+	defaultSemanticThreshold = 0.95
+	// semanticCandidates is how many of the most similar entries are checked for a matching context
+	semanticCandidates = 5
+	redisKeyPrefix     = "response_cache:"
+)
+
+// Hit is a response served from the cache
+type Hit struct {
+	Response *models.CachedResponse
+	// Semantic is set for similarity matches, with the similarity score in Score
+	Semantic bool
+	Score    float32
+	// Latency is how long the lookup took, which is all the client waits for on a hit
+	Latency time.Duration
+}
+
+// Service caches complete responses of deterministic requests and replays them, as JSON or as
+// a simulated stream, without calling a provider. A nil *Service is a disabled cache.
+type Service struct {
+	cache        *semantic_cache.Cache[models.CachedResponse]
+	threshold    float32
+	exactTTL     time.Duration
+	semanticTTL  time.Duration
+	usageService *usage.Service
+	// aliases are the configured model aliases, whose chains are part of cache keys
+	aliases map[string]models.ModelAlias
+}
+
+// NewService creates the response cache. It returns nil when the cache is not enabled.
+func NewService(cfg *config.Config, usageService *usage.Service) (*Service, error) {
+	cacheConfig := cfg.ResponseCache
+	if cacheConfig == nil || !cacheConfig.Enabled {
+		return nil, nil
+	}
+
+	threshold := cacheConfig.SemanticThreshold
+	if threshold == 0 {
+		threshold = defaultSemanticThreshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("invalid semantic threshold %.2f; must be in (0.0, 1.0]", threshold)
+	}
+
+	embedding, err := cfg.ResolveEmbedding(*cacheConfig)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := semantic_cache.New[models.CachedResponse](*cacheConfig, embedding, redisKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fiberlog.Infof("ResponseCache: Enabled (backend: %s, threshold: %.2f)", cacheConfig.Backend, threshold)
+	exactTTL, semanticTTL := cacheConfig.TierTTLs()
+	return &Service{
+		cache:        cache,
+		threshold:    float32(threshold),
+		exactTTL:     exactTTL,
+		semanticTTL:  semanticTTL,
+		usageService: usageService,
+		aliases:      cfg.ModelAliases,
+	}, nil
+}
+
+// Lookup finds a cached response by exact key first, then by similarity of the final message
+// among entries with the same context
+func (s *Service) Lookup(ctx context.Context, key Key, requestID string) (*Hit, bool) {
+	if s == nil {
+		return nil, false
+	}
+	start := time.Now()
+
+	if cached, found, err := s.cache.Get(ctx, key.Exact); err != nil {
+		fiberlog.Errorf("[%s] ResponseCache: Error during exact lookup: %v", requestID, err)
+	} else if found && models.Expired(cached.CachedAt, s.exactTTL, time.Now()) {
+		fiberlog.Debugf("[%s] ResponseCache: Exact entry expired", requestID)
+		s.cache.DeleteAsync(ctx, key.Exact)
+	} else if found {
+		fiberlog.Infof("[%s] ResponseCache: Exact hit (%s/%s)", requestID, cached.Provider, cached.Model)
+		latency := time.Since(start)
+		s.cache.RecordHit(false, 1.0, latency)
+		return &Hit{Response: &cached, Score: 1.0, Latency: latency}, true
+	}
+
+	matches, err := s.cache.TopMatches(ctx, key.Prompt, semanticCandidates)
+	if err != nil {
+		fiberlog.Errorf("[%s] ResponseCache: Error during semantic lookup: %v", requestID, err)
+		s.cache.RecordMiss(time.Since(start))
+		return nil, false
+	}
+	for _, match := range matches {
+		if match.Score < s.threshold {
+			break
+		}
+		if match.Value.Context != key.Context || models.Expired(match.Value.CachedAt, s.semanticTTL, time.Now()) {
+			continue
+		}
+		fiberlog.Infof("[%s] ResponseCache: Semantic hit (%s/%s, score: %.2f)",
+			requestID, match.Value.Provider, match.Value.Model, match.Score)
+		latency := time.Since(start)
+		s.cache.RecordHit(true, match.Score, latency)
+		return &Hit{Response: &match.Value, Semantic: true, Score: match.Score, Latency: latency}, true
+	}
+
+	fiberlog.Debugf("[%s] ResponseCache: Miss", requestID)
+	s.cache.RecordMiss(time.Since(start))
+	return nil, false
+}
+
+// Store saves the response just written to c (fire-and-forget). Only successful JSON responses
+// are stored; the provider and model are taken from the routing trace. Streams are relayed
+// chunk by chunk and never assembled into a response, so streamed responses are not stored;
+// cached responses are still replayed to streaming requests.
+func (s *Service) Store(c *fiber.Ctx, key Key, requestID string) {
+	if s == nil || c.Response().StatusCode() != fiber.StatusOK {
+		return
+	}
+	body := c.Response().Body()
+	if !json.Valid(body) {
+		return
+	}
+
+	trace := routing_trace.From(c).Snapshot()
+	if trace == nil || trace.Provider == "" {
+		return
+	}
+
+	// The response buffer is reused once the request completes
+	cached := models.CachedResponse{
+		Provider: trace.Provider,
+		Model:    trace.Model,
+		Context:  key.Context,
+		Body:     append(json.RawMessage(nil), body...),
+		CachedAt: time.Now(),
+	}
+	fiberlog.Debugf("[%s] ResponseCache: Storing response (%s/%s)", requestID, cached.Provider, cached.Model)
+	s.cache.SetAsync(context.WithoutCancel(c.UserContext()), key.Exact, key.Prompt, cached)
+}
+
+// ServeOpenAI replays a cached chat completion, as SSE when the client asked to stream
+func (s *Service) ServeOpenAI(c *fiber.Ctx, hit *Hit, isStream bool, requestID string) error {
+	var resp models.ChatCompletion
+	if err := json.Unmarshal(hit.Response.Body, &resp); err != nil {
+		return fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	resp.Usage.CacheTier = models.CacheTierPromptResponse
+
+	trace := s.recordHit(c, hit, "/v1/chat/completions", int(resp.Usage.PromptTokens), int(resp.Usage.CompletionTokens), requestID)
+	resp.Routing = trace.ForResponse()
+
+	if isStream {
+		return stream_simulator.StreamOpenAICachedResponse(c, &resp, requestID)
+	}
+	return c.JSON(resp)
+}
+
+// ServeAnthropic replays a cached Messages response, as SSE when the client asked to stream
+func (s *Service) ServeAnthropic(c *fiber.Ctx, hit *Hit, isStream bool, requestID string) error {
+	var resp models.AnthropicMessage
+	if err := json.Unmarshal(hit.Response.Body, &resp); err != nil {
+		return fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	resp.Usage.CacheTier = models.CacheTierPromptResponse
+
+	s.recordHit(c, hit, "/v1/messages", int(resp.Usage.InputTokens), int(resp.Usage.OutputTokens), requestID)
+
+	if isStream {
+		return stream_simulator.StreamAnthropicCachedResponse(c, &resp, requestID)
+	}
+	return c.JSON(resp)
+}
+
+// ServeGemini replays a cached GenerateContent response, as SSE when the client asked to stream
+func (s *Service) ServeGemini(c *fiber.Ctx, hit *Hit, isStream bool, requestID string) error {
+	var resp models.GeminiGenerateContentResponse
+	if err := json.Unmarshal(hit.Response.Body, &resp); err != nil {
+		return fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	if resp.UsageMetadata == nil {
+		resp.UsageMetadata = &models.AdaptiveGeminiUsage{}
+	}
+	resp.UsageMetadata.CacheTier = models.CacheTierPromptResponse
+
+	s.recordHit(c, hit, "/v1beta/models/:model:generateContent",
+		int(resp.UsageMetadata.PromptTokenCount), int(resp.UsageMetadata.CandidatesTokenCount), requestID)
+
+	if isStream {
+		return stream_simulator.StreamGeminiCachedResponse(c, &resp, requestID)
+	}
+	return c.JSON(resp)
+}
+
+// recordHit adds the hit to the routing trace and records usage without cost, since no
+// provider was called
+func (s *Service) recordHit(c *fiber.Ctx, hit *Hit, endpoint string, inputTokens, outputTokens int, requestID string) *routing_trace.Recorder {
+	trace := routing_trace.From(c)
+	trace.SetCache(models.CacheTierPromptResponse, hit.Score)
+	trace.Select(hit.Response.Provider, hit.Response.Model)
+	usage.SetUsageMetadata(c, "cache_tier", models.CacheTierPromptResponse)
+
+	if s.usageService == nil {
+		return trace
+	}
+	apiKey, ok := auth.GetAPIKey(c)
+	if !ok || apiKey == nil {
+		return trace
+	}
+	_, err := s.usageService.RecordUsage(c.UserContext(), models.RecordUsageParams{
+		APIKeyID:       apiKey.ID,
+		OrganizationID: apiKey.OrganizationID,
+		UserID:         apiKey.UserID,
+		Endpoint:       endpoint,
+		Provider:       hit.Response.Provider,
+		Model:          hit.Response.Model,
+		TokensInput:    inputTokens,
+		TokensOutput:   outputTokens,
+		StatusCode:     200,
+		LatencyMs:      int(hit.Latency.Milliseconds()),
+		RequestID:      requestID,
+		Metadata:       usage.UsageMetadata(c),
+	})
+	if err != nil {
+		fiberlog.Errorf("[%s] Failed to record usage: %v", requestID, err)
+	}
+	return trace
+}
+
+// Stats reports the response cache's entries and hit rates
+func (s *Service) Stats(ctx context.Context) (models.CacheStats, error) {
+	entries, err := s.cache.Len(ctx)
+	if err != nil {
+		return models.CacheStats{}, fmt.Errorf("failed to count response cache entries: %w", err)
+	}
+	return s.cache.Stats("response", entries), nil
+}
@@ -0,0 +1,318 @@
+// Package routing_trace collects the routing decisions made for a request so they can be
+// returned to the client as X-Adaptive-* headers and an optional routing object.
+package routing_trace
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const localKey = "routing_trace"
+
+type contextKey struct{}
+
+// Recorder accumulates a request's routing trace. It is safe for concurrent use, since race
+// fallback attempts record in parallel, and all methods are no-ops on a nil Recorder.
+type Recorder struct {
+	mu       sync.Mutex
+	trace    models.RoutingTrace
+	included bool
+	// forked counts the records a fork started with, which Merge does not copy back
+	forked struct{ filtered, fallbacks, race int }
+}
+
+// Start attaches a new recorder to the request, both to its locals and to its user context so
+// services that only receive a context.Context can record into it. includeInResponse reports
+// whether the client asked for the routing object in the response body.
+func Start(c *fiber.Ctx, includeInResponse bool) *Recorder {
+	r := &Recorder{included: includeInResponse}
+	Attach(c, r)
+	return r
+}
+
+// Attach makes r the recorder of c, in its locals and its user context
+func Attach(c *fiber.Ctx, r *Recorder) {
+	c.Locals(localKey, r)
+	c.SetUserContext(context.WithValue(c.UserContext(), contextKey{}, r))
+}
+
+// Fork returns a recorder for one of several hedged attempts at the request. It starts from a
+// copy of the trace so far, and what the attempt records, including the model it selects, stays
+// in the fork until Merge. Attempts running at the same time therefore never overwrite each
+// other's selection.
+func (r *Recorder) Fork() *Recorder {
+	if r == nil {
+		return nil
+	}
+	fork := &Recorder{trace: *r.Snapshot(), included: r.included}
+	fork.forked.filtered = len(fork.trace.Filtered)
+	fork.forked.fallbacks = len(fork.trace.Fallbacks)
+	fork.forked.race = len(fork.trace.Race)
+	return fork
+}
+
+// Merge adds the filtered candidates, failed attempts and race contenders recorded by fork
+// since it was forked from r. The fork's selection is not copied; the caller selects the
+// attempt that was served.
+func (r *Recorder) Merge(fork *Recorder) {
+	if r == nil || fork == nil {
+		return
+	}
+	fork.mu.Lock()
+	filtered := slices.Clone(fork.trace.Filtered[fork.forked.filtered:])
+	fallbacks := slices.Clone(fork.trace.Fallbacks[fork.forked.fallbacks:])
+	race := slices.Clone(fork.trace.Race[fork.forked.race:])
+	fork.forked.filtered += len(filtered)
+	fork.forked.fallbacks += len(fallbacks)
+	fork.forked.race += len(race)
+	fork.mu.Unlock()
+
+	for _, candidate := range filtered {
+		r.Filter(candidate.Provider, candidate.Model, candidate.Reason)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Fallbacks = append(r.trace.Fallbacks, fallbacks...)
+	r.trace.Race = append(r.trace.Race, race...)
+}
+
+// From returns the request's recorder, or nil when none was started
+func From(c *fiber.Ctx) *Recorder {
+	r, _ := c.Locals(localKey).(*Recorder)
+	return r
+}
+
+// FromContext returns the recorder attached to ctx, or nil
+func FromContext(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(contextKey{}).(*Recorder)
+	return r
+}
+
+// Consider records the router's candidate set. Only the first call has an effect, so models
+// removed by later filters stay listed as candidates.
+func (r *Recorder) Consider(candidates []models.ModelCapability) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.trace.Candidates != nil {
+		return
+	}
+	r.trace.Candidates = make([]models.Alternative, 0, len(candidates))
+	for _, candidate := range candidates {
+		r.trace.Candidates = append(r.trace.Candidates, models.Alternative{Provider: candidate.Provider, Model: candidate.ModelName})
+	}
+}
+
+// Filter records a candidate removed during routing
+func (r *Recorder) Filter(provider, model, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, filtered := range r.trace.Filtered {
+		if filtered.Provider == provider && filtered.Model == model && filtered.Reason == reason {
+			return
+		}
+	}
+	r.trace.Filtered = append(r.trace.Filtered, models.FilteredCandidate{Provider: provider, Model: model, Reason: reason})
+}
+
+// SetRule records the routing rule that matched the request
+func (r *Recorder) SetRule(name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Rule = name
+}
+
+// SetAlias records the model alias the request was resolved through
+func (r *Recorder) SetAlias(name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Alias = name
+}
+
+// SetExperiment records the experiment arm the request was assigned to
+func (r *Recorder) SetExperiment(assignment *models.ExperimentAssignment) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Experiment = assignment
+}
+
+// SetCache records the router cache tier and similarity score that produced the selection
+func (r *Recorder) SetCache(tier string, similarity float32) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.CacheTier = tier
+	r.trace.CacheSimilarity = similarity
+}
+
+// SetRouterLatency records how long model selection took
+func (r *Recorder) SetRouterLatency(latency time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.RouterLatencyMs = latency.Milliseconds()
+}
+
+// Select records the model chosen for (or serving) the request
+func (r *Recorder) Select(provider, model string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Provider = provider
+	r.trace.Model = model
+}
+
+// Failed records a failed provider attempt
+func (r *Recorder) Failed(provider, model string, err error, latency time.Duration) {
+	if r == nil || err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Fallbacks = append(r.trace.Fallbacks, models.FallbackAttempt{
+		Provider:  provider,
+		Model:     model,
+		Error:     err.Error(),
+		LatencyMs: latency.Milliseconds(),
+	})
+}
+
+// Contend records a streaming race contender's outcome and, when it produced content, its time
+// to first token
+func (r *Recorder) Contend(provider, model, outcome string, timeToFirstToken time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Race = append(r.trace.Race, models.RaceContender{
+		Provider:           provider,
+		Model:              model,
+		Outcome:            outcome,
+		TimeToFirstTokenMs: timeToFirstToken.Milliseconds(),
+	})
+}
+
+// Snapshot returns a copy of the trace recorded so far
+func (r *Recorder) Snapshot() *models.RoutingTrace {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	trace := r.trace
+	trace.Candidates = append([]models.Alternative(nil), r.trace.Candidates...)
+	trace.Filtered = append([]models.FilteredCandidate(nil), r.trace.Filtered...)
+	trace.Fallbacks = append([]models.FallbackAttempt(nil), r.trace.Fallbacks...)
+	trace.Race = append([]models.RaceContender(nil), r.trace.Race...)
+	return &trace
+}
+
+// ForResponse returns the trace for the response body, or nil when the client did not ask for it
+func (r *Recorder) ForResponse() *models.RoutingTrace {
+	if r == nil || !r.included {
+		return nil
+	}
+	return r.Snapshot()
+}
+
+// WriteHeaders sets the X-Adaptive-* response headers from the request's trace. Handlers defer
+// it so headers are set before fasthttp sends the response, including streamed ones.
+func WriteHeaders(c *fiber.Ctx) {
+	trace := From(c).Snapshot()
+	if trace == nil {
+		return
+	}
+
+	setHeader(c, "X-Adaptive-Provider", trace.Provider)
+	setHeader(c, "X-Adaptive-Model", trace.Model)
+	setHeader(c, "X-Adaptive-Routing-Rule", trace.Rule)
+	setHeader(c, "X-Adaptive-Model-Alias", trace.Alias)
+	if trace.Experiment != nil {
+		setHeader(c, "X-Adaptive-Experiment", trace.Experiment.Experiment+"/"+trace.Experiment.Arm)
+	}
+
+	candidates := make([]string, 0, len(trace.Candidates))
+	for _, candidate := range trace.Candidates {
+		candidates = append(candidates, modelKey(candidate.Provider, candidate.Model))
+	}
+	setHeader(c, "X-Adaptive-Candidates", strings.Join(candidates, ","))
+
+	filtered := make([]string, 0, len(trace.Filtered))
+	for _, candidate := range trace.Filtered {
+		filtered = append(filtered, modelKey(candidate.Provider, candidate.Model)+"="+candidate.Reason)
+	}
+	setHeader(c, "X-Adaptive-Filtered", strings.Join(filtered, ","))
+
+	setHeader(c, "X-Adaptive-Cache-Tier", trace.CacheTier)
+	if trace.CacheSimilarity > 0 {
+		setHeader(c, "X-Adaptive-Cache-Similarity", fmt.Sprintf("%.4f", trace.CacheSimilarity))
+	}
+	if trace.RouterLatencyMs > 0 {
+		setHeader(c, "X-Adaptive-Router-Latency-Ms", strconv.FormatInt(trace.RouterLatencyMs, 10))
+	}
+
+	// Error messages are only returned in the routing object; headers list the failed models
+	fallbacks := make([]string, 0, len(trace.Fallbacks))
+	for _, attempt := range trace.Fallbacks {
+		fallbacks = append(fallbacks, modelKey(attempt.Provider, attempt.Model))
+	}
+	setHeader(c, "X-Adaptive-Fallbacks", strings.Join(fallbacks, ","))
+
+	race := make([]string, 0, len(trace.Race))
+	for _, contender := range trace.Race {
+		entry := modelKey(contender.Provider, contender.Model) + "=" + contender.Outcome
+		if contender.TimeToFirstTokenMs > 0 {
+			entry += ":" + strconv.FormatInt(contender.TimeToFirstTokenMs, 10) + "ms"
+		}
+		race = append(race, entry)
+	}
+	setHeader(c, "X-Adaptive-Race", strings.Join(race, ","))
+}
+
+// modelKey formats a candidate as provider/model, or provider/* for provider-only entries
+func modelKey(provider, model string) string {
+	if model == "" {
+		model = "*"
+	}
+	return provider + "/" + model
+}
+
+// setHeader sets a response header when the value is not empty
+func setHeader(c *fiber.Ctx, key, value string) {
+	if value != "" {
+		c.Set(key, value)
+	}
+}
@@ -31,6 +31,11 @@ func (rs *RequestService) ParseSelectModelRequest(c *fiber.Ctx) (*models.SelectM
 
 // ValidateSelectModelRequest validates the parsed select model request
 func (rs *RequestService) ValidateSelectModelRequest(req *models.SelectModelRequest) error {
+	// A model alias carries its own provider chain
+	if req.Model != "" {
+		return nil
+	}
+
 	if len(req.Models) == 0 {
 		return &ValidationError{Field: "models", Message: "Models slice cannot be empty"}
 	}
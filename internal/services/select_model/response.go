@@ -24,6 +24,11 @@ func (rs *ResponseService) BadRequest(c *fiber.Ctx, message string) error {
 	return rs.Error(c, fiber.StatusBadRequest, message, "invalid_request_error", "bad_request")
 }
 
+// Forbidden sends a forbidden error response when routing policy rejects the request
+func (rs *ResponseService) Forbidden(c *fiber.Ctx, message string) error {
+	return rs.Error(c, fiber.StatusForbidden, message, "permission_error", "forbidden")
+}
+
 // InternalError sends an internal server error response specific to model selection
 func (rs *ResponseService) InternalError(c *fiber.Ctx, message string) error {
 	return rs.Error(c, fiber.StatusInternalServerError, message, "internal_error", "model_selection_failed")
@@ -2,15 +2,21 @@ package select_model
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Egham-7/adaptive-proxy/internal/models"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/routing_trace"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	fiberlog "github.com/gofiber/fiber/v2/log"
 )
 
+// ErrUnknownModel is returned when the requested model is neither a model alias nor in provider:model format
+var ErrUnknownModel = errors.New("unknown model: expected a model alias or provider:model")
+
 // Service handles model selection logic
 type Service struct {
 	modelRouter *model_router.ModelRouter
@@ -23,6 +29,74 @@ func NewService(modelRouter *model_router.ModelRouter) *Service {
 	}
 }
 
+// ApplyRoutingRules applies the routing rule matching the request to mergedConfig and assigns
+// the request to an experiment arm when one applies. When the rule pins a model or an arm is
+// assigned, that selection is returned and the model router can be skipped.
+func (s *Service) ApplyRoutingRules(
+	ctx context.Context,
+	rc models.RoutingContext,
+	req *models.SelectModelRequest,
+	mergedConfig *models.ModelRouterConfig,
+	requestID string,
+) (*models.SelectModelResponse, error) {
+	rule, model, err := s.modelRouter.ApplyRoutingRules(ctx, rc, req.Model, mergedConfig, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil && rule.Model != "" {
+		return s.ResolveModel(ctx, model, mergedConfig, requestID)
+	}
+
+	assignment := s.modelRouter.AssignExperiment(rc, model, rule, requestID)
+	if assignment == nil {
+		return nil, nil
+	}
+	resp, err := s.ResolveModel(ctx, assignment.Model, mergedConfig, requestID)
+	if err != nil {
+		return nil, err
+	}
+	resp.Experiment = assignment
+	routing_trace.FromContext(ctx).SetExperiment(assignment)
+	return resp, nil
+}
+
+// ScopeCache returns ctx with the router cache namespace of the request attached; see
+// model_router.ModelRouter.ScopeCache
+func (s *Service) ScopeCache(ctx context.Context, rc models.RoutingContext, mergedConfig *models.ModelRouterConfig) context.Context {
+	return s.modelRouter.ScopeCache(ctx, rc, mergedConfig)
+}
+
+// ResolveModel returns the selection for an explicitly requested model: the provider chain of
+// a model alias, or the provider:model itself
+func (s *Service) ResolveModel(
+	ctx context.Context,
+	model string,
+	mergedConfig *models.ModelRouterConfig,
+	requestID string,
+) (*models.SelectModelResponse, error) {
+	resp, _, err := s.modelRouter.ResolveAlias(model, mergedConfig, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		routing_trace.FromContext(ctx).SetAlias(model)
+		return &models.SelectModelResponse{
+			Provider:     resp.Provider,
+			Model:        resp.Model,
+			Alternatives: resp.Alternatives,
+		}, nil
+	}
+
+	provider, modelName, err := utils.ParseProviderModel(model)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownModel, model)
+	}
+	return &models.SelectModelResponse{
+		Provider: provider,
+		Model:    modelName,
+	}, nil
+}
+
 // SelectModel performs model selection based on the request
 func (s *Service) SelectModel(
 	ctx context.Context,
@@ -41,10 +115,16 @@ func (s *Service) SelectModel(
 
 	// Perform model selection directly with prompt
 	// Pass through tool context for function-calling-aware routing
+	profile := &models.RequestProfile{
+		EstimatedInputTokens: utils.EstimateTokens(req.Prompt) + utils.EstimateJSONTokens(req.Tools),
+		MaxOutputTokens:      req.MaxOutputTokens,
+		Features:             req.Features,
+	}
 	resp, cacheSource, err := s.modelRouter.SelectModelWithCache(
 		ctx,
 		req.Prompt, userID, requestID, mergedConfig, circuitBreakers,
 		req.Tools, req.ToolCall, // Pass tool context for intelligent routing
+		profile,
 	)
 	if err != nil {
 		fiberlog.Errorf("[%s] Model selection error: %v", requestID, err)
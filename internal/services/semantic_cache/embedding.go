@@ -0,0 +1,158 @@
+package semantic_cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/botirk38/semanticcache/types"
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+const (
+	defaultEmbeddingModel  = "text-embedding-3-large"
+	defaultLocalDimensions = 256
+	embeddingTimeout       = 10 * time.Second
+	// dimensionProbe is embedded at startup to detect the size of models not in knownDimensions
+	dimensionProbe = "dimension probe"
+)
+
+// knownDimensions lists the default embedding sizes of common models so no probe request is needed
+var knownDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// NewEmbeddingProvider creates the embedder for a resolved embedding configuration and returns
+// it with the size of the vectors it produces
+func NewEmbeddingProvider(embedding models.EmbeddingConfig) (types.EmbeddingProvider, int, error) {
+	if embedding.Type == models.EmbeddingProviderLocal {
+		dimensions := embedding.Dimensions
+		if dimensions == 0 {
+			dimensions = defaultLocalDimensions
+		}
+		return &localEmbedder{dimensions: dimensions}, dimensions, nil
+	}
+
+	model := embedding.Model
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+	opts := []option.RequestOption{}
+	if embedding.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(embedding.APIKey))
+	}
+	if embedding.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(embedding.BaseURL))
+	}
+	provider := &openAIEmbedder{
+		client:     openai.NewClient(opts...),
+		model:      model,
+		dimensions: embedding.Dimensions,
+	}
+
+	if embedding.Dimensions > 0 {
+		return provider, embedding.Dimensions, nil
+	}
+	if dimensions, ok := knownDimensions[model]; ok {
+		return provider, dimensions, nil
+	}
+	probe, err := provider.EmbedText(dimensionProbe)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to detect dimensions of embedding model %s: %w", model, err)
+	}
+	return provider, len(probe), nil
+}
+
+// openAIEmbedder calls an OpenAI-compatible /embeddings endpoint
+type openAIEmbedder struct {
+	client     openai.Client
+	model      string
+	dimensions int
+}
+
+// EmbedText embeds text with the configured model
+func (e *openAIEmbedder) EmbedText(text string) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingTimeout)
+	defer cancel()
+
+	params := openai.EmbeddingNewParams{
+		Model: openai.EmbeddingModel(e.model),
+		Input: openai.EmbeddingNewParamsInputUnion{OfString: openai.String(text)},
+	}
+	if e.dimensions > 0 {
+		params.Dimensions = openai.Int(int64(e.dimensions))
+	}
+	resp, err := e.client.Embeddings.New(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no embedding returned by %s", e.model)
+	}
+
+	embedding := make([]float32, len(resp.Data[0].Embedding))
+	for i, v := range resp.Data[0].Embedding {
+		embedding[i] = float32(v)
+	}
+	return embedding, nil
+}
+
+func (e *openAIEmbedder) Close() {}
+
+// localEmbedder hashes words and word pairs into a fixed-size vector. It has no notion of
+// meaning, but it is deterministic and offline, so identical and near-identical prompts match.
+type localEmbedder struct {
+	dimensions int
+}
+
+// EmbedText returns the L2-normalized feature hash of text
+func (e *localEmbedder) EmbedText(text string) ([]float32, error) {
+	vector := make([]float32, e.dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		e.add(vector, word)
+		if i > 0 {
+			e.add(vector, words[i-1]+" "+word)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		// Cosine similarity is undefined for the zero vector
+		vector[0] = 1
+		return vector, nil
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector, nil
+}
+
+// add hashes a feature into one dimension, using a hash bit as the sign so collisions cancel out
+func (e *localEmbedder) add(vector []float32, feature string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&(1<<63) != 0 {
+		vector[sum%uint64(e.dimensions)]--
+	} else {
+		vector[sum%uint64(e.dimensions)]++
+	}
+}
+
+func (e *localEmbedder) Close() {}
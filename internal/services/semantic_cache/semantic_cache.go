@@ -0,0 +1,239 @@
+package semantic_cache
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/botirk38/semanticcache"
+	"github.com/botirk38/semanticcache/backends"
+	"github.com/botirk38/semanticcache/options"
+	"github.com/botirk38/semanticcache/similarity"
+	"github.com/botirk38/semanticcache/types"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultMemoryCapacity = 1000
+
+// Cache is a semantic cache that keeps its backend, so entries can be listed for
+// administration, and counts its lookups
+type Cache[V any] struct {
+	*semanticcache.SemanticCache[string, V]
+	backend     types.CacheBackend[string, V]
+	backendType models.CacheBackendType
+	provider    types.EmbeddingProvider
+	stats       stats
+}
+
+// vectorSearcher is implemented by backends that rank entries by similarity themselves,
+// instead of having every embedding fetched one at a time
+type vectorSearcher[V any] interface {
+	Search(ctx context.Context, embedding []float32, keyPrefix string, n int) ([]semanticcache.Match[V], error)
+}
+
+// scoredKey is the similarity of an entry's embedding to the searched one
+type scoredKey struct {
+	key   string
+	score float32
+}
+
+// topScored sorts candidates by descending similarity and keeps the first n
+func topScored(candidates []scoredKey, n int) []scoredKey {
+	slices.SortFunc(candidates, func(a, b scoredKey) int { return cmp.Compare(b.score, a.score) })
+	return candidates[:min(n, len(candidates))]
+}
+
+// New creates a semantic cache on the configured backend, embedding with the resolved embedding
+// configuration. Redis entries are stored under keyPrefix so several caches can share one Redis
+// database.
+func New[V any](cacheConfig models.CacheConfig, embedding models.EmbeddingConfig, keyPrefix string) (*Cache[V], error) {
+	provider, dimensions, err := NewEmbeddingProvider(embedding)
+	if err != nil {
+		fiberlog.Errorf("SemanticCache: Failed to create embedding provider: %v", err)
+		return nil, err
+	}
+	fiberlog.Debugf("SemanticCache: Using %s embeddings (model=%s, dimensions=%d)", embedding.Type, embedding.Model, dimensions)
+
+	backend := cacheConfig.Backend
+	if backend == "" {
+		backend = models.CacheBackendRedis // Default to Redis for backward compatibility
+		fiberlog.Warn("SemanticCache: Backend not specified, defaulting to redis")
+	}
+
+	var cacheBackend types.CacheBackend[string, V]
+
+	switch backend {
+	case models.CacheBackendMemory:
+		capacity := cacheConfig.Capacity
+		if capacity <= 0 {
+			capacity = defaultMemoryCapacity
+			fiberlog.Warnf("SemanticCache: Invalid or missing capacity, using default %d", capacity)
+		}
+		fiberlog.Debugf("SemanticCache: Using in-memory LRU backend with capacity=%d", capacity)
+		cacheBackend, err = backends.NewLRUBackend[string, V](types.BackendConfig{Capacity: capacity})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memory backend: %w", err)
+		}
+
+	case models.CacheBackendRedis:
+		// Get Redis URL from cache config
+		redisURL := cacheConfig.RedisURL
+		if redisURL == "" {
+			fiberlog.Error("SemanticCache: redis URL not set - please configure redis_url in cache config")
+			return nil, fmt.Errorf("redis URL not set - please configure redis_url in cache config")
+		}
+		// The backend rebuilds its vector index on startup, so entries of another size would
+		// silently stop matching
+		if err := validateStoredDimensions(redisURL, keyPrefix, dimensions); err != nil {
+			return nil, err
+		}
+		fiberlog.Debugf("SemanticCache: Using Redis backend with URL=%s, prefix=%s", redisURL, keyPrefix)
+		cacheBackend, err = backends.NewRedisBackend[string, V](types.BackendConfig{
+			ConnectionString: redisURL,
+			Options:          map[string]any{"prefix": keyPrefix, "dimensions": dimensions},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create redis backend: %w", err)
+		}
+
+	case models.CacheBackendSQLite:
+		if cacheConfig.SQLitePath == "" {
+			return nil, fmt.Errorf("sqlite path not set - please configure sqlite_path in cache config")
+		}
+		capacity := cacheConfig.Capacity
+		if capacity <= 0 {
+			capacity = defaultSQLiteCapacity
+		}
+		fiberlog.Debugf("SemanticCache: Using SQLite backend with path=%s, prefix=%s, capacity=%d",
+			cacheConfig.SQLitePath, keyPrefix, capacity)
+		sqliteBackend, err := newSQLiteBackend[V](cacheConfig.SQLitePath, keyPrefix, capacity)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := sqliteBackend.StoredDimensions(context.Background())
+		if err != nil {
+			_ = sqliteBackend.Close()
+			return nil, fmt.Errorf("failed to inspect semantic cache entries: %w", err)
+		}
+		if stored != 0 && stored != dimensions {
+			_ = sqliteBackend.Close()
+			return nil, fmt.Errorf("embedding dimensions %d do not match the %d-dimensional entries stored under %q; "+
+				"flush the cache or switch back to the previous embedding model", dimensions, stored, keyPrefix)
+		}
+		cacheBackend = sqliteBackend
+
+	default:
+		return nil, fmt.Errorf("unsupported cache backend: %s (supported: redis, memory, sqlite)", backend)
+	}
+
+	cache, err := semanticcache.New(
+		options.WithCustomProvider[string, V](provider),
+		options.WithCustomBackend(cacheBackend),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create semantic cache: %w", err)
+	}
+	return &Cache[V]{SemanticCache: cache, backend: cacheBackend, backendType: backend, provider: provider}, nil
+}
+
+// TopMatches returns up to n entries sorted by descending similarity to text
+func (c *Cache[V]) TopMatches(ctx context.Context, text string, n int) ([]semanticcache.Match[V], error) {
+	return c.TopMatchesWithPrefix(ctx, text, "", n)
+}
+
+// TopMatchesWithPrefix returns up to n of the entries whose key starts with keyPrefix, sorted by
+// descending similarity to text. Entries under other prefixes are left out before ranking, so
+// they never take the places of matching ones. The backend ranks entries when it can.
+func (c *Cache[V]) TopMatchesWithPrefix(ctx context.Context, text, keyPrefix string, n int) ([]semanticcache.Match[V], error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive")
+	}
+	embedding, err := c.provider.EmbedText(text)
+	if err != nil {
+		return nil, err
+	}
+	if searcher, ok := c.backend.(vectorSearcher[V]); ok {
+		return searcher.Search(ctx, embedding, keyPrefix, n)
+	}
+
+	keys, err := c.backend.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []scoredKey
+	for _, key := range keys {
+		if !strings.HasPrefix(key, keyPrefix) {
+			continue
+		}
+		stored, found, err := c.backend.GetEmbedding(ctx, key)
+		if err != nil || !found {
+			continue
+		}
+		candidates = append(candidates, scoredKey{key: key, score: similarity.CosineSimilarity(embedding, stored)})
+	}
+
+	// Only the best entries are read, and so marked as recently used
+	candidates = topScored(candidates, n)
+	matches := make([]semanticcache.Match[V], 0, len(candidates))
+	for _, candidate := range candidates {
+		entry, found, err := c.backend.Get(ctx, candidate.key)
+		if err == nil && found {
+			matches = append(matches, semanticcache.Match[V]{Value: entry.Value, Score: candidate.score})
+		}
+	}
+	return matches, nil
+}
+
+// Close releases the backend; the library does not close backends itself
+func (c *Cache[V]) Close() error {
+	if err := c.SemanticCache.Close(); err != nil {
+		return err
+	}
+	return c.backend.Close()
+}
+
+// Keys lists the keys of all entries. It scans the whole backend and is meant for administration.
+func (c *Cache[V]) Keys(ctx context.Context) ([]string, error) {
+	return c.backend.Keys(ctx)
+}
+
+// validateStoredDimensions checks that entries already stored under keyPrefix were embedded
+// with vectors of the configured size
+func validateStoredDimensions(redisURL, keyPrefix string, dimensions int) error {
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return fmt.Errorf("invalid redis URL: %w", err)
+	}
+	client := redis.NewClient(opt)
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, keyPrefix+"*", 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to inspect semantic cache entries: %w", err)
+		}
+		if len(keys) > 0 {
+			lengths, err := client.JSONArrLen(ctx, keys[0], "$.embedding").Result()
+			if err != nil || len(lengths) == 0 {
+				// Not a cache entry; nothing to compare against
+				return nil
+			}
+			if stored := int(lengths[0]); stored != dimensions {
+				return fmt.Errorf("embedding dimensions %d do not match the %d-dimensional entries stored under %q; "+
+					"flush the cache or switch back to the previous embedding model", dimensions, stored, keyPrefix)
+			}
+			return nil
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,300 @@
+package semantic_cache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/botirk38/semanticcache"
+	"github.com/botirk38/semanticcache/similarity"
+	"github.com/botirk38/semanticcache/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultSQLiteCapacity = 10000
+	// sqliteBusyTimeoutMs lets the cache share a database file with the usage database
+	sqliteBusyTimeoutMs = 5000
+)
+
+// likeEscaper escapes the LIKE wildcards of key prefixes, with ! as the escape character
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// sqliteEntry is one cache entry. Prefix keeps caches sharing a database file apart, and
+// AccessedAt orders entries for least-recently-used eviction.
+type sqliteEntry struct {
+	Prefix     string `gorm:"column:prefix;primaryKey;size:64;index:idx_semantic_cache_lru,priority:1"`
+	Key        string `gorm:"column:entry_key;primaryKey"`
+	Embedding  []byte `gorm:"column:embedding;not null"`
+	Value      []byte `gorm:"column:value;not null"`
+	AccessedAt int64  `gorm:"column:accessed_at;not null;index:idx_semantic_cache_lru,priority:2"`
+}
+
+func (sqliteEntry) TableName() string {
+	return "semantic_cache_entries"
+}
+
+// sqliteBackend stores entries, their embeddings and JSON-encoded values in a SQLite database,
+// so single-node deployments keep their cache across restarts without Redis. Similarity search
+// scans the embeddings of the prefix in one query; capacity bounds how many entries are kept.
+type sqliteBackend[V any] struct {
+	db       *gorm.DB
+	prefix   string
+	capacity int
+}
+
+func newSQLiteBackend[V any](path, prefix string, capacity int) (*sqliteBackend[V], error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_busy_timeout=%d&_journal_mode=WAL", path, separator, sqliteBusyTimeoutMs)
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite cache database: %w", err)
+	}
+	if err := db.AutoMigrate(&sqliteEntry{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate semantic cache table: %w", err)
+	}
+	return &sqliteBackend[V]{db: db, prefix: prefix, capacity: capacity}, nil
+}
+
+func (b *sqliteBackend[V]) entries(ctx context.Context) *gorm.DB {
+	return b.db.WithContext(ctx).Model(&sqliteEntry{}).Where("prefix = ?", b.prefix)
+}
+
+// Set stores the entry and evicts the least recently used entries beyond capacity
+func (b *sqliteBackend[V]) Set(ctx context.Context, key string, entry types.Entry[V]) error {
+	value, err := json.Marshal(entry.Value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+	row := sqliteEntry{
+		Prefix:     b.prefix,
+		Key:        key,
+		Embedding:  encodeEmbedding(entry.Embedding),
+		Value:      value,
+		AccessedAt: time.Now().UnixNano(),
+	}
+
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`DELETE FROM semantic_cache_entries WHERE prefix = ? AND entry_key IN (
+			SELECT entry_key FROM semantic_cache_entries WHERE prefix = ? ORDER BY accessed_at DESC LIMIT -1 OFFSET ?)`,
+			b.prefix, b.prefix, b.capacity).Error
+	})
+}
+
+// Get returns the entry and marks it as recently used
+func (b *sqliteBackend[V]) Get(ctx context.Context, key string) (types.Entry[V], bool, error) {
+	var rows []sqliteEntry
+	if err := b.entries(ctx).Where("entry_key = ?", key).Limit(1).Find(&rows).Error; err != nil {
+		return types.Entry[V]{}, false, err
+	}
+	if len(rows) == 0 {
+		return types.Entry[V]{}, false, nil
+	}
+
+	entry, err := decodeEntry[V](rows[0])
+	if err != nil {
+		return types.Entry[V]{}, false, err
+	}
+	if err := b.entries(ctx).Where("entry_key = ?", key).Update("accessed_at", time.Now().UnixNano()).Error; err != nil {
+		return types.Entry[V]{}, false, err
+	}
+	return entry, true, nil
+}
+
+func (b *sqliteBackend[V]) Delete(ctx context.Context, key string) error {
+	return b.db.WithContext(ctx).Where("prefix = ? AND entry_key = ?", b.prefix, key).Delete(&sqliteEntry{}).Error
+}
+
+func (b *sqliteBackend[V]) Contains(ctx context.Context, key string) (bool, error) {
+	var count int64
+	err := b.entries(ctx).Where("entry_key = ?", key).Count(&count).Error
+	return count > 0, err
+}
+
+func (b *sqliteBackend[V]) Flush(ctx context.Context) error {
+	return b.db.WithContext(ctx).Where("prefix = ?", b.prefix).Delete(&sqliteEntry{}).Error
+}
+
+func (b *sqliteBackend[V]) Len(ctx context.Context) (int, error) {
+	var count int64
+	err := b.entries(ctx).Count(&count).Error
+	return int(count), err
+}
+
+func (b *sqliteBackend[V]) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := b.entries(ctx).Pluck("entry_key", &keys).Error
+	return keys, err
+}
+
+func (b *sqliteBackend[V]) GetEmbedding(ctx context.Context, key string) ([]float32, bool, error) {
+	var embeddings [][]byte
+	if err := b.entries(ctx).Where("entry_key = ?", key).Limit(1).Pluck("embedding", &embeddings).Error; err != nil {
+		return nil, false, err
+	}
+	if len(embeddings) == 0 {
+		return nil, false, nil
+	}
+	return decodeEmbedding(embeddings[0]), true, nil
+}
+
+// Search returns the n entries under keyPrefix most similar to the embedding, by cosine similarity
+func (b *sqliteBackend[V]) Search(ctx context.Context, embedding []float32, keyPrefix string, n int) ([]semanticcache.Match[V], error) {
+	query := b.entries(ctx)
+	if keyPrefix != "" {
+		query = query.Where(`entry_key LIKE ? ESCAPE '!'`, likeEscaper.Replace(keyPrefix)+"%")
+	}
+	rows, err := query.Select("entry_key", "embedding").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var candidates []scoredKey
+	for rows.Next() {
+		var key string
+		var stored []byte
+		if err := rows.Scan(&key, &stored); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, scoredKey{key: key, score: similarity.CosineSimilarity(embedding, decodeEmbedding(stored))})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates = topScored(candidates, n)
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = candidate.key
+	}
+	var found []sqliteEntry
+	if err := b.entries(ctx).Where("entry_key IN ?", keys).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]sqliteEntry, len(found))
+	for _, row := range found {
+		byKey[row.Key] = row
+	}
+
+	matches := make([]semanticcache.Match[V], 0, len(candidates))
+	for _, candidate := range candidates {
+		row, ok := byKey[candidate.key]
+		if !ok {
+			continue
+		}
+		entry, err := decodeEntry[V](row)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, semanticcache.Match[V]{Value: entry.Value, Score: candidate.score})
+	}
+	return matches, nil
+}
+
+// StoredDimensions returns the embedding size of the stored entries, or 0 when there are none
+func (b *sqliteBackend[V]) StoredDimensions(ctx context.Context) (int, error) {
+	var embeddings [][]byte
+	if err := b.entries(ctx).Limit(1).Pluck("embedding", &embeddings).Error; err != nil {
+		return 0, err
+	}
+	if len(embeddings) == 0 {
+		return 0, nil
+	}
+	return len(embeddings[0]) / 4, nil
+}
+
+func (b *sqliteBackend[V]) Close() error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func (b *sqliteBackend[V]) SetAsync(ctx context.Context, key string, entry types.Entry[V]) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- b.Set(ctx, key, entry)
+	}()
+	return errCh
+}
+
+func (b *sqliteBackend[V]) GetAsync(ctx context.Context, key string) <-chan types.AsyncGetResult[V] {
+	resultCh := make(chan types.AsyncGetResult[V], 1)
+	go func() {
+		defer close(resultCh)
+		entry, found, err := b.Get(ctx, key)
+		resultCh <- types.AsyncGetResult[V]{Entry: entry, Found: found, Error: err}
+	}()
+	return resultCh
+}
+
+func (b *sqliteBackend[V]) DeleteAsync(ctx context.Context, key string) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		errCh <- b.Delete(ctx, key)
+	}()
+	return errCh
+}
+
+func (b *sqliteBackend[V]) GetBatchAsync(ctx context.Context, keys []string) <-chan types.AsyncBatchResult[string, V] {
+	resultCh := make(chan types.AsyncBatchResult[string, V], 1)
+	go func() {
+		defer close(resultCh)
+		entries := make(map[string]types.Entry[V], len(keys))
+		var rows []sqliteEntry
+		err := b.entries(ctx).Where("entry_key IN ?", keys).Find(&rows).Error
+		for _, row := range rows {
+			if entry, decodeErr := decodeEntry[V](row); decodeErr == nil {
+				entries[row.Key] = entry
+			}
+		}
+		resultCh <- types.AsyncBatchResult[string, V]{Entries: entries, Error: err}
+	}()
+	return resultCh
+}
+
+func decodeEntry[V any](row sqliteEntry) (types.Entry[V], error) {
+	var value V
+	if err := json.Unmarshal(row.Value, &value); err != nil {
+		return types.Entry[V]{}, fmt.Errorf("failed to decode cache value: %w", err)
+	}
+	return types.Entry[V]{Embedding: decodeEmbedding(row.Embedding), Value: value}, nil
+}
+
+// encodeEmbedding packs an embedding as little-endian float32s
+func encodeEmbedding(embedding []float32) []byte {
+	buf := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeEmbedding(buf []byte) []float32 {
+	embedding := make([]float32, len(buf)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return embedding
+}
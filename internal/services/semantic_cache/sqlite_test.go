@@ -0,0 +1,237 @@
+package semantic_cache
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/botirk38/semanticcache/types"
+)
+
+// newTestSQLiteBackend opens a backend on a database file of the test, closed when it ends
+func newTestSQLiteBackend(t *testing.T, path, prefix string, capacity int) *sqliteBackend[string] {
+	t.Helper()
+	backend, err := newSQLiteBackend[string](path, prefix, capacity)
+	if err != nil {
+		t.Fatalf("newSQLiteBackend() = %v", err)
+	}
+	t.Cleanup(func() { _ = backend.Close() })
+	return backend
+}
+
+func entry(value string, embedding ...float32) types.Entry[string] {
+	return types.Entry[string]{Value: value, Embedding: embedding}
+}
+
+func TestSQLiteBackendEntries(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestSQLiteBackend(t, filepath.Join(t.TempDir(), "cache.db"), "test:", 10)
+
+	if err := backend.Set(ctx, "a", entry("first", 1, 0.5, -2)); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if err := backend.Set(ctx, "a", entry("replaced", 1, 0.5, -2)); err != nil {
+		t.Fatalf("Set() again = %v", err)
+	}
+
+	got, found, err := backend.Get(ctx, "a")
+	if err != nil || !found || got.Value != "replaced" || !slices.Equal(got.Embedding, []float32{1, 0.5, -2}) {
+		t.Errorf("Get(a) = (%+v, %v, %v), want the replaced entry", got, found, err)
+	}
+	if _, found, err := backend.Get(ctx, "missing"); found || err != nil {
+		t.Errorf("Get(missing) = (%v, %v), want not found", found, err)
+	}
+	if n, err := backend.Len(ctx); n != 1 || err != nil {
+		t.Errorf("Len() = (%d, %v), want 1", n, err)
+	}
+	if embedding, found, err := backend.GetEmbedding(ctx, "a"); !found || err != nil || !slices.Equal(embedding, []float32{1, 0.5, -2}) {
+		t.Errorf("GetEmbedding(a) = (%v, %v, %v)", embedding, found, err)
+	}
+
+	if err := backend.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if ok, err := backend.Contains(ctx, "a"); ok || err != nil {
+		t.Errorf("Contains(a) after Delete = (%v, %v), want false", ok, err)
+	}
+}
+
+func TestSQLiteBackendEviction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.db")
+	backend := newTestSQLiteBackend(t, path, "small:", 3)
+	// Another cache in the same file is neither counted nor evicted
+	other := newTestSQLiteBackend(t, path, "other:", 1)
+	if err := other.Set(ctx, "kept", entry("kept", 1)); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := backend.Set(ctx, key, entry(key, 1)); err != nil {
+			t.Fatalf("Set(%s) = %v", key, err)
+		}
+	}
+	// Reading a makes b the least recently used entry
+	if _, found, err := backend.Get(ctx, "a"); !found || err != nil {
+		t.Fatalf("Get(a) = (%v, %v)", found, err)
+	}
+	if err := backend.Set(ctx, "d", entry("d", 1)); err != nil {
+		t.Fatalf("Set(d) = %v", err)
+	}
+
+	keys, err := backend.Keys(ctx)
+	if err != nil {
+		t.Fatalf("Keys() = %v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "c", "d"}) {
+		t.Errorf("keys after eviction = %v, want [a c d]", keys)
+	}
+	if ok, _ := other.Contains(ctx, "kept"); !ok {
+		t.Errorf("entry of another prefix was evicted")
+	}
+
+	if err := backend.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if n, _ := backend.Len(ctx); n != 0 {
+		t.Errorf("Len() after Flush = %d, want 0", n)
+	}
+	if n, _ := other.Len(ctx); n != 1 {
+		t.Errorf("Len() of another prefix after Flush = %d, want 1", n)
+	}
+}
+
+func TestSQLiteBackendSearch(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestSQLiteBackend(t, filepath.Join(t.TempDir(), "cache.db"), "test:", 10)
+
+	for key, embedding := range map[string][]float32{
+		"same":      {1, 0, 0},
+		"close":     {0.9, 0.1, 0},
+		"opposite":  {-1, 0, 0},
+		"unrelated": {0, 0, 1},
+	} {
+		if err := backend.Set(ctx, key, entry(key, embedding...)); err != nil {
+			t.Fatalf("Set(%s) = %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: 1, want: []string{"same"}},
+		{n: 3, want: []string{"same", "close", "unrelated"}},
+		{n: 10, want: []string{"same", "close", "unrelated", "opposite"}},
+	}
+
+	for _, tt := range tests {
+		matches, err := backend.Search(ctx, []float32{1, 0, 0}, "", tt.n)
+		if err != nil {
+			t.Fatalf("Search(%d) = %v", tt.n, err)
+		}
+		var got []string
+		for i, match := range matches {
+			got = append(got, match.Value)
+			if i > 0 && match.Score > matches[i-1].Score {
+				t.Errorf("Search(%d) scores not descending: %v", tt.n, matches)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteDimensionCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	cacheConfig := models.CacheConfig{Backend: models.CacheBackendSQLite, SQLitePath: path}
+	local := func(dimensions int) models.EmbeddingConfig {
+		return models.EmbeddingConfig{Type: models.EmbeddingProviderLocal, Dimensions: dimensions}
+	}
+
+	backend := newTestSQLiteBackend(t, path, "cache:", 10)
+	if n, err := backend.StoredDimensions(context.Background()); n != 0 || err != nil {
+		t.Errorf("StoredDimensions() of an empty cache = (%d, %v), want 0", n, err)
+	}
+	if err := backend.Set(context.Background(), "a", entry("a", make([]float32, 8)...)); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if n, err := backend.StoredDimensions(context.Background()); n != 8 || err != nil {
+		t.Errorf("StoredDimensions() = (%d, %v), want 8", n, err)
+	}
+
+	tests := []struct {
+		name      string
+		prefix    string
+		embedding models.EmbeddingConfig
+		wantErr   bool
+	}{
+		{name: "matching dimensions", prefix: "cache:", embedding: local(8)},
+		{name: "other dimensions", prefix: "cache:", embedding: local(16), wantErr: true},
+		{name: "other dimensions in an empty cache", prefix: "fresh:", embedding: local(16)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := New[string](cacheConfig, tt.embedding, tt.prefix)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "do not match") {
+					t.Errorf("New() = %v, want a dimension mismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+			_ = cache.Close()
+		})
+	}
+}
+
+func TestTopMatchesWithPrefix(t *testing.T) {
+	local := models.EmbeddingConfig{Type: models.EmbeddingProviderLocal, Dimensions: 64}
+	backends := map[string]models.CacheConfig{
+		"memory": {Backend: models.CacheBackendMemory, Capacity: 100},
+		"sqlite": {Backend: models.CacheBackendSQLite, SQLitePath: filepath.Join(t.TempDir(), "cache.db")},
+	}
+
+	for name, cacheConfig := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cache, err := New[string](cacheConfig, local, "prefix:")
+			if err != nil {
+				t.Fatalf("New() = %v", err)
+			}
+			defer func() { _ = cache.Close() }()
+
+			// Another tenant's identical prompts rank above the tenant's own entry; the underscore
+			// of its prefix must not match the other tenant's character
+			for i := range 6 {
+				key := "tx1|hello world " + strings.Repeat("!", i)
+				if err := cache.Set(ctx, key, "hello world", "other"); err != nil {
+					t.Fatalf("Set(%s) = %v", key, err)
+				}
+			}
+			if err := cache.Set(ctx, "t_1|hello there world", "hello there world", "own"); err != nil {
+				t.Fatalf("Set() = %v", err)
+			}
+
+			matches, err := cache.TopMatchesWithPrefix(ctx, "hello world", "t_1|", 5)
+			if err != nil {
+				t.Fatalf("TopMatchesWithPrefix() = %v", err)
+			}
+			if len(matches) != 1 || matches[0].Value != "own" {
+				t.Errorf("TopMatchesWithPrefix() = %v, want only the entry under the prefix", matches)
+			}
+			if matches, _ := cache.TopMatches(ctx, "hello world", 10); len(matches) != 7 {
+				t.Errorf("TopMatches() returned %d entries, want all 7", len(matches))
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package semantic_cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+)
+
+// stats counts the lookups of a cache since startup. Counts are per process.
+type stats struct {
+	mu            sync.Mutex
+	lookups       int64
+	exactHits     int64
+	semanticHits  int64
+	similaritySum float64
+	latency       time.Duration
+}
+
+// RecordHit counts a lookup answered from the cache
+func (c *Cache[V]) RecordHit(semantic bool, similarity float32, latency time.Duration) {
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	c.stats.lookups++
+	c.stats.latency += latency
+	if semantic {
+		c.stats.semanticHits++
+		c.stats.similaritySum += float64(similarity)
+	} else {
+		c.stats.exactHits++
+	}
+}
+
+// RecordMiss counts a lookup that found nothing
+func (c *Cache[V]) RecordMiss(latency time.Duration) {
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	c.stats.lookups++
+	c.stats.latency += latency
+}
+
+// Stats reports the cache's hit rates since startup and its current number of entries
+func (c *Cache[V]) Stats(name string, entries int) models.CacheStats {
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+
+	result := models.CacheStats{
+		Name:         name,
+		Backend:      string(c.backendType),
+		Entries:      entries,
+		Lookups:      c.stats.lookups,
+		ExactHits:    c.stats.exactHits,
+		SemanticHits: c.stats.semanticHits,
+		Misses:       c.stats.lookups - c.stats.exactHits - c.stats.semanticHits,
+	}
+	if c.stats.lookups > 0 {
+		result.ExactHitRate = float64(c.stats.exactHits) / float64(c.stats.lookups)
+		result.SemanticHitRate = float64(c.stats.semanticHits) / float64(c.stats.lookups)
+		result.AverageLookupLatencyMs = float64(c.stats.latency.Microseconds()) / 1000 / float64(c.stats.lookups)
+	}
+	if c.stats.semanticHits > 0 {
+		result.AverageSimilarity = c.stats.similaritySum / float64(c.stats.semanticHits)
+	}
+	return result
+}
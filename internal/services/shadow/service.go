@@ -0,0 +1,207 @@
+package shadow
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
+	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/handlers"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+	"github.com/Egham-7/adaptive-proxy/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+const (
+	primaryLocalKey = "shadow_primary"
+	defaultTimeout  = 60 * time.Second
+	// maxInFlight caps concurrent shadow calls; requests beyond it are not mirrored
+	maxInFlight = 32
+	// maxOutputLength truncates stored outputs
+	maxOutputLength = 32 * 1024
+)
+
+// Call executes the mirrored request against the shadow provider/model.
+// Implementations must not record usage: shadow calls are never billed.
+type Call func(ctx context.Context, provider, model string) models.ShadowResult
+
+// Service mirrors sampled requests to shadow models and stores the comparisons.
+// When db is nil, comparisons are only logged.
+type Service struct {
+	db       *gorm.DB
+	inFlight chan struct{}
+}
+
+// NewService creates a shadow traffic service. db may be nil.
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:       db,
+		inFlight: make(chan struct{}, maxInFlight),
+	}
+}
+
+// AutoMigrate creates the shadow comparison table
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&models.ShadowComparison{})
+}
+
+// SetPrimary records the primary response of the request for comparison. It is called where the
+// response is written to the client, so it never races with other writers of the request context.
+func SetPrimary(c *fiber.Ctx, result models.ShadowResult) {
+	c.Locals(primaryLocalKey, result)
+}
+
+// primary returns the primary response recorded for the request
+func primary(c *fiber.Ctx) (models.ShadowResult, bool) {
+	result, ok := c.Locals(primaryLocalKey).(models.ShadowResult)
+	return result, ok
+}
+
+// Mirror samples a successfully served request and runs call against the shadow model in the
+// background. start is when the primary call began. A streamed primary is compared once its
+// stream was served in full, with the output, tokens and latency of the whole stream; streams
+// that fail or are abandoned by the client are not mirrored. Nothing the shadow does reaches the
+// client. The request's prompt is never mirrored to a model its matched routing rule does not allow.
+func (s *Service) Mirror(
+	c *fiber.Ctx,
+	cfg *models.ShadowConfig,
+	endpoint, requestID string,
+	start time.Time,
+	isStream bool,
+	call Call,
+) {
+	if s == nil || cfg == nil || cfg.Model == "" || cfg.SampleRate <= 0 {
+		return
+	}
+	primaryResult, served := primary(c)
+	if !served || c.Response().StatusCode() >= fiber.StatusBadRequest {
+		return
+	}
+	if cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate { // #nosec G404 - sampling does not need a secure source
+		return
+	}
+
+	provider, model, err := utils.ParseProviderModel(cfg.Model)
+	if err != nil {
+		fiberlog.Warnf("[%s] 👥 Invalid shadow model %q for %s: %v", requestID, cfg.Model, endpoint, err)
+		return
+	}
+	if rule := model_router.RuleFromContext(c.UserContext()); !rule.AllowsModel(provider, model) {
+		fiberlog.Warnf("[%s] 👥 Skipping shadow to %s/%s: not permitted by routing rule %q", requestID, provider, model, rule.Name)
+		return
+	}
+
+	// Everything needed from the request context is captured before the handler returns
+	comparison := &models.ShadowComparison{
+		RequestID:       requestID,
+		Endpoint:        endpoint,
+		Streamed:        isStream,
+		PrimaryProvider: primaryResult.Provider,
+		PrimaryModel:    primaryResult.Model,
+	}
+	if apiKey, ok := auth.GetAPIKey(c); ok && apiKey != nil {
+		comparison.APIKeyID = apiKey.ID
+	}
+	timeout := defaultTimeout
+	if cfg.TimeoutMs > 0 {
+		timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	mirror := func(primaryResult models.ShadowResult) {
+		comparison.PrimaryLatencyMs = int(time.Since(start).Milliseconds())
+		comparison.PrimaryTokensInput = primaryResult.TokensInput
+		comparison.PrimaryTokensOutput = primaryResult.TokensOutput
+		comparison.PrimaryCost = primaryResult.Cost
+		comparison.PrimaryOutput = truncate(primaryResult.Output)
+		s.run(comparison, provider, model, timeout, call)
+	}
+
+	// A stream is served after the handler returned, so its primary is complete only then
+	if isStream && handlers.OnCompletion(c, func(completion handlers.Completion) {
+		primaryResult.TokensInput = completion.TokensInput
+		primaryResult.TokensOutput = completion.TokensOutput
+		primaryResult.Cost = usage.CalculateCost(primaryResult.Provider, primaryResult.Model, completion.TokensInput, completion.TokensOutput)
+		primaryResult.Output = completion.Output
+		mirror(primaryResult)
+	}) {
+		return
+	}
+	mirror(primaryResult)
+}
+
+// run calls the shadow model in the background and records the comparison
+func (s *Service) run(comparison *models.ShadowComparison, provider, model string, timeout time.Duration, call Call) {
+	requestID := comparison.RequestID
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		fiberlog.Warnf("[%s] 👥 Too many shadow calls in flight, skipping shadow to %s/%s", requestID, provider, model)
+		return
+	}
+
+	go func() {
+		defer func() { <-s.inFlight }()
+		defer func() {
+			if r := recover(); r != nil {
+				fiberlog.Errorf("[%s] 👥 Shadow call to %s/%s panicked: %v", requestID, provider, model, r)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		fiberlog.Infof("[%s] 👥 Mirroring %s request to shadow %s/%s", requestID, comparison.Endpoint, provider, model)
+		shadowStart := time.Now()
+		result := call(ctx, provider, model)
+
+		comparison.ShadowProvider = provider
+		comparison.ShadowModel = model
+		comparison.ShadowLatencyMs = int(time.Since(shadowStart).Milliseconds())
+		comparison.ShadowTokensInput = result.TokensInput
+		comparison.ShadowTokensOutput = result.TokensOutput
+		comparison.ShadowCost = result.Cost
+		comparison.ShadowOutput = truncate(result.Output)
+		comparison.ShadowError = result.Error
+
+		s.record(comparison)
+	}()
+}
+
+// record stores the comparison, or logs it when no database is configured
+func (s *Service) record(comparison *models.ShadowComparison) {
+	fiberlog.Infof("[%s] 👥 Shadow %s/%s: %dms, %d/%d tokens, $%.6f (primary %s/%s: %dms, %d/%d tokens, $%.6f)%s",
+		comparison.RequestID, comparison.ShadowProvider, comparison.ShadowModel,
+		comparison.ShadowLatencyMs, comparison.ShadowTokensInput, comparison.ShadowTokensOutput, comparison.ShadowCost,
+		comparison.PrimaryProvider, comparison.PrimaryModel,
+		comparison.PrimaryLatencyMs, comparison.PrimaryTokensInput, comparison.PrimaryTokensOutput, comparison.PrimaryCost,
+		errorSuffix(comparison.ShadowError))
+
+	if s.db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.db.WithContext(ctx).Create(comparison).Error; err != nil {
+		fiberlog.Errorf("[%s] 👥 Failed to store shadow comparison: %v", comparison.RequestID, err)
+	}
+}
+
+// errorSuffix formats a shadow error for the comparison log line
+func errorSuffix(err string) string {
+	if err == "" {
+		return ""
+	}
+	return " error: " + err
+}
+
+// truncate limits stored outputs to maxOutputLength bytes
+func truncate(output string) string {
+	if len(output) <= maxOutputLength {
+		return output
+	}
+	return output[:maxOutputLength]
+}
@@ -89,6 +89,15 @@ func IsClientDisconnect(err error) bool {
 	return false
 }
 
+// IsProviderError checks if error originated from the upstream provider
+func IsProviderError(err error) bool {
+	var streamErr *StreamError
+	if errors.As(err, &streamErr) {
+		return streamErr.Type == ProviderError
+	}
+	return false
+}
+
 // IsExpectedError checks if error is expected (not a real error)
 func IsExpectedError(err error) bool {
 	var streamErr *StreamError
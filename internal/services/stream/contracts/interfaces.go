@@ -5,18 +5,37 @@ import (
 	"io"
 )
 
-// StreamReader provides pure I/O reading interface
+// EventStream is a stream of decoded provider events. The SDK SSE streams implement it, as do
+// the converted streams serving one API from a provider of another.
+type EventStream[T any] interface {
+	Next() bool
+	Current() T
+	Err() error
+	Close() error
+}
+
+// StreamReader provides pure I/O reading interface. Each event read is a JSON document ended by
+// EventDelimiter; an event larger than the read buffer is returned over several reads.
 type StreamReader interface {
 	io.Reader
 	io.Closer
 }
 
+// EventDelimiter ends every event a StreamReader returns. JSON encoding escapes newlines in
+// strings, so it never occurs inside an event.
+const EventDelimiter = '\n'
+
 // ChunkProcessor handles format conversion and business logic
 type ChunkProcessor interface {
 	Process(ctx context.Context, data []byte) ([]byte, error)
 	Provider() string
 }
 
+// UsageReporter is implemented by processors that see the token usage the provider reports
+type UsageReporter interface {
+	Usage() (inputTokens, outputTokens int)
+}
+
 // StreamWriter handles output with flush capabilities
 type StreamWriter interface {
 	Write([]byte) error
@@ -27,6 +46,8 @@ type StreamWriter interface {
 // StreamHandler orchestrates the streaming pipeline
 type StreamHandler interface {
 	Handle(ctx context.Context, writer StreamWriter) error
+	// Close releases a pipeline that will not be handled
+	Close() error
 }
 
 // ConnectionState tracks client connection status
@@ -34,3 +55,23 @@ type ConnectionState interface {
 	IsConnected() bool
 	Done() <-chan struct{}
 }
+
+// StreamObserver is notified of stream timing and outcome, used for provider performance tracking
+type StreamObserver interface {
+	MarkFirstToken()
+	Finish(err error)
+}
+
+// StreamSplicer tracks what a stream has sent so that, after a mid-stream failure, a
+// continuation from another provider can be spliced into the same response
+type StreamSplicer interface {
+	// Observe records a chunk as it is sent to the client
+	Observe(data []byte)
+	// Partial returns the text sent so far, and false once the stream has sent output a
+	// continuation cannot pick up from, such as tool calls
+	Partial() (string, bool)
+	// Continue marks the start of a continuation stream
+	Continue()
+	// Splice rewrites a continuation chunk so the client sees one response; nil drops the chunk
+	Splice(data []byte) []byte
+}
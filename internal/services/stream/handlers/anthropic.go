@@ -1,57 +1,35 @@
 package handlers
 
 import (
-	"bufio"
-
 	"github.com/Egham-7/adaptive-proxy/internal/models"
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
-	"github.com/Egham-7/adaptive-proxy/internal/services/stream/writers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
 	"github.com/gofiber/fiber/v2"
 	fiberlog "github.com/gofiber/fiber/v2/log"
-	"github.com/valyala/fasthttp"
 )
 
-// HandleAnthropicNative handles native Anthropic SDK streams using proper layered architecture
-func HandleAnthropicNative(c *fiber.Ctx, stream *ssestream.Stream[anthropic.MessageStreamEventUnion], requestID, provider, cacheSource, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker) error {
+// HandleAnthropicNative validates the stream and starts streaming it to the client
+func HandleAnthropicNative(c *fiber.Ctx, stream contracts.EventStream[anthropic.MessageStreamEventUnion], requestID, provider, cacheSource, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker, observer contracts.StreamObserver) error {
+	pending, err := PrepareAnthropicNative(stream, requestID, provider, cacheSource, model, endpoint, usageService, apiKey, usage.AttemptOf(c), usageWorker, observer)
+	if err != nil {
+		return err
+	}
+	return pending.Commit(c)
+}
+
+// PrepareAnthropicNative validates the stream without touching the response: the pipeline reads up to the
+// first content chunk, so provider errors (429, 500, etc.) are returned BEFORE HTTP streaming
+// starts and fallback can trigger
+func PrepareAnthropicNative(stream contracts.EventStream[anthropic.MessageStreamEventUnion], requestID, provider, cacheSource, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, attempt usage.Attempt, usageWorker *usage.Worker, observer contracts.StreamObserver) (*PendingStream, error) {
 	fiberlog.Infof("[%s] Starting native Anthropic stream handling", requestID)
 
-	// Create streaming pipeline - validates stream internally by reading first event
-	// If validation fails (429, 500, etc.), error is returned BEFORE HTTP streaming starts
 	factory := NewStreamFactory(usageWorker)
-	handler, err := factory.CreateAnthropicNativePipeline(stream, requestID, provider, cacheSource, model, endpoint, usageService, apiKey)
+	handler, err := factory.CreateAnthropicNativePipeline(stream, requestID, provider, cacheSource, model, endpoint, usageService, apiKey, attempt, observer)
 	if err != nil {
 		fiberlog.Errorf("[%s] Stream validation failed: %v", requestID, err)
-		return err
+		return nil, err
 	}
-
-	fiberlog.Infof("[%s] Stream validated successfully, starting HTTP stream", requestID)
-
-	fasthttpCtx := c.Context()
-	c.Set("Content-Type", "text/event-stream")
-	c.Set("Cache-Control", "no-cache")
-	c.Set("Connection", "keep-alive")
-	c.Set("Access-Control-Allow-Origin", "*")
-
-	fasthttpCtx.SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
-		// Create connection state tracker
-		connState := writers.NewFastHTTPConnectionState(fasthttpCtx)
-
-		// Create HTTP writer (with [DONE] message for Anthropic compatibility)
-		httpWriter := writers.NewHTTPStreamWriter(w, connState, requestID, true)
-
-		// Handle the stream
-		if err := handler.Handle(fasthttpCtx, httpWriter); err != nil {
-			if !contracts.IsExpectedError(err) {
-				fiberlog.Errorf("[%s] Stream error: %v", requestID, err)
-			} else {
-				fiberlog.Infof("[%s] Stream ended: %v", requestID, err)
-			}
-		}
-	}))
-
-	return nil
+	return &PendingStream{handler: handler, requestID: requestID, sendDone: true, provider: provider, model: model}, nil
 }
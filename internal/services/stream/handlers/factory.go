@@ -7,12 +7,11 @@ import (
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/processors"
 	"github.com/Egham-7/adaptive-proxy/internal/services/stream/readers"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/splicers"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
 	"github.com/openai/openai-go/v2"
-	openai_ssestream "github.com/openai/openai-go/v2/packages/ssestream"
 	"google.golang.org/genai"
 )
 
@@ -31,33 +30,45 @@ func NewStreamFactory(usageWorker *usage.Worker) *StreamFactory {
 // CreateOpenAIPipeline creates a complete OpenAI streaming pipeline
 // Returns error if stream validation fails (allows fallback before HTTP streaming starts)
 func (f *StreamFactory) CreateOpenAIPipeline(
-	stream *openai_ssestream.Stream[openai.ChatCompletionChunk],
+	stream contracts.EventStream[openai.ChatCompletionChunk],
 	requestID, provider, cacheSource, model, endpoint string,
 	usageService *usage.Service,
 	apiKey *models.APIKey,
+	attempt usage.Attempt,
+	observer contracts.StreamObserver,
 ) (contracts.StreamHandler, error) {
 	reader, err := readers.NewOpenAIStreamReader(stream, requestID)
 	if err != nil {
 		return nil, err
 	}
-	processor := processors.NewOpenAIChunkProcessor(provider, cacheSource, requestID, model, endpoint, usageService, apiKey, f.usageWorker)
-	return NewStreamOrchestrator(reader, processor, requestID), nil
+	// Readers validate the stream by reading up to the first content chunk, so it has arrived by now
+	if observer != nil {
+		observer.MarkFirstToken()
+	}
+	processor := processors.NewOpenAIChunkProcessor(provider, cacheSource, requestID, model, endpoint, usageService, apiKey, f.usageWorker, attempt)
+	return NewStreamOrchestrator(reader, processor, requestID, observer).WithSplicer(splicers.NewOpenAISplicer()), nil
 }
 
 // CreateAnthropicNativePipeline creates a complete Anthropic native streaming pipeline
 // Returns error if stream validation fails (allows fallback before HTTP streaming starts)
 func (f *StreamFactory) CreateAnthropicNativePipeline(
-	stream *ssestream.Stream[anthropic.MessageStreamEventUnion],
+	stream contracts.EventStream[anthropic.MessageStreamEventUnion],
 	requestID, provider, cacheSource, model, endpoint string,
 	usageService *usage.Service,
 	apiKey *models.APIKey,
+	attempt usage.Attempt,
+	observer contracts.StreamObserver,
 ) (contracts.StreamHandler, error) {
 	reader, err := readers.NewAnthropicNativeStreamReader(stream, requestID)
 	if err != nil {
 		return nil, err
 	}
-	processor := processors.NewAnthropicChunkProcessor(provider, cacheSource, requestID, model, endpoint, usageService, apiKey, f.usageWorker)
-	return NewStreamOrchestrator(reader, processor, requestID), nil
+	// Readers validate the stream by reading up to the first content chunk, so it has arrived by now
+	if observer != nil {
+		observer.MarkFirstToken()
+	}
+	processor := processors.NewAnthropicChunkProcessor(provider, cacheSource, requestID, model, endpoint, usageService, apiKey, f.usageWorker, attempt)
+	return NewStreamOrchestrator(reader, processor, requestID, observer).WithSplicer(splicers.NewAnthropicSplicer()), nil
 }
 
 // CreateGeminiPipeline creates a complete Gemini streaming pipeline
@@ -67,12 +78,18 @@ func (f *StreamFactory) CreateGeminiPipeline(
 	requestID, provider, cacheSource, model, endpoint string,
 	usageService *usage.Service,
 	apiKey *models.APIKey,
+	attempt usage.Attempt,
+	observer contracts.StreamObserver,
 ) (contracts.StreamHandler, error) {
 	reader, err := readers.NewGeminiStreamReader(streamIter, requestID)
 	if err != nil {
 		return nil, err
 	}
+	// Readers validate the stream by reading up to the first content chunk, so it has arrived by now
+	if observer != nil {
+		observer.MarkFirstToken()
+	}
 	// Use Gemini processor to format as SSE events for SDK compatibility
-	processor := processors.NewGeminiChunkProcessor(provider, cacheSource, requestID, model, endpoint, usageService, apiKey, f.usageWorker)
-	return NewStreamOrchestrator(reader, processor, requestID), nil
+	processor := processors.NewGeminiChunkProcessor(provider, cacheSource, requestID, model, endpoint, usageService, apiKey, f.usageWorker, attempt)
+	return NewStreamOrchestrator(reader, processor, requestID, observer).WithSplicer(splicers.NewGeminiSplicer()), nil
 }
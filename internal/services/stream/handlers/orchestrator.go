@@ -17,19 +17,47 @@ type StreamOrchestrator struct {
 	reader    contracts.StreamReader
 	processor contracts.ChunkProcessor
 	requestID string
+	observer  contracts.StreamObserver
+	// splicer and resume continue the stream on another provider when it fails mid-way;
+	// resume is nil unless mid-stream recovery is enabled
+	splicer contracts.StreamSplicer
+	resume  ResumeFunc
+	spliced bool
+	// onComplete is called once the stream was served in full; may be nil
+	onComplete CompletionFunc
 }
 
-// NewStreamOrchestrator creates a new stream orchestrator
-func NewStreamOrchestrator(reader contracts.StreamReader, processor contracts.ChunkProcessor, requestID string) *StreamOrchestrator {
+// Completion summarizes a stream that was served to the client in full
+type Completion struct {
+	// Output is the text sent to the client; tool calls and other output are left out
+	Output string
+	// TokensInput and TokensOutput are the usage reported by the provider that ended the stream
+	TokensInput  int
+	TokensOutput int
+}
+
+// CompletionFunc receives the summary of a stream served in full
+type CompletionFunc func(Completion)
+
+// NewStreamOrchestrator creates a new stream orchestrator.
+// observer is optional and receives the stream outcome for provider performance tracking.
+func NewStreamOrchestrator(reader contracts.StreamReader, processor contracts.ChunkProcessor, requestID string, observer contracts.StreamObserver) *StreamOrchestrator {
 	return &StreamOrchestrator{
 		reader:    reader,
 		processor: processor,
 		requestID: requestID,
+		observer:  observer,
 	}
 }
 
+// WithSplicer sets the splicer that lets the stream be resumed after a mid-stream failure
+func (s *StreamOrchestrator) WithSplicer(splicer contracts.StreamSplicer) *StreamOrchestrator {
+	s.splicer = splicer
+	return s
+}
+
 // Handle orchestrates the complete streaming pipeline
-func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.StreamWriter) error {
+func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.StreamWriter) (err error) {
 	startTime := time.Now()
 	var totalChunks int64
 	var totalBytes int64
@@ -49,6 +77,10 @@ func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.Stream
 	}
 	buffer := buf.B
 
+	// Events larger than buffer arrive over several reads and are assembled here
+	event := utils.Get()
+	defer utils.Put(event)
+
 	// Batched flush configuration
 	const flushInterval = 5
 	var chunksSinceFlush int64
@@ -59,6 +91,15 @@ func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.Stream
 		fiberlog.Infof("[%s] Stream completed: %d chunks, %d bytes in %v (%.2f KB/s)",
 			s.requestID, totalChunks, totalBytes, duration, float64(totalBytes)/duration.Seconds()/1024)
 
+		// Only provider errors count against the provider; disconnects and internal errors do not
+		if s.observer != nil {
+			if contracts.IsProviderError(err) {
+				s.observer.Finish(err)
+			} else {
+				s.observer.Finish(nil)
+			}
+		}
+
 		// Close resources
 		if err := s.reader.Close(); err != nil {
 			fiberlog.Errorf("[%s] Error closing reader: %v", s.requestID, err)
@@ -82,10 +123,18 @@ func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.Stream
 		if err == io.EOF {
 			// Natural end of stream
 			fiberlog.Infof("[%s] Stream completed naturally", s.requestID)
+			s.complete()
 			return contracts.NewStreamCompleteError(s.requestID)
 		}
 		if err != nil {
-			return contracts.NewProviderError(s.requestID, providerName, err)
+			providerErr := contracts.NewProviderError(s.requestID, providerName, err)
+			if !s.resumeStream(ctx, providerErr) {
+				return providerErr
+			}
+			providerName = s.processor.Provider()
+			// The failed stream's unfinished event is never sent
+			event.B = event.B[:0]
+			continue
 		}
 
 		// Skip empty reads
@@ -93,8 +142,30 @@ func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.Stream
 			continue
 		}
 
+		// Only whole events are processed; data stays valid until the next read is appended
+		data := buffer[:n]
+		if len(event.B) > 0 || data[n-1] != contracts.EventDelimiter {
+			event.B = append(event.B, data...)
+			if data[n-1] != contracts.EventDelimiter {
+				continue
+			}
+			data = event.B
+			event.B = event.B[:0]
+		}
+		data = data[:len(data)-1]
+
+		// Track what the client receives, and fit continuation chunks into the response
+		if s.spliced {
+			if data = s.splicer.Splice(data); data == nil {
+				continue
+			}
+		}
+		if s.splicer != nil && (s.resume != nil || s.onComplete != nil) {
+			s.splicer.Observe(data)
+		}
+
 		// Process the chunk data
-		processedData, err := s.processor.Process(ctx, buffer[:n])
+		processedData, err := s.processor.Process(ctx, data)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				return contracts.NewClientDisconnectError(s.requestID)
@@ -143,6 +214,67 @@ func (s *StreamOrchestrator) Handle(ctx context.Context, writer contracts.Stream
 	}
 }
 
+// resumeStream continues a stream that failed after output was sent on the next provider, whose
+// chunks are spliced into the response from then on. It reports whether the stream goes on.
+func (s *StreamOrchestrator) resumeStream(ctx context.Context, cause error) bool {
+	if s.resume == nil || ctx.Err() != nil {
+		return false
+	}
+	partial, ok := s.splicer.Partial()
+	if !ok {
+		fiberlog.Warnf("[%s] Stream failed after sending output other than text, cannot resume it", s.requestID)
+		return false
+	}
+
+	fiberlog.Warnf("[%s] ⚠️  Stream from %s failed after %d bytes of text, resuming on the next provider: %v",
+		s.requestID, s.processor.Provider(), len(partial), cause)
+	next, err := s.resume(ctx, partial)
+	if err != nil {
+		fiberlog.Errorf("[%s] ❌ Could not resume stream: %v", s.requestID, err)
+		return false
+	}
+	continuation, ok := next.handler.(*StreamOrchestrator)
+	if !ok {
+		next.Abort()
+		return false
+	}
+
+	// The failed provider is charged with the failure; the continuation reports its own outcome
+	if s.observer != nil {
+		s.observer.Finish(cause)
+	}
+	if err := s.reader.Close(); err != nil {
+		fiberlog.Debugf("[%s] Error closing failed reader: %v", s.requestID, err)
+	}
+	s.reader, s.processor, s.observer = continuation.reader, continuation.processor, continuation.observer
+	s.splicer.Continue()
+	s.spliced = true
+
+	fiberlog.Infof("[%s] 🔀 Stream resumed on %s", s.requestID, s.processor.Provider())
+	return true
+}
+
+// complete reports the stream, served in full, to onComplete
+func (s *StreamOrchestrator) complete() {
+	if s.onComplete == nil {
+		return
+	}
+	var completion Completion
+	if s.splicer != nil {
+		completion.Output, _ = s.splicer.Partial()
+	}
+	if reporter, ok := s.processor.(contracts.UsageReporter); ok {
+		completion.TokensInput, completion.TokensOutput = reporter.Usage()
+	}
+	s.onComplete(completion)
+}
+
+// Close closes the reader of a stream that will not be handled. The observer is left to the
+// caller, which knows why the stream was dropped.
+func (s *StreamOrchestrator) Close() error {
+	return s.reader.Close()
+}
+
 // RequestID returns the request ID
 func (s *StreamOrchestrator) RequestID() string {
 	return s.requestID
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+)
+
+// scriptedReader returns its reads in order, then fails with err (io.EOF when nil)
+type scriptedReader struct {
+	reads  []string
+	err    error
+	closed bool
+}
+
+func (r *scriptedReader) Read(p []byte) (int, error) {
+	if len(r.reads) == 0 {
+		if r.err == nil {
+			return 0, io.EOF
+		}
+		return 0, r.err
+	}
+	n := copy(p, r.reads[0])
+	r.reads = r.reads[1:]
+	return n, nil
+}
+
+func (r *scriptedReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// echoProcessor passes events through, dropping those it is told to skip
+type echoProcessor struct {
+	provider string
+	skip     string
+}
+
+func (p *echoProcessor) Process(ctx context.Context, data []byte) ([]byte, error) {
+	if string(data) == p.skip {
+		return nil, nil
+	}
+	return slices.Clone(data), nil
+}
+
+func (p *echoProcessor) Provider() string {
+	return p.provider
+}
+
+// recordingWriter collects what the client receives
+type recordingWriter struct {
+	writes []string
+	closed bool
+}
+
+func (w *recordingWriter) Write(data []byte) error {
+	w.writes = append(w.writes, string(data))
+	return nil
+}
+
+func (w *recordingWriter) Flush() error { return nil }
+
+func (w *recordingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// recordingObserver records the outcome a stream reports
+type recordingObserver struct {
+	finished []error
+}
+
+func (o *recordingObserver) MarkFirstToken() {}
+
+func (o *recordingObserver) Finish(err error) {
+	o.finished = append(o.finished, err)
+}
+
+// textSplicer treats events as plain text; events starting with "tool" cannot be continued, and
+// continuation events are marked so the test can tell them apart
+type textSplicer struct {
+	sent        strings.Builder
+	unresumable bool
+	continued   int
+}
+
+func (s *textSplicer) Observe(data []byte) {
+	if strings.HasPrefix(string(data), "tool") {
+		s.unresumable = true
+	}
+	s.sent.Write(data)
+}
+
+func (s *textSplicer) Partial() (string, bool) {
+	return s.sent.String(), !s.unresumable
+}
+
+func (s *textSplicer) Continue() {
+	s.continued++
+}
+
+func (s *textSplicer) Splice(data []byte) []byte {
+	if string(data) == "hello" {
+		// The continuation's greeting repeats the start of the response
+		return nil
+	}
+	return append([]byte("+"), data...)
+}
+
+func TestStreamOrchestratorHandle(t *testing.T) {
+	errReset := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		reads      []string
+		err        error
+		skip       string
+		want       []string
+		wantResult func(error) bool
+		wantFinish error
+	}{
+		{
+			name:       "whole events",
+			reads:      []string{"a\n", "b\n"},
+			want:       []string{"a", "b"},
+			wantResult: contracts.IsExpectedError,
+		},
+		{
+			name:       "event split over reads",
+			reads:      []string{"{\"text\":", "\"long\"", "}\n", "c\n"},
+			want:       []string{`{"text":"long"}`, "c"},
+			wantResult: contracts.IsExpectedError,
+		},
+		{
+			name:       "empty reads and processed events skipped",
+			reads:      []string{"", "a\n", "ping\n", "b\n"},
+			skip:       "ping",
+			want:       []string{"a", "b"},
+			wantResult: contracts.IsExpectedError,
+		},
+		{
+			name:       "provider failure",
+			reads:      []string{"a\n", "unfinis"},
+			err:        errReset,
+			want:       []string{"a"},
+			wantResult: contracts.IsProviderError,
+			wantFinish: errReset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &scriptedReader{reads: tt.reads, err: tt.err}
+			observer := &recordingObserver{}
+			writer := &recordingWriter{}
+			orchestrator := NewStreamOrchestrator(reader, &echoProcessor{provider: "acme", skip: tt.skip}, "req", observer)
+
+			err := orchestrator.Handle(context.Background(), writer)
+			if !tt.wantResult(err) {
+				t.Errorf("Handle() = %v", err)
+			}
+			if !slices.Equal(writer.writes, tt.want) {
+				t.Errorf("client received %q, want %q", writer.writes, tt.want)
+			}
+			if len(observer.finished) != 1 || !errors.Is(observer.finished[0], tt.wantFinish) {
+				t.Errorf("observer finished with %v, want [%v]", observer.finished, tt.wantFinish)
+			}
+			if !reader.closed || !writer.closed {
+				t.Errorf("reader closed = %v, writer closed = %v; want both closed", reader.closed, writer.closed)
+			}
+		})
+	}
+}
+
+func TestStreamOrchestratorHandleCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	observer := &recordingObserver{}
+	orchestrator := NewStreamOrchestrator(&scriptedReader{reads: []string{"a\n"}}, &echoProcessor{}, "req", observer)
+
+	if err := orchestrator.Handle(ctx, &recordingWriter{}); !contracts.IsClientDisconnect(err) {
+		t.Errorf("Handle() = %v, want a client disconnect", err)
+	}
+	// A client leaving is not the provider's fault
+	if len(observer.finished) != 1 || observer.finished[0] != nil {
+		t.Errorf("observer finished with %v, want [<nil>]", observer.finished)
+	}
+}
+
+func TestStreamOrchestratorResume(t *testing.T) {
+	errReset := errors.New("connection reset")
+
+	// continuation is a stream of a provider that picks up from the partial output
+	type continuation struct {
+		reads []string
+		err   error
+	}
+
+	tests := []struct {
+		name          string
+		reads         []string
+		continuations []continuation
+		want          []string
+		wantPartials  []string
+		wantResult    func(error) bool
+		wantFailed    int
+	}{
+		{
+			name:          "continues on the next provider",
+			reads:         []string{"hello\n", " wor"},
+			continuations: []continuation{{reads: []string{"hello\n", "world\n"}}},
+			want:          []string{"hello", "+world"},
+			wantPartials:  []string{"hello"},
+			wantResult:    contracts.IsExpectedError,
+			wantFailed:    1,
+		},
+		{
+			name:  "failed continuation resumes again",
+			reads: []string{"one\n"},
+			continuations: []continuation{
+				{reads: []string{"two\n"}, err: errReset},
+				{reads: []string{"three\n"}},
+			},
+			want:         []string{"one", "+two", "+three"},
+			wantPartials: []string{"one", "one+two"},
+			wantResult:   contracts.IsExpectedError,
+			wantFailed:   2,
+		},
+		{
+			name:         "no provider left",
+			reads:        []string{"one\n"},
+			want:         []string{"one"},
+			wantPartials: []string{"one"},
+			wantResult:   contracts.IsProviderError,
+			wantFailed:   1,
+		},
+		{
+			name:          "output other than text is not resumed",
+			reads:         []string{"tool call\n"},
+			continuations: []continuation{{reads: []string{"two\n"}}},
+			want:          []string{"tool call"},
+			wantResult:    contracts.IsProviderError,
+			wantFailed:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &scriptedReader{reads: tt.reads, err: errReset}
+			observer := &recordingObserver{}
+			splicer := &textSplicer{}
+			orchestrator := NewStreamOrchestrator(reader, &echoProcessor{provider: "first"}, "req", observer).WithSplicer(splicer)
+
+			readers := []*scriptedReader{reader}
+			observers := []*recordingObserver{observer}
+			var partials []string
+			orchestrator.resume = func(ctx context.Context, partial string) (*PendingStream, error) {
+				partials = append(partials, partial)
+				if len(partials) > len(tt.continuations) {
+					return nil, errors.New("no provider left to resume the stream")
+				}
+				next := tt.continuations[len(partials)-1]
+				reader := &scriptedReader{reads: next.reads, err: next.err}
+				observer := &recordingObserver{}
+				readers, observers = append(readers, reader), append(observers, observer)
+				return &PendingStream{handler: NewStreamOrchestrator(reader, &echoProcessor{provider: "next"}, "req", observer)}, nil
+			}
+			writer := &recordingWriter{}
+
+			err := orchestrator.Handle(context.Background(), writer)
+			if !tt.wantResult(err) {
+				t.Errorf("Handle() = %v", err)
+			}
+			if !slices.Equal(writer.writes, tt.want) {
+				t.Errorf("client received %q, want %q", writer.writes, tt.want)
+			}
+			if !slices.Equal(partials, tt.wantPartials) {
+				t.Errorf("resumed from %q, want %q", partials, tt.wantPartials)
+			}
+			if splicer.continued != len(readers)-1 {
+				t.Errorf("splicer continued %d times, want %d", splicer.continued, len(readers)-1)
+			}
+
+			// Every failed provider is charged with its failure, and every reader is closed
+			failed := 0
+			for i, observer := range observers {
+				if len(observer.finished) != 1 {
+					t.Errorf("observer %d finished %d times, want once", i, len(observer.finished))
+					continue
+				}
+				if errors.Is(observer.finished[0], errReset) {
+					failed++
+				}
+				if !readers[i].closed {
+					t.Errorf("reader %d not closed", i)
+				}
+			}
+			if failed != tt.wantFailed {
+				t.Errorf("%d providers charged with a failure, want %d", failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+// usageProcessor is an echoProcessor whose provider reports usage
+type usageProcessor struct {
+	echoProcessor
+}
+
+func (p *usageProcessor) Usage() (inputTokens, outputTokens int) {
+	return 12, 34
+}
+
+func TestStreamOrchestratorCompletion(t *testing.T) {
+	tests := []struct {
+		name  string
+		reads []string
+		err   error
+		want  []Completion
+	}{
+		{
+			name:  "served in full",
+			reads: []string{"hello\n", " world\n"},
+			want:  []Completion{{Output: "hello world", TokensInput: 12, TokensOutput: 34}},
+		},
+		{
+			name:  "failed stream",
+			reads: []string{"hello\n"},
+			err:   errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &scriptedReader{reads: tt.reads, err: tt.err}
+			orchestrator := NewStreamOrchestrator(reader, &usageProcessor{}, "req", nil).WithSplicer(&textSplicer{})
+			var got []Completion
+			orchestrator.onComplete = func(completion Completion) {
+				got = append(got, completion)
+			}
+
+			_ = orchestrator.Handle(context.Background(), &recordingWriter{})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("completions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
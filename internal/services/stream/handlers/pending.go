@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/writers"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/valyala/fasthttp"
+)
+
+// PendingStream is a validated streaming pipeline that has not been attached to a response yet.
+// Preparing one never touches the fiber.Ctx, so streaming races can prepare one per provider
+// concurrently and commit only the winner.
+type PendingStream struct {
+	handler   contracts.StreamHandler
+	requestID string
+	// sendDone appends the [DONE] message expected by OpenAI and Anthropic clients
+	sendDone bool
+	// provider and model serve the stream, and are skipped when resuming it
+	provider string
+	model    string
+}
+
+// ResumeFunc opens the continuation of a stream that failed after partial, the text already
+// sent to the client, on the next provider. The continuation is spliced in, never committed.
+type ResumeFunc func(ctx context.Context, partial string) (*PendingStream, error)
+
+// Resumer returns the ResumeFunc for a stream served by provider and model
+type Resumer func(provider, model string) ResumeFunc
+
+// resumerKey is the fiber locals key of the request's Resumer
+const resumerKey = "stream_resumer"
+
+// committedKey is the fiber locals key of the stream committed to the request
+const committedKey = "stream_committed"
+
+// SetResumer enables mid-stream recovery for the streams committed to c
+func SetResumer(c *fiber.Ctx, resumer Resumer) {
+	c.Locals(resumerKey, resumer)
+}
+
+// OnCompletion registers fn to be called once the stream committed to c was served in full. The
+// stream runs after the handler returned, so fn must not touch c. It reports false when no
+// stream was committed to c.
+func OnCompletion(c *fiber.Ctx, fn CompletionFunc) bool {
+	orchestrator, ok := c.Locals(committedKey).(*StreamOrchestrator)
+	if ok {
+		orchestrator.onComplete = fn
+	}
+	return ok
+}
+
+// Commit starts streaming the pipeline to the client
+func (p *PendingStream) Commit(c *fiber.Ctx) error {
+	fiberlog.Infof("[%s] Stream validated successfully, starting HTTP stream", p.requestID)
+
+	if orchestrator, ok := p.handler.(*StreamOrchestrator); ok {
+		if resumer, ok := c.Locals(resumerKey).(Resumer); ok && orchestrator.splicer != nil {
+			orchestrator.resume = resumer(p.provider, p.model)
+		}
+		c.Locals(committedKey, orchestrator)
+	}
+
+	fasthttpCtx := c.Context()
+	// SSE for all formats (the Gemini SDK matches responseLineRE against it too)
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Access-Control-Allow-Origin", "*")
+
+	fasthttpCtx.SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		// Create connection state tracker
+		connState := writers.NewFastHTTPConnectionState(fasthttpCtx)
+
+		httpWriter := writers.NewHTTPStreamWriter(w, connState, p.requestID, p.sendDone)
+
+		// Handle the stream
+		if err := p.handler.Handle(fasthttpCtx, httpWriter); err != nil {
+			if !contracts.IsExpectedError(err) {
+				fiberlog.Errorf("[%s] Stream error: %v", p.requestID, err)
+			} else {
+				fiberlog.Infof("[%s] Stream ended: %v", p.requestID, err)
+			}
+		}
+	}))
+
+	return nil
+}
+
+// Abort closes the upstream stream without sending it to the client
+func (p *PendingStream) Abort() {
+	if err := p.handler.Close(); err != nil {
+		fiberlog.Debugf("[%s] Error closing aborted stream: %v", p.requestID, err)
+	}
+}
@@ -22,10 +22,15 @@ type AnthropicChunkProcessor struct {
 	model        string
 	endpoint     string
 	usageWorker  *usage.Worker
+	// attempt provides the metadata (e.g. experiment assignment) and latency of recorded usage
+	attempt usage.Attempt
+	// inputTokens and outputTokens are the last usage the provider reported
+	inputTokens  int
+	outputTokens int
 }
 
 // NewAnthropicChunkProcessor creates a new Anthropic chunk processor
-func NewAnthropicChunkProcessor(provider, cacheSource, requestID, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker) *AnthropicChunkProcessor {
+func NewAnthropicChunkProcessor(provider, cacheSource, requestID, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker, attempt usage.Attempt) *AnthropicChunkProcessor {
 	return &AnthropicChunkProcessor{
 		provider:     provider,
 		cacheSource:  cacheSource,
@@ -35,6 +40,7 @@ func NewAnthropicChunkProcessor(provider, cacheSource, requestID, model, endpoin
 		model:        model,
 		endpoint:     endpoint,
 		usageWorker:  usageWorker,
+		attempt:      attempt,
 	}
 }
 
@@ -70,25 +76,29 @@ func (p *AnthropicChunkProcessor) Process(ctx context.Context, data []byte) ([]b
 	}
 
 	// Check if this is a message_delta event with usage data and record it
-	if adaptiveChunk.Type == "message_delta" && adaptiveChunk.Usage != nil && p.usageWorker != nil && p.apiKey != nil {
-		inputTokens := int(adaptiveChunk.Usage.InputTokens)
-		outputTokens := int(adaptiveChunk.Usage.OutputTokens)
-
-		usageParams := models.RecordUsageParams{
-			APIKeyID:       p.apiKey.ID,
-			OrganizationID: p.apiKey.OrganizationID,
-			UserID:         p.apiKey.UserID,
-			Endpoint:       p.endpoint,
-			Provider:       p.provider,
-			Model:          p.model,
-			TokensInput:    inputTokens,
-			TokensOutput:   outputTokens,
-			Cost:           usage.CalculateCost(p.provider, p.model, inputTokens, outputTokens),
-			StatusCode:     200,
-			RequestID:      p.requestID,
+	if adaptiveChunk.Type == "message_delta" && adaptiveChunk.Usage != nil {
+		p.inputTokens = int(adaptiveChunk.Usage.InputTokens)
+		p.outputTokens = int(adaptiveChunk.Usage.OutputTokens)
+
+		if p.usageWorker != nil && p.apiKey != nil {
+			usageParams := models.RecordUsageParams{
+				APIKeyID:       p.apiKey.ID,
+				OrganizationID: p.apiKey.OrganizationID,
+				UserID:         p.apiKey.UserID,
+				Endpoint:       p.endpoint,
+				Provider:       p.provider,
+				Model:          p.model,
+				TokensInput:    p.inputTokens,
+				TokensOutput:   p.outputTokens,
+				Cost:           usage.CalculateCost(p.provider, p.model, p.inputTokens, p.outputTokens),
+				StatusCode:     200,
+				RequestID:      p.requestID,
+				LatencyMs:      p.attempt.LatencyMs(),
+				Metadata:       p.attempt.Metadata,
+			}
+
+			p.usageWorker.Submit(usageParams, p.requestID)
 		}
-
-		p.usageWorker.Submit(usageParams, p.requestID)
 	}
 
 	// Marshal to JSON
@@ -117,3 +127,8 @@ func (p *AnthropicChunkProcessor) Process(ctx context.Context, data []byte) ([]b
 func (p *AnthropicChunkProcessor) Provider() string {
 	return p.provider
 }
+
+// Usage returns the token usage the provider reported, zero until it reports any
+func (p *AnthropicChunkProcessor) Usage() (inputTokens, outputTokens int) {
+	return p.inputTokens, p.outputTokens
+}
@@ -24,10 +24,15 @@ type GeminiChunkProcessor struct {
 	model        string
 	endpoint     string
 	usageWorker  *usage.Worker
+	// attempt provides the metadata (e.g. experiment assignment) and latency of recorded usage
+	attempt usage.Attempt
+	// inputTokens and outputTokens are the last usage the provider reported
+	inputTokens  int
+	outputTokens int
 }
 
 // NewGeminiChunkProcessor creates a new Gemini chunk processor
-func NewGeminiChunkProcessor(provider, cacheSource, requestID, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker) *GeminiChunkProcessor {
+func NewGeminiChunkProcessor(provider, cacheSource, requestID, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker, attempt usage.Attempt) *GeminiChunkProcessor {
 	return &GeminiChunkProcessor{
 		provider:     provider,
 		cacheSource:  cacheSource,
@@ -37,6 +42,7 @@ func NewGeminiChunkProcessor(provider, cacheSource, requestID, model, endpoint s
 		model:        model,
 		endpoint:     endpoint,
 		usageWorker:  usageWorker,
+		attempt:      attempt,
 	}
 }
 
@@ -67,25 +73,29 @@ func (p *GeminiChunkProcessor) Process(ctx context.Context, data []byte) ([]byte
 		// Note: Cache metadata would be added here if needed in the response structure
 	}
 
-	if adaptiveResponse.UsageMetadata != nil && p.usageWorker != nil && p.apiKey != nil {
-		inputTokens := int(adaptiveResponse.UsageMetadata.PromptTokenCount)
-		outputTokens := int(adaptiveResponse.UsageMetadata.CandidatesTokenCount)
-
-		usageParams := models.RecordUsageParams{
-			APIKeyID:       p.apiKey.ID,
-			OrganizationID: p.apiKey.OrganizationID,
-			UserID:         p.apiKey.UserID,
-			Endpoint:       p.endpoint,
-			Provider:       p.provider,
-			Model:          p.model,
-			TokensInput:    inputTokens,
-			TokensOutput:   outputTokens,
-			Cost:           usage.CalculateCost(p.provider, p.model, inputTokens, outputTokens),
-			StatusCode:     200,
-			RequestID:      p.requestID,
+	if adaptiveResponse.UsageMetadata != nil {
+		p.inputTokens = int(adaptiveResponse.UsageMetadata.PromptTokenCount)
+		p.outputTokens = int(adaptiveResponse.UsageMetadata.CandidatesTokenCount)
+
+		if p.usageWorker != nil && p.apiKey != nil {
+			usageParams := models.RecordUsageParams{
+				APIKeyID:       p.apiKey.ID,
+				OrganizationID: p.apiKey.OrganizationID,
+				UserID:         p.apiKey.UserID,
+				Endpoint:       p.endpoint,
+				Provider:       p.provider,
+				Model:          p.model,
+				TokensInput:    p.inputTokens,
+				TokensOutput:   p.outputTokens,
+				Cost:           usage.CalculateCost(p.provider, p.model, p.inputTokens, p.outputTokens),
+				StatusCode:     200,
+				RequestID:      p.requestID,
+				LatencyMs:      p.attempt.LatencyMs(),
+				Metadata:       p.attempt.Metadata,
+			}
+
+			p.usageWorker.Submit(usageParams, p.requestID)
 		}
-
-		p.usageWorker.Submit(usageParams, p.requestID)
 	}
 
 	// Marshal back to JSON for output
@@ -111,3 +121,8 @@ func (p *GeminiChunkProcessor) Process(ctx context.Context, data []byte) ([]byte
 func (p *GeminiChunkProcessor) Provider() string {
 	return p.provider
 }
+
+// Usage returns the token usage the provider reported, zero until it reports any
+func (p *GeminiChunkProcessor) Usage() (inputTokens, outputTokens int) {
+	return p.inputTokens, p.outputTokens
+}
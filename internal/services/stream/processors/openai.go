@@ -22,10 +22,15 @@ type OpenAIChunkProcessor struct {
 	model        string
 	endpoint     string
 	usageWorker  *usage.Worker
+	// attempt provides the metadata (e.g. experiment assignment) and latency of recorded usage
+	attempt usage.Attempt
+	// inputTokens and outputTokens are the last usage the provider reported
+	inputTokens  int
+	outputTokens int
 }
 
 // NewOpenAIChunkProcessor creates a new OpenAI chunk processor
-func NewOpenAIChunkProcessor(provider, cacheSource, requestID, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker) *OpenAIChunkProcessor {
+func NewOpenAIChunkProcessor(provider, cacheSource, requestID, model, endpoint string, usageService *usage.Service, apiKey *models.APIKey, usageWorker *usage.Worker, attempt usage.Attempt) *OpenAIChunkProcessor {
 	return &OpenAIChunkProcessor{
 		provider:     provider,
 		cacheSource:  cacheSource,
@@ -35,6 +40,7 @@ func NewOpenAIChunkProcessor(provider, cacheSource, requestID, model, endpoint s
 		model:        model,
 		endpoint:     endpoint,
 		usageWorker:  usageWorker,
+		attempt:      attempt,
 	}
 }
 
@@ -65,25 +71,29 @@ func (p *OpenAIChunkProcessor) Process(ctx context.Context, data []byte) ([]byte
 	}
 
 	// Check if this chunk contains usage data (final chunk) and record it
-	if adaptiveChunk.Usage.TotalTokens > 0 && p.usageWorker != nil && p.apiKey != nil {
-		inputTokens := int(adaptiveChunk.Usage.PromptTokens)
-		outputTokens := int(adaptiveChunk.Usage.CompletionTokens)
-
-		usageParams := models.RecordUsageParams{
-			APIKeyID:       p.apiKey.ID,
-			OrganizationID: p.apiKey.OrganizationID,
-			UserID:         p.apiKey.UserID,
-			Endpoint:       p.endpoint,
-			Provider:       p.provider,
-			Model:          p.model,
-			TokensInput:    inputTokens,
-			TokensOutput:   outputTokens,
-			Cost:           usage.CalculateCost(p.provider, p.model, inputTokens, outputTokens),
-			StatusCode:     200,
-			RequestID:      p.requestID,
+	if adaptiveChunk.Usage.TotalTokens > 0 {
+		p.inputTokens = int(adaptiveChunk.Usage.PromptTokens)
+		p.outputTokens = int(adaptiveChunk.Usage.CompletionTokens)
+
+		if p.usageWorker != nil && p.apiKey != nil {
+			usageParams := models.RecordUsageParams{
+				APIKeyID:       p.apiKey.ID,
+				OrganizationID: p.apiKey.OrganizationID,
+				UserID:         p.apiKey.UserID,
+				Endpoint:       p.endpoint,
+				Provider:       p.provider,
+				Model:          p.model,
+				TokensInput:    p.inputTokens,
+				TokensOutput:   p.outputTokens,
+				Cost:           usage.CalculateCost(p.provider, p.model, p.inputTokens, p.outputTokens),
+				StatusCode:     200,
+				RequestID:      p.requestID,
+				LatencyMs:      p.attempt.LatencyMs(),
+				Metadata:       p.attempt.Metadata,
+			}
+
+			p.usageWorker.Submit(usageParams, p.requestID)
 		}
-
-		p.usageWorker.Submit(usageParams, p.requestID)
 	}
 
 	// Marshal to JSON
@@ -108,3 +118,8 @@ func (p *OpenAIChunkProcessor) Process(ctx context.Context, data []byte) ([]byte
 func (p *OpenAIChunkProcessor) Provider() string {
 	return p.provider
 }
+
+// Usage returns the token usage the provider reported, zero until it reports any
+func (p *OpenAIChunkProcessor) Usage() (inputTokens, outputTokens int) {
+	return p.inputTokens, p.outputTokens
+}
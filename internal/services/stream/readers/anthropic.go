@@ -5,43 +5,47 @@ import (
 	"io"
 	"sync"
 
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
 	"github.com/valyala/bytebufferpool"
 )
 
 // AnthropicNativeStreamReader wraps native Anthropic SDK streams
 type AnthropicNativeStreamReader struct {
-	stream     *ssestream.Stream[anthropic.MessageStreamEventUnion]
-	buffer     *bytebufferpool.ByteBuffer
-	requestID  string
-	closeOnce  sync.Once
-	firstEvent *anthropic.MessageStreamEventUnion // Cached first event to replay
-	firstRead  bool                               // Track if first event has been read
+	stream    contracts.EventStream[anthropic.MessageStreamEventUnion]
+	buffer    *bytebufferpool.ByteBuffer
+	requestID string
+	closeOnce sync.Once
+	pending   []anthropic.MessageStreamEventUnion // Events read during validation, replayed first
 }
 
 // NewAnthropicNativeStreamReader creates a new native Anthropic stream reader
-// Validates stream by reading first event
-func NewAnthropicNativeStreamReader(stream *ssestream.Stream[anthropic.MessageStreamEventUnion], requestID string) (*AnthropicNativeStreamReader, error) {
-	// Validate stream by trying to get first event
-	if !stream.Next() {
-		if err := stream.Err(); err != nil {
-			return nil, err
+// Validates stream by reading up to the first content event
+func NewAnthropicNativeStreamReader(stream contracts.EventStream[anthropic.MessageStreamEventUnion], requestID string) (*AnthropicNativeStreamReader, error) {
+	// Validate stream by reading past message_start until it produces content, so errors such as
+	// overloaded_error sent after message_start still allow fallback
+	var pending []anthropic.MessageStreamEventUnion
+	for stream.Next() {
+		event := stream.Current()
+		pending = append(pending, event)
+		if hasAnthropicContent(event) {
+			break
 		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
 		return nil, io.EOF
 	}
 
-	// Get the first event - we'll replay it in Read()
-	firstEvent := stream.Current()
-
 	return &AnthropicNativeStreamReader{
-		stream:     stream,
-		buffer:     utils.Get(), // Get buffer from pool
-		requestID:  requestID,
-		firstEvent: &firstEvent,
-		firstRead:  false,
+		stream:    stream,
+		buffer:    utils.Get(), // Get buffer from pool
+		requestID: requestID,
+		pending:   pending,
 	}, nil
 }
 
@@ -56,10 +60,10 @@ func (r *AnthropicNativeStreamReader) Read(p []byte) (n int, err error) {
 
 	var event anthropic.MessageStreamEventUnion
 
-	// If we have a first event and haven't read it yet, use it
-	if r.firstEvent != nil && !r.firstRead {
-		event = *r.firstEvent
-		r.firstRead = true
+	// Replay events read during validation first
+	if len(r.pending) > 0 {
+		event = r.pending[0]
+		r.pending = r.pending[1:]
 	} else {
 		// Try to get next event
 		if !r.stream.Next() {
@@ -78,6 +82,7 @@ func (r *AnthropicNativeStreamReader) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return 0, err
 	}
+	eventData = append(eventData, contracts.EventDelimiter)
 
 	// Buffer the data
 	r.buffer.B = append(r.buffer.B[:0], eventData...)
@@ -102,3 +107,13 @@ func (r *AnthropicNativeStreamReader) Close() error {
 	})
 	return err
 }
+
+// hasAnthropicContent reports whether an event carries output: a content delta or the end of
+// the message
+func hasAnthropicContent(event anthropic.MessageStreamEventUnion) bool {
+	switch event.Type {
+	case "content_block_delta", "message_delta", "message_stop":
+		return true
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/valyala/bytebufferpool"
@@ -16,19 +17,18 @@ import (
 // GeminiStreamReader provides pure I/O reading from Gemini streams
 // This reader ONLY reads raw chunk data - no format conversion
 type GeminiStreamReader struct {
-	iterator   iter.Seq2[*genai.GenerateContentResponse, error]
-	buffer     *bytebufferpool.ByteBuffer
-	done       atomic.Bool
-	requestID  string
-	closeOnce  sync.Once
-	next       func() (*genai.GenerateContentResponse, error, bool)
-	stop       func()
-	firstChunk *genai.GenerateContentResponse // Cached first chunk to replay
-	firstRead  bool                           // Track if first chunk has been read
+	iterator  iter.Seq2[*genai.GenerateContentResponse, error]
+	buffer    *bytebufferpool.ByteBuffer
+	done      atomic.Bool
+	requestID string
+	closeOnce sync.Once
+	next      func() (*genai.GenerateContentResponse, error, bool)
+	stop      func()
+	pending   []*genai.GenerateContentResponse // Chunks read during validation, replayed first
 }
 
 // NewGeminiStreamReader creates a new Gemini stream reader
-// Validates stream by reading first chunk
+// Validates stream by reading up to the first content chunk
 func NewGeminiStreamReader(
 	streamIter iter.Seq2[*genai.GenerateContentResponse, error],
 	requestID string,
@@ -42,22 +42,29 @@ func NewGeminiStreamReader(
 	// Set up stateful iterator using iter.Pull2
 	reader.setupIterator()
 
-	// Validate stream by trying to get first chunk
-	firstChunk, err, hasNext := reader.next()
-	if !hasNext || err != nil {
-		if reader.stop != nil {
-			reader.stop()
+	// Validate stream by reading until it produces content
+	for {
+		chunk, err, hasNext := reader.next()
+		if !hasNext || err != nil {
+			if err != nil && err != io.EOF || len(reader.pending) == 0 {
+				if reader.stop != nil {
+					reader.stop()
+				}
+				if err != nil && err != io.EOF {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			// The stream ended without content; replay what it sent
+			reader.done.Store(true)
+			break
 		}
-		if err != nil && err != io.EOF {
-			return nil, err
+		reader.pending = append(reader.pending, chunk)
+		if hasGeminiContent(chunk) {
+			break
 		}
-		return nil, io.EOF
 	}
 
-	// Store first chunk to replay it
-	reader.firstChunk = firstChunk
-	reader.firstRead = false
-
 	return reader, nil
 }
 
@@ -94,20 +101,17 @@ func (r *GeminiStreamReader) Read(p []byte) (n int, err error) {
 		return n, nil
 	}
 
-	// Check if stream is done
-	if r.done.Load() {
-		return 0, io.EOF
-	}
-
 	var chunk *genai.GenerateContentResponse
 	var hasNext bool
 
-	// If we have a first chunk and haven't read it yet, use it
-	if r.firstChunk != nil && !r.firstRead {
-		chunk = r.firstChunk
-		err = nil
+	// Replay chunks read during validation first
+	if len(r.pending) > 0 {
+		chunk = r.pending[0]
+		r.pending = r.pending[1:]
 		hasNext = true
-		r.firstRead = true
+	} else if r.done.Load() {
+		// Check if stream is done
+		return 0, io.EOF
 	} else {
 		// Read next chunk from Gemini stream
 		chunk, err, hasNext = r.next()
@@ -131,6 +135,7 @@ func (r *GeminiStreamReader) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return 0, err
 	}
+	chunkData = append(chunkData, contracts.EventDelimiter)
 
 	// Copy to output buffer (no additional formatting needed)
 	n = copy(p, chunkData)
@@ -159,3 +164,17 @@ func (r *GeminiStreamReader) Close() error {
 	})
 	return closeErr
 }
+
+// hasGeminiContent reports whether a chunk carries output: candidate parts, a finish reason or
+// a blocked prompt
+func hasGeminiContent(chunk *genai.GenerateContentResponse) bool {
+	if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+		return true
+	}
+	for _, candidate := range chunk.Candidates {
+		if candidate.FinishReason != "" || candidate.Content != nil && len(candidate.Content.Parts) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -8,50 +8,59 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/Egham-7/adaptive-proxy/internal/services/stream/contracts"
 	"github.com/Egham-7/adaptive-proxy/internal/utils"
 
 	"github.com/openai/openai-go/v2"
-	ssestream "github.com/openai/openai-go/v2/packages/ssestream"
 	"github.com/valyala/bytebufferpool"
 )
 
 // OpenAIStreamReader provides pure I/O reading from OpenAI streams
 // This reader ONLY reads raw chunk data - no format conversion
 type OpenAIStreamReader struct {
-	stream     *ssestream.Stream[openai.ChatCompletionChunk]
-	buffer     *bytebufferpool.ByteBuffer
-	done       atomic.Bool
-	requestID  string
-	closeOnce  sync.Once
-	firstChunk *openai.ChatCompletionChunk // Cached first chunk to replay
-	firstRead  bool                        // Track if first chunk has been read
+	stream    contracts.EventStream[openai.ChatCompletionChunk]
+	buffer    *bytebufferpool.ByteBuffer
+	done      atomic.Bool
+	requestID string
+	closeOnce sync.Once
+	pending   []openai.ChatCompletionChunk // Chunks read during validation, replayed first
 }
 
 // NewOpenAIStreamReader creates a new OpenAI stream reader
-// Validates stream by reading first chunk, returns error if stream is invalid
+// Validates stream by reading up to the first content chunk, returns error if stream is invalid
 func NewOpenAIStreamReader(
-	stream *ssestream.Stream[openai.ChatCompletionChunk],
+	stream contracts.EventStream[openai.ChatCompletionChunk],
 	requestID string,
 ) (*OpenAIStreamReader, error) {
-	// Validate stream by trying to get first chunk
-	// This detects provider errors (429, 500, etc.) before starting HTTP stream
-	if !stream.Next() {
-		if err := stream.Err(); err != nil {
-			return nil, err
+	// Validate stream by reading until it produces content
+	// This detects provider errors (429, 500, etc.) before starting HTTP stream, including
+	// those sent after a role-only opening chunk
+	var pending []openai.ChatCompletionChunk
+	ended := true
+	for stream.Next() {
+		chunk := stream.Current()
+		pending = append(pending, chunk)
+		if hasOpenAIContent(&chunk) {
+			ended = false
+			break
 		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
 		return nil, errors.New("empty stream from provider")
 	}
 
-	// Get the first chunk - we'll replay it in Read()
-	firstChunk := stream.Current()
-
-	return &OpenAIStreamReader{
-		stream:     stream,
-		buffer:     utils.Get(), // Get buffer from pool
-		requestID:  requestID,
-		firstChunk: &firstChunk,
-		firstRead:  false,
-	}, nil
+	reader := &OpenAIStreamReader{
+		stream:    stream,
+		buffer:    utils.Get(), // Get buffer from pool
+		requestID: requestID,
+		pending:   pending,
+	}
+	// The stream ended without content; replay what it sent
+	reader.done.Store(ended)
+	return reader, nil
 }
 
 // Read implements io.Reader - pure I/O operation
@@ -63,17 +72,15 @@ func (r *OpenAIStreamReader) Read(p []byte) (n int, err error) {
 		return n, nil
 	}
 
-	// Check if stream is done
-	if r.done.Load() {
-		return 0, io.EOF
-	}
-
 	var chunk openai.ChatCompletionChunk
 
-	// If we have a first chunk and haven't read it yet, use it
-	if r.firstChunk != nil && !r.firstRead {
-		chunk = *r.firstChunk
-		r.firstRead = true
+	// Replay chunks read during validation first
+	if len(r.pending) > 0 {
+		chunk = r.pending[0]
+		r.pending = r.pending[1:]
+	} else if r.done.Load() {
+		// Check if stream is done
+		return 0, io.EOF
 	} else {
 		// Try to read next chunk from stream
 		if !r.stream.Next() {
@@ -103,6 +110,7 @@ func (r *OpenAIStreamReader) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return 0, err
 	}
+	chunkData = append(chunkData, contracts.EventDelimiter)
 
 	// Buffer the data
 	r.buffer.B = append(r.buffer.B[:0], chunkData...)
@@ -138,3 +146,14 @@ func (r *OpenAIStreamReader) Close() error {
 func (r *OpenAIStreamReader) hasFinishReason(chunk *openai.ChatCompletionChunk) bool {
 	return len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != ""
 }
+
+// hasOpenAIContent reports whether a chunk carries output: text, a refusal, tool calls or the
+// finish reason
+func hasOpenAIContent(chunk *openai.ChatCompletionChunk) bool {
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" || choice.Delta.Refusal != "" || len(choice.Delta.ToolCalls) > 0 || choice.FinishReason != "" {
+			return true
+		}
+	}
+	return false
+}
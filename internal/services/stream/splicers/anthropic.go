@@ -0,0 +1,113 @@
+package splicers
+
+import (
+	"encoding/json"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// AnthropicSplicer splices Anthropic message stream events. The continuation's message_start is
+// dropped, its first text block joins the text block the failed stream left open, and its other
+// content blocks are renumbered to follow the blocks already sent.
+type AnthropicSplicer struct {
+	textState
+	// next is the index of the next content block
+	next int64
+	// open is set while the last content block sent has not been stopped
+	open bool
+	// offset is added to the block indexes of the continuation
+	offset int64
+	// join is set while the continuation's first text block is still to be joined to the open one
+	join bool
+}
+
+// NewAnthropicSplicer creates a new Anthropic splicer
+func NewAnthropicSplicer() *AnthropicSplicer {
+	return &AnthropicSplicer{}
+}
+
+// Observe records an event sent to the client
+func (s *AnthropicSplicer) Observe(data []byte) {
+	var event anthropic.MessageStreamEventUnion
+	if err := json.Unmarshal(data, &event); err != nil {
+		s.unresumable = true
+		return
+	}
+	switch event.Type {
+	case "content_block_start":
+		// Only text can be continued; tool use and thinking cannot be carried over
+		if event.ContentBlock.Type != "text" {
+			s.unresumable = true
+		}
+		s.text.WriteString(event.ContentBlock.Text)
+		s.next = event.Index + 1
+		s.open = true
+	case "content_block_delta":
+		if event.Delta.Type != "text_delta" {
+			s.unresumable = true
+		}
+		s.text.WriteString(event.Delta.Text)
+	case "content_block_stop":
+		s.open = false
+	}
+}
+
+// Continue marks the start of a continuation stream
+func (s *AnthropicSplicer) Continue() {
+	s.textState.Continue()
+	s.offset = s.next
+	s.join = s.open
+	if s.open {
+		s.offset = s.next - 1
+	}
+}
+
+// Splice rewrites a continuation event
+func (s *AnthropicSplicer) Splice(data []byte) []byte {
+	var event anthropic.MessageStreamEventUnion
+	if err := json.Unmarshal(data, &event); err != nil {
+		return data
+	}
+	switch event.Type {
+	case "message_start":
+		// The client already has the message
+		return nil
+	case "content_block_start":
+		if s.join {
+			s.join = false
+			if event.Index == 0 && event.ContentBlock.Type == "text" {
+				// Text sent with the start carries over as a delta of the open block
+				text := s.trim(event.ContentBlock.Text)
+				if text == "" {
+					return nil
+				}
+				event = anthropic.MessageStreamEventUnion{
+					Type:  "content_block_delta",
+					Index: event.Index,
+					Delta: anthropic.MessageStreamEventUnionDelta{Type: "text_delta", Text: text},
+				}
+				break
+			}
+			// A continuation opening with anything but text starts after the open block
+			s.offset++
+		}
+		event.ContentBlock.Text = s.trim(event.ContentBlock.Text)
+	case "content_block_delta":
+		if event.Delta.Type == "text_delta" {
+			if event.Delta.Text = s.trim(event.Delta.Text); event.Delta.Text == "" {
+				return nil
+			}
+		}
+	case "content_block_stop":
+		// Only renumbered
+	default:
+		return data
+	}
+	event.Index += s.offset
+
+	spliced, err := json.Marshal(&event)
+	if err != nil {
+		return data
+	}
+	return spliced
+}
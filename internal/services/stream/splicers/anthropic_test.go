@@ -0,0 +1,149 @@
+package splicers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// describeAnthropicEvent summarizes a spliced event as "type#index text", or "dropped"
+func describeAnthropicEvent(t *testing.T, data []byte) string {
+	t.Helper()
+	if data == nil {
+		return "dropped"
+	}
+	var event struct {
+		Type         string `json:"type"`
+		Index        int64  `json:"index"`
+		ContentBlock struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content_block"`
+		Delta struct {
+			Type       string `json:"type"`
+			Text       string `json:"text"`
+			StopReason string `json:"stop_reason"`
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("spliced event is not JSON: %v", err)
+	}
+	switch event.Type {
+	case "content_block_start":
+		return fmt.Sprintf("%s#%d %s:%s", event.Type, event.Index, event.ContentBlock.Type, event.ContentBlock.Text)
+	case "content_block_delta":
+		return fmt.Sprintf("%s#%d %s", event.Type, event.Index, event.Delta.Text)
+	case "content_block_stop":
+		return fmt.Sprintf("%s#%d", event.Type, event.Index)
+	default:
+		return event.Type + " " + event.Delta.StopReason
+	}
+}
+
+func TestAnthropicSplicer(t *testing.T) {
+	const (
+		messageStart = `{"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[]}}`
+		messageDelta = `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`
+	)
+	textStart := func(index int, text string) string {
+		return fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"text","text":%q}}`, index, text)
+	}
+	textDelta := func(index int, text string) string {
+		return fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":%q}}`, index, text)
+	}
+	stop := func(index int) string {
+		return fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, index)
+	}
+	toolStart := func(index int) string {
+		return fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}`, index)
+	}
+
+	tests := []struct {
+		name          string
+		sent          []string
+		wantPartial   string
+		wantResumable bool
+		continuation  []string
+		want          []string
+	}{
+		{
+			name:          "continuation joins the open text block",
+			sent:          []string{messageStart, textStart(0, ""), textDelta(0, "Hello, wor")},
+			wantPartial:   "Hello, wor",
+			wantResumable: true,
+			continuation:  []string{messageStart, textStart(0, ""), textDelta(0, "ld!"), stop(0), messageDelta},
+			want: []string{
+				"dropped", "dropped", "content_block_delta#0 ld!", "content_block_stop#0", "message_delta end_turn",
+			},
+		},
+		{
+			name:          "text sent with the joined block start becomes a delta",
+			sent:          []string{textStart(0, "Hello, wor")},
+			wantPartial:   "Hello, wor",
+			wantResumable: true,
+			continuation:  []string{textStart(0, "ld"), textDelta(0, "!")},
+			want:          []string{"content_block_delta#0 ld", "content_block_delta#0 !"},
+		},
+		{
+			name:          "blocks after a stopped block are renumbered",
+			sent:          []string{textStart(0, ""), textDelta(0, "Done."), stop(0)},
+			wantPartial:   "Done.",
+			wantResumable: true,
+			continuation:  []string{textStart(0, ""), textDelta(0, " More"), stop(0), toolStart(1)},
+			want: []string{
+				"content_block_start#1 text:", "content_block_delta#1  More", "content_block_stop#1", "content_block_start#2 tool_use:",
+			},
+		},
+		{
+			name:          "continuation opening with a tool follows the open block",
+			sent:          []string{textStart(0, "Let me check")},
+			wantPartial:   "Let me check",
+			wantResumable: true,
+			continuation:  []string{toolStart(0), stop(0)},
+			want:          []string{"content_block_start#1 tool_use:", "content_block_stop#1"},
+		},
+		{
+			name:          "leading whitespace dropped after a space",
+			sent:          []string{textStart(0, "Hello, ")},
+			wantPartial:   "Hello, ",
+			wantResumable: true,
+			continuation:  []string{textStart(0, " "), textDelta(0, "\n"), textDelta(0, " world")},
+			want:          []string{"dropped", "dropped", "content_block_delta#0 world"},
+		},
+		{
+			name:        "tool use cannot be continued",
+			sent:        []string{textStart(0, "Checking"), stop(0), toolStart(1)},
+			wantPartial: "Checking",
+		},
+		{
+			name: "thinking cannot be continued",
+			sent: []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm"}}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splicer := NewAnthropicSplicer()
+			for _, event := range tt.sent {
+				splicer.Observe([]byte(event))
+			}
+			partial, resumable := splicer.Partial()
+			if partial != tt.wantPartial || resumable != tt.wantResumable {
+				t.Errorf("Partial() = (%q, %v), want (%q, %v)", partial, resumable, tt.wantPartial, tt.wantResumable)
+			}
+
+			splicer.Continue()
+			var got []string
+			for _, event := range tt.continuation {
+				got = append(got, describeAnthropicEvent(t, splicer.Splice([]byte(event))))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("spliced %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
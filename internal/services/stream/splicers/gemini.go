@@ -0,0 +1,92 @@
+package splicers
+
+import (
+	"encoding/json"
+
+	"google.golang.org/genai"
+)
+
+// GeminiSplicer splices Gemini GenerateContentResponse chunks. Chunks stand alone, so the
+// continuation only takes the original response id.
+type GeminiSplicer struct {
+	textState
+	responseID string
+}
+
+// NewGeminiSplicer creates a new Gemini splicer
+func NewGeminiSplicer() *GeminiSplicer {
+	return &GeminiSplicer{}
+}
+
+// Observe records a chunk sent to the client
+func (s *GeminiSplicer) Observe(data []byte) {
+	var chunk genai.GenerateContentResponse
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		s.unresumable = true
+		return
+	}
+	if s.responseID == "" {
+		s.responseID = chunk.ResponseID
+	}
+	for _, candidate := range chunk.Candidates {
+		// Only a single text candidate can be continued
+		if candidate.Index != 0 {
+			s.unresumable = true
+		}
+		if candidate.Content == nil {
+			continue
+		}
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil || part.ExecutableCode != nil || part.CodeExecutionResult != nil ||
+				part.InlineData != nil || part.FileData != nil:
+				s.unresumable = true
+			case !part.Thought:
+				s.text.WriteString(part.Text)
+			}
+		}
+	}
+}
+
+// Splice rewrites a continuation chunk, dropping those left without content
+func (s *GeminiSplicer) Splice(data []byte) []byte {
+	var chunk genai.GenerateContentResponse
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return data
+	}
+	if s.responseID != "" {
+		chunk.ResponseID = s.responseID
+	}
+
+	keep := chunk.UsageMetadata != nil || chunk.PromptFeedback != nil
+	for _, candidate := range chunk.Candidates {
+		if candidate.FinishReason != "" {
+			keep = true
+		}
+		if candidate.Content == nil {
+			continue
+		}
+		parts := candidate.Content.Parts[:0]
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" && !part.Thought {
+				if part.Text = s.trim(part.Text); part.Text == "" {
+					continue
+				}
+			}
+			parts = append(parts, part)
+		}
+		candidate.Content.Parts = parts
+		if len(parts) > 0 {
+			keep = true
+		}
+	}
+	if !keep {
+		return nil
+	}
+
+	spliced, err := json.Marshal(&chunk)
+	if err != nil {
+		return data
+	}
+	return spliced
+}
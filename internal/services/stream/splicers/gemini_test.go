@@ -0,0 +1,120 @@
+package splicers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// describeGeminiChunk summarizes a spliced chunk as "responseId text|text/finishReason", or
+// "dropped"
+func describeGeminiChunk(t *testing.T, data []byte) string {
+	t.Helper()
+	if data == nil {
+		return "dropped"
+	}
+	var chunk struct {
+		ResponseID string `json:"responseId"`
+		Candidates []struct {
+			Content *struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		t.Fatalf("spliced chunk is not JSON: %v", err)
+	}
+	description := chunk.ResponseID
+	for _, candidate := range chunk.Candidates {
+		var texts []string
+		if candidate.Content != nil {
+			for _, part := range candidate.Content.Parts {
+				texts = append(texts, part.Text)
+			}
+		}
+		description += fmt.Sprintf(" %s/%s", strings.Join(texts, "|"), candidate.FinishReason)
+	}
+	return description
+}
+
+func TestGeminiSplicer(t *testing.T) {
+	text := func(responseID, text string) string {
+		return fmt.Sprintf(`{"responseId":%q,"candidates":[{"index":0,"content":{"role":"model","parts":[{"text":%q}]}}]}`, responseID, text)
+	}
+
+	tests := []struct {
+		name          string
+		sent          []string
+		wantPartial   string
+		wantResumable bool
+		continuation  []string
+		want          []string
+	}{
+		{
+			name:          "continuation takes the response id",
+			sent:          []string{text("resp-1", "Hello"), text("resp-1", ", wor")},
+			wantPartial:   "Hello, wor",
+			wantResumable: true,
+			continuation: []string{
+				text("resp-2", "ld!"),
+				`{"responseId":"resp-2","candidates":[{"index":0,"finishReason":"STOP"}]}`,
+				`{"responseId":"resp-2","usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":2}}`,
+			},
+			want: []string{"resp-1 ld!/", "resp-1 /STOP", "resp-1"},
+		},
+		{
+			name: "thoughts are not part of the partial text",
+			sent: []string{
+				`{"responseId":"resp-1","candidates":[{"index":0,"content":{"parts":[{"text":"Planning","thought":true},{"text":"Answer: "}]}}]}`,
+			},
+			wantPartial:   "Answer: ",
+			wantResumable: true,
+			continuation:  []string{text("resp-2", " "), text("resp-2", "  42")},
+			want:          []string{"dropped", "resp-1 42/"},
+		},
+		{
+			name: "function calls cannot be continued",
+			sent: []string{
+				`{"candidates":[{"index":0,"content":{"parts":[{"functionCall":{"name":"weather","args":{}}}]}}]}`,
+			},
+		},
+		{
+			name: "inline data cannot be continued",
+			sent: []string{
+				`{"candidates":[{"index":0,"content":{"parts":[{"inlineData":{"mimeType":"image/png","data":"iVBORw0KGgo="}}]}}]}`,
+			},
+		},
+		{
+			name:        "several candidates cannot be continued",
+			sent:        []string{`{"candidates":[{"index":0,"content":{"parts":[{"text":"A"}]}},{"index":1,"content":{"parts":[{"text":"B"}]}}]}`},
+			wantPartial: "AB",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splicer := NewGeminiSplicer()
+			for _, chunk := range tt.sent {
+				splicer.Observe([]byte(chunk))
+			}
+			partial, resumable := splicer.Partial()
+			if partial != tt.wantPartial || resumable != tt.wantResumable {
+				t.Errorf("Partial() = (%q, %v), want (%q, %v)", partial, resumable, tt.wantPartial, tt.wantResumable)
+			}
+
+			splicer.Continue()
+			var got []string
+			for _, chunk := range tt.continuation {
+				got = append(got, describeGeminiChunk(t, splicer.Splice([]byte(chunk))))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("spliced %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
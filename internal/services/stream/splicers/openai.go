@@ -0,0 +1,67 @@
+package splicers
+
+import (
+	"encoding/json"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// OpenAISplicer splices OpenAI chat completion chunks. Continuation chunks take the original
+// response's id and creation time and lose their role, which the client has already received.
+type OpenAISplicer struct {
+	textState
+	id      string
+	created int64
+}
+
+// NewOpenAISplicer creates a new OpenAI splicer
+func NewOpenAISplicer() *OpenAISplicer {
+	return &OpenAISplicer{}
+}
+
+// Observe records a chunk sent to the client
+func (s *OpenAISplicer) Observe(data []byte) {
+	var chunk openai.ChatCompletionChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		s.unresumable = true
+		return
+	}
+	if s.id == "" {
+		s.id, s.created = chunk.ID, chunk.Created
+	}
+	for _, choice := range chunk.Choices {
+		// Only a single text choice can be continued
+		if choice.Index != 0 || choice.Delta.Refusal != "" || len(choice.Delta.ToolCalls) > 0 || choice.Delta.FunctionCall.Name != "" {
+			s.unresumable = true
+		}
+		s.text.WriteString(choice.Delta.Content)
+	}
+}
+
+// Splice rewrites a continuation chunk, dropping those left without content
+func (s *OpenAISplicer) Splice(data []byte) []byte {
+	var chunk openai.ChatCompletionChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return data
+	}
+	chunk.ID, chunk.Created = s.id, s.created
+
+	keep := chunk.Usage.TotalTokens > 0
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		choice.Delta.Role = ""
+		choice.Delta.Content = s.trim(choice.Delta.Content)
+		if choice.Delta.Content != "" || choice.Delta.Refusal != "" || len(choice.Delta.ToolCalls) > 0 || choice.FinishReason != "" {
+			keep = true
+		}
+	}
+	if !keep {
+		return nil
+	}
+
+	spliced, err := json.Marshal(&chunk)
+	if err != nil {
+		return data
+	}
+	return spliced
+}
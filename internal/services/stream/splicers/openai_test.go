@@ -0,0 +1,118 @@
+package splicers
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// describeOpenAIChunk summarizes a spliced chunk as "id@created role:content/finish_reason ...",
+// or "dropped"
+func describeOpenAIChunk(t *testing.T, data []byte) string {
+	t.Helper()
+	if data == nil {
+		return "dropped"
+	}
+	var chunk struct {
+		ID      string `json:"id"`
+		Created int64  `json:"created"`
+		Choices []struct {
+			Delta struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"delta"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		t.Fatalf("spliced chunk is not JSON: %v", err)
+	}
+	description := fmt.Sprintf("%s@%d", chunk.ID, chunk.Created)
+	for _, choice := range chunk.Choices {
+		description += fmt.Sprintf(" %s:%s/%s", choice.Delta.Role, choice.Delta.Content, choice.FinishReason)
+	}
+	return description
+}
+
+func TestOpenAISplicer(t *testing.T) {
+	tests := []struct {
+		name          string
+		sent          []string
+		wantPartial   string
+		wantResumable bool
+		continuation  []string
+		want          []string
+	}{
+		{
+			name: "continuation joins the response",
+			sent: []string{
+				`{"id":"chatcmpl-1","created":100,"choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`,
+				`{"id":"chatcmpl-1","created":100,"choices":[{"index":0,"delta":{"content":", wor"}}]}`,
+			},
+			wantPartial:   "Hello, wor",
+			wantResumable: true,
+			continuation: []string{
+				`{"id":"chatcmpl-2","created":200,"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+				`{"id":"chatcmpl-2","created":200,"choices":[{"index":0,"delta":{"content":"ld!"}}]}`,
+				`{"id":"chatcmpl-2","created":200,"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+				`{"id":"chatcmpl-2","created":200,"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`,
+			},
+			want: []string{"dropped", "chatcmpl-1@100 :ld!/", "chatcmpl-1@100 :/stop", "chatcmpl-1@100"},
+		},
+		{
+			name:          "leading whitespace dropped after a space",
+			sent:          []string{`{"id":"chatcmpl-1","created":100,"choices":[{"index":0,"delta":{"content":"Hello, "}}]}`},
+			wantPartial:   "Hello, ",
+			wantResumable: true,
+			continuation: []string{
+				`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{"content":"  "}}]}`,
+				`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{"content":" world "}}]}`,
+				`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{"content":" again"}}]}`,
+			},
+			want: []string{"dropped", "chatcmpl-1@100 :world /", "chatcmpl-1@100 : again/"},
+		},
+		{
+			name: "tool calls cannot be continued",
+			sent: []string{
+				`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"weather"}}]}}]}`,
+			},
+		},
+		{
+			name:        "refusals cannot be continued",
+			sent:        []string{`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"I","refusal":"No"}}]}`},
+			wantPartial: "I",
+		},
+		{
+			name:        "several choices cannot be continued",
+			sent:        []string{`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"A"}},{"index":1,"delta":{"content":"B"}}]}`},
+			wantPartial: "AB",
+		},
+		{
+			name: "malformed chunks cannot be continued",
+			sent: []string{`{"id":`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splicer := NewOpenAISplicer()
+			for _, chunk := range tt.sent {
+				splicer.Observe([]byte(chunk))
+			}
+			partial, resumable := splicer.Partial()
+			if partial != tt.wantPartial || resumable != tt.wantResumable {
+				t.Errorf("Partial() = (%q, %v), want (%q, %v)", partial, resumable, tt.wantPartial, tt.wantResumable)
+			}
+
+			splicer.Continue()
+			var got []string
+			for _, chunk := range tt.continuation {
+				got = append(got, describeOpenAIChunk(t, splicer.Splice([]byte(chunk))))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("spliced %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
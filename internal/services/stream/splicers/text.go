@@ -0,0 +1,40 @@
+package splicers
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textState tracks the text a stream has sent, shared by the format splicers
+type textState struct {
+	text strings.Builder
+	// unresumable is set once the stream sent output other than text
+	unresumable bool
+	// trimLeading drops the leading whitespace of a continuation when the partial text already
+	// ends with whitespace, which prefills have to leave out
+	trimLeading bool
+}
+
+// Partial returns the text sent so far and whether a continuation can pick up from it
+func (t *textState) Partial() (string, bool) {
+	return t.text.String(), !t.unresumable
+}
+
+// Continue marks the start of a continuation stream
+func (t *textState) Continue() {
+	last, _ := utf8.DecodeLastRuneInString(t.text.String())
+	t.trimLeading = unicode.IsSpace(last)
+}
+
+// trim drops the whitespace a continuation starts with when required, up to its first text
+func (t *textState) trim(text string) string {
+	if !t.trimLeading {
+		return text
+	}
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	if text != "" {
+		t.trimLeading = false
+	}
+	return text
+}
@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// attemptStartLocalKey holds when the provider attempt running on a request started
+const attemptStartLocalKey = "usage_attempt_start"
+
+// StartAttempt marks the start of a provider attempt on c; the latency of usage recorded for the
+// attempt is measured from here
+func StartAttempt(c *fiber.Ctx) {
+	c.Locals(attemptStartLocalKey, time.Now())
+}
+
+// Attempt is what the usage of a provider attempt needs from its request. It is captured so the
+// usage can be recorded after the handler returned, e.g. at the end of a stream.
+type Attempt struct {
+	// Metadata is the request's usage metadata encoded as JSON (see UsageMetadata)
+	Metadata string
+	// Start is when the attempt started; the zero time leaves latency unset
+	Start time.Time
+}
+
+// AttemptOf captures the usage metadata of c and the start of its current attempt
+func AttemptOf(c *fiber.Ctx) Attempt {
+	start, _ := c.Locals(attemptStartLocalKey).(time.Time)
+	return Attempt{Metadata: UsageMetadata(c), Start: start}
+}
+
+// LatencyMs returns the milliseconds since the attempt started, or 0 when its start is unknown
+func (a Attempt) LatencyMs() int {
+	if a.Start.IsZero() {
+		return 0
+	}
+	return int(time.Since(a.Start).Milliseconds())
+}
+
+// FailedAttempt returns the usage of a provider attempt that failed with err. It has no tokens
+// and is never billed, but carries the request's metadata, so failures count toward error rates
+// such as an experiment arm's. statusCode is the provider's status, 502 when there was none.
+func FailedAttempt(apiKey *models.APIKey, attempt Attempt, endpoint string, provider models.Alternative, statusCode int, err error, requestID string) models.RecordUsageParams {
+	if statusCode == 0 {
+		statusCode = http.StatusBadGateway
+	}
+	params := models.RecordUsageParams{
+		Endpoint:   endpoint,
+		Provider:   provider.Provider,
+		Model:      provider.Model,
+		StatusCode: statusCode,
+		LatencyMs:  attempt.LatencyMs(),
+		Metadata:   attempt.Metadata,
+		RequestID:  requestID,
+	}
+	if apiKey != nil {
+		params.APIKeyID = apiKey.ID
+		params.OrganizationID = apiKey.OrganizationID
+		params.UserID = apiKey.UserID
+	}
+	if err != nil {
+		params.ErrorMessage = err.Error()
+	}
+	return params
+}
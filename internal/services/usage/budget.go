@@ -2,7 +2,9 @@ package usage
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Egham-7/adaptive-proxy/internal/models"
@@ -177,3 +179,66 @@ func (s *Service) TrackUsage(ctx context.Context, usage *models.APIKeyUsage) err
 
 	return nil
 }
+
+// likeEscaper escapes LIKE wildcards in a literal, with '!' as the escape character
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// GetUsageByExperimentArm aggregates usage per arm of an experiment, using the experiment
+// assignment recorded in the usage metadata. Failed attempts are recorded with the same
+// metadata, so each arm's failures and latency are included.
+func (s *Service) GetUsageByExperimentArm(ctx context.Context, apiKeyID uint, experiment string, startTime, endTime time.Time) (map[string]*models.UsageStats, error) {
+	experimentJSON, err := json.Marshal(experiment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode experiment name: %w", err)
+	}
+
+	query := s.db.WithContext(ctx).
+		Model(&models.APIKeyUsage{}).
+		Where("api_key_id = ?", apiKeyID).
+		Where(`metadata LIKE ? ESCAPE '!'`, "%"+likeEscaper.Replace(`"experiment":`+string(experimentJSON))+"%")
+
+	if !startTime.IsZero() {
+		query = query.Where("created_at >= ?", startTime)
+	}
+	if !endTime.IsZero() {
+		query = query.Where("created_at <= ?", endTime)
+	}
+
+	var records []models.APIKeyUsage
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get usage by experiment arm: %w", err)
+	}
+
+	statsMap := make(map[string]*models.UsageStats)
+	latencySums := make(map[string]float64)
+	for _, record := range records {
+		var metadata map[string]string
+		if err := json.Unmarshal([]byte(record.Metadata), &metadata); err != nil || metadata["experiment"] != experiment {
+			continue
+		}
+
+		arm := metadata["experiment_arm"]
+		stats := statsMap[arm]
+		if stats == nil {
+			stats = &models.UsageStats{}
+			statsMap[arm] = stats
+		}
+
+		stats.TotalRequests++
+		stats.TotalCost += record.Cost
+		stats.TotalTokens += int64(record.TokensTotal)
+		if record.StatusCode >= 200 && record.StatusCode < 300 {
+			stats.SuccessRequests++
+		}
+		if record.StatusCode >= 400 {
+			stats.FailedRequests++
+		}
+		latencySums[arm] += float64(record.LatencyMs)
+	}
+
+	for arm, stats := range statsMap {
+		stats.AvgLatencyMs = latencySums[arm] / float64(stats.TotalRequests)
+	}
+
+	return statsMap, nil
+}
@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestService returns a usage service on an in-memory database
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	s := NewService(db, nil)
+	if err := s.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return s
+}
+
+func TestGetUsageByExperimentArm(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	arm := func(experiment, arm string) Attempt {
+		return Attempt{Metadata: `{"experiment":"` + experiment + `","experiment_arm":"` + arm + `"}`}
+	}
+	records := []models.RecordUsageParams{
+		{APIKeyID: 1, StatusCode: 200, LatencyMs: 100, TokensInput: 10, Metadata: arm("exp_1", "control").Metadata},
+		{APIKeyID: 1, StatusCode: 200, LatencyMs: 300, TokensInput: 10, Metadata: arm("exp_1", "control").Metadata},
+		{APIKeyID: 1, StatusCode: 200, LatencyMs: 50, Metadata: arm("exp_1", "treatment").Metadata},
+		FailedAttempt(&models.APIKey{ID: 1}, arm("exp_1", "treatment"), "/v1/messages", models.Alternative{Provider: "acme"}, 0, errors.New("reset"), "req"),
+		// The underscore must not match any character
+		{APIKeyID: 1, StatusCode: 200, LatencyMs: 999, Metadata: arm("expX1", "control").Metadata},
+		{APIKeyID: 2, StatusCode: 200, LatencyMs: 999, Metadata: arm("exp_1", "control").Metadata},
+	}
+	for _, params := range records {
+		if _, err := s.RecordUsage(ctx, params); err != nil {
+			t.Fatalf("RecordUsage() error = %v", err)
+		}
+	}
+
+	got, err := s.GetUsageByExperimentArm(ctx, 1, "exp_1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetUsageByExperimentArm() error = %v", err)
+	}
+	want := map[string]models.UsageStats{
+		"control":   {TotalRequests: 2, TotalTokens: 20, SuccessRequests: 2, AvgLatencyMs: 200},
+		"treatment": {TotalRequests: 2, SuccessRequests: 1, FailedRequests: 1, AvgLatencyMs: 25},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetUsageByExperimentArm() returned arms %v, want %v", got, want)
+	}
+	for name, stats := range want {
+		if got[name] == nil || *got[name] != stats {
+			t.Errorf("arm %q = %+v, want %+v", name, got[name], stats)
+		}
+	}
+}
+
+func TestFailedAttempt(t *testing.T) {
+	attempt := Attempt{Metadata: `{"experiment":"e"}`, Start: time.Now().Add(-time.Second)}
+	apiKey := &models.APIKey{ID: 7, OrganizationID: "org", UserID: "user"}
+	provider := models.Alternative{Provider: "acme", Model: "m"}
+
+	got := FailedAttempt(apiKey, attempt, "/v1/messages", provider, 0, errors.New("connection reset"), "req")
+	if got.StatusCode != http.StatusBadGateway || got.ErrorMessage != "connection reset" || got.Cost != 0 {
+		t.Errorf("FailedAttempt() = %+v, want an unbilled 502 with the error", got)
+	}
+	if got.APIKeyID != 7 || got.OrganizationID != "org" || got.Provider != "acme" || got.Metadata != attempt.Metadata {
+		t.Errorf("FailedAttempt() = %+v, want the key, provider and metadata of the attempt", got)
+	}
+	if got.LatencyMs < 1000 {
+		t.Errorf("FailedAttempt() latency = %dms, want the time since the attempt started", got.LatencyMs)
+	}
+
+	if got := FailedAttempt(nil, Attempt{}, "", provider, http.StatusTooManyRequests, nil, "req"); got.StatusCode != http.StatusTooManyRequests || got.LatencyMs != 0 {
+		t.Errorf("FailedAttempt() = %+v, want status 429 without latency", got)
+	}
+}
+
+func TestRecordMeasuresAttemptLatency(t *testing.T) {
+	s := newTestService(t)
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	StartAttempt(c)
+	time.Sleep(20 * time.Millisecond)
+	s.Record(c, models.RecordUsageParams{APIKeyID: 1, StatusCode: 200, RequestID: "measured"})
+	s.Record(c, models.RecordUsageParams{APIKeyID: 1, StatusCode: 200, LatencyMs: 5, RequestID: "given"})
+
+	var records []models.APIKeyUsage
+	if err := s.db.Order("id").Find(&records).Error; err != nil {
+		t.Fatalf("find usage: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("recorded %d usage records, want 2", len(records))
+	}
+	if records[0].LatencyMs < 20 {
+		t.Errorf("measured latency = %dms, want at least 20ms", records[0].LatencyMs)
+	}
+	if records[1].LatencyMs != 5 {
+		t.Errorf("given latency = %dms, want 5ms kept", records[1].LatencyMs)
+	}
+}
@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlog "github.com/gofiber/fiber/v2/log"
+)
+
+// heldUsageLocalKey marks a detached attempt in fiber locals and holds its usage records
+const heldUsageLocalKey = "held_usage"
+
+// heldUsage is the usage recorded by a detached attempt, waiting for the attempt to be served
+type heldUsage struct {
+	mu      sync.Mutex
+	records []func()
+}
+
+// Detach makes c a detached attempt, one of several hedged attempts at the same request. It gets
+// its own copy of the usage metadata, and usage recorded on it through Record is held until
+// Commit, so only the attempt served to the client is billed.
+func Detach(c *fiber.Ctx) {
+	if metadata, ok := c.Locals(usageMetadataLocalKey).(map[string]string); ok {
+		c.Locals(usageMetadataLocalKey, maps.Clone(metadata))
+	}
+	c.Locals(heldUsageLocalKey, &heldUsage{})
+}
+
+// Commit records the usage held for the detached attempt c, which was served to the client, and
+// stops holding any more. Usage held for attempts that are never committed is dropped.
+func Commit(c *fiber.Ctx) {
+	held, ok := c.Locals(heldUsageLocalKey).(*heldUsage)
+	if !ok {
+		return
+	}
+	c.Context().RemoveUserValue(heldUsageLocalKey)
+
+	held.mu.Lock()
+	records := held.records
+	held.records = nil
+	held.mu.Unlock()
+	for _, record := range records {
+		record()
+	}
+}
+
+// Record records usage of the request served on c, or holds it when c is a detached attempt.
+// Latency left unset is measured from the start of the attempt (see StartAttempt).
+func (s *Service) Record(c *fiber.Ctx, params models.RecordUsageParams) {
+	if params.LatencyMs == 0 {
+		params.LatencyMs = AttemptOf(c).LatencyMs()
+	}
+	ctx := context.WithoutCancel(c.UserContext())
+	record := func() {
+		if _, err := s.RecordUsage(ctx, params); err != nil {
+			fiberlog.Errorf("[%s] Failed to record usage: %v", params.RequestID, err)
+		}
+	}
+
+	if held, ok := c.Locals(heldUsageLocalKey).(*heldUsage); ok {
+		held.mu.Lock()
+		held.records = append(held.records, record)
+		held.mu.Unlock()
+		return
+	}
+	record()
+}
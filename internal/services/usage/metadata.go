@@ -0,0 +1,33 @@
+package usage
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// usageMetadataLocalKey stores request-scoped usage metadata in fiber locals
+const usageMetadataLocalKey = "usage_metadata"
+
+// SetUsageMetadata attaches a key/value pair to the usage records of the current request
+func SetUsageMetadata(c *fiber.Ctx, key, value string) {
+	metadata, _ := c.Locals(usageMetadataLocalKey).(map[string]string)
+	if metadata == nil {
+		metadata = make(map[string]string)
+		c.Locals(usageMetadataLocalKey, metadata)
+	}
+	metadata[key] = value
+}
+
+// UsageMetadata returns the request's usage metadata encoded as JSON, or "" when none is set
+func UsageMetadata(c *fiber.Ctx) string {
+	metadata, _ := c.Locals(usageMetadataLocalKey).(map[string]string)
+	if len(metadata) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
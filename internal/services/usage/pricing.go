@@ -1,5 +1,7 @@
 package usage
 
+import "github.com/Egham-7/adaptive-proxy/internal/models"
+
 type ModelPricing struct {
 	InputTokenCost  float64
 	OutputTokenCost float64
@@ -217,6 +219,36 @@ const (
 	OutputTokenOverhead = 0.20
 )
 
+// RegisterCatalogPricing makes prices from the model catalog take precedence over the built-in
+// price list. Catalog models without a price keep the built-in one. It must be called before
+// requests are served since GlobalPricing is not synchronized.
+func RegisterCatalogPricing(catalog []models.ModelCapability) {
+	for _, model := range catalog {
+		if model.CostPer1MInputTokens == 0 && model.CostPer1MOutputTokens == 0 {
+			continue
+		}
+		if GlobalPricing[model.Provider] == nil {
+			GlobalPricing[model.Provider] = ProviderPricing{}
+		}
+		GlobalPricing[model.Provider][model.ModelName] = ModelPricing{
+			InputTokenCost:  model.CostPer1MInputTokens,
+			OutputTokenCost: model.CostPer1MOutputTokens,
+		}
+	}
+}
+
+// WithPricing fills in missing costs of a model from the price list
+func WithPricing(model models.ModelCapability) models.ModelCapability {
+	if model.CostPer1MInputTokens != 0 || model.CostPer1MOutputTokens != 0 {
+		return model
+	}
+	if pricing, ok := GlobalPricing[model.Provider][model.ModelName]; ok {
+		model.CostPer1MInputTokens = pricing.InputTokenCost
+		model.CostPer1MOutputTokens = pricing.OutputTokenCost
+	}
+	return model
+}
+
 func CalculateCost(provider, model string, inputTokens, outputTokens int) float64 {
 	providerPricing, exists := GlobalPricing[provider]
 	if !exists {
@@ -111,6 +111,42 @@ func (s *Service) GetUsageByAPIKey(ctx context.Context, apiKeyID uint, limit, of
 	return usage, nil
 }
 
+// UsageOwner restricts a usage lookup to the requests a caller owns; the zero value matches
+// every request
+type UsageOwner struct {
+	// APIKeyID matches the requests made with that API key
+	APIKeyID *uint
+	// UserID and OrganizationIDs match the requests made with API keys of that user or of
+	// those organizations
+	UserID          string
+	OrganizationIDs []string
+}
+
+// GetUsageByRequestID returns the usage row of a request owned by owner. The attempt that served
+// the request is preferred over failed attempts; among those the most recent row is returned.
+func (s *Service) GetUsageByRequestID(ctx context.Context, requestID string, owner UsageOwner) (*models.APIKeyUsage, error) {
+	query := s.db.WithContext(ctx).Where("api_key_usages.request_id = ?", requestID)
+	switch {
+	case owner.APIKeyID != nil:
+		query = query.Where("api_key_usages.api_key_id = ?", *owner.APIKeyID)
+	case owner.UserID != "":
+		query = query.Joins("JOIN api_keys ON api_keys.id = api_key_usages.api_key_id")
+		if len(owner.OrganizationIDs) > 0 {
+			query = query.Where("api_keys.user_id = ? OR api_keys.organization_id IN ?", owner.UserID, owner.OrganizationIDs)
+		} else {
+			query = query.Where("api_keys.user_id = ?", owner.UserID)
+		}
+	}
+
+	var usage models.APIKeyUsage
+	if err := query.
+		Order("api_key_usages.status_code >= 400, api_key_usages.created_at DESC").
+		First(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to get usage for request %s: %w", requestID, err)
+	}
+	return &usage, nil
+}
+
 func (s *Service) GetUsageStats(ctx context.Context, apiKeyID uint, startDate, endDate time.Time) (*models.UsageStats, error) {
 	var stats models.UsageStats
 
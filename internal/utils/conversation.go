@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+const (
+	defaultPromptTurns    = 4
+	defaultPromptMaxChars = 8000
+	// summary sections are truncated so no single turn dominates the summary
+	summarySystemChars = 500
+	summaryTurnChars   = 1000
+)
+
+// turn is a conversation message reduced to what prompt extraction needs
+type turn struct {
+	role string
+	text string
+	// tools holds the names of tools called in the turn
+	tools []string
+	// toolResult marks turns that only carry tool output, not text written by a person
+	toolResult bool
+}
+
+// conversation is a provider-neutral view of a request's messages
+type conversation struct {
+	system string
+	turns  []turn
+}
+
+// ValidatePromptExtraction checks the prompt extraction configuration
+func ValidatePromptExtraction(cfg models.PromptExtractionConfig) error {
+	switch cfg.Strategy {
+	case "", models.PromptStrategyLastMessage, models.PromptStrategyLastUser,
+		models.PromptStrategyRecentTurns, models.PromptStrategySummary:
+	default:
+		return fmt.Errorf("unknown prompt extraction strategy %q", cfg.Strategy)
+	}
+	if cfg.Turns < 0 || cfg.MaxChars < 0 {
+		return fmt.Errorf("prompt extraction turns and max_chars must not be negative")
+	}
+	return nil
+}
+
+// ExtractOpenAIPrompt reduces an OpenAI conversation to the routing prompt using the configured strategy
+func ExtractOpenAIPrompt(messages []openai.ChatCompletionMessageParamUnion, cfg models.PromptExtractionConfig) (string, error) {
+	if cfg.Strategy == "" || cfg.Strategy == models.PromptStrategyLastMessage {
+		return ExtractLastMessage(messages)
+	}
+	return extractPrompt(openAIConversation(messages), cfg)
+}
+
+// ExtractAnthropicPrompt reduces an Anthropic conversation to the routing prompt using the configured strategy
+func ExtractAnthropicPrompt(system []anthropic.TextBlockParam, messages []anthropic.MessageParam, cfg models.PromptExtractionConfig) (string, error) {
+	if cfg.Strategy == "" || cfg.Strategy == models.PromptStrategyLastMessage {
+		return ExtractPromptFromAnthropicMessages(messages)
+	}
+	return extractPrompt(anthropicConversation(system, messages), cfg)
+}
+
+// ExtractGeminiPrompt reduces a Gemini conversation to the routing prompt using the configured strategy
+func ExtractGeminiPrompt(systemInstruction *genai.Content, contents []*genai.Content, cfg models.PromptExtractionConfig) (string, error) {
+	if cfg.Strategy == "" || cfg.Strategy == models.PromptStrategyLastMessage {
+		return ExtractPromptFromGeminiContents(contents)
+	}
+	return extractPrompt(geminiConversation(systemInstruction, contents), cfg)
+}
+
+// extractPrompt applies a full-conversation strategy
+func extractPrompt(conv conversation, cfg models.PromptExtractionConfig) (string, error) {
+	if len(conv.turns) == 0 {
+		return "", fmt.Errorf("no messages provided")
+	}
+	maxChars := cfg.MaxChars
+	if maxChars == 0 {
+		maxChars = defaultPromptMaxChars
+	}
+
+	var prompt string
+	switch cfg.Strategy {
+	case models.PromptStrategyLastUser:
+		if t, ok := conv.lastUserTurn(); ok {
+			prompt = t.text
+		}
+	case models.PromptStrategyRecentTurns:
+		turns := cfg.Turns
+		if turns == 0 {
+			turns = defaultPromptTurns
+		}
+		prompt = truncateHead(conv.recentTurns(turns), maxChars)
+	case models.PromptStrategySummary:
+		prompt = truncateHead(conv.summary(), maxChars)
+	default:
+		return "", fmt.Errorf("unknown prompt extraction strategy %q", cfg.Strategy)
+	}
+
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return "", fmt.Errorf("no extractable text found in conversation for routing")
+	}
+	return prompt, nil
+}
+
+// lastUserTurn returns the last user turn with text, skipping tool results
+func (c conversation) lastUserTurn() (turn, bool) {
+	for i := len(c.turns) - 1; i >= 0; i-- {
+		t := c.turns[i]
+		if t.role == "user" && !t.toolResult && t.text != "" {
+			return t, true
+		}
+	}
+	return turn{}, false
+}
+
+// recentTurns formats the system prompt followed by the last n turns
+func (c conversation) recentTurns(n int) string {
+	var lines []string
+	if c.system != "" {
+		lines = append(lines, formatTurn("system", c.system))
+	}
+	start := max(len(c.turns)-n, 0)
+	for _, t := range c.turns[start:] {
+		if line := t.format(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// summary condenses the whole conversation into the system prompt, the original task, the
+// shape of the session (turn counts and tools used) and the latest request
+func (c conversation) summary() string {
+	var lines []string
+	if c.system != "" {
+		lines = append(lines, formatTurn("system", truncateTail(c.system, summarySystemChars)))
+	}
+
+	first := -1
+	for i, t := range c.turns {
+		if t.role == "user" && !t.toolResult && t.text != "" {
+			first = i
+			break
+		}
+	}
+	if first >= 0 {
+		lines = append(lines, formatTurn("task", truncateTail(c.turns[first].text, summaryTurnChars)))
+	}
+
+	counts := make(map[string]int)
+	var tools []string
+	for _, t := range c.turns {
+		role := t.role
+		if t.toolResult {
+			role = "tool"
+		}
+		counts[role]++
+		for _, name := range t.tools {
+			if !slices.Contains(tools, name) {
+				tools = append(tools, name)
+			}
+		}
+	}
+	shape := fmt.Sprintf("%d turns (%d user, %d assistant, %d tool)",
+		len(c.turns), counts["user"], counts["assistant"], counts["tool"])
+	if len(tools) > 0 {
+		shape += ", tools used: " + strings.Join(tools, ", ")
+	}
+	lines = append(lines, formatTurn("conversation", shape))
+
+	last := -1
+	for i := len(c.turns) - 1; i > first; i-- {
+		if t := c.turns[i]; t.role == "user" && !t.toolResult && t.text != "" {
+			last = i
+			break
+		}
+	}
+	if last >= 0 {
+		lines = append(lines, formatTurn("latest", truncateTail(c.turns[last].text, summaryTurnChars)))
+	}
+	if final := len(c.turns) - 1; final != first && final != last {
+		if line := c.turns[final].format(); line != "" {
+			lines = append(lines, truncateTail(line, summaryTurnChars))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// format renders the turn as "[role]: text", listing tool calls when the turn has no text
+func (t turn) format() string {
+	role := t.role
+	if t.toolResult {
+		role = "tool"
+	}
+	text := t.text
+	if text == "" && len(t.tools) > 0 {
+		text = "tool_call:" + strings.Join(t.tools, ",")
+	}
+	if text == "" {
+		return ""
+	}
+	return formatTurn(role, text)
+}
+
+func formatTurn(role, text string) string {
+	return fmt.Sprintf("[%s]: %s", role, text)
+}
+
+// truncateTail keeps the first maxChars bytes of s
+func truncateTail(s string, maxChars int) string {
+	if len(s) <= maxChars {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxChars], "") + "..."
+}
+
+// truncateHead keeps the last maxChars bytes of s, since the end of a conversation matters most for routing
+func truncateHead(s string, maxChars int) string {
+	if len(s) <= maxChars {
+		return s
+	}
+	return "..." + strings.ToValidUTF8(s[len(s)-maxChars:], "")
+}
+
+// openAIConversation converts OpenAI chat messages into a conversation
+func openAIConversation(messages []openai.ChatCompletionMessageParamUnion) conversation {
+	var conv conversation
+	var system []string
+	for _, msg := range messages {
+		switch {
+		case msg.OfSystem != nil:
+			system = append(system, extractContentFromSystem(msg.OfSystem))
+		case msg.OfDeveloper != nil:
+			system = append(system, extractContentFromDeveloper(msg.OfDeveloper))
+		case msg.OfUser != nil:
+			conv.turns = append(conv.turns, turn{role: "user", text: extractContentFromUser(msg.OfUser)})
+		case msg.OfAssistant != nil:
+			t := turn{role: "assistant"}
+			if msg.OfAssistant.Content.OfString.Valid() {
+				t.text = msg.OfAssistant.Content.OfString.Value
+			} else if msg.OfAssistant.Content.OfArrayOfContentParts != nil {
+				t.text = extractTextFromAssistantContentParts(msg.OfAssistant.Content.OfArrayOfContentParts)
+			}
+			for _, toolCall := range msg.OfAssistant.ToolCalls {
+				if toolCall.OfFunction != nil {
+					t.tools = append(t.tools, toolCall.OfFunction.Function.Name)
+				}
+			}
+			conv.turns = append(conv.turns, t)
+		case msg.OfTool != nil:
+			conv.turns = append(conv.turns, turn{role: "tool", text: extractContentFromTool(msg.OfTool), toolResult: true})
+		}
+	}
+	conv.system = strings.Join(system, "\n")
+	return conv
+}
+
+// anthropicConversation converts Anthropic messages into a conversation. User messages that only
+// carry tool results are marked as tool turns.
+func anthropicConversation(system []anthropic.TextBlockParam, messages []anthropic.MessageParam) conversation {
+	var conv conversation
+	var systemTexts []string
+	for _, block := range system {
+		systemTexts = append(systemTexts, block.Text)
+	}
+	conv.system = strings.Join(systemTexts, "\n")
+
+	for _, msg := range messages {
+		t := turn{role: "user"}
+		if msg.Role == anthropic.MessageParamRoleAssistant {
+			t.role = "assistant"
+		}
+		var texts, results []string
+		for _, block := range msg.Content {
+			switch {
+			case block.OfText != nil:
+				texts = append(texts, block.OfText.Text)
+			case block.OfToolUse != nil:
+				t.tools = append(t.tools, block.OfToolUse.Name)
+			case block.OfToolResult != nil:
+				for _, content := range block.OfToolResult.Content {
+					if content.OfText != nil {
+						results = append(results, content.OfText.Text)
+					}
+				}
+			}
+		}
+		if len(texts) == 0 && len(results) > 0 {
+			t.toolResult = true
+			texts = results
+		}
+		t.text = strings.Join(texts, " ")
+		conv.turns = append(conv.turns, t)
+	}
+	return conv
+}
+
+// geminiConversation converts Gemini contents into a conversation. Contents that only carry
+// function responses are marked as tool turns.
+func geminiConversation(systemInstruction *genai.Content, contents []*genai.Content) conversation {
+	var conv conversation
+	if systemInstruction != nil {
+		var texts []string
+		for _, part := range systemInstruction.Parts {
+			if part != nil && part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		}
+		conv.system = strings.Join(texts, "\n")
+	}
+
+	for _, content := range contents {
+		if content == nil {
+			continue
+		}
+		t := turn{role: "user"}
+		if content.Role == "model" {
+			t.role = "assistant"
+		}
+		var texts, results []string
+		for _, part := range content.Parts {
+			if part == nil {
+				continue
+			}
+			switch {
+			case part.Text != "":
+				texts = append(texts, part.Text)
+			case part.FunctionCall != nil:
+				t.tools = append(t.tools, part.FunctionCall.Name)
+			case part.FunctionResponse != nil:
+				results = append(results, extractTextFromPart(part))
+			case part.CodeExecutionResult != nil:
+				results = append(results, extractTextFromPart(part))
+			}
+		}
+		if len(texts) == 0 && len(results) > 0 {
+			t.toolResult = true
+			texts = results
+		}
+		t.text = strings.Join(texts, " ")
+		conv.turns = append(conv.turns, t)
+	}
+	return conv
+}
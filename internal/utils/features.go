@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"slices"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	anthropicparam "github.com/anthropics/anthropic-sdk-go/packages/param"
+	"github.com/openai/openai-go/v2/packages/param"
+)
+
+// OpenAIRequestFeatures detects the features a chat completion request needs from the serving model
+func OpenAIRequestFeatures(req *models.ChatCompletionRequest) []string {
+	var features orderedSet
+	for _, msg := range req.Messages {
+		if msg.OfUser == nil {
+			continue
+		}
+		for _, part := range msg.OfUser.Content.OfArrayOfContentParts {
+			switch {
+			case part.OfImageURL != nil:
+				features.add(models.FeatureVision)
+			case part.OfInputAudio != nil:
+				features.add(models.FeatureAudio)
+			}
+		}
+	}
+	if slices.Contains(req.Modalities, "audio") || !param.IsOmitted(req.Audio) {
+		features.add(models.FeatureAudio)
+	}
+	if req.ResponseFormat.OfJSONSchema != nil {
+		features.add(models.FeatureStructuredOutputs)
+	}
+	if req.ReasoningEffort != "" {
+		features.add(models.FeatureReasoning)
+	}
+	if !param.IsOmitted(req.WebSearchOptions) {
+		features.add(models.FeatureWebSearch)
+	}
+	return features
+}
+
+// AnthropicRequestFeatures detects the features a Messages request needs from the serving model
+func AnthropicRequestFeatures(req *models.AnthropicMessageRequest) []string {
+	var features orderedSet
+	for _, block := range req.System {
+		if !anthropicparam.IsOmitted(block.CacheControl) {
+			features.add(models.FeaturePromptCaching)
+		}
+	}
+	for _, msg := range req.Messages {
+		for _, block := range msg.Content {
+			if hasAnthropicCacheControl(block.GetCacheControl()) {
+				features.add(models.FeaturePromptCaching)
+			}
+			switch {
+			case block.OfImage != nil:
+				features.add(models.FeatureVision)
+			case block.OfToolResult != nil:
+				for _, content := range block.OfToolResult.Content {
+					if content.OfImage != nil {
+						features.add(models.FeatureVision)
+					}
+				}
+			}
+		}
+	}
+	for _, tool := range req.Tools {
+		if hasAnthropicCacheControl(tool.GetCacheControl()) {
+			features.add(models.FeaturePromptCaching)
+		}
+		if tool.OfWebSearchTool20250305 != nil {
+			features.add(models.FeatureWebSearch)
+		}
+	}
+	if req.Thinking.OfEnabled != nil {
+		features.add(models.FeatureReasoning)
+	}
+	return features
+}
+
+// hasAnthropicCacheControl reports whether a cache_control breakpoint is set
+func hasAnthropicCacheControl(cacheControl *anthropic.CacheControlEphemeralParam) bool {
+	return cacheControl != nil && !anthropicparam.IsOmitted(*cacheControl)
+}
+
+// GeminiRequestFeatures detects the features a GenerateContent request needs from the serving model
+func GeminiRequestFeatures(req *models.GeminiGenerateRequest) []string {
+	var features orderedSet
+	for _, content := range req.Contents {
+		if content == nil {
+			continue
+		}
+		for _, part := range content.Parts {
+			if part == nil {
+				continue
+			}
+			mimeType := ""
+			switch {
+			case part.InlineData != nil:
+				mimeType = part.InlineData.MIMEType
+			case part.FileData != nil:
+				mimeType = part.FileData.MIMEType
+			}
+			switch mimeModality(mimeType) {
+			case models.ModalityImage, models.ModalityVideo:
+				features.add(models.FeatureVision)
+			case models.ModalityAudio:
+				features.add(models.FeatureAudio)
+			}
+		}
+	}
+	for _, tool := range req.Tools {
+		if tool != nil && (tool.GoogleSearch != nil || tool.GoogleSearchRetrieval != nil || tool.EnterpriseWebSearch != nil) {
+			features.add(models.FeatureWebSearch)
+		}
+	}
+	if cfg := req.GenerationConfig; cfg != nil {
+		if cfg.ResponseSchema != nil || cfg.ResponseJsonSchema != nil {
+			features.add(models.FeatureStructuredOutputs)
+		}
+		if cfg.ThinkingConfig != nil && (cfg.ThinkingConfig.IncludeThoughts ||
+			(cfg.ThinkingConfig.ThinkingBudget != nil && *cfg.ThinkingConfig.ThinkingBudget != 0)) {
+			features.add(models.FeatureReasoning)
+		}
+		if slices.Contains(cfg.ResponseModalities, "AUDIO") {
+			features.add(models.FeatureAudio)
+		}
+		if cfg.CachedContent != "" {
+			features.add(models.FeaturePromptCaching)
+		}
+	}
+	return features
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -73,3 +74,35 @@ func ParseProviderModelWithDefault(modelSpec, defaultProvider string) (provider,
 	// Use strict parsing for provider:model format
 	return ParseProviderModel(modelSpec)
 }
+
+// JoinModelList folds an OpenRouter-style models array into the model field, after the model
+// itself, so explicit fallback lists only need handling in their comma-separated form.
+// Duplicate entries are dropped.
+//   - ("openai:gpt-4o", ["anthropic:claude-sonnet-4-5"]) -> "openai:gpt-4o,anthropic:claude-sonnet-4-5"
+//   - ("", ["openai:gpt-4o"]) -> "openai:gpt-4o"
+func JoinModelList(model string, list []string) string {
+	if len(list) == 0 {
+		return model
+	}
+	entries := make([]string, 0, len(list)+1)
+	for _, entry := range append([]string{model}, list...) {
+		if entry = strings.TrimSpace(entry); entry != "" && !slices.Contains(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+// SplitModelList splits a comma-separated list of "provider:model" entries into its ordered,
+// de-duplicated entries. ok is false when spec names a single model.
+func SplitModelList(spec string) (entries []string, ok bool) {
+	if !strings.Contains(spec, ",") {
+		return nil, false
+	}
+	for entry := range strings.SplitSeq(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" && !slices.Contains(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, true
+}
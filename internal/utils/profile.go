@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+// orderedSet collects unique values in insertion order
+type orderedSet []string
+
+func (s *orderedSet) add(value string) {
+	if !slices.Contains(*s, value) {
+		*s = append(*s, value)
+	}
+}
+
+// OpenAIRequestProfile describes an OpenAI chat request for routing
+func OpenAIRequestProfile(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolUnionParam, maxOutputTokens int) *models.RequestProfile {
+	var modalities orderedSet
+	turns := 0
+	for _, msg := range messages {
+		if msg.OfSystem != nil || msg.OfDeveloper != nil {
+			modalities.add(models.ModalityText)
+			continue
+		}
+		turns++
+		if msg.OfUser == nil {
+			modalities.add(models.ModalityText)
+			continue
+		}
+		if msg.OfUser.Content.OfString.Valid() {
+			modalities.add(models.ModalityText)
+		}
+		for _, part := range msg.OfUser.Content.OfArrayOfContentParts {
+			switch {
+			case part.OfText != nil:
+				modalities.add(models.ModalityText)
+			case part.OfImageURL != nil:
+				modalities.add(models.ModalityImage)
+			case part.OfInputAudio != nil:
+				modalities.add(models.ModalityAudio)
+			case part.OfFile != nil:
+				modalities.add(models.ModalityDocument)
+			}
+		}
+	}
+
+	return &models.RequestProfile{
+		EstimatedInputTokens: EstimateOpenAIRequestTokens(messages, tools),
+		MaxOutputTokens:      maxOutputTokens,
+		TurnCount:            turns,
+		Modalities:           modalities,
+		ToolCount:            len(tools),
+	}
+}
+
+// AnthropicRequestProfile describes an Anthropic Messages request for routing
+func AnthropicRequestProfile(system []anthropic.TextBlockParam, messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam, maxOutputTokens int) *models.RequestProfile {
+	var modalities orderedSet
+	if len(system) > 0 {
+		modalities.add(models.ModalityText)
+	}
+	for _, msg := range messages {
+		for _, block := range msg.Content {
+			addAnthropicModality(&modalities, block)
+		}
+	}
+
+	return &models.RequestProfile{
+		EstimatedInputTokens: EstimateAnthropicRequestTokens(system, messages, tools),
+		MaxOutputTokens:      maxOutputTokens,
+		TurnCount:            len(messages),
+		Modalities:           modalities,
+		ToolCount:            len(tools),
+	}
+}
+
+// addAnthropicModality records the modality of a content block, including tool result contents
+func addAnthropicModality(modalities *orderedSet, block anthropic.ContentBlockParamUnion) {
+	switch {
+	case block.OfImage != nil:
+		modalities.add(models.ModalityImage)
+	case block.OfDocument != nil:
+		modalities.add(models.ModalityDocument)
+	case block.OfToolResult != nil:
+		for _, content := range block.OfToolResult.Content {
+			switch {
+			case content.OfImage != nil:
+				modalities.add(models.ModalityImage)
+			case content.OfDocument != nil:
+				modalities.add(models.ModalityDocument)
+			default:
+				modalities.add(models.ModalityText)
+			}
+		}
+	default:
+		modalities.add(models.ModalityText)
+	}
+}
+
+// GeminiRequestProfile describes a Gemini GenerateContent request for routing
+func GeminiRequestProfile(systemInstruction *genai.Content, contents []*genai.Content, tools []*genai.Tool, maxOutputTokens int) *models.RequestProfile {
+	var modalities orderedSet
+	if systemInstruction != nil {
+		modalities.add(models.ModalityText)
+	}
+	turns := 0
+	for _, content := range contents {
+		if content == nil {
+			continue
+		}
+		turns++
+		for _, part := range content.Parts {
+			if part == nil {
+				continue
+			}
+			switch {
+			case part.InlineData != nil:
+				modalities.add(mimeModality(part.InlineData.MIMEType))
+			case part.FileData != nil:
+				modalities.add(mimeModality(part.FileData.MIMEType))
+			default:
+				modalities.add(models.ModalityText)
+			}
+		}
+	}
+
+	toolCount := 0
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		// Built-in tools such as Google Search have no declarations but still count as one tool
+		toolCount += max(len(tool.FunctionDeclarations), 1)
+	}
+
+	return &models.RequestProfile{
+		EstimatedInputTokens: EstimateGeminiRequestTokens(systemInstruction, contents, tools),
+		MaxOutputTokens:      maxOutputTokens,
+		TurnCount:            turns,
+		Modalities:           modalities,
+		ToolCount:            toolCount,
+	}
+}
+
+// mimeModality maps a MIME type to a modality
+func mimeModality(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return models.ModalityImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return models.ModalityAudio
+	case strings.HasPrefix(mimeType, "video/"):
+		return models.ModalityVideo
+	case strings.HasPrefix(mimeType, "text/"):
+		return models.ModalityText
+	}
+	return models.ModalityDocument
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+)
+
+const (
+	// charsPerToken is the average number of characters per token used for estimation.
+	// It matches the commonly used ~4 characters per token approximation for English text.
+	charsPerToken = 4
+	// messageOverheadTokens approximates the role/formatting tokens added per message
+	messageOverheadTokens = 4
+	// mediaTokenEstimate approximates the tokens consumed by a single image, audio or file part.
+	// Media is not sized from its (often base64) payload, which would hugely overestimate it.
+	mediaTokenEstimate = 1000
+)
+
+// EstimateTokens returns a rough token count for the given text.
+// This is a cheap approximation used for routing decisions, not for billing.
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	chars := utf8.RuneCountInString(text)
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateJSONTokens estimates the tokens of a value by its JSON encoding.
+// Used for structured payloads like tool definitions and tool call arguments.
+func EstimateJSONTokens(value any) int {
+	if value == nil {
+		return 0
+	}
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" || string(data) == "[]" {
+		return 0
+	}
+	return EstimateTokens(string(data))
+}
+
+// EstimateOpenAIRequestTokens estimates the input tokens of an OpenAI chat request (messages and tools)
+func EstimateOpenAIRequestTokens(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolUnionParam) int {
+	total := 0
+	for _, msg := range messages {
+		total += messageOverheadTokens
+		switch {
+		case msg.OfUser != nil:
+			total += EstimateTokens(extractContentFromUser(msg.OfUser))
+			for _, part := range msg.OfUser.Content.OfArrayOfContentParts {
+				if part.OfImageURL != nil || part.OfInputAudio != nil || part.OfFile != nil {
+					total += mediaTokenEstimate
+				}
+			}
+		case msg.OfAssistant != nil:
+			total += EstimateTokens(extractContentFromAssistant(msg.OfAssistant))
+		case msg.OfSystem != nil:
+			total += EstimateTokens(extractContentFromSystem(msg.OfSystem))
+		case msg.OfDeveloper != nil:
+			total += EstimateTokens(extractContentFromDeveloper(msg.OfDeveloper))
+		case msg.OfTool != nil:
+			total += EstimateTokens(extractContentFromTool(msg.OfTool))
+		}
+	}
+	if len(tools) > 0 {
+		total += EstimateJSONTokens(tools)
+	}
+	return total
+}
+
+// EstimateAnthropicRequestTokens estimates the input tokens of an Anthropic Messages request (system, messages and tools)
+func EstimateAnthropicRequestTokens(system []anthropic.TextBlockParam, messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam) int {
+	total := 0
+	for _, block := range system {
+		total += EstimateTokens(block.Text)
+	}
+	for _, msg := range messages {
+		total += messageOverheadTokens
+		for _, block := range msg.Content {
+			total += estimateAnthropicBlockTokens(block)
+		}
+	}
+	if len(tools) > 0 {
+		total += EstimateJSONTokens(tools)
+	}
+	return total
+}
+
+// estimateAnthropicBlockTokens estimates the tokens of a single Anthropic content block
+func estimateAnthropicBlockTokens(block anthropic.ContentBlockParamUnion) int {
+	switch {
+	case block.OfText != nil:
+		return EstimateTokens(block.OfText.Text)
+	case block.OfImage != nil, block.OfDocument != nil:
+		return mediaTokenEstimate
+	case block.OfThinking != nil:
+		return EstimateTokens(block.OfThinking.Thinking)
+	case block.OfToolUse != nil:
+		return EstimateTokens(block.OfToolUse.Name) + EstimateJSONTokens(block.OfToolUse.Input)
+	case block.OfToolResult != nil:
+		total := 0
+		for _, content := range block.OfToolResult.Content {
+			switch {
+			case content.OfText != nil:
+				total += EstimateTokens(content.OfText.Text)
+			case content.OfImage != nil, content.OfDocument != nil:
+				total += mediaTokenEstimate
+			}
+		}
+		return total
+	case block.OfSearchResult != nil:
+		return EstimateJSONTokens(block.OfSearchResult)
+	case block.OfServerToolUse != nil:
+		return EstimateJSONTokens(block.OfServerToolUse.Input)
+	case block.OfWebSearchToolResult != nil:
+		return EstimateJSONTokens(block.OfWebSearchToolResult)
+	}
+	return 0
+}
+
+// EstimateGeminiRequestTokens estimates the input tokens of a Gemini GenerateContent request (system instruction, contents and tools)
+func EstimateGeminiRequestTokens(systemInstruction *genai.Content, contents []*genai.Content, tools []*genai.Tool) int {
+	total := estimateGeminiContentTokens(systemInstruction)
+	for _, content := range contents {
+		total += messageOverheadTokens + estimateGeminiContentTokens(content)
+	}
+	if len(tools) > 0 {
+		total += EstimateJSONTokens(tools)
+	}
+	return total
+}
+
+// estimateGeminiContentTokens estimates the tokens of all parts in a Gemini content
+func estimateGeminiContentTokens(content *genai.Content) int {
+	if content == nil {
+		return 0
+	}
+	total := 0
+	for _, part := range content.Parts {
+		if part == nil {
+			continue
+		}
+		total += EstimateTokens(part.Text)
+		if part.FunctionCall != nil {
+			total += EstimateTokens(part.FunctionCall.Name) + EstimateJSONTokens(part.FunctionCall.Args)
+		}
+		if part.FunctionResponse != nil {
+			total += EstimateTokens(part.FunctionResponse.Name) + EstimateJSONTokens(part.FunctionResponse.Response)
+		}
+		if part.ExecutableCode != nil {
+			total += EstimateTokens(part.ExecutableCode.Code)
+		}
+		if part.CodeExecutionResult != nil {
+			total += EstimateTokens(part.CodeExecutionResult.Output)
+		}
+		if part.InlineData != nil || part.FileData != nil {
+			total += mediaTokenEstimate
+		}
+	}
+	return total
+}
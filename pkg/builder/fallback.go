@@ -43,3 +43,51 @@ func (b *Builder) WithModelRouter(cfg models.ModelRouterConfig) *Builder {
 	b.cfg.ModelRouter = &cfg
 	return b
 }
+
+// WithRoutingRules appends routing rules, evaluated in order before the model router
+func (b *Builder) WithRoutingRules(rules ...models.RoutingRule) *Builder {
+	b.cfg.RoutingRules = append(b.cfg.RoutingRules, rules...)
+	return b
+}
+
+// WithModelAlias registers a virtual model name resolving to an ordered provider:model chain
+func (b *Builder) WithModelAlias(name string, alias models.ModelAlias) *Builder {
+	if b.cfg.ModelAliases == nil {
+		b.cfg.ModelAliases = make(map[string]models.ModelAlias)
+	}
+	b.cfg.ModelAliases[name] = alias
+	return b
+}
+
+// WithExperiments appends weighted A/B experiments between models
+func (b *Builder) WithExperiments(experiments ...models.Experiment) *Builder {
+	b.cfg.Experiments = append(b.cfg.Experiments, experiments...)
+	return b
+}
+
+// WithShadow mirrors a sample of an endpoint's traffic to a shadow model
+func (b *Builder) WithShadow(endpoint string, cfg models.ShadowConfig) *Builder {
+	switch endpoint {
+	case "chat_completions":
+		b.cfg.Endpoints.ChatCompletions.Shadow = &cfg
+	case "messages":
+		b.cfg.Endpoints.Messages.Shadow = &cfg
+	case "generate":
+		b.cfg.Endpoints.Generate.Shadow = &cfg
+	}
+	return b
+}
+
+// WithFeedback configures how /v1/feedback submissions invalidate the semantic cache and are forwarded to adaptive_router
+func (b *Builder) WithFeedback(cfg models.FeedbackConfig) *Builder {
+	b.cfg.Feedback = &cfg
+	return b
+}
+
+// WithResponseCache caches full responses of deterministic requests and replays them without
+// calling a provider
+func (b *Builder) WithResponseCache(cfg models.CacheConfig) *Builder {
+	cfg.Enabled = true
+	b.cfg.ResponseCache = &cfg
+	return b
+}
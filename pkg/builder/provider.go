@@ -11,6 +11,8 @@ type ProviderBuilder struct {
 	rateLimitRpm   *int
 	timeoutMs      int
 	headers        map[string]string
+	models         []models.ModelCapability
+	protocol       models.ProviderProtocol
 }
 
 func NewProviderBuilder(apiKey string) *ProviderBuilder {
@@ -55,6 +57,20 @@ func (pb *ProviderBuilder) WithHeader(key, value string) *ProviderBuilder {
 	return pb
 }
 
+// WithModels adds models to the provider's catalog. The provider field may be left empty; it is
+// filled in from the name the provider is added under.
+func (pb *ProviderBuilder) WithModels(catalog ...models.ModelCapability) *ProviderBuilder {
+	pb.models = append(pb.models, catalog...)
+	return pb
+}
+
+// WithProtocol sets the API the provider speaks, for providers added to an endpoint of another
+// API. Requests are converted to and from the provider's format.
+func (pb *ProviderBuilder) WithProtocol(protocol models.ProviderProtocol) *ProviderBuilder {
+	pb.protocol = protocol
+	return pb
+}
+
 func (pb *ProviderBuilder) Build() models.ProviderConfig {
 	return models.ProviderConfig{
 		APIKey:         pb.apiKey,
@@ -65,6 +81,8 @@ func (pb *ProviderBuilder) Build() models.ProviderConfig {
 		RateLimitRpm:   pb.rateLimitRpm,
 		TimeoutMs:      pb.timeoutMs,
 		Headers:        pb.headers,
+		Models:         pb.models,
+		Protocol:       pb.protocol,
 	}
 }
 
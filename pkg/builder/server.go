@@ -19,3 +19,8 @@ func (b *Builder) LogLevel(level string) *Builder {
 	b.cfg.Server.LogLevel = level
 	return b
 }
+
+func (b *Builder) AdminToken(token string) *Builder {
+	b.cfg.Server.AdminToken = token
+	return b
+}
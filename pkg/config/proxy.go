@@ -17,12 +17,16 @@ import (
 	"github.com/Egham-7/adaptive-proxy/internal/services/auth"
 	"github.com/Egham-7/adaptive-proxy/internal/services/circuitbreaker"
 	"github.com/Egham-7/adaptive-proxy/internal/services/database"
+	"github.com/Egham-7/adaptive-proxy/internal/services/feedback"
 	"github.com/Egham-7/adaptive-proxy/internal/services/middleware"
 	"github.com/Egham-7/adaptive-proxy/internal/services/model_router"
 	"github.com/Egham-7/adaptive-proxy/internal/services/openai/chat/completions"
 	"github.com/Egham-7/adaptive-proxy/internal/services/organizations"
 	"github.com/Egham-7/adaptive-proxy/internal/services/projects"
+	"github.com/Egham-7/adaptive-proxy/internal/services/provider_stats"
+	"github.com/Egham-7/adaptive-proxy/internal/services/response_cache"
 	"github.com/Egham-7/adaptive-proxy/internal/services/select_model"
+	"github.com/Egham-7/adaptive-proxy/internal/services/shadow"
 	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
 	"github.com/Egham-7/adaptive-proxy/pkg/builder"
 
@@ -344,7 +348,10 @@ func setupMiddleware(app *fiber.App, cfg *config.Config, p *Proxy) {
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 		MaxAge:           86400,
-		ExposeHeaders:    "Content-Length, Content-Type, X-Request-ID",
+		ExposeHeaders: "Content-Length, Content-Type, X-Request-ID, " +
+			"X-Adaptive-Provider, X-Adaptive-Model, X-Adaptive-Candidates, X-Adaptive-Filtered, " +
+			"X-Adaptive-Routing-Rule, X-Adaptive-Model-Alias, X-Adaptive-Experiment, X-Adaptive-Cache-Tier, " +
+			"X-Adaptive-Cache-Similarity, X-Adaptive-Router-Latency-Ms, X-Adaptive-Fallbacks",
 	}))
 
 	// Custom middlewares from builder
@@ -462,12 +469,35 @@ func testRedisConnectionWithRetry(client *redis.Client) (*redis.Client, error) {
 	return nil, fmt.Errorf("failed to connect to Redis after %d attempts", maxAttempts)
 }
 
+// cacheAdminPath is where the router and response caches are administered
+const cacheAdminPath = "/admin/cache"
+
 func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client, db *database.DB, enabledEndpoints map[string]bool, usageWorker *usage.Worker) error {
 	// Create shared services
 	reqSvc := completions.NewRequestService()
 
+	// Rolling per provider/model latency and error stats, shared through Redis when configured
+	statsTracker := provider_stats.NewTracker(redisClient)
+
+	// Shadow comparisons are stored when a database is configured, otherwise only logged
+	var shadowSvc *shadow.Service
+	if db != nil {
+		shadowSvc = shadow.NewService(db.DB)
+	} else {
+		shadowSvc = shadow.NewService(nil)
+	}
+
+	// Catalog prices are used for billing as well as routing
+	if err := cfg.NormalizeModelCatalog(); err != nil {
+		return fmt.Errorf("invalid model catalog: %w", err)
+	}
+	if err := cfg.NormalizeProviderProtocols(); err != nil {
+		return fmt.Errorf("invalid provider protocol: %w", err)
+	}
+	usage.RegisterCatalogPricing(cfg.ModelCatalog())
+
 	// Create model router
-	modelRouter, err := model_router.NewModelRouter(cfg, redisClient)
+	modelRouter, err := model_router.NewModelRouter(cfg, redisClient, statsTracker)
 	if err != nil {
 		return fmt.Errorf("model router initialization failed: %w", err)
 	}
@@ -493,6 +523,7 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 	var creditsSvc *usage.CreditsService
 	var stripeSvc *usage.StripeService
 	var authMiddleware *middleware.AuthMiddleware
+	var authProvider auth.AuthProvider
 	if db != nil && cfg.APIKey != nil && cfg.APIKey.Enabled {
 		apiKeySvc := usage.NewAPIKeyService(db.DB)
 
@@ -511,7 +542,6 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 
 		usageSvc = usage.NewService(db.DB, creditsSvc)
 
-		var authProvider auth.AuthProvider
 		var projectsSvc *projects.Service
 
 		if cfg.Auth != nil {
@@ -523,7 +553,7 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 					AllowAnonymous: false,
 					ClerkSecretKey: cfg.Auth.ClerkConfig.SecretKey,
 					HeaderNames:    []string{"Authorization"},
-					SkipPaths:      []string{"/health", "/webhooks"},
+					SkipPaths:      []string{"/health", "/webhooks", cacheAdminPath},
 					EnableAPIKeys:  true,
 				})
 
@@ -551,7 +581,7 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 					Enabled:        true,
 					AllowAnonymous: false,
 					HeaderNames:    []string{"Authorization"},
-					SkipPaths:      []string{"/health", "/webhooks"},
+					SkipPaths:      []string{"/health", "/webhooks", cacheAdminPath},
 					EnableAPIKeys:  true,
 				})
 
@@ -620,7 +650,27 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 		}
 	}
 
-	completionSvc := completions.NewCompletionService(cfg, respSvc, circuitBreakers, usageSvc, usageWorker)
+	completionSvc := completions.NewCompletionService(cfg, respSvc, circuitBreakers, usageSvc, usageWorker, statsTracker, shadowSvc)
+
+	// Full responses of deterministic requests are cached when response_cache is enabled
+	responseCache, err := response_cache.NewService(cfg, usageSvc)
+	if err != nil {
+		return fmt.Errorf("response cache initialization failed: %w", err)
+	}
+
+	// Cache administration; requires an admin-scoped API key or server.admin_token. RequireAuth
+	// skips these routes, since it does not know the admin token, and API keys are checked here.
+	var identify func(*fiber.Ctx) bool
+	if authMiddleware != nil {
+		identify = authMiddleware.Identify
+	}
+	cacheAdminHandler := api.NewCacheAdminHandler(modelRouter.Cache(), responseCache)
+	cacheGroup := app.Group(cacheAdminPath, middleware.RequireAdmin(cfg.Server.AdminToken, identify))
+	cacheGroup.Get("/stats", cacheAdminHandler.Stats)
+	cacheGroup.Post("/search", cacheAdminHandler.Search)
+	cacheGroup.Post("/lookup", cacheAdminHandler.Lookup)
+	cacheGroup.Delete("/entries", cacheAdminHandler.DeleteEntry)
+	cacheGroup.Post("/flush", cacheAdminHandler.Flush)
 
 	// Create select model services
 	selectModelReqSvc := select_model.NewRequestService()
@@ -633,6 +683,7 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 	var messagesHandler *api.MessagesHandler
 	var generateHandler *geminiapi.GenerateHandler
 	var countTokensHandler *geminiapi.CountTokensHandler
+	var feedbackHandler *api.FeedbackHandler
 
 	// Helper function to check if endpoint is enabled (if map is empty, enable all)
 	isEnabled := func(endpoint string) bool {
@@ -643,7 +694,7 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 	}
 
 	if isEnabled("chat_completions") {
-		chatCompletionHandler = api.NewCompletionHandler(cfg, reqSvc, respSvc, completionSvc, modelRouter, circuitBreakers)
+		chatCompletionHandler = api.NewCompletionHandler(cfg, reqSvc, respSvc, completionSvc, modelRouter, circuitBreakers, responseCache)
 	}
 
 	if isEnabled("select_model") {
@@ -651,18 +702,25 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 	}
 
 	if isEnabled("messages") {
-		messagesHandler = api.NewMessagesHandler(cfg, modelRouter, circuitBreakers, usageSvc, usageWorker)
+		messagesHandler = api.NewMessagesHandler(cfg, modelRouter, circuitBreakers, usageSvc, usageWorker, statsTracker, shadowSvc, responseCache)
 	}
 
 	if isEnabled("generate") {
-		generateHandler = geminiapi.NewGenerateHandler(cfg, modelRouter, circuitBreakers, usageSvc, usageWorker)
+		generateHandler = geminiapi.NewGenerateHandler(cfg, modelRouter, circuitBreakers, usageSvc, usageWorker, statsTracker, shadowSvc, responseCache)
 	}
 
 	if isEnabled("count_tokens") {
 		countTokensHandler = geminiapi.NewCountTokensHandler(cfg, modelRouter, circuitBreakers)
 	}
 
+	// Feedback is stored next to usage rows, so it needs usage tracking
+	if usageSvc != nil {
+		feedbackSvc := feedback.NewService(db.DB, usageSvc, authProvider, modelRouter, cfg.Feedback)
+		feedbackHandler = api.NewFeedbackHandler(feedbackSvc)
+	}
+
 	healthHandler := api.NewHealthHandler(cfg, redisClient, db)
+	modelsHandler := api.NewModelsHandler(cfg)
 
 	// Health check endpoint (always enabled)
 	app.Get("/health", healthHandler.HealthCheck)
@@ -673,6 +731,10 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 		v1Group.Use(authMiddleware.RequireAuth())
 	}
 
+	// Model catalog (always enabled)
+	v1Group.Get("/models", modelsHandler.List)
+	v1Group.Get("/models/*", modelsHandler.Get)
+
 	if chatCompletionHandler != nil {
 		v1Group.Post("/chat/completions", chatCompletionHandler.ChatCompletion)
 	}
@@ -685,6 +747,10 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 		v1Group.Post("/select-model", selectModelHandler.SelectModel)
 	}
 
+	if feedbackHandler != nil {
+		v1Group.Post("/feedback", feedbackHandler.Feedback)
+	}
+
 	if generateHandler != nil {
 		v1Group.Post("/generate", generateHandler.Generate)
 		v1Group.Post("/generate/stream", generateHandler.StreamGenerate)
@@ -695,6 +761,7 @@ func setupRoutes(app *fiber.App, cfg *config.Config, redisClient *redis.Client,
 		if authMiddleware != nil {
 			v1betaGroup.Use(authMiddleware.RequireAuth())
 		}
+		v1betaGroup.Get("/models", geminiapi.NewModelsHandler(cfg).List)
 		v1betaGroup.Post(`/models/:model\:generateContent`, generateHandler.Generate)
 		v1betaGroup.Post(`/models/:model\:streamGenerateContent`, generateHandler.StreamGenerate)
 	}
@@ -722,6 +789,8 @@ func welcomeHandler() fiber.Handler {
 				"chat":         "/v1/chat/completions",
 				"messages":     "/v1/messages",
 				"select_model": "/v1/select-model",
+				"feedback":     "/v1/feedback",
+				"models":       "/v1/models",
 				"generate":     "/v1/generate",
 				"health":       "/health",
 			},
@@ -745,6 +814,16 @@ func runDatabaseMigrations(db *database.DB) error {
 		return fmt.Errorf("failed to migrate usage table: %w", err)
 	}
 
+	shadowSvc := shadow.NewService(db.DB)
+	if err := shadowSvc.AutoMigrate(); err != nil {
+		return fmt.Errorf("failed to migrate shadow comparisons table: %w", err)
+	}
+
+	feedbackSvc := feedback.NewService(db.DB, usageSvc, nil, nil, nil)
+	if err := feedbackSvc.AutoMigrate(); err != nil {
+		return fmt.Errorf("failed to migrate routing feedback table: %w", err)
+	}
+
 	return nil
 }
 
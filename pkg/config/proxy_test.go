@@ -0,0 +1,92 @@
+package config
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Egham-7/adaptive-proxy/internal/config"
+	"github.com/Egham-7/adaptive-proxy/internal/models"
+	"github.com/Egham-7/adaptive-proxy/internal/services/database"
+	"github.com/Egham-7/adaptive-proxy/internal/services/usage"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCacheAdminAuth(t *testing.T) {
+	gormDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := gormDB.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	apiKeys := usage.NewAPIKeyService(gormDB)
+	if err := apiKeys.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	createKey := func(scopes ...string) string {
+		t.Helper()
+		key, err := apiKeys.CreateAPIKey(context.Background(), &models.APIKeyCreateRequest{Name: "test", Scopes: scopes})
+		if err != nil {
+			t.Fatalf("CreateAPIKey() = %v", err)
+		}
+		return key.Key
+	}
+	adminKey, memberKey := createKey("admin"), createKey("read")
+
+	cfg := &config.Config{
+		Server: models.ServerConfig{AdminToken: "admin-secret"},
+		APIKey: &models.APIKeyConfig{Enabled: true},
+		Auth:   &models.AuthConfig{DatabaseConfig: &models.DatabaseAuthConfig{}},
+		ModelRouter: &models.ModelRouterConfig{
+			Client: models.ModelRouterClientConfig{Mode: models.ModelRouterModeHeuristic},
+		},
+	}
+	app := fiber.New()
+	if err := setupRoutes(app, cfg, nil, &database.DB{DB: gormDB}, nil, nil); err != nil {
+		t.Fatalf("setupRoutes() = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "admin token header", headers: map[string]string{"X-Admin-Token": "admin-secret"}, want: fiber.StatusOK},
+		{name: "admin token as bearer", headers: map[string]string{"Authorization": "Bearer admin-secret"}, want: fiber.StatusOK},
+		{name: "admin-scoped API key", headers: map[string]string{"Authorization": "Bearer " + adminKey}, want: fiber.StatusOK},
+		{name: "API key without admin scope", headers: map[string]string{"Authorization": "Bearer " + memberKey}, want: fiber.StatusForbidden},
+		{name: "wrong admin token", headers: map[string]string{"X-Admin-Token": "guess"}, want: fiber.StatusUnauthorized},
+		{name: "no credentials", want: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, "/admin/cache/stats", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() = %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if resp.StatusCode != tt.want {
+				t.Errorf("GET /admin/cache/stats = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// Other admin routes still require a valid API key or session
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/api-keys", nil))
+	if err != nil {
+		t.Fatalf("app.Test() = %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("GET /admin/api-keys without credentials = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}